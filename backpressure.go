@@ -0,0 +1,67 @@
+package sealing
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const defaultSealerBusyRecheck = 10 * time.Second
+
+// SealerCapacity reports whether the sealer can take another piece right
+// away, e.g. because it has a free worker and enough disk space. It can be
+// set in Config, or implemented by the SectorManager
+type SealerCapacity interface {
+	CanAcceptPiece(ctx context.Context) (bool, error)
+}
+
+// ErrSealerBusy is returned by AddPieceToAnySector and AddPieceToSector when
+// the sealer can't take more pieces, and Config.RejectWhenSealerBusy is set
+type ErrSealerBusy struct{ error }
+
+func (m *Sealing) sealerCapacity() SealerCapacity {
+	if m.cfg.SealerCapacity != nil {
+		return m.cfg.SealerCapacity
+	}
+
+	c, _ := m.sealer.(SealerCapacity)
+	return c
+}
+
+// waitSealerCapacity waits until the sealer can take a piece, or fails with
+// *ErrSealerBusy if RejectWhenSealerBusy is set. When the capacity check
+// fails, the piece is handed to the sealer anyway
+func (m *Sealing) waitSealerCapacity(ctx context.Context) error {
+	c := m.sealerCapacity()
+	if c == nil {
+		return nil
+	}
+
+	recheck := m.cfg.SealerBusyRecheck
+	if recheck == 0 {
+		recheck = defaultSealerBusyRecheck
+	}
+
+	for {
+		ok, err := c.CanAcceptPiece(ctx)
+		if err != nil {
+			log.Warnf("checking sealer capacity, not waiting: %+v", err)
+			return nil
+		}
+		if ok {
+			return nil
+		}
+
+		if m.cfg.RejectWhenSealerBusy {
+			return &ErrSealerBusy{xerrors.New("the sealer can't take more pieces right now")}
+		}
+
+		log.Infof("sealer can't take more pieces, checking again in %s", recheck)
+		select {
+		case <-m.clock.After(recheck):
+		case <-ctx.Done():
+			return xerrors.Errorf("waiting for sealer capacity: %w", ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+type fakeCapacity struct {
+	lk     sync.Mutex
+	busy   bool
+	checks int
+}
+
+func (c *fakeCapacity) CanAcceptPiece(ctx context.Context) (bool, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.checks++
+	return !c.busy, nil
+}
+
+func (c *fakeCapacity) setBusy(busy bool) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.busy = busy
+}
+
+func TestSealerBusyReject(t *testing.T) {
+	capacity := &fakeCapacity{busy: true}
+	h := newTestHarness(t, Config{SealerCapacity: capacity, RejectWhenSealerBusy: true})
+
+	h.setZeroDeal(1, 512)
+	_, _, err := h.m.AddPieceToAnySector(context.Background(), 508, bytes.NewReader(make([]byte, 508)), DealInfo{DealID: 1})
+	require.True(t, xerrors.As(err, new(*ErrSealerBusy)), "%+v", err)
+
+	// no sector was created for the piece
+	sectors, err := h.m.ListSectors()
+	require.NoError(t, err)
+	require.Empty(t, sectors)
+
+	capacity.setBusy(false)
+	sid, _ := h.addDeal(1, 512)
+	h.waitPieces(sid, 1)
+}
+
+func TestSealerBusyWait(t *testing.T) {
+	clk := newFakeClock()
+	capacity := &fakeCapacity{busy: true}
+	h := newTestHarness(t, Config{Clock: clk, SealerCapacity: capacity})
+
+	h.setZeroDeal(1, 512)
+	done := make(chan abi.SectorNumber)
+	go func() {
+		sid, _, err := h.m.AddPieceToAnySector(context.Background(), 508, bytes.NewReader(make([]byte, 508)), DealInfo{DealID: 1})
+		require.NoError(t, err)
+		done <- sid
+	}()
+
+	clk.waitTimers(t, 1)
+	clk.advance(defaultSealerBusyRecheck)
+	clk.waitTimers(t, 1)
+
+	select {
+	case <-done:
+		t.Fatal("piece added while the sealer was busy")
+	default:
+	}
+
+	capacity.setBusy(false)
+	clk.advance(defaultSealerBusyRecheck)
+	h.waitPieces(<-done, 1)
+
+	capacity.lk.Lock()
+	require.Equal(t, 3, capacity.checks)
+	capacity.lk.Unlock()
+}
+
+func TestSealerBusyCancel(t *testing.T) {
+	h := newTestHarness(t, Config{SealerCapacity: &fakeCapacity{busy: true}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := h.m.AddPieceToSector(ctx, 1, 508, bytes.NewReader(make([]byte, 508)), DealInfo{DealID: 1})
+	require.True(t, xerrors.Is(err, context.Canceled), "%+v", err)
+}
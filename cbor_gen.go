@@ -7,6 +7,8 @@ import (
 	"io"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	cbg "github.com/whyrusleeping/cbor-gen"
 	xerrors "golang.org/x/xerrors"
 )
@@ -131,10 +133,32 @@ func (t *DealInfo) MarshalCBOR(w io.Writer) error {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{162}); err != nil {
+	if _, err := w.Write([]byte{164}); err != nil {
+		return err
+	}
+
+	// t.PublishCid (cid.Cid) (struct)
+	if len("PublishCid") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"PublishCid\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("PublishCid")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("PublishCid")); err != nil {
 		return err
 	}
 
+	if t.PublishCid == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteCid(w, *t.PublishCid); err != nil {
+			return xerrors.Errorf("failed to write cid field t.PublishCid: %w", err)
+		}
+	}
+
 	// t.DealID (abi.DealID) (uint64)
 	if len("DealID") > cbg.MaxLength {
 		return xerrors.Errorf("Value in field \"DealID\" was too long")
@@ -166,6 +190,22 @@ func (t *DealInfo) MarshalCBOR(w io.Writer) error {
 	if err := t.DealSchedule.MarshalCBOR(w); err != nil {
 		return err
 	}
+	// t.VerifiedDeal (bool) (bool)
+	if len("VerifiedDeal") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"VerifiedDeal\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("VerifiedDeal")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("VerifiedDeal")); err != nil {
+		return err
+	}
+
+	if err := cbg.WriteBool(w, t.VerifiedDeal); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -199,7 +239,32 @@ func (t *DealInfo) UnmarshalCBOR(r io.Reader) error {
 		}
 
 		switch name {
-		// t.DealID (abi.DealID) (uint64)
+		// t.PublishCid (cid.Cid) (struct)
+		case "PublishCid":
+
+			{
+
+				pb, err := br.PeekByte()
+				if err != nil {
+					return err
+				}
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
+					}
+				} else {
+
+					c, err := cbg.ReadCid(br)
+					if err != nil {
+						return xerrors.Errorf("failed to read cid field t.PublishCid: %w", err)
+					}
+
+					t.PublishCid = &c
+				}
+
+			}
+			// t.DealID (abi.DealID) (uint64)
 		case "DealID":
 
 			{
@@ -225,6 +290,24 @@ func (t *DealInfo) UnmarshalCBOR(r io.Reader) error {
 
 			}
 
+			// t.VerifiedDeal (bool) (bool)
+		case "VerifiedDeal":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+			if maj != cbg.MajOther {
+				return fmt.Errorf("booleans must be major type 7")
+			}
+			switch extra {
+			case 20:
+				t.VerifiedDeal = false
+			case 21:
+				t.VerifiedDeal = true
+			default:
+				return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+			}
 		default:
 			return fmt.Errorf("unknown struct field %d: '%s'", i, name)
 		}
@@ -382,7 +465,7 @@ func (t *SectorInfo) MarshalCBOR(w io.Writer) error {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{180}); err != nil {
+	if _, err := w.Write([]byte{184, 46}); err != nil {
 		return err
 	}
 
@@ -447,6 +530,29 @@ func (t *SectorInfo) MarshalCBOR(w io.Writer) error {
 		}
 	}
 
+	// t.SealMode (sealing.SealMode) (string)
+	if len("SealMode") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"SealMode\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("SealMode")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("SealMode")); err != nil {
+		return err
+	}
+
+	if len(t.SealMode) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.SealMode was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.SealMode)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.SealMode)); err != nil {
+		return err
+	}
+
 	// t.Pieces ([]sealing.Piece) (slice)
 	if len("Pieces") > cbg.MaxLength {
 		return xerrors.Errorf("Value in field \"Pieces\" was too long")
@@ -472,6 +578,70 @@ func (t *SectorInfo) MarshalCBOR(w io.Writer) error {
 		}
 	}
 
+	// t.DealWeight (big.Int) (struct)
+	if len("DealWeight") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"DealWeight\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("DealWeight")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("DealWeight")); err != nil {
+		return err
+	}
+
+	if err := t.DealWeight.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.VerifiedDealWeight (big.Int) (struct)
+	if len("VerifiedDealWeight") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"VerifiedDealWeight\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("VerifiedDealWeight")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("VerifiedDealWeight")); err != nil {
+		return err
+	}
+
+	if err := t.VerifiedDealWeight.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.WritingDeal (sealing.DealInfo) (struct)
+	if len("WritingDeal") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"WritingDeal\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("WritingDeal")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("WritingDeal")); err != nil {
+		return err
+	}
+
+	if err := t.WritingDeal.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.WritingSize (abi.UnpaddedPieceSize) (uint64)
+	if len("WritingSize") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"WritingSize\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("WritingSize")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("WritingSize")); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.WritingSize))); err != nil {
+		return err
+	}
+
 	// t.TicketValue (abi.SealRandomness) (slice)
 	if len("TicketValue") > cbg.MaxLength {
 		return xerrors.Errorf("Value in field \"TicketValue\" was too long")
@@ -540,6 +710,51 @@ func (t *SectorInfo) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.ForcedTicket (abi.SealRandomness) (slice)
+	if len("ForcedTicket") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"ForcedTicket\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("ForcedTicket")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("ForcedTicket")); err != nil {
+		return err
+	}
+
+	if len(t.ForcedTicket) > cbg.ByteArrayMaxLen {
+		return xerrors.Errorf("Byte array in field t.ForcedTicket was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajByteString, uint64(len(t.ForcedTicket)))); err != nil {
+		return err
+	}
+	if _, err := w.Write(t.ForcedTicket); err != nil {
+		return err
+	}
+
+	// t.ForcedTicketEpoch (abi.ChainEpoch) (int64)
+	if len("ForcedTicketEpoch") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"ForcedTicketEpoch\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("ForcedTicketEpoch")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("ForcedTicketEpoch")); err != nil {
+		return err
+	}
+
+	if t.ForcedTicketEpoch >= 0 {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.ForcedTicketEpoch))); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.ForcedTicketEpoch)-1)); err != nil {
+			return err
+		}
+	}
+
 	// t.CommD (cid.Cid) (struct)
 	if len("CommD") > cbg.MaxLength {
 		return xerrors.Errorf("Value in field \"CommD\" was too long")
@@ -652,6 +867,22 @@ func (t *SectorInfo) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.PreCommitInfo (miner.SectorPreCommitInfo) (struct)
+	if len("PreCommitInfo") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"PreCommitInfo\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("PreCommitInfo")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("PreCommitInfo")); err != nil {
+		return err
+	}
+
+	if err := t.PreCommitInfo.MarshalCBOR(w); err != nil {
+		return err
+	}
+
 	// t.PreCommit2Fails (uint64) (uint64)
 	if len("PreCommit2Fails") > cbg.MaxLength {
 		return xerrors.Errorf("Value in field \"PreCommit2Fails\" was too long")
@@ -668,6 +899,22 @@ func (t *SectorInfo) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.PreCommitDeposit (big.Int) (struct)
+	if len("PreCommitDeposit") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"PreCommitDeposit\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("PreCommitDeposit")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("PreCommitDeposit")); err != nil {
+		return err
+	}
+
+	if err := t.PreCommitDeposit.MarshalCBOR(w); err != nil {
+		return err
+	}
+
 	// t.SeedValue (abi.InteractiveSealRandomness) (slice)
 	if len("SeedValue") > cbg.MaxLength {
 		return xerrors.Errorf("Value in field \"SeedValue\" was too long")
@@ -751,121 +998,472 @@ func (t *SectorInfo) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
-	// t.FaultReportMsg (cid.Cid) (struct)
-	if len("FaultReportMsg") > cbg.MaxLength {
-		return xerrors.Errorf("Value in field \"FaultReportMsg\" was too long")
+	// t.ProofSeedEpoch (abi.ChainEpoch) (int64)
+	if len("ProofSeedEpoch") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"ProofSeedEpoch\" was too long")
 	}
 
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("FaultReportMsg")))); err != nil {
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("ProofSeedEpoch")))); err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte("FaultReportMsg")); err != nil {
+	if _, err := w.Write([]byte("ProofSeedEpoch")); err != nil {
 		return err
 	}
 
-	if t.FaultReportMsg == nil {
-		if _, err := w.Write(cbg.CborNull); err != nil {
+	if t.ProofSeedEpoch >= 0 {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.ProofSeedEpoch))); err != nil {
 			return err
 		}
 	} else {
-		if err := cbg.WriteCid(w, *t.FaultReportMsg); err != nil {
-			return xerrors.Errorf("failed to write cid field t.FaultReportMsg: %w", err)
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.ProofSeedEpoch)-1)); err != nil {
+			return err
 		}
 	}
 
-	// t.LastErr (string) (string)
-	if len("LastErr") > cbg.MaxLength {
-		return xerrors.Errorf("Value in field \"LastErr\" was too long")
+	// t.ProofCommR (cid.Cid) (struct)
+	if len("ProofCommR") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"ProofCommR\" was too long")
 	}
 
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("LastErr")))); err != nil {
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("ProofCommR")))); err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte("LastErr")); err != nil {
+	if _, err := w.Write([]byte("ProofCommR")); err != nil {
 		return err
 	}
 
-	if len(t.LastErr) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.LastErr was too long")
+	if t.ProofCommR == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteCid(w, *t.ProofCommR); err != nil {
+			return xerrors.Errorf("failed to write cid field t.ProofCommR: %w", err)
+		}
 	}
 
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.LastErr)))); err != nil {
+	// t.InactiveDeals ([]sealing.DealInfo) (slice)
+	if len("InactiveDeals") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"InactiveDeals\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("InactiveDeals")))); err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte(t.LastErr)); err != nil {
+	if _, err := w.Write([]byte("InactiveDeals")); err != nil {
 		return err
 	}
 
-	// t.Log ([]sealing.Log) (slice)
-	if len("Log") > cbg.MaxLength {
-		return xerrors.Errorf("Value in field \"Log\" was too long")
+	if len(t.InactiveDeals) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.InactiveDeals was too long")
 	}
 
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Log")))); err != nil {
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.InactiveDeals)))); err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte("Log")); err != nil {
+	for _, v := range t.InactiveDeals {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+
+	// t.StuckWait (sealing.SectorState) (string)
+	if len("StuckWait") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"StuckWait\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("StuckWait")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("StuckWait")); err != nil {
 		return err
 	}
 
-	if len(t.Log) > cbg.MaxLength {
-		return xerrors.Errorf("Slice value in field t.Log was too long")
+	if len(t.StuckWait) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.StuckWait was too long")
 	}
 
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.Log)))); err != nil {
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.StuckWait)))); err != nil {
 		return err
 	}
-	for _, v := range t.Log {
-		if err := v.MarshalCBOR(w); err != nil {
-			return err
-		}
+	if _, err := w.Write([]byte(t.StuckWait)); err != nil {
+		return err
 	}
-	return nil
-}
 
-func (t *SectorInfo) UnmarshalCBOR(r io.Reader) error {
-	br := cbg.GetPeeker(r)
+	// t.FailedWait (sealing.SectorState) (string)
+	if len("FailedWait") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"FailedWait\" was too long")
+	}
 
-	maj, extra, err := cbg.CborReadHeader(br)
-	if err != nil {
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("FailedWait")))); err != nil {
 		return err
 	}
-	if maj != cbg.MajMap {
-		return fmt.Errorf("cbor input should be of type map")
+	if _, err := w.Write([]byte("FailedWait")); err != nil {
+		return err
 	}
 
-	if extra > cbg.MaxLength {
-		return fmt.Errorf("SectorInfo: map struct too large (%d)", extra)
+	if len(t.FailedWait) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.FailedWait was too long")
 	}
 
-	var name string
-	n := extra
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.FailedWait)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.FailedWait)); err != nil {
+		return err
+	}
 
-	for i := uint64(0); i < n; i++ {
+	// t.MessageExitCode (exitcode.ExitCode) (int64)
+	if len("MessageExitCode") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"MessageExitCode\" was too long")
+	}
 
-		{
-			sval, err := cbg.ReadString(br)
-			if err != nil {
-				return err
-			}
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("MessageExitCode")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("MessageExitCode")); err != nil {
+		return err
+	}
 
-			name = string(sval)
+	if t.MessageExitCode >= 0 {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MessageExitCode))); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.MessageExitCode)-1)); err != nil {
+			return err
 		}
+	}
 
-		switch name {
-		// t.State (sealing.SectorState) (string)
-		case "State":
+	// t.MessageGasUsed (int64) (int64)
+	if len("MessageGasUsed") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"MessageGasUsed\" was too long")
+	}
 
-			{
-				sval, err := cbg.ReadString(br)
-				if err != nil {
-					return err
-				}
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("MessageGasUsed")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("MessageGasUsed")); err != nil {
+		return err
+	}
 
-				t.State = SectorState(sval)
-			}
-			// t.SectorNumber (abi.SectorNumber) (uint64)
-		case "SectorNumber":
+	if t.MessageGasUsed >= 0 {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MessageGasUsed))); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.MessageGasUsed)-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.DiskWaitPhase (sealing.SectorState) (string)
+	if len("DiskWaitPhase") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"DiskWaitPhase\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("DiskWaitPhase")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("DiskWaitPhase")); err != nil {
+		return err
+	}
+
+	if len(t.DiskWaitPhase) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.DiskWaitPhase was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.DiskWaitPhase)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.DiskWaitPhase)); err != nil {
+		return err
+	}
+
+	// t.DeadlineAssigned (bool) (bool)
+	if len("DeadlineAssigned") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"DeadlineAssigned\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("DeadlineAssigned")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("DeadlineAssigned")); err != nil {
+		return err
+	}
+
+	if err := cbg.WriteBool(w, t.DeadlineAssigned); err != nil {
+		return err
+	}
+
+	// t.Deadline (uint64) (uint64)
+	if len("Deadline") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Deadline\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Deadline")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Deadline")); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Deadline))); err != nil {
+		return err
+	}
+
+	// t.Partition (uint64) (uint64)
+	if len("Partition") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Partition\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Partition")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Partition")); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Partition))); err != nil {
+		return err
+	}
+
+	// t.CommitNotified (bool) (bool)
+	if len("CommitNotified") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"CommitNotified\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("CommitNotified")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("CommitNotified")); err != nil {
+		return err
+	}
+
+	if err := cbg.WriteBool(w, t.CommitNotified); err != nil {
+		return err
+	}
+
+	// t.FaultReportMsg (cid.Cid) (struct)
+	if len("FaultReportMsg") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"FaultReportMsg\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("FaultReportMsg")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("FaultReportMsg")); err != nil {
+		return err
+	}
+
+	if t.FaultReportMsg == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteCid(w, *t.FaultReportMsg); err != nil {
+			return xerrors.Errorf("failed to write cid field t.FaultReportMsg: %w", err)
+		}
+	}
+
+	// t.RecoveryMessage (cid.Cid) (struct)
+	if len("RecoveryMessage") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"RecoveryMessage\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("RecoveryMessage")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("RecoveryMessage")); err != nil {
+		return err
+	}
+
+	if t.RecoveryMessage == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteCid(w, *t.RecoveryMessage); err != nil {
+			return xerrors.Errorf("failed to write cid field t.RecoveryMessage: %w", err)
+		}
+	}
+
+	// t.TerminateMessage (cid.Cid) (struct)
+	if len("TerminateMessage") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"TerminateMessage\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("TerminateMessage")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("TerminateMessage")); err != nil {
+		return err
+	}
+
+	if t.TerminateMessage == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteCid(w, *t.TerminateMessage); err != nil {
+			return xerrors.Errorf("failed to write cid field t.TerminateMessage: %w", err)
+		}
+	}
+
+	// t.Worker (string) (string)
+	if len("Worker") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Worker\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Worker")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Worker")); err != nil {
+		return err
+	}
+
+	if len(t.Worker) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Worker was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Worker)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Worker)); err != nil {
+		return err
+	}
+
+	// t.Priority (int64) (int64)
+	if len("Priority") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Priority\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Priority")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Priority")); err != nil {
+		return err
+	}
+
+	if t.Priority >= 0 {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Priority))); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.Priority)-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.Paused (bool) (bool)
+	if len("Paused") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Paused\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Paused")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Paused")); err != nil {
+		return err
+	}
+
+	if err := cbg.WriteBool(w, t.Paused); err != nil {
+		return err
+	}
+
+	// t.LastErr (string) (string)
+	if len("LastErr") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"LastErr\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("LastErr")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("LastErr")); err != nil {
+		return err
+	}
+
+	if len(t.LastErr) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.LastErr was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.LastErr)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.LastErr)); err != nil {
+		return err
+	}
+
+	// t.Log ([]sealing.Log) (slice)
+	if len("Log") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Log\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Log")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Log")); err != nil {
+		return err
+	}
+
+	if len(t.Log) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Log was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.Log)))); err != nil {
+		return err
+	}
+	for _, v := range t.Log {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *SectorInfo) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("SectorInfo: map struct too large (%d)", extra)
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+
+		{
+			sval, err := cbg.ReadString(br)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.State (sealing.SectorState) (string)
+		case "State":
+
+			{
+				sval, err := cbg.ReadString(br)
+				if err != nil {
+					return err
+				}
+
+				t.State = SectorState(sval)
+			}
+			// t.SectorNumber (abi.SectorNumber) (uint64)
+		case "SectorNumber":
 
 			{
 
@@ -903,10 +1501,507 @@ func (t *SectorInfo) UnmarshalCBOR(r io.Reader) error {
 					return fmt.Errorf("wrong type for int64 field: %d", maj)
 				}
 
-				t.SectorType = abi.RegisteredSealProof(extraI)
+				t.SectorType = abi.RegisteredSealProof(extraI)
+			}
+			// t.SealMode (sealing.SealMode) (string)
+		case "SealMode":
+
+			{
+				sval, err := cbg.ReadString(br)
+				if err != nil {
+					return err
+				}
+
+				t.SealMode = SealMode(sval)
+			}
+			// t.Pieces ([]sealing.Piece) (slice)
+		case "Pieces":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.MaxLength {
+				return fmt.Errorf("t.Pieces: array too large (%d)", extra)
+			}
+
+			if maj != cbg.MajArray {
+				return fmt.Errorf("expected cbor array")
+			}
+
+			if extra > 0 {
+				t.Pieces = make([]Piece, extra)
+			}
+
+			for i := 0; i < int(extra); i++ {
+
+				var v Piece
+				if err := v.UnmarshalCBOR(br); err != nil {
+					return err
+				}
+
+				t.Pieces[i] = v
+			}
+
+			// t.DealWeight (big.Int) (struct)
+		case "DealWeight":
+
+			{
+
+				if err := t.DealWeight.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.DealWeight: %w", err)
+				}
+
+			}
+			// t.VerifiedDealWeight (big.Int) (struct)
+		case "VerifiedDealWeight":
+
+			{
+
+				if err := t.VerifiedDealWeight.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.VerifiedDealWeight: %w", err)
+				}
+
+			}
+			// t.WritingDeal (sealing.DealInfo) (struct)
+		case "WritingDeal":
+
+			{
+
+				pb, err := br.PeekByte()
+				if err != nil {
+					return err
+				}
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
+					}
+				} else {
+					t.WritingDeal = new(DealInfo)
+					if err := t.WritingDeal.UnmarshalCBOR(br); err != nil {
+						return xerrors.Errorf("unmarshaling t.WritingDeal pointer: %w", err)
+					}
+				}
+
+			}
+			// t.WritingSize (abi.UnpaddedPieceSize) (uint64)
+		case "WritingSize":
+
+			{
+
+				maj, extra, err = cbg.CborReadHeader(br)
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.WritingSize = abi.UnpaddedPieceSize(extra)
+
+			}
+			// t.TicketValue (abi.SealRandomness) (slice)
+		case "TicketValue":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.ByteArrayMaxLen {
+				return fmt.Errorf("t.TicketValue: byte array too large (%d)", extra)
+			}
+			if maj != cbg.MajByteString {
+				return fmt.Errorf("expected byte array")
+			}
+			t.TicketValue = make([]byte, extra)
+			if _, err := io.ReadFull(br, t.TicketValue); err != nil {
+				return err
+			}
+			// t.TicketEpoch (abi.ChainEpoch) (int64)
+		case "TicketEpoch":
+			{
+				maj, extra, err := cbg.CborReadHeader(br)
+				var extraI int64
+				if err != nil {
+					return err
+				}
+				switch maj {
+				case cbg.MajUnsignedInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 positive overflow")
+					}
+				case cbg.MajNegativeInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 negative oveflow")
+					}
+					extraI = -1 - extraI
+				default:
+					return fmt.Errorf("wrong type for int64 field: %d", maj)
+				}
+
+				t.TicketEpoch = abi.ChainEpoch(extraI)
+			}
+			// t.PreCommit1Out (storage.PreCommit1Out) (slice)
+		case "PreCommit1Out":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.ByteArrayMaxLen {
+				return fmt.Errorf("t.PreCommit1Out: byte array too large (%d)", extra)
+			}
+			if maj != cbg.MajByteString {
+				return fmt.Errorf("expected byte array")
+			}
+			t.PreCommit1Out = make([]byte, extra)
+			if _, err := io.ReadFull(br, t.PreCommit1Out); err != nil {
+				return err
+			}
+			// t.ForcedTicket (abi.SealRandomness) (slice)
+		case "ForcedTicket":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.ByteArrayMaxLen {
+				return fmt.Errorf("t.ForcedTicket: byte array too large (%d)", extra)
+			}
+			if maj != cbg.MajByteString {
+				return fmt.Errorf("expected byte array")
+			}
+			t.ForcedTicket = make([]byte, extra)
+			if _, err := io.ReadFull(br, t.ForcedTicket); err != nil {
+				return err
+			}
+			// t.ForcedTicketEpoch (abi.ChainEpoch) (int64)
+		case "ForcedTicketEpoch":
+			{
+				maj, extra, err := cbg.CborReadHeader(br)
+				var extraI int64
+				if err != nil {
+					return err
+				}
+				switch maj {
+				case cbg.MajUnsignedInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 positive overflow")
+					}
+				case cbg.MajNegativeInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 negative oveflow")
+					}
+					extraI = -1 - extraI
+				default:
+					return fmt.Errorf("wrong type for int64 field: %d", maj)
+				}
+
+				t.ForcedTicketEpoch = abi.ChainEpoch(extraI)
+			}
+			// t.CommD (cid.Cid) (struct)
+		case "CommD":
+
+			{
+
+				pb, err := br.PeekByte()
+				if err != nil {
+					return err
+				}
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
+					}
+				} else {
+
+					c, err := cbg.ReadCid(br)
+					if err != nil {
+						return xerrors.Errorf("failed to read cid field t.CommD: %w", err)
+					}
+
+					t.CommD = &c
+				}
+
+			}
+			// t.CommR (cid.Cid) (struct)
+		case "CommR":
+
+			{
+
+				pb, err := br.PeekByte()
+				if err != nil {
+					return err
+				}
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
+					}
+				} else {
+
+					c, err := cbg.ReadCid(br)
+					if err != nil {
+						return xerrors.Errorf("failed to read cid field t.CommR: %w", err)
+					}
+
+					t.CommR = &c
+				}
+
+			}
+			// t.Proof ([]uint8) (slice)
+		case "Proof":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.ByteArrayMaxLen {
+				return fmt.Errorf("t.Proof: byte array too large (%d)", extra)
+			}
+			if maj != cbg.MajByteString {
+				return fmt.Errorf("expected byte array")
+			}
+			t.Proof = make([]byte, extra)
+			if _, err := io.ReadFull(br, t.Proof); err != nil {
+				return err
+			}
+			// t.PreCommitMessage (cid.Cid) (struct)
+		case "PreCommitMessage":
+
+			{
+
+				pb, err := br.PeekByte()
+				if err != nil {
+					return err
+				}
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
+					}
+				} else {
+
+					c, err := cbg.ReadCid(br)
+					if err != nil {
+						return xerrors.Errorf("failed to read cid field t.PreCommitMessage: %w", err)
+					}
+
+					t.PreCommitMessage = &c
+				}
+
+			}
+			// t.PreCommitTipSet (sealing.TipSetToken) (slice)
+		case "PreCommitTipSet":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.ByteArrayMaxLen {
+				return fmt.Errorf("t.PreCommitTipSet: byte array too large (%d)", extra)
+			}
+			if maj != cbg.MajByteString {
+				return fmt.Errorf("expected byte array")
+			}
+			t.PreCommitTipSet = make([]byte, extra)
+			if _, err := io.ReadFull(br, t.PreCommitTipSet); err != nil {
+				return err
+			}
+			// t.PreCommitInfo (miner.SectorPreCommitInfo) (struct)
+		case "PreCommitInfo":
+
+			{
+
+				pb, err := br.PeekByte()
+				if err != nil {
+					return err
+				}
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
+					}
+				} else {
+					t.PreCommitInfo = new(miner.SectorPreCommitInfo)
+					if err := t.PreCommitInfo.UnmarshalCBOR(br); err != nil {
+						return xerrors.Errorf("unmarshaling t.PreCommitInfo pointer: %w", err)
+					}
+				}
+
+			}
+			// t.PreCommit2Fails (uint64) (uint64)
+		case "PreCommit2Fails":
+
+			{
+
+				maj, extra, err = cbg.CborReadHeader(br)
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.PreCommit2Fails = uint64(extra)
+
+			}
+			// t.PreCommitDeposit (big.Int) (struct)
+		case "PreCommitDeposit":
+
+			{
+
+				if err := t.PreCommitDeposit.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.PreCommitDeposit: %w", err)
+				}
+
+			}
+			// t.SeedValue (abi.InteractiveSealRandomness) (slice)
+		case "SeedValue":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.ByteArrayMaxLen {
+				return fmt.Errorf("t.SeedValue: byte array too large (%d)", extra)
+			}
+			if maj != cbg.MajByteString {
+				return fmt.Errorf("expected byte array")
+			}
+			t.SeedValue = make([]byte, extra)
+			if _, err := io.ReadFull(br, t.SeedValue); err != nil {
+				return err
+			}
+			// t.SeedEpoch (abi.ChainEpoch) (int64)
+		case "SeedEpoch":
+			{
+				maj, extra, err := cbg.CborReadHeader(br)
+				var extraI int64
+				if err != nil {
+					return err
+				}
+				switch maj {
+				case cbg.MajUnsignedInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 positive overflow")
+					}
+				case cbg.MajNegativeInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 negative oveflow")
+					}
+					extraI = -1 - extraI
+				default:
+					return fmt.Errorf("wrong type for int64 field: %d", maj)
+				}
+
+				t.SeedEpoch = abi.ChainEpoch(extraI)
+			}
+			// t.CommitMessage (cid.Cid) (struct)
+		case "CommitMessage":
+
+			{
+
+				pb, err := br.PeekByte()
+				if err != nil {
+					return err
+				}
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
+					}
+				} else {
+
+					c, err := cbg.ReadCid(br)
+					if err != nil {
+						return xerrors.Errorf("failed to read cid field t.CommitMessage: %w", err)
+					}
+
+					t.CommitMessage = &c
+				}
+
+			}
+			// t.InvalidProofs (uint64) (uint64)
+		case "InvalidProofs":
+
+			{
+
+				maj, extra, err = cbg.CborReadHeader(br)
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.InvalidProofs = uint64(extra)
+
+			}
+			// t.ProofSeedEpoch (abi.ChainEpoch) (int64)
+		case "ProofSeedEpoch":
+			{
+				maj, extra, err := cbg.CborReadHeader(br)
+				var extraI int64
+				if err != nil {
+					return err
+				}
+				switch maj {
+				case cbg.MajUnsignedInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 positive overflow")
+					}
+				case cbg.MajNegativeInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 negative oveflow")
+					}
+					extraI = -1 - extraI
+				default:
+					return fmt.Errorf("wrong type for int64 field: %d", maj)
+				}
+
+				t.ProofSeedEpoch = abi.ChainEpoch(extraI)
 			}
-			// t.Pieces ([]sealing.Piece) (slice)
-		case "Pieces":
+			// t.ProofCommR (cid.Cid) (struct)
+		case "ProofCommR":
+
+			{
+
+				pb, err := br.PeekByte()
+				if err != nil {
+					return err
+				}
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
+					}
+				} else {
+
+					c, err := cbg.ReadCid(br)
+					if err != nil {
+						return xerrors.Errorf("failed to read cid field t.ProofCommR: %w", err)
+					}
+
+					t.ProofCommR = &c
+				}
+
+			}
+			// t.InactiveDeals ([]sealing.DealInfo) (slice)
+		case "InactiveDeals":
 
 			maj, extra, err = cbg.CborReadHeader(br)
 			if err != nil {
@@ -914,7 +2009,7 @@ func (t *SectorInfo) UnmarshalCBOR(r io.Reader) error {
 			}
 
 			if extra > cbg.MaxLength {
-				return fmt.Errorf("t.Pieces: array too large (%d)", extra)
+				return fmt.Errorf("t.InactiveDeals: array too large (%d)", extra)
 			}
 
 			if maj != cbg.MajArray {
@@ -922,39 +2017,43 @@ func (t *SectorInfo) UnmarshalCBOR(r io.Reader) error {
 			}
 
 			if extra > 0 {
-				t.Pieces = make([]Piece, extra)
+				t.InactiveDeals = make([]DealInfo, extra)
 			}
 
 			for i := 0; i < int(extra); i++ {
 
-				var v Piece
+				var v DealInfo
 				if err := v.UnmarshalCBOR(br); err != nil {
 					return err
 				}
 
-				t.Pieces[i] = v
+				t.InactiveDeals[i] = v
 			}
 
-			// t.TicketValue (abi.SealRandomness) (slice)
-		case "TicketValue":
+			// t.StuckWait (sealing.SectorState) (string)
+		case "StuckWait":
 
-			maj, extra, err = cbg.CborReadHeader(br)
-			if err != nil {
-				return err
-			}
+			{
+				sval, err := cbg.ReadString(br)
+				if err != nil {
+					return err
+				}
 
-			if extra > cbg.ByteArrayMaxLen {
-				return fmt.Errorf("t.TicketValue: byte array too large (%d)", extra)
-			}
-			if maj != cbg.MajByteString {
-				return fmt.Errorf("expected byte array")
+				t.StuckWait = SectorState(sval)
 			}
-			t.TicketValue = make([]byte, extra)
-			if _, err := io.ReadFull(br, t.TicketValue); err != nil {
-				return err
+			// t.FailedWait (sealing.SectorState) (string)
+		case "FailedWait":
+
+			{
+				sval, err := cbg.ReadString(br)
+				if err != nil {
+					return err
+				}
+
+				t.FailedWait = SectorState(sval)
 			}
-			// t.TicketEpoch (abi.ChainEpoch) (int64)
-		case "TicketEpoch":
+			// t.MessageExitCode (exitcode.ExitCode) (int64)
+		case "MessageExitCode":
 			{
 				maj, extra, err := cbg.CborReadHeader(br)
 				var extraI int64
@@ -977,139 +2076,80 @@ func (t *SectorInfo) UnmarshalCBOR(r io.Reader) error {
 					return fmt.Errorf("wrong type for int64 field: %d", maj)
 				}
 
-				t.TicketEpoch = abi.ChainEpoch(extraI)
-			}
-			// t.PreCommit1Out (storage.PreCommit1Out) (slice)
-		case "PreCommit1Out":
-
-			maj, extra, err = cbg.CborReadHeader(br)
-			if err != nil {
-				return err
-			}
-
-			if extra > cbg.ByteArrayMaxLen {
-				return fmt.Errorf("t.PreCommit1Out: byte array too large (%d)", extra)
-			}
-			if maj != cbg.MajByteString {
-				return fmt.Errorf("expected byte array")
+				t.MessageExitCode = exitcode.ExitCode(extraI)
 			}
-			t.PreCommit1Out = make([]byte, extra)
-			if _, err := io.ReadFull(br, t.PreCommit1Out); err != nil {
-				return err
-			}
-			// t.CommD (cid.Cid) (struct)
-		case "CommD":
-
+			// t.MessageGasUsed (int64) (int64)
+		case "MessageGasUsed":
 			{
-
-				pb, err := br.PeekByte()
+				maj, extra, err := cbg.CborReadHeader(br)
+				var extraI int64
 				if err != nil {
 					return err
 				}
-				if pb == cbg.CborNull[0] {
-					var nbuf [1]byte
-					if _, err := br.Read(nbuf[:]); err != nil {
-						return err
+				switch maj {
+				case cbg.MajUnsignedInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 positive overflow")
 					}
-				} else {
-
-					c, err := cbg.ReadCid(br)
-					if err != nil {
-						return xerrors.Errorf("failed to read cid field t.CommD: %w", err)
+				case cbg.MajNegativeInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 negative oveflow")
 					}
-
-					t.CommD = &c
+					extraI = -1 - extraI
+				default:
+					return fmt.Errorf("wrong type for int64 field: %d", maj)
 				}
 
+				t.MessageGasUsed = int64(extraI)
 			}
-			// t.CommR (cid.Cid) (struct)
-		case "CommR":
+			// t.DiskWaitPhase (sealing.SectorState) (string)
+		case "DiskWaitPhase":
 
 			{
-
-				pb, err := br.PeekByte()
+				sval, err := cbg.ReadString(br)
 				if err != nil {
 					return err
 				}
-				if pb == cbg.CborNull[0] {
-					var nbuf [1]byte
-					if _, err := br.Read(nbuf[:]); err != nil {
-						return err
-					}
-				} else {
-
-					c, err := cbg.ReadCid(br)
-					if err != nil {
-						return xerrors.Errorf("failed to read cid field t.CommR: %w", err)
-					}
-
-					t.CommR = &c
-				}
 
+				t.DiskWaitPhase = SectorState(sval)
 			}
-			// t.Proof ([]uint8) (slice)
-		case "Proof":
+			// t.DeadlineAssigned (bool) (bool)
+		case "DeadlineAssigned":
 
 			maj, extra, err = cbg.CborReadHeader(br)
 			if err != nil {
 				return err
 			}
-
-			if extra > cbg.ByteArrayMaxLen {
-				return fmt.Errorf("t.Proof: byte array too large (%d)", extra)
-			}
-			if maj != cbg.MajByteString {
-				return fmt.Errorf("expected byte array")
+			if maj != cbg.MajOther {
+				return fmt.Errorf("booleans must be major type 7")
 			}
-			t.Proof = make([]byte, extra)
-			if _, err := io.ReadFull(br, t.Proof); err != nil {
-				return err
+			switch extra {
+			case 20:
+				t.DeadlineAssigned = false
+			case 21:
+				t.DeadlineAssigned = true
+			default:
+				return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
 			}
-			// t.PreCommitMessage (cid.Cid) (struct)
-		case "PreCommitMessage":
+			// t.Deadline (uint64) (uint64)
+		case "Deadline":
 
 			{
 
-				pb, err := br.PeekByte()
+				maj, extra, err = cbg.CborReadHeader(br)
 				if err != nil {
 					return err
 				}
-				if pb == cbg.CborNull[0] {
-					var nbuf [1]byte
-					if _, err := br.Read(nbuf[:]); err != nil {
-						return err
-					}
-				} else {
-
-					c, err := cbg.ReadCid(br)
-					if err != nil {
-						return xerrors.Errorf("failed to read cid field t.PreCommitMessage: %w", err)
-					}
-
-					t.PreCommitMessage = &c
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
 				}
+				t.Deadline = uint64(extra)
 
 			}
-			// t.PreCommitTipSet (sealing.TipSetToken) (slice)
-		case "PreCommitTipSet":
-
-			maj, extra, err = cbg.CborReadHeader(br)
-			if err != nil {
-				return err
-			}
-
-			if extra > cbg.ByteArrayMaxLen {
-				return fmt.Errorf("t.PreCommitTipSet: byte array too large (%d)", extra)
-			}
-			if maj != cbg.MajByteString {
-				return fmt.Errorf("expected byte array")
-			}
-			t.PreCommitTipSet = make([]byte, extra)
-			if _, err := io.ReadFull(br, t.PreCommitTipSet); err != nil {
-				return err
-			}
-			// t.PreCommit2Fails (uint64) (uint64)
-		case "PreCommit2Fails":
+			// t.Partition (uint64) (uint64)
+		case "Partition":
 
 			{
 
@@ -1120,55 +2160,54 @@ func (t *SectorInfo) UnmarshalCBOR(r io.Reader) error {
 				if maj != cbg.MajUnsignedInt {
 					return fmt.Errorf("wrong type for uint64 field")
 				}
-				t.PreCommit2Fails = uint64(extra)
+				t.Partition = uint64(extra)
 
 			}
-			// t.SeedValue (abi.InteractiveSealRandomness) (slice)
-		case "SeedValue":
+			// t.CommitNotified (bool) (bool)
+		case "CommitNotified":
 
 			maj, extra, err = cbg.CborReadHeader(br)
 			if err != nil {
 				return err
 			}
-
-			if extra > cbg.ByteArrayMaxLen {
-				return fmt.Errorf("t.SeedValue: byte array too large (%d)", extra)
-			}
-			if maj != cbg.MajByteString {
-				return fmt.Errorf("expected byte array")
+			if maj != cbg.MajOther {
+				return fmt.Errorf("booleans must be major type 7")
 			}
-			t.SeedValue = make([]byte, extra)
-			if _, err := io.ReadFull(br, t.SeedValue); err != nil {
-				return err
+			switch extra {
+			case 20:
+				t.CommitNotified = false
+			case 21:
+				t.CommitNotified = true
+			default:
+				return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
 			}
-			// t.SeedEpoch (abi.ChainEpoch) (int64)
-		case "SeedEpoch":
+			// t.FaultReportMsg (cid.Cid) (struct)
+		case "FaultReportMsg":
+
 			{
-				maj, extra, err := cbg.CborReadHeader(br)
-				var extraI int64
+
+				pb, err := br.PeekByte()
 				if err != nil {
 					return err
 				}
-				switch maj {
-				case cbg.MajUnsignedInt:
-					extraI = int64(extra)
-					if extraI < 0 {
-						return fmt.Errorf("int64 positive overflow")
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
 					}
-				case cbg.MajNegativeInt:
-					extraI = int64(extra)
-					if extraI < 0 {
-						return fmt.Errorf("int64 negative oveflow")
+				} else {
+
+					c, err := cbg.ReadCid(br)
+					if err != nil {
+						return xerrors.Errorf("failed to read cid field t.FaultReportMsg: %w", err)
 					}
-					extraI = -1 - extraI
-				default:
-					return fmt.Errorf("wrong type for int64 field: %d", maj)
+
+					t.FaultReportMsg = &c
 				}
 
-				t.SeedEpoch = abi.ChainEpoch(extraI)
 			}
-			// t.CommitMessage (cid.Cid) (struct)
-		case "CommitMessage":
+			// t.RecoveryMessage (cid.Cid) (struct)
+		case "RecoveryMessage":
 
 			{
 
@@ -1185,52 +2224,92 @@ func (t *SectorInfo) UnmarshalCBOR(r io.Reader) error {
 
 					c, err := cbg.ReadCid(br)
 					if err != nil {
-						return xerrors.Errorf("failed to read cid field t.CommitMessage: %w", err)
+						return xerrors.Errorf("failed to read cid field t.RecoveryMessage: %w", err)
 					}
 
-					t.CommitMessage = &c
+					t.RecoveryMessage = &c
 				}
 
 			}
-			// t.InvalidProofs (uint64) (uint64)
-		case "InvalidProofs":
+			// t.TerminateMessage (cid.Cid) (struct)
+		case "TerminateMessage":
 
 			{
 
-				maj, extra, err = cbg.CborReadHeader(br)
+				pb, err := br.PeekByte()
 				if err != nil {
 					return err
 				}
-				if maj != cbg.MajUnsignedInt {
-					return fmt.Errorf("wrong type for uint64 field")
+				if pb == cbg.CborNull[0] {
+					var nbuf [1]byte
+					if _, err := br.Read(nbuf[:]); err != nil {
+						return err
+					}
+				} else {
+
+					c, err := cbg.ReadCid(br)
+					if err != nil {
+						return xerrors.Errorf("failed to read cid field t.TerminateMessage: %w", err)
+					}
+
+					t.TerminateMessage = &c
 				}
-				t.InvalidProofs = uint64(extra)
 
 			}
-			// t.FaultReportMsg (cid.Cid) (struct)
-		case "FaultReportMsg":
+			// t.Worker (string) (string)
+		case "Worker":
 
 			{
-
-				pb, err := br.PeekByte()
+				sval, err := cbg.ReadString(br)
 				if err != nil {
 					return err
 				}
-				if pb == cbg.CborNull[0] {
-					var nbuf [1]byte
-					if _, err := br.Read(nbuf[:]); err != nil {
-						return err
+
+				t.Worker = string(sval)
+			}
+			// t.Priority (int64) (int64)
+		case "Priority":
+			{
+				maj, extra, err := cbg.CborReadHeader(br)
+				var extraI int64
+				if err != nil {
+					return err
+				}
+				switch maj {
+				case cbg.MajUnsignedInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 positive overflow")
 					}
-				} else {
-
-					c, err := cbg.ReadCid(br)
-					if err != nil {
-						return xerrors.Errorf("failed to read cid field t.FaultReportMsg: %w", err)
+				case cbg.MajNegativeInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return fmt.Errorf("int64 negative oveflow")
 					}
-
-					t.FaultReportMsg = &c
+					extraI = -1 - extraI
+				default:
+					return fmt.Errorf("wrong type for int64 field: %d", maj)
 				}
 
+				t.Priority = int64(extraI)
+			}
+			// t.Paused (bool) (bool)
+		case "Paused":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+			if maj != cbg.MajOther {
+				return fmt.Errorf("booleans must be major type 7")
+			}
+			switch extra {
+			case 20:
+				t.Paused = false
+			case 21:
+				t.Paused = true
+			default:
+				return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
 			}
 			// t.LastErr (string) (string)
 		case "LastErr":
@@ -1462,3 +2541,283 @@ func (t *Log) UnmarshalCBOR(r io.Reader) error {
 
 	return nil
 }
+func (t *SectorTimings) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{163}); err != nil {
+		return err
+	}
+
+	// t.SectorNumber (abi.SectorNumber) (uint64)
+	if len("SectorNumber") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"SectorNumber\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("SectorNumber")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("SectorNumber")); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.SectorNumber))); err != nil {
+		return err
+	}
+
+	// t.Finished (uint64) (uint64)
+	if len("Finished") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Finished\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Finished")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Finished")); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Finished))); err != nil {
+		return err
+	}
+
+	// t.Phases ([]sealing.PhaseTiming) (slice)
+	if len("Phases") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Phases\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Phases")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Phases")); err != nil {
+		return err
+	}
+
+	if len(t.Phases) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Phases was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.Phases)))); err != nil {
+		return err
+	}
+	for _, v := range t.Phases {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *SectorTimings) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("SectorTimings: map struct too large (%d)", extra)
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+
+		{
+			sval, err := cbg.ReadString(br)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.SectorNumber (abi.SectorNumber) (uint64)
+		case "SectorNumber":
+
+			{
+
+				maj, extra, err = cbg.CborReadHeader(br)
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.SectorNumber = abi.SectorNumber(extra)
+
+			}
+			// t.Finished (uint64) (uint64)
+		case "Finished":
+
+			{
+
+				maj, extra, err = cbg.CborReadHeader(br)
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.Finished = uint64(extra)
+
+			}
+			// t.Phases ([]sealing.PhaseTiming) (slice)
+		case "Phases":
+
+			maj, extra, err = cbg.CborReadHeader(br)
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.MaxLength {
+				return fmt.Errorf("t.Phases: array too large (%d)", extra)
+			}
+
+			if maj != cbg.MajArray {
+				return fmt.Errorf("expected cbor array")
+			}
+
+			if extra > 0 {
+				t.Phases = make([]PhaseTiming, extra)
+			}
+
+			for i := 0; i < int(extra); i++ {
+
+				var v PhaseTiming
+				if err := v.UnmarshalCBOR(br); err != nil {
+					return err
+				}
+
+				t.Phases[i] = v
+			}
+
+		default:
+			return fmt.Errorf("unknown struct field %d: '%s'", i, name)
+		}
+	}
+
+	return nil
+}
+func (t *PhaseTiming) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{162}); err != nil {
+		return err
+	}
+
+	// t.Phase (sealing.SectorState) (string)
+	if len("Phase") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Phase\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Phase")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Phase")); err != nil {
+		return err
+	}
+
+	if len(t.Phase) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Phase was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Phase)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Phase)); err != nil {
+		return err
+	}
+
+	// t.Seconds (uint64) (uint64)
+	if len("Seconds") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Seconds\" was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len("Seconds")))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("Seconds")); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Seconds))); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *PhaseTiming) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("PhaseTiming: map struct too large (%d)", extra)
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+
+		{
+			sval, err := cbg.ReadString(br)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.Phase (sealing.SectorState) (string)
+		case "Phase":
+
+			{
+				sval, err := cbg.ReadString(br)
+				if err != nil {
+					return err
+				}
+
+				t.Phase = SectorState(sval)
+			}
+			// t.Seconds (uint64) (uint64)
+		case "Seconds":
+
+			{
+
+				maj, extra, err = cbg.CborReadHeader(br)
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.Seconds = uint64(extra)
+
+			}
+
+		default:
+			return fmt.Errorf("unknown struct field %d: '%s'", i, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultCCBackfillInterval = time.Minute
+
+// states of sectors which aren't sealing anymore. Sectors in failed sealing
+// states are retried, so they still count as sealing - otherwise a broken
+// sealer would get an endless stream of new sectors
+var ccBackfillDone = map[SectorState]struct{}{
+	Proving:             {},
+	FailedUnrecoverable: {},
+	DealsExpired:        {},
+	Faulty:              {},
+	FaultReported:       {},
+	FaultedFinal:        {},
+	RecoveringFault:     {},
+	RecoveryWait:        {},
+	RecoveryFailed:      {},
+	Terminating:         {},
+	TerminateWait:       {},
+	TerminateFailed:     {},
+	Terminated:          {},
+	Removing:            {},
+	RemoveFailed:        {},
+	Removed:             {},
+	Aborting:            {},
+	AbortFailed:         {},
+	Aborted:             {},
+
+	DataCommitmentMismatch: {},
+	ExpiredPreCommit:       {},
+	UnsealedCorrupt:        {},
+}
+
+// StartCCBackfill keeps target committed capacity sectors sealing at once,
+// pledging new ones as sealing ones finish. Pieces of the new sectors are
+// written within the MaxConcurrentAddPiece limit. Runs until ctx is cancelled
+func (m *Sealing) StartCCBackfill(ctx context.Context, target int) {
+	go m.runCCBackfill(ctx, target)
+}
+
+func (m *Sealing) runCCBackfill(ctx context.Context, target int) {
+	interval := m.cfg.CCBackfillInterval
+	if interval == 0 {
+		interval = defaultCCBackfillInterval
+	}
+
+	var lk sync.Mutex
+	var pending int // being pledged, not in the sector list yet
+
+	for {
+		sealing, err := m.sealingCCSectors()
+		if err != nil {
+			log.Errorf("CC backfill: listing sectors: %+v", err)
+		} else {
+			lk.Lock()
+			for n := sealing + pending; n < target; n++ {
+				pending++
+				go func() {
+					sid, err := m.PledgeSector(ctx)
+					if err != nil {
+						log.Errorf("CC backfill: pledging sector: %+v", err)
+					} else {
+						log.Infof("CC backfill: pledged sector %d", sid)
+					}
+
+					lk.Lock()
+					pending--
+					lk.Unlock()
+				}()
+			}
+			lk.Unlock()
+		}
+
+		select {
+		case <-m.clock.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sealingCCSectors counts sectors without deals which are still sealing.
+// Sectors waiting for deals don't count, even if they don't have any yet
+func (m *Sealing) sealingCCSectors() (int, error) {
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, si := range sectors {
+		if _, done := ccBackfillDone[si.State]; done || si.State == WaitDeals || si.hasDeals() {
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
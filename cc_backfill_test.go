@@ -0,0 +1,90 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+func TestPledgeSector(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.setHead(10)
+
+	sid, err := h.m.PledgeSector(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(1), sid)
+
+	si := h.waitState(sid, WaitSeed)
+	require.Len(t, si.Pieces, 1)
+	require.Nil(t, si.Pieces[0].DealInfo)
+	require.Equal(t, abi.PaddedPieceSize(2048), si.Pieces[0].Piece.Size)
+
+	h.api.setHead(10 + miner.PreCommitChallengeDelay)
+	h.waitState(sid, Proving)
+}
+
+// countStates returns the number of sectors in each state
+func (h *testHarness) countStates() map[SectorState]int {
+	sectors, err := h.m.ListSectors()
+	require.NoError(h.t, err)
+
+	out := map[SectorState]int{}
+	for _, si := range sectors {
+		out[si.State]++
+	}
+	return out
+}
+
+func TestCCBackfill(t *testing.T) {
+	h := newTestHarness(t, Config{MaxConcurrentAddPiece: 1, CCBackfillInterval: 5 * time.Millisecond})
+	h.api.setHead(10)
+
+	var lk sync.Mutex
+	var running, maxRunning int
+	h.sealer.addPiece = func(ctx context.Context, sector abi.SectorID, size abi.UnpaddedPieceSize) {
+		lk.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lk.Unlock()
+
+		time.Sleep(2 * time.Millisecond)
+
+		lk.Lock()
+		running--
+		lk.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.m.StartCCBackfill(ctx, 3)
+
+	require.Eventually(t, func() bool {
+		return h.countStates()[WaitSeed] == 3
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// sealing sectors are waiting for the seed, no more get pledged
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, map[SectorState]int{WaitSeed: 3}, h.countStates())
+
+	// as sectors finish sealing, new ones replace them
+	h.api.setHead(10 + miner.PreCommitChallengeDelay)
+	require.Eventually(t, func() bool {
+		c := h.countStates()
+		return c[Proving] == 3 && c[WaitSeed] == 3
+	}, 5*time.Second, 5*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, map[SectorState]int{Proving: 3, WaitSeed: 3}, h.countStates())
+
+	lk.Lock()
+	require.Equal(t, 1, maxRunning)
+	lk.Unlock()
+}
@@ -0,0 +1,75 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// SectorChainInfo is the on-chain state of a sector
+type SectorChainInfo struct {
+	PreCommit *miner.SectorPreCommitOnChainInfo // nil if not precommitted, or already proven
+	Sector    *miner.SectorOnChainInfo          // nil if not proven
+}
+
+// SectorsChainState is the on-chain state of a set of sectors of a miner
+type SectorsChainState struct {
+	SectorSize abi.SectorSize
+	Sectors    map[abi.SectorNumber]SectorChainInfo
+}
+
+// SealingAPIBatch can be implemented by the SealingAPI passed to New, to fetch
+// StateSectorPreCommitInfo, StateSectorGetInfo and StateMinerSectorSize of
+// many sectors in one round trip. Without it, the individual calls are made
+type SealingAPIBatch interface {
+	StateSectorsBatch(ctx context.Context, maddr address.Address, sectors []abi.SectorNumber, tok TipSetToken) (*SectorsChainState, error)
+}
+
+// sectorsChainState returns the chain state of the given sectors at tok
+func (m *Sealing) sectorsChainState(ctx context.Context, sectors []abi.SectorNumber, tok TipSetToken) (*SectorsChainState, error) {
+	if m.batch != nil {
+		if m.breaker != nil {
+			if err := m.breaker.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		out, err := m.batch.StateSectorsBatch(ctx, m.maddr, sectors, tok)
+		if m.breaker != nil {
+			m.breaker.done(err)
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("getting chain state of %d sectors: %w", len(sectors), err)
+		}
+		return out, nil
+	}
+
+	ss, err := m.api.StateMinerSectorSize(ctx, m.maddr, tok)
+	if err != nil {
+		return nil, xerrors.Errorf("getting sector size: %w", err)
+	}
+
+	out := &SectorsChainState{
+		SectorSize: ss,
+		Sectors:    map[abi.SectorNumber]SectorChainInfo{},
+	}
+	for _, sn := range sectors {
+		pci, err := m.api.StateSectorPreCommitInfo(ctx, m.maddr, sn, tok)
+		if err != nil {
+			return nil, xerrors.Errorf("getting precommit info of sector %d: %w", sn, err)
+		}
+
+		si, err := m.api.StateSectorGetInfo(ctx, m.maddr, sn, tok)
+		if err != nil {
+			return nil, xerrors.Errorf("getting on-chain info of sector %d: %w", sn, err)
+		}
+
+		out.Sectors[sn] = SectorChainInfo{PreCommit: pci, Sector: si}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,95 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// batchAPI serves StateSectorsBatch from the fakeAPI state
+type batchAPI struct {
+	*fakeAPI
+
+	lk    sync.Mutex
+	calls int
+}
+
+func (b *batchAPI) StateSectorsBatch(ctx context.Context, maddr address.Address, sectors []abi.SectorNumber, tok TipSetToken) (*SectorsChainState, error) {
+	b.lk.Lock()
+	b.calls++
+	b.lk.Unlock()
+
+	b.fakeAPI.lk.Lock()
+	defer b.fakeAPI.lk.Unlock()
+
+	out := &SectorsChainState{SectorSize: 2048, Sectors: map[abi.SectorNumber]SectorChainInfo{}}
+	for _, sn := range sectors {
+		out.Sectors[sn] = SectorChainInfo{PreCommit: b.precommits[sn], Sector: b.sectors[sn]}
+	}
+	return out, nil
+}
+
+func (b *batchAPI) batchCalls() int {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	return b.calls
+}
+
+func (h *testHarness) seedChainSectors() {
+	h.api.lk.Lock()
+	defer h.api.lk.Unlock()
+	h.api.precommits[1] = &miner.SectorPreCommitOnChainInfo{PreCommitEpoch: 10}
+	h.api.sectors[2] = &miner.SectorOnChainInfo{ActivationEpoch: 20}
+}
+
+func requireChainState(t *testing.T, cs *SectorsChainState) {
+	require.Equal(t, abi.SectorSize(2048), cs.SectorSize)
+	require.Len(t, cs.Sectors, 3)
+	require.Equal(t, abi.ChainEpoch(10), cs.Sectors[1].PreCommit.PreCommitEpoch)
+	require.Nil(t, cs.Sectors[1].Sector)
+	require.Nil(t, cs.Sectors[2].PreCommit)
+	require.Equal(t, abi.ChainEpoch(20), cs.Sectors[2].Sector.ActivationEpoch)
+	require.Equal(t, SectorChainInfo{}, cs.Sectors[3])
+}
+
+func TestSectorsChainStateUnbatched(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.seedChainSectors()
+
+	cs, err := h.m.sectorsChainState(context.Background(), []abi.SectorNumber{1, 2, 3}, nil)
+	require.NoError(t, err)
+	requireChainState(t, cs)
+
+	h.api.lk.Lock()
+	require.Equal(t, 1+2*3, h.api.stateCalls)
+	h.api.lk.Unlock()
+}
+
+func TestSectorsChainStateBatched(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.seedChainSectors()
+
+	api := &batchAPI{fakeAPI: h.api}
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+	h.m = NewWithConfig(api, h.api, h.m.maddr, h.ds, h.sealer, &fakeCounter{}, fakeVerifier{}, &pcp, Config{})
+
+	cs, err := h.m.sectorsChainState(context.Background(), []abi.SectorNumber{1, 2, 3}, nil)
+	require.NoError(t, err)
+	requireChainState(t, cs)
+	require.Equal(t, 1, api.batchCalls())
+
+	h.api.lk.Lock()
+	require.Zero(t, h.api.stateCalls)
+	h.api.lk.Unlock()
+
+	// the commit step goes through the batch call too
+	h.start(h.committingSector(4))
+	h.waitState(4, Proving)
+	require.Equal(t, 2, api.batchCalls())
+}
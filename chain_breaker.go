@@ -0,0 +1,265 @@
+package sealing
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+)
+
+// chainBreaker is a circuit breaker shared by all chain calls. After
+// `threshold` consecutive failures it opens, and holds all calls back for the
+// backoff period. Once that passes, calls are let through again - the first
+// one to succeed closes the breaker, the first one to fail re-opens it.
+type chainBreaker struct {
+	threshold int
+	backoff   time.Duration
+	clock     Clock
+
+	lk        sync.Mutex
+	failures  int
+	lastErr   error
+	openUntil time.Time
+}
+
+func newChainBreaker(threshold int, backoff time.Duration, clock Clock) *chainBreaker {
+	return &chainBreaker{
+		threshold: threshold,
+		backoff:   backoff,
+		clock:     clock,
+	}
+}
+
+// wait blocks while the breaker is open
+func (b *chainBreaker) wait(ctx context.Context) error {
+	b.lk.Lock()
+	until := b.openUntil
+	b.lk.Unlock()
+
+	wait := until.Sub(b.clock.Now())
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-b.clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// nodeFailure tells whether the error means the chain node couldn't serve the
+// call, rather than the node rejecting the call itself, e.g. for an unknown
+// deal or an invalid message. Only the former count as breaker failures
+func nodeFailure(err error) bool {
+	if xerrors.As(err, new(*ErrNodeUnreachable)) {
+		return true
+	}
+
+	var nerr net.Error
+	if xerrors.As(err, &nerr) {
+		return true
+	}
+
+	return xerrors.Is(err, io.EOF) || xerrors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// done records the outcome of a call. Errors which aren't node failures mean
+// the node is there, and count as successes
+func (b *chainBreaker) done(err error) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if err == nil || !nodeFailure(err) {
+		if b.failures >= b.threshold {
+			log.Infow("chain node recovered, resuming chain calls", "failures", b.failures)
+		}
+
+		b.failures = 0
+		b.lastErr = nil
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+
+	if now := b.clock.Now(); b.failures >= b.threshold && !now.Before(b.openUntil) {
+		log.Errorf("chain node unavailable (%d consecutive failures), pausing chain calls for %s: %+v", b.failures, b.backoff, err)
+		b.openUntil = now.Add(b.backoff)
+	}
+}
+
+func (b *chainBreaker) health() ChainHealth {
+	if b == nil {
+		return ChainHealth{Available: true}
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if b.failures < b.threshold {
+		return ChainHealth{Available: true}
+	}
+
+	return ChainHealth{
+		Error:   b.lastErr.Error(),
+		RetryAt: b.openUntil,
+	}
+}
+
+// breakerAPI guards all SealingAPI calls with a chainBreaker
+type breakerAPI struct {
+	api SealingAPI
+	b   *chainBreaker
+}
+
+func (a *breakerAPI) StateWaitMsg(ctx context.Context, c cid.Cid) (MsgLookup, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return MsgLookup{}, err
+	}
+	out, err := a.api.StateWaitMsg(ctx, c)
+	if ctx.Err() == nil { // waits given up by the caller don't count
+		a.b.done(err)
+	}
+	return out, err
+}
+
+func (a *breakerAPI) StateComputeDataCommitment(ctx context.Context, maddr address.Address, sectorType abi.RegisteredSealProof, deals []abi.DealID, tok TipSetToken) (cid.Cid, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return cid.Undef, err
+	}
+	out, err := a.api.StateComputeDataCommitment(ctx, maddr, sectorType, deals, tok)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) StateSectorPreCommitInfo(ctx context.Context, maddr address.Address, sectorNumber abi.SectorNumber, tok TipSetToken) (*miner.SectorPreCommitOnChainInfo, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return nil, err
+	}
+	out, err := a.api.StateSectorPreCommitInfo(ctx, maddr, sectorNumber, tok)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) StateSectorGetInfo(ctx context.Context, maddr address.Address, sectorNumber abi.SectorNumber, tok TipSetToken) (*miner.SectorOnChainInfo, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return nil, err
+	}
+	out, err := a.api.StateSectorGetInfo(ctx, maddr, sectorNumber, tok)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) StateMinerSectorSize(ctx context.Context, maddr address.Address, tok TipSetToken) (abi.SectorSize, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return 0, err
+	}
+	out, err := a.api.StateMinerSectorSize(ctx, maddr, tok)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) StateMinerWorkerAddress(ctx context.Context, maddr address.Address, tok TipSetToken) (address.Address, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return address.Undef, err
+	}
+	out, err := a.api.StateMinerWorkerAddress(ctx, maddr, tok)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) StateMinerDeadlines(ctx context.Context, maddr address.Address, tok TipSetToken) (*miner.Deadlines, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return nil, err
+	}
+	out, err := a.api.StateMinerDeadlines(ctx, maddr, tok)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) StateMinerInitialPledgeCollateral(ctx context.Context, maddr address.Address, sectorNumber abi.SectorNumber, tok TipSetToken) (big.Int, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return big.Int{}, err
+	}
+	out, err := a.api.StateMinerInitialPledgeCollateral(ctx, maddr, sectorNumber, tok)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) StateMinerAvailableBalance(ctx context.Context, maddr address.Address, tok TipSetToken) (big.Int, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return big.Int{}, err
+	}
+	out, err := a.api.StateMinerAvailableBalance(ctx, maddr, tok)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) StateMarketStorageDeal(ctx context.Context, id abi.DealID, tok TipSetToken) (market.DealProposal, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return market.DealProposal{}, err
+	}
+	out, err := a.api.StateMarketStorageDeal(ctx, id, tok)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) EstimateMessageGas(ctx context.Context, from, to address.Address, method abi.MethodNum, value big.Int, params []byte) (big.Int, int64, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return big.Int{}, 0, err
+	}
+	price, limit, err := a.api.EstimateMessageGas(ctx, from, to, method, value, params)
+	a.b.done(err)
+	return price, limit, err
+}
+
+func (a *breakerAPI) SendMsg(ctx context.Context, from, to address.Address, method abi.MethodNum, value, gasPrice big.Int, gasLimit int64, params []byte) (cid.Cid, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return cid.Undef, err
+	}
+	out, err := a.api.SendMsg(ctx, from, to, method, value, gasPrice, gasLimit, params)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) ChainHead(ctx context.Context) (TipSetToken, abi.ChainEpoch, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return nil, 0, err
+	}
+	tok, h, err := a.api.ChainHead(ctx)
+	a.b.done(err)
+	return tok, h, err
+}
+
+func (a *breakerAPI) ChainGetRandomness(ctx context.Context, tok TipSetToken, personalization crypto.DomainSeparationTag, randEpoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return nil, err
+	}
+	out, err := a.api.ChainGetRandomness(ctx, tok, personalization, randEpoch, entropy)
+	a.b.done(err)
+	return out, err
+}
+
+func (a *breakerAPI) ChainReadObj(ctx context.Context, c cid.Cid) ([]byte, error) {
+	if err := a.b.wait(ctx); err != nil {
+		return nil, err
+	}
+	out, err := a.api.ChainReadObj(ctx, c)
+	a.b.done(err)
+	return out, err
+}
+
+var _ SealingAPI = &breakerAPI{}
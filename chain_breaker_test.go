@@ -0,0 +1,92 @@
+package sealing
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestChainBreaker(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t, Config{ChainFailureThreshold: 3, ChainBackoff: 200 * time.Millisecond})
+
+	require.True(t, h.m.Health().Chain.Available)
+
+	h.api.setOutage(&ErrNodeUnreachable{xerrors.New("connection refused")})
+	for i := 0; i < 3; i++ {
+		_, _, err := h.m.api.ChainHead(ctx)
+		require.Error(t, err)
+	}
+
+	health := h.m.Health().Chain
+	require.False(t, health.Available)
+	require.Equal(t, "connection refused", health.Error)
+	require.True(t, health.RetryAt.After(time.Now()))
+
+	// calls are held back while the breaker is open
+	done := make(chan error)
+	go func() {
+		_, _, err := h.m.api.ChainHead(ctx)
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 3, h.api.headCalls())
+
+	// still down when the breaker lets calls through, open it again
+	require.Error(t, <-done)
+	require.Equal(t, 4, h.api.headCalls())
+	require.False(t, h.m.Health().Chain.Available)
+	require.True(t, h.m.Health().Chain.RetryAt.After(health.RetryAt))
+
+	h.api.setOutage(nil)
+
+	_, _, err := h.m.api.ChainHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 5, h.api.headCalls())
+	require.Equal(t, ChainHealth{Available: true}, h.m.Health().Chain)
+}
+
+func TestChainBreakerNetworkErrors(t *testing.T) {
+	h := newTestHarness(t, Config{ChainFailureThreshold: 3})
+
+	h.api.setOutage(xerrors.Errorf("sendRequest failed: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}))
+	for i := 0; i < 3; i++ {
+		_, _, err := h.m.api.ChainHead(context.Background())
+		require.Error(t, err)
+	}
+
+	require.False(t, h.m.Health().Chain.Available)
+}
+
+func TestChainBreakerApplicationErrors(t *testing.T) {
+	h := newTestHarness(t, Config{ChainFailureThreshold: 3})
+
+	// the node answers, it just rejects the calls
+	h.api.setOutage(xerrors.New("deal 5 not found"))
+	for i := 0; i < 10; i++ {
+		_, _, err := h.m.api.ChainHead(context.Background())
+		require.Error(t, err)
+	}
+
+	require.Equal(t, 10, h.api.headCalls())
+	require.True(t, h.m.Health().Chain.Available)
+}
+
+func TestChainBreakerDisabled(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.api.setOutage(&ErrNodeUnreachable{xerrors.New("connection refused")})
+	for i := 0; i < 10; i++ {
+		_, _, err := h.m.api.ChainHead(context.Background())
+		require.Error(t, err)
+	}
+
+	require.Equal(t, 10, h.api.headCalls())
+	require.True(t, h.m.Health().Chain.Available)
+}
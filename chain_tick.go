@@ -0,0 +1,41 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// chainTick pins the chain head for one run of a state handler, so that all
+// chain state the handler reads is read at the same tipset, even if the head
+// moves in the meantime. The head is fetched on first use. Handlers which
+// wait on the chain, or on long sealer work, read a fresh head from m.api
+type chainTick struct {
+	SealingAPI
+
+	once   sync.Once
+	tok    TipSetToken
+	height abi.ChainEpoch
+	err    error
+}
+
+func newChainTick(api SealingAPI) *chainTick {
+	return &chainTick{SealingAPI: api}
+}
+
+func (t *chainTick) ChainHead(ctx context.Context) (TipSetToken, abi.ChainEpoch, error) {
+	t.once.Do(func() {
+		t.tok, t.height, t.err = t.SealingAPI.ChainHead(ctx)
+	})
+	return t.tok, t.height, t.err
+}
+
+// tickAPI is the API with the chain head pinned for the running handler of
+// the sector. Outside of handlers it's m.api
+func (m *Sealing) tickAPI(sector SectorInfo) SealingAPI {
+	if sector.tick == nil {
+		return m.api
+	}
+	return sector.tick
+}
@@ -0,0 +1,174 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// movingHeadAPI advances the head on every ChainHead call, and records the
+// tipsets chain state is read at until the first message is sent
+type movingHeadAPI struct {
+	*fakeAPI
+
+	lk        sync.Mutex
+	heads     int
+	read      []TipSetToken
+	sentHeads int // heads read before the first message was sent, 0 until then
+}
+
+func (a *movingHeadAPI) ChainHead(ctx context.Context) (TipSetToken, abi.ChainEpoch, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	a.heads++
+	return TipSetToken{byte(a.heads)}, abi.ChainEpoch(10 + a.heads), nil
+}
+
+func (a *movingHeadAPI) readAt(tok TipSetToken) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if a.sentHeads == 0 {
+		a.read = append(a.read, tok)
+	}
+}
+
+func (a *movingHeadAPI) reads() (int, []TipSetToken) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.sentHeads, append([]TipSetToken(nil), a.read...)
+}
+
+func (a *movingHeadAPI) StateMinerWorkerAddress(ctx context.Context, maddr address.Address, tok TipSetToken) (address.Address, error) {
+	a.readAt(tok)
+	return a.fakeAPI.StateMinerWorkerAddress(ctx, maddr, tok)
+}
+
+func (a *movingHeadAPI) StateComputeDataCommitment(ctx context.Context, maddr address.Address, sectorType abi.RegisteredSealProof, deals []abi.DealID, tok TipSetToken) (cid.Cid, error) {
+	a.readAt(tok)
+	return a.fakeAPI.StateComputeDataCommitment(ctx, maddr, sectorType, deals, tok)
+}
+
+func (a *movingHeadAPI) StateSectorPreCommitInfo(ctx context.Context, maddr address.Address, sn abi.SectorNumber, tok TipSetToken) (*miner.SectorPreCommitOnChainInfo, error) {
+	a.readAt(tok)
+	return a.fakeAPI.StateSectorPreCommitInfo(ctx, maddr, sn, tok)
+}
+
+func (a *movingHeadAPI) StateMarketStorageDeal(ctx context.Context, id abi.DealID, tok TipSetToken) (market.DealProposal, error) {
+	a.readAt(tok)
+	return a.fakeAPI.StateMarketStorageDeal(ctx, id, tok)
+}
+
+func (a *movingHeadAPI) StateMinerInitialPledgeCollateral(ctx context.Context, maddr address.Address, sn abi.SectorNumber, tok TipSetToken) (big.Int, error) {
+	a.readAt(tok)
+	return a.fakeAPI.StateMinerInitialPledgeCollateral(ctx, maddr, sn, tok)
+}
+
+func (a *movingHeadAPI) SendMsg(ctx context.Context, from, to address.Address, method abi.MethodNum, value, gasPrice big.Int, gasLimit int64, params []byte) (cid.Cid, error) {
+	a.lk.Lock()
+	if a.sentHeads == 0 {
+		a.sentHeads = a.heads
+	}
+	a.lk.Unlock()
+	return a.fakeAPI.SendMsg(ctx, from, to, method, value, gasPrice, gasLimit, params)
+}
+
+func TestChainTickPreCommitting(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	api := &movingHeadAPI{fakeAPI: h.api}
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+	h.m = NewWithConfig(api, h.api, h.m.maddr, h.ds, h.sealer, &fakeCounter{}, fakeVerifier{}, &pcp, Config{})
+
+	si := dealSector(h, PreCommitting)
+	h.api.setDeal(1, market.DealProposal{
+		PieceCID:   testCommD,
+		PieceSize:  1024,
+		StartEpoch: 5000,
+		EndEpoch:   10000,
+	})
+	si.Pieces[0].DealInfo.DealSchedule.StartEpoch = 5000
+	si.TicketValue = abi.SealRandomness(testRand)
+	si.TicketEpoch = 5
+	h.start(si)
+	h.waitState(1, PreCommitWait)
+
+	// the worker, CommD, precommit, deal and pledge are all read at the
+	// single head read by the handler
+	heads, read := api.reads()
+	require.Equal(t, 1, heads)
+	require.Len(t, read, 5)
+	for _, tok := range read {
+		require.Equal(t, TipSetToken{1}, tok)
+	}
+}
+
+func TestChainTickPreCommit1(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	api := &movingHeadAPI{fakeAPI: h.api}
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+	h.m = NewWithConfig(api, h.api, h.m.maddr, h.ds, h.sealer, &fakeCounter{}, fakeVerifier{}, &pcp, Config{})
+
+	sealing := make(chan struct{})
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		close(sealing)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	si := dealSector(h, PreCommit1)
+	h.api.setDeal(1, market.DealProposal{
+		PieceCID:   testCommD,
+		PieceSize:  1024,
+		StartEpoch: 5000,
+		EndEpoch:   10000,
+	})
+	si.Pieces[0].DealInfo.DealSchedule.StartEpoch = 5000
+	h.start(si)
+	<-sealing
+
+	// the deal checked before sealing and the precommit checked for the
+	// ticket are read at the same head
+	_, read := api.reads()
+	require.Len(t, read, 2)
+	for _, tok := range read {
+		require.Equal(t, TipSetToken{1}, tok)
+	}
+}
+
+func TestChainTickPerHandler(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	api := &movingHeadAPI{fakeAPI: h.api}
+	si := SectorInfo{SectorNumber: 1}
+
+	// outside of handlers every call reads the head
+	h.m.api = api
+	tok1, _, err := h.m.tickAPI(si).ChainHead(context.Background())
+	require.NoError(t, err)
+	tok2, _, err := h.m.tickAPI(si).ChainHead(context.Background())
+	require.NoError(t, err)
+	require.NotEqual(t, tok1, tok2)
+
+	// each handler run pins its own head
+	si.tick = newChainTick(api)
+	tok3, h3, err := h.m.tickAPI(si).ChainHead(context.Background())
+	require.NoError(t, err)
+	tok4, h4, err := h.m.tickAPI(si).ChainHead(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, tok3, tok4)
+	require.Equal(t, h3, h4)
+
+	si.tick = newChainTick(api)
+	tok5, _, err := h.m.tickAPI(si).ChainHead(context.Background())
+	require.NoError(t, err)
+	require.NotEqual(t, tok3, tok5)
+}
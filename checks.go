@@ -1,17 +1,17 @@
 package sealing
 
 import (
-	"bytes"
 	"context"
+	"io"
 
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
-	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/sector-storage/storiface"
 	"github.com/filecoin-project/sector-storage/zerocomm"
 	"github.com/filecoin-project/specs-actors/actors/abi"
-	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 )
 
 // TODO: For now we handle this by halting state execution, when we get jsonrpc reconnecting
@@ -21,15 +21,19 @@ type ErrApi struct{ error }
 type ErrInvalidDeals struct{ error }
 type ErrInvalidPiece struct{ error }
 type ErrExpiredDeals struct{ error }
+type ErrDealNotPublished struct{ error }
 
 type ErrBadCommD struct{ error }
 type ErrExpiredTicket struct{ error }
 type ErrBadTicket struct{ error }
 type ErrPrecommitOnChain struct{ error }
 
+type ErrCorruptPiece struct{ error }
+
 type ErrBadSeed struct{ error }
 type ErrInvalidProof struct{ error }
 type ErrNoPrecommit struct{ error }
+type ErrPreCommitExpired struct{ error }
 
 func checkPieces(ctx context.Context, si SectorInfo, api SealingAPI) error {
 	tok, height, err := api.ChainHead(ctx)
@@ -69,6 +73,123 @@ func checkPieces(ctx context.Context, si SectorInfo, api SealingAPI) error {
 	return nil
 }
 
+// checkDealsPublished checks that all deals in the sector are published and
+// didn't start yet. Deals with a PublishCid wait for the publish message to
+// land first. Deals which weren't published are returned, the error is
+// *ErrApi or *ErrExpiredDeals
+func checkDealsPublished(ctx context.Context, si SectorInfo, api SealingAPI) ([]abi.DealID, error) {
+	if len(si.dealIDs()) == 0 {
+		return nil, nil
+	}
+
+	failed := map[abi.DealID]bool{}
+	for _, p := range si.Pieces {
+		if p.DealInfo == nil || p.DealInfo.PublishCid == nil {
+			continue
+		}
+
+		lookup, err := api.StateWaitMsg(ctx, *p.DealInfo.PublishCid)
+		if err != nil {
+			return nil, &ErrApi{xerrors.Errorf("waiting for publish message %s of deal %d: %w", p.DealInfo.PublishCid, p.DealInfo.DealID, err)}
+		}
+
+		if lookup.Receipt.ExitCode != exitcode.Ok {
+			log.Warnf("publish message %s of deal %d in sector %d failed with exit code %d", p.DealInfo.PublishCid, p.DealInfo.DealID, si.SectorNumber, lookup.Receipt.ExitCode)
+			failed[p.DealInfo.DealID] = true
+		}
+	}
+
+	tok, height, err := api.ChainHead(ctx)
+	if err != nil {
+		return nil, &ErrApi{xerrors.Errorf("getting chain head: %w", err)}
+	}
+
+	var unpublished []abi.DealID
+	for i, p := range si.Pieces {
+		if p.DealInfo == nil {
+			continue
+		}
+		if failed[p.DealInfo.DealID] {
+			unpublished = append(unpublished, p.DealInfo.DealID)
+			continue
+		}
+
+		proposal, err := api.StateMarketStorageDeal(ctx, p.DealInfo.DealID, tok)
+		if err != nil {
+			return nil, &ErrApi{xerrors.Errorf("getting deal %d for piece %d: %w", p.DealInfo.DealID, i, err)}
+		}
+
+		if !proposal.PieceCID.Defined() {
+			unpublished = append(unpublished, p.DealInfo.DealID)
+			continue
+		}
+
+		if height >= proposal.StartEpoch {
+			return nil, &ErrExpiredDeals{xerrors.Errorf("piece %d (of %d) of sector %d refers expired deal %d - should start at %d, head %d", i, len(si.Pieces), si.SectorNumber, p.DealInfo.DealID, proposal.StartEpoch, height)}
+		}
+	}
+
+	return unpublished, nil
+}
+
+// liveDeals returns the deals in the sector which didn't start yet
+func liveDeals(ctx context.Context, si SectorInfo, api SealingAPI) ([]abi.DealID, error) {
+	tok, height, err := api.ChainHead(ctx)
+	if err != nil {
+		return nil, &ErrApi{xerrors.Errorf("getting chain head: %w", err)}
+	}
+
+	var out []abi.DealID
+	for _, p := range si.Pieces {
+		if p.DealInfo == nil {
+			continue
+		}
+
+		proposal, err := api.StateMarketStorageDeal(ctx, p.DealInfo.DealID, tok)
+		if err != nil {
+			return nil, &ErrApi{xerrors.Errorf("getting deal %d: %w", p.DealInfo.DealID, err)}
+		}
+
+		if height < proposal.StartEpoch {
+			out = append(out, p.DealInfo.DealID)
+		}
+	}
+
+	return out, nil
+}
+
+// checkUnsealedPieces reads back every deal piece from the unsealed copy of the
+// sector, and checks that it still matches the deal PieceCID
+func (m *Sealing) checkUnsealedPieces(ctx context.Context, si SectorInfo) error {
+	var offset abi.UnpaddedPieceSize
+	for i, p := range si.Pieces {
+		pieceOffset, size := offset, p.Piece.Size.Unpadded()
+		offset += size
+
+		if p.DealInfo == nil {
+			continue
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			err := m.sealer.ReadPiece(ctx, pw, m.minerSector(si.SectorNumber), storiface.UnpaddedByteIndex(pieceOffset), size, si.TicketValue, *si.CommD)
+			_ = pw.CloseWithError(err)
+		}()
+
+		commP, err := m.commP(si.SectorType, pr, size)
+		_ = pr.Close()
+		if err != nil {
+			return xerrors.Errorf("computing commitment of piece %d (deal %d): %w", i, p.DealInfo.DealID, err)
+		}
+
+		if !commP.Equals(p.Piece.PieceCID) {
+			return &ErrCorruptPiece{xerrors.Errorf("piece %d (deal %d) of sector %d doesn't match its PieceCID: %s != %s", i, p.DealInfo.DealID, si.SectorNumber, commP, p.Piece.PieceCID)}
+		}
+	}
+
+	return nil
+}
+
 // checkPrecommit checks that data commitment generated in the sealing process
 //  matches pieces, and that the seal ticket isn't expired
 func checkPrecommit(ctx context.Context, maddr address.Address, si SectorInfo, tok TipSetToken, height abi.ChainEpoch, api SealingAPI) (err error) {
@@ -114,16 +235,16 @@ func (m *Sealing) checkCommit(ctx context.Context, si SectorInfo, proof []byte,
 		return &ErrNoPrecommit{xerrors.Errorf("precommit info not found on-chain")}
 	}
 
-	if pci.PreCommitEpoch+miner.PreCommitChallengeDelay != si.SeedEpoch {
-		return &ErrBadSeed{xerrors.Errorf("seed epoch doesn't match on chain info: %d != %d", pci.PreCommitEpoch+miner.PreCommitChallengeDelay, si.SeedEpoch)}
+	if seedEpoch := m.commitSeedEpoch(pci); seedEpoch != si.SeedEpoch {
+		return &ErrBadSeed{xerrors.Errorf("seed epoch doesn't match on chain info: %d != %d", seedEpoch, si.SeedEpoch)}
 	}
 
-	buf := new(bytes.Buffer)
-	if err := m.maddr.MarshalCBOR(buf); err != nil {
+	entropy, err := m.entropy.SeedEntropy(m.maddr, si.SectorNumber)
+	if err != nil {
 		return err
 	}
 
-	seed, err := m.api.ChainGetRandomness(ctx, tok, crypto.DomainSeparationTag_InteractiveSealChallengeSeed, si.SeedEpoch, buf.Bytes())
+	seed, err := m.api.ChainGetRandomness(ctx, tok, crypto.DomainSeparationTag_InteractiveSealChallengeSeed, si.SeedEpoch, entropy)
 	if err != nil {
 		return &ErrApi{xerrors.Errorf("failed to get randomness for computing seal proof: %w", err)}
 	}
@@ -132,23 +253,18 @@ func (m *Sealing) checkCommit(ctx context.Context, si SectorInfo, proof []byte,
 		return &ErrBadSeed{xerrors.Errorf("seed has changed")}
 	}
 
-	ss, err := m.api.StateMinerSectorSize(ctx, m.maddr, tok)
-	if err != nil {
-		return &ErrApi{err}
-	}
-	spt, err := ffiwrapper.SealProofTypeFromSectorSize(ss)
-	if err != nil {
-		return err
-	}
-
 	if *si.CommR != pci.Info.SealedCID {
 		log.Warn("on-chain sealed CID doesn't match!")
 	}
 
+	if m.cfg.SkipCommitVerification {
+		return nil
+	}
+
 	ok, err := m.verif.VerifySeal(abi.SealVerifyInfo{
 		SectorID:              m.minerSector(si.SectorNumber),
 		SealedCID:             pci.Info.SealedCID,
-		SealProof:             spt,
+		SealProof:             pci.Info.SealProof, // the sector was precommitted with
 		Proof:                 proof,
 		Randomness:            si.TicketValue,
 		InteractiveRandomness: si.SeedValue,
@@ -0,0 +1,15 @@
+package sealing
+
+import "time"
+
+// Clock is the source of time for Sealing; all time reads and waits go
+// through it, so that tests can control time
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
@@ -0,0 +1,67 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestFakeClockMessageStuck(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{MessageWaitTimeout: time.Hour, Clock: clk})
+	h.api.dropMsgs = 1
+
+	h.start(stuckCommitSector(h))
+	h.waitState(1, CommitWait)
+	clk.waitTimers(t, 1)
+
+	clk.advance(time.Hour - time.Second)
+	require.Equal(t, CommitWait, h.sector(1).State)
+
+	clk.advance(time.Second)
+	si := h.waitState(1, Proving)
+	require.True(t, hasEvent(si, SectorMessageStuck{}))
+}
+
+func TestFakeClockPackingSweep(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{MaxWaitTime: time.Hour, PackingSweepInterval: time.Minute, Clock: clk})
+	require.NoError(t, h.m.Run(context.Background()))
+
+	h.addDeal(1, 256)
+	h.waitPieces(1, 1)
+
+	clk.waitTimers(t, 2) // stats refresh, packing sweep
+	clk.advance(time.Hour)
+	h.waitPacked(1)
+}
+
+// the wait is measured from the first piece recorded before a restart, not
+// from the restart
+func TestFakeClockPackingSweepAfterRestart(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{MaxWaitTime: time.Hour, PackingSweepInterval: time.Minute, Clock: clk})
+
+	h.setZeroDeal(1, 256)
+	h.put(SectorInfo{
+		State:        WaitDeals,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces: []Piece{{
+			Piece:    abi.PieceInfo{Size: 256, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(256).Unpadded())},
+			DealInfo: &DealInfo{DealID: 1},
+		}},
+		Log: []Log{{Kind: logKind(SectorAddPiece{}), Timestamp: uint64(clk.Now().Add(-50 * time.Minute).Unix())}},
+	})
+	require.NoError(t, h.m.Run(context.Background()))
+
+	clk.waitTimers(t, 2) // stats refresh, packing sweep
+	clk.advance(10 * time.Minute)
+	h.waitPacked(1)
+	require.Equal(t, []abi.DealID{1}, h.sector(1).dealIDs())
+}
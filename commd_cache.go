@@ -0,0 +1,121 @@
+package sealing
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+const defaultCommDCacheSize = 256
+
+// DataCommitmentKey identifies the data commitment of an ordered deal set.
+// It is a hash of the miner address, sector type and deal IDs, so any
+// change to the deal set, including its order, gives a different key
+type DataCommitmentKey [sha256.Size]byte
+
+func dataCommitmentKey(maddr address.Address, sectorType abi.RegisteredSealProof, deals []abi.DealID) DataCommitmentKey {
+	h := sha256.New()
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	h.Write(maddr.Bytes())                                  // nolint:errcheck
+	h.Write(buf[:binary.PutVarint(buf, int64(sectorType))]) // nolint:errcheck
+	for _, d := range deals {
+		h.Write(buf[:binary.PutUvarint(buf, uint64(d))]) // nolint:errcheck
+	}
+
+	var out DataCommitmentKey
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// DataCommitmentCache stores results of StateComputeDataCommitment. The
+// default one keeps the most recently used entries in memory, see
+// NewDataCommitmentCache
+type DataCommitmentCache interface {
+	Get(key DataCommitmentKey) (cid.Cid, bool)
+	Put(key DataCommitmentKey, commD cid.Cid)
+}
+
+type commDEntry struct {
+	key   DataCommitmentKey
+	commD cid.Cid
+}
+
+type memCommDCache struct {
+	size int
+
+	lk      sync.Mutex
+	entries map[DataCommitmentKey]*list.Element
+	lru     *list.List
+}
+
+// NewDataCommitmentCache returns an in-memory cache of `size` entries,
+// evicting the least recently used ones
+func NewDataCommitmentCache(size int) DataCommitmentCache {
+	return &memCommDCache{
+		size:    size,
+		entries: map[DataCommitmentKey]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+func (c *memCommDCache) Get(key DataCommitmentKey) (cid.Cid, bool) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return cid.Undef, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*commDEntry).commD, true
+}
+
+func (c *memCommDCache) Put(key DataCommitmentKey, commD cid.Cid) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*commDEntry).commD = commD
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&commDEntry{key: key, commD: commD})
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*commDEntry).key)
+	}
+}
+
+// commDCacheAPI serves StateComputeDataCommitment from a DataCommitmentCache.
+// Only successful results are cached
+type commDCacheAPI struct {
+	SealingAPI
+	cache DataCommitmentCache
+}
+
+func (a *commDCacheAPI) StateComputeDataCommitment(ctx context.Context, maddr address.Address, sectorType abi.RegisteredSealProof, deals []abi.DealID, tok TipSetToken) (cid.Cid, error) {
+	key := dataCommitmentKey(maddr, sectorType, deals)
+	if commD, ok := a.cache.Get(key); ok {
+		return commD, nil
+	}
+
+	commD, err := a.SealingAPI.StateComputeDataCommitment(ctx, maddr, sectorType, deals, tok)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	a.cache.Put(key, commD)
+	return commD, nil
+}
+
+var _ SealingAPI = &commDCacheAPI{}
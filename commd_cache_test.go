@@ -0,0 +1,76 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestDataCommitmentCache(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	ctx := context.Background()
+
+	calls := func() int {
+		h.api.lk.Lock()
+		defer h.api.lk.Unlock()
+		return h.api.commDCalls
+	}
+	compute := func(st abi.RegisteredSealProof, deals ...abi.DealID) error {
+		commD, err := h.m.api.StateComputeDataCommitment(ctx, h.m.maddr, st, deals, nil)
+		if err == nil {
+			require.Equal(t, testCommD, commD)
+		}
+		return err
+	}
+
+	require.NoError(t, compute(abi.RegisteredSealProof_StackedDrg2KiBV1, 1, 2))
+	require.NoError(t, compute(abi.RegisteredSealProof_StackedDrg2KiBV1, 1, 2))
+	require.Equal(t, 1, calls())
+
+	// different deal sets, orders and sector types miss
+	require.NoError(t, compute(abi.RegisteredSealProof_StackedDrg2KiBV1, 1, 2, 3))
+	require.NoError(t, compute(abi.RegisteredSealProof_StackedDrg2KiBV1, 2, 1))
+	require.NoError(t, compute(abi.RegisteredSealProof_StackedDrg8MiBV1, 1, 2))
+	require.Equal(t, 4, calls())
+
+	// errors aren't cached
+	h.api.lk.Lock()
+	h.api.commDErr = xerrors.New("node down")
+	h.api.lk.Unlock()
+	require.Error(t, compute(abi.RegisteredSealProof_StackedDrg2KiBV1, 4))
+
+	h.api.lk.Lock()
+	h.api.commDErr = nil
+	h.api.lk.Unlock()
+	require.NoError(t, compute(abi.RegisteredSealProof_StackedDrg2KiBV1, 4))
+	require.NoError(t, compute(abi.RegisteredSealProof_StackedDrg2KiBV1, 4))
+	require.Equal(t, 6, calls())
+}
+
+func TestDataCommitmentCacheEvicts(t *testing.T) {
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	c := NewDataCommitmentCache(2)
+	key := func(d abi.DealID) DataCommitmentKey {
+		return dataCommitmentKey(maddr, abi.RegisteredSealProof_StackedDrg2KiBV1, []abi.DealID{d})
+	}
+
+	c.Put(key(1), testCommD)
+	c.Put(key(2), testCommD)
+	_, ok := c.Get(key(1))
+	require.True(t, ok)
+
+	c.Put(key(3), testCommD)
+	_, ok = c.Get(key(2))
+	require.False(t, ok)
+	_, ok = c.Get(key(1))
+	require.True(t, ok)
+	_, ok = c.Get(key(3))
+	require.True(t, ok)
+}
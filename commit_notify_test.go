@@ -0,0 +1,121 @@
+package sealing
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// committedSectors records the calls to Config.OnSectorCommitted
+type committedSectors struct {
+	lk    sync.Mutex
+	calls []miner.SectorOnChainInfo
+}
+
+func (c *committedSectors) notify(sid abi.SectorNumber, info miner.SectorOnChainInfo) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.calls = append(c.calls, info)
+}
+
+func (c *committedSectors) called() []miner.SectorOnChainInfo {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return append([]miner.SectorOnChainInfo(nil), c.calls...)
+}
+
+// landedSector returns a sector whose commit landed, as it's persisted
+// before it's finalized
+func landedSector(h *testHarness, sn abi.SectorNumber) SectorInfo {
+	si := h.committingSector(sn)
+	si.State = FinalizeSector
+
+	h.api.lk.Lock()
+	h.api.sectors[sn] = &miner.SectorOnChainInfo{SectorNumber: sn, Activation: 20}
+	h.api.lk.Unlock()
+
+	return si
+}
+
+func TestCommittedNotify(t *testing.T) {
+	var committed committedSectors
+	h := newTestHarness(t, Config{OnSectorCommitted: committed.notify})
+
+	si := h.committingSector(1)
+	h.api.lk.Lock()
+	h.api.sectors[1] = &miner.SectorOnChainInfo{SectorNumber: 1, Activation: 20}
+	h.api.lk.Unlock()
+	h.start(si)
+
+	si = h.waitState(1, Proving)
+	require.True(t, si.CommitNotified)
+	calls := committed.called()
+	require.Len(t, calls, 1)
+	require.Equal(t, abi.SectorNumber(1), calls[0].SectorNumber)
+	require.Equal(t, abi.ChainEpoch(20), calls[0].Activation)
+}
+
+func TestCommittedNotifyAfterRestart(t *testing.T) {
+	var committed committedSectors
+	h := newTestHarness(t, Config{OnSectorCommitted: committed.notify})
+
+	// stopped after the commit landed, before the hook was called
+	h.start(landedSector(h, 1))
+	require.True(t, h.waitState(1, Proving).CommitNotified)
+	require.Len(t, committed.called(), 1)
+
+	// stopped after the hook was called, before the sector was finalized
+	si := landedSector(h, 2)
+	si.CommitNotified = true
+	h.start(si)
+	h.waitState(2, Proving)
+
+	calls := committed.called()
+	require.Len(t, calls, 1)
+	require.Equal(t, abi.SectorNumber(1), calls[0].SectorNumber)
+}
+
+func TestCommittedNotifyReverted(t *testing.T) {
+	var committed committedSectors
+	h := newTestHarness(t, Config{OnSectorCommitted: committed.notify})
+
+	// the commit lands again once it's resubmitted
+	h.api.waitMsg = func(cid.Cid) (MsgLookup, error) {
+		h.api.lk.Lock()
+		h.api.sectors[1] = &miner.SectorOnChainInfo{SectorNumber: 1, Activation: 30}
+		h.api.lk.Unlock()
+		return MsgLookup{Height: 30}, nil
+	}
+
+	// the commit is reverted before the hook was called
+	si := landedSector(h, 1)
+	h.api.lk.Lock()
+	delete(h.api.sectors, 1)
+	h.api.lk.Unlock()
+	h.start(si)
+
+	si = h.waitState(1, Proving)
+	require.True(t, hasEvent(si, SectorCommitReverted{}))
+	require.True(t, si.CommitNotified)
+
+	calls := committed.called()
+	require.Len(t, calls, 1)
+	require.Equal(t, abi.ChainEpoch(30), calls[0].Activation)
+
+	msgs := h.api.sentMsgs()
+	require.Len(t, msgs, 1)
+	require.Equal(t, builtin.MethodsMiner.ProveCommitSector, msgs[0].method)
+}
+
+func TestCommittedNotifyUnset(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.start(landedSector(h, 1))
+	require.False(t, h.waitState(1, Proving).CommitNotified)
+}
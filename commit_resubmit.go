@@ -0,0 +1,145 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// ResubmitCommit recomputes the commit proof of a sector whose ProveCommit
+// message was lost, against the seed currently on chain, and sends it again.
+// The proof sent before is reused if the seed didn't change.
+// Sectors whose precommit expired can't be committed anymore, and have to be
+// sealed from scratch.
+//
+// A sector waiting on the lost message picks up the new one once that wait
+// is given up, see Config.MessageWaitTimeout
+func (m *Sealing) ResubmitCommit(ctx context.Context, sid abi.SectorNumber) error {
+	sector, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return err
+	}
+
+	switch sector.State {
+	case CommitWait, CommitFailed, MessageStuck:
+	default:
+		return xerrors.Errorf("sector %d isn't waiting for its commit (state %s)", sid, sector.State)
+	}
+	if sector.CommD == nil || sector.CommR == nil {
+		return xerrors.Errorf("sector %d has no sealed commitments", sid)
+	}
+
+	tok, height, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return &ErrApi{xerrors.Errorf("getting chain head: %w", err)}
+	}
+
+	onChain, err := m.api.StateSectorGetInfo(ctx, m.maddr, sid, tok)
+	if err != nil {
+		return &ErrApi{xerrors.Errorf("getting sector info: %w", err)}
+	}
+	if onChain != nil {
+		return xerrors.Errorf("sector %d is already proven", sid)
+	}
+
+	pci, err := m.api.StateSectorPreCommitInfo(ctx, m.maddr, sid, tok)
+	if err != nil {
+		return &ErrApi{xerrors.Errorf("getting precommit info: %w", err)}
+	}
+	if pci == nil {
+		return &ErrNoPrecommit{xerrors.Errorf("precommit of sector %d not found on chain", sid)}
+	}
+	if preCommitExpired(sector.SectorType, pci, height) {
+		return &ErrPreCommitExpired{xerrors.Errorf("precommit of sector %d expired at epoch %d, the sector has to be sealed again", sid, pci.PreCommitEpoch+miner.MaxSealDuration[sector.SectorType])}
+	}
+
+	seedEpoch := m.commitSeedEpoch(pci)
+	if height < seedEpoch {
+		return xerrors.Errorf("seed of sector %d isn't available until epoch %d", sid, seedEpoch)
+	}
+
+	entropy, err := m.entropy.SeedEntropy(m.maddr, sid)
+	if err != nil {
+		return err
+	}
+	seed, err := m.api.ChainGetRandomness(ctx, tok, crypto.DomainSeparationTag_InteractiveSealChallengeSeed, seedEpoch, entropy)
+	if err != nil {
+		return &ErrApi{xerrors.Errorf("getting seed randomness: %w", err)}
+	}
+	if seedEpoch != sector.SeedEpoch || !bytes.Equal(seed, sector.SeedValue) {
+		sector.Proof = nil // computed for another seed
+	}
+	sector.SeedValue = abi.InteractiveSealRandomness(seed)
+	sector.SeedEpoch = seedEpoch
+
+	proof, cached := sector.cachedProof()
+	if cached {
+		m.sectorLog(sid).Warnf("resending the commit proof computed before, lost message: %s", sector.CommitMessage)
+	} else {
+		m.sectorLog(sid).Warnf("recomputing commit proof, lost message: %s", sector.CommitMessage)
+
+		cids := storage.SectorCids{
+			Unsealed: *sector.CommD,
+			Sealed:   *sector.CommR,
+		}
+		c2in, err := m.sealCommit1(ctx, sector, cids)
+		if err != nil {
+			return xerrors.Errorf("computing seal proof failed(1): %w", err)
+		}
+
+		if err := m.c2Limit.acquireOrdered(ctx, sector.limitOrder()); err != nil {
+			return err
+		}
+		sctx, done := m.jobCtx(ctx, sector)
+		proof, err = m.sealer.SealCommit2(sctx, m.minerSector(sid), c2in)
+		done()
+		m.c2Limit.release()
+		if err != nil {
+			return xerrors.Errorf("computing seal proof failed(2): %w", err)
+		}
+	}
+
+	if err := m.checkCommit(ctx, sector, proof, tok); err != nil {
+		return xerrors.Errorf("commit check error: %w", err)
+	}
+
+	params := &miner.ProveCommitSectorParams{
+		SectorNumber: sid,
+		Proof:        proof,
+	}
+
+	enc := new(bytes.Buffer)
+	if err := params.MarshalCBOR(enc); err != nil {
+		return xerrors.Errorf("could not serialize commit sector parameters: %w", err)
+	}
+
+	waddr, err := m.api.StateMinerWorkerAddress(ctx, m.maddr, tok)
+	if err != nil {
+		return &ErrApi{xerrors.Errorf("getting worker address: %w", err)}
+	}
+
+	collateral, err := m.commitCollateral(ctx, sid, tok)
+	if err != nil {
+		return err
+	}
+
+	gasPrice, gasLimit := m.messageGas(ctx, waddr, m.maddr, builtin.MethodsMiner.ProveCommitSector, collateral, enc.Bytes())
+	mcid, err := m.sendMsg(ctx, waddr, m.maddr, builtin.MethodsMiner.ProveCommitSector, collateral, gasPrice, gasLimit, enc.Bytes())
+	if err != nil {
+		return xerrors.Errorf("pushing message to mpool: %w", err)
+	}
+
+	return m.send(sid, SectorCommitResubmitted{
+		Proof:     proof,
+		Message:   mcid,
+		SeedValue: sector.SeedValue,
+		SeedEpoch: sector.SeedEpoch,
+	})
+}
@@ -0,0 +1,101 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// lostCommitSector is a precommitted sector whose commit message never landed
+func lostCommitSector(h *testHarness) SectorInfo {
+	si := h.committingSector(1)
+	si.State = CommitFailed
+	lost := testCommR
+	si.CommitMessage = &lost
+	si.Proof = []byte{1}
+
+	h.api.lk.Lock()
+	delete(h.api.sectors, 1)
+	h.api.lk.Unlock()
+	h.api.setHead(10 + miner.PreCommitChallengeDelay + 10)
+
+	return si
+}
+
+func TestResubmitCommit(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		return storage.Proof{2}, nil
+	}
+	h.put(lostCommitSector(h))
+
+	require.NoError(t, h.m.ResubmitCommit(context.Background(), 1))
+
+	sent := h.api.sentMsgs()
+	require.Len(t, sent, 1)
+	require.Equal(t, builtin.MethodsMiner.ProveCommitSector, sent[0].method)
+
+	si := h.waitState(1, Proving)
+	require.Equal(t, []byte{2}, si.Proof)
+	require.Equal(t, builtin.CronActorCodeID, *si.CommitMessage)
+	require.Equal(t, 10+miner.PreCommitChallengeDelay, si.SeedEpoch)
+}
+
+func TestResubmitCommitExpired(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		t.Error("computed a proof for an expired precommit")
+		return nil, nil
+	}
+	si := lostCommitSector(h)
+	h.api.setHead(10 + miner.MaxSealDuration[si.SectorType] + 1)
+	h.put(si)
+
+	err := h.m.ResubmitCommit(context.Background(), 1)
+	require.True(t, xerrors.As(err, new(*ErrPreCommitExpired)), "%+v", err)
+	require.Empty(t, h.api.sentMsgs())
+	require.Equal(t, CommitFailed, h.sector(1).State)
+}
+
+func TestResubmitCommitCachedProof(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		t.Error("recomputed a proof for an unchanged seed")
+		return nil, nil
+	}
+	si := lostCommitSector(h)
+	si.ProofSeedEpoch = si.SeedEpoch
+	si.ProofCommR = si.CommR
+	h.put(si)
+
+	require.NoError(t, h.m.ResubmitCommit(context.Background(), 1))
+	require.Len(t, h.api.sentMsgs(), 1)
+
+	si = h.waitState(1, Proving)
+	require.Equal(t, []byte{1}, si.Proof)
+}
+
+func TestResubmitCommitSeedChanged(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		return storage.Proof{2}, nil
+	}
+	si := lostCommitSector(h)
+	si.SeedValue = abi.InteractiveSealRandomness{9} // the chain has testRand
+	si.ProofSeedEpoch = si.SeedEpoch
+	si.ProofCommR = si.CommR
+	h.put(si)
+
+	require.NoError(t, h.m.ResubmitCommit(context.Background(), 1))
+
+	si = h.waitState(1, Proving)
+	require.Equal(t, []byte{2}, si.Proof)
+	require.Equal(t, abi.InteractiveSealRandomness(testRand), si.SeedValue)
+}
@@ -0,0 +1,377 @@
+package sealing
+
+import (
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+const defaultChainBackoff = 1 * time.Minute
+
+const defaultCommitHoldMargin = abi.ChainEpoch(1000)
+
+// Config holds the optional tunables of a Sealing instance. The zero value of
+// every field keeps the default behaviour, so callers only need to set what
+// they want to change.
+type Config struct {
+	// CommitDeadlinePolicy, when set, is consulted before a ProveCommit
+	// message is submitted, and may hold the sector back to spread new sectors
+	// more evenly across proving deadlines
+	CommitDeadlinePolicy CommitDeadlinePolicy
+
+	// MaxConcurrentPreCommit1 and MaxConcurrentPreCommit2 limit how many
+	// sectors run PreCommit1 and PreCommit2 at once, independently, e.g. to
+	// queue many sectors for PreCommit1 on the CPU while fewer run PreCommit2
+	// on the GPU. Sectors stay in the phase until a slot frees up, in the
+	// same order as for MaxConcurrentCommit2. 0 means no limit
+	MaxConcurrentPreCommit1 int
+	MaxConcurrentPreCommit2 int
+
+	// MaxConcurrentCommit2 limits how many sectors can compute the commit
+	// proof (SealCommit2) at once. Sectors ready for it queue until a slot
+	// frees up, sectors with a higher priority (see SetSectorPriority) go
+	// first, then sectors with the earliest deal start. 0 means no limit
+	MaxConcurrentCommit2 int
+
+	// MaxConcurrentAddPiece limits how many pieces, of deals and CC sectors,
+	// are written by the sealer at once. 0 means no limit
+	MaxConcurrentAddPiece int
+
+	// SectorLocator is used on startup to check that in-flight sectors can
+	// still find their files. When nil, the sealer's CheckProvable is used,
+	// which only checks sealed and cache files
+	SectorLocator SectorFileLocator
+
+	// DropExpiredDeals makes sectors whose deals all started before sealing
+	// began get repacked with filler data, instead of being moved to the
+	// DealsExpired state
+	DropExpiredDeals bool
+
+	// CheckUnsealedPieces enables reading back all deal pieces from the
+	// unsealed sector copy, and checking them against their PieceCIDs, before
+	// the unsealed copy is dropped in FinalizeSector. This reads all deal data
+	// in the sector, so it's off by default
+	CheckUnsealedPieces bool
+
+	// ChainFailureThreshold is the number of consecutive failed chain calls
+	// after which the chain node is considered unavailable, and all chain calls
+	// are held back for ChainBackoff (1 minute if not set) to let the node
+	// recover. Only calls the node couldn't serve count, i.e. errors wrapping
+	// ErrNodeUnreachable or network errors; calls the node rejected, e.g. for
+	// an unknown deal or an invalid message, don't. 0 disables this
+	ChainFailureThreshold int
+	ChainBackoff          time.Duration
+
+	// HoldCommits parks sectors in CommitHold once their precommit lands, until
+	// CommitSector is called for them. Sectors are committed anyway
+	// CommitHoldMargin epochs (1000 if not set) before the prove-commit
+	// deadline, so that the precommit deposit isn't lost. The margin has to
+	// cover commit proof computation and message inclusion. Past that point the
+	// CommitDeadlinePolicy doesn't hold sectors back either. Held sectors are
+	// reported in Health another CommitHoldMargin epochs before they are
+	// committed
+	HoldCommits      bool
+	CommitHoldMargin abi.ChainEpoch
+
+	// ExternalTickets makes sectors use seal tickets from TicketProvider
+	// instead of chain randomness. Tickets are still checked to be usable for
+	// precommit at the current height. A ticket which doesn't match chain
+	// randomness at its epoch produces a sector which can't be precommitted,
+	// so this is meant for testing and specialized setups. Sectors fail
+	// PreCommit1 if TicketProvider isn't set
+	ExternalTickets bool
+	TicketProvider  TicketProvider
+
+	// Entropy builds the entropy of chain randomness drawn for tickets and
+	// seeds, FilecoinEntropy if not set. Only needed for networks whose miner
+	// actor uses other entropy
+	Entropy EntropyBuilder
+
+	// MinSectorExpiration is the shortest lifetime sectors are precommitted
+	// with, in epochs past the current height. Shorter expirations set by the
+	// PreCommitPolicy are extended by whole proving periods, up to
+	// MaxSectorLifetime. Off if not set
+	MinSectorExpiration abi.ChainEpoch
+
+	// MaxPreCommitRandomnessLookback is how many epochs the chain head can be
+	// past the seal height of a sector (its ticket epoch +
+	// SealRandomnessLookback) when sending its precommit. Sectors with older
+	// tickets draw a new one, and redo PreCommit1 and PreCommit2.
+	// SealRandomnessLookbackLimit of the sector type, enforced by the miner
+	// actor, if not set; lower values leave time for the precommit to land
+	MaxPreCommitRandomnessLookback abi.ChainEpoch
+
+	// DiskSpaceProbe is used to check that there is enough free space before
+	// starting PreCommit1 and PreCommit2. When nil, the SectorManager is used
+	// if it implements DiskSpaceProbe, otherwise disk space isn't checked.
+	// Sectors which don't have enough space to start are moved to WaitDisk,
+	// and checked again every DiskRecheckInterval (1 minute if not set)
+	DiskSpaceProbe      DiskSpaceProbe
+	DiskRecheckInterval time.Duration
+	// DiskSafetyMargin is the number of bytes which should be left free on
+	// top of what the phase is expected to write
+	DiskSafetyMargin uint64
+	// NewSectorHeadroom is the multiple of the disk space sealing a sector
+	// uses which must be free, as reported by the DiskSpaceProbe, for a new
+	// sector to be created (1 if not set). Sector creation fails with
+	// ErrInsufficientStorage otherwise
+	NewSectorHeadroom float64
+
+	// Finalize controls which sector files are kept when finalizing sectors
+	Finalize FinalizePolicy
+
+	// DatastorePrefix is the datastore namespace sector records
+	// (SectorStorePrefix) and timings (SectorTimingsPrefix) are kept under,
+	// the datastore root if not set. With DatastorePerMiner they are also
+	// namespaced by the miner address, so that multiple miners can share a
+	// datastore
+	DatastorePrefix   string
+	DatastorePerMiner bool
+
+	// StateWriteBatchInterval coalesces sector record writes made within the
+	// interval into one datastore batch. State handlers wait for the record
+	// of their state to be committed before running, so a crash can only lose
+	// transitions nothing acted on yet. Records are written one by one if not
+	// set
+	StateWriteBatchInterval time.Duration
+
+	// JournalEvents records every event sent to a sector from outside of its
+	// state handlers, readable with SectorJournal. Off by default, as it adds
+	// a datastore write to every event
+	JournalEvents bool
+
+	// SealerIntents records an intent before AddPiece, finalize, MoveStorage
+	// and remove calls to the sealer, which change sector files outside of
+	// the sector record, and drops it once the call returns. Intents left
+	// over by a crash are reconciled on startup, see DanglingIntents
+	SealerIntents bool
+
+	// TimingRetention is the number of most recently finished sectors whose
+	// phase timings are kept for Stats (1000 if not set). Older timing records
+	// are pruned every MetricsRefreshInterval (10 minutes if not set), when
+	// the averages are recomputed
+	TimingRetention        int
+	MetricsRefreshInterval time.Duration
+
+	// DealIndexer, when set, is given the locations of all deals in sectors
+	// which reach Proving, for retrieval indexing
+	DealIndexer DealIndexer
+
+	// OnSectorCommitted, when set, is called once the commit of a sector is
+	// confirmed and the sector is on chain, before it's finalized. It's
+	// called once per sector, also across restarts, unless the node stops
+	// before it's recorded. Sectors whose commit is reverted before the call
+	// are committed again. The sealing of the sector waits for it to return
+	OnSectorCommitted func(sid abi.SectorNumber, info miner.SectorOnChainInfo)
+
+	// FailedSectorHandler, when set, is told about the deals of sectors
+	// entering a state they can't be sealed from, like PackingFailed or
+	// DealsExpired, see OnSectorFailed
+	FailedSectorHandler FailedSectorHandler
+
+	// RequeueFailedDeals adds the deals of such sectors which didn't start
+	// yet to other sectors with AddPieceToAnySector, reading their data with
+	// DealInfo.Reopen. Deals without Reopen are only passed to the
+	// FailedSectorHandler
+	RequeueFailedDeals bool
+
+	// MessageRateLimit is the maximum number of chain messages sent per
+	// MessageRateInterval (1 minute if not set). Sends are spaced out evenly
+	// over the interval, sectors ready to send a message wait for their turn.
+	// 0 means no limit
+	MessageRateLimit    int
+	MessageRateInterval time.Duration
+
+	// PackingStrategy picks the sector new deals are added to, when more than
+	// one sector has room for them. FirstFit by default. With a sealer
+	// implementing StorageLocality, sectors local to the worker writing the
+	// piece are picked from first
+	PackingStrategy PackingStrategy
+
+	// MaxWaitDealsSectors limits how many sectors accept deals at once. When
+	// none of them has room for a new piece, AddPieceToAnySector waits for
+	// one to start packing, or returns ErrTooManyOpenSectors if
+	// RejectOverWaitDealsLimit is set. 0 means no limit
+	MaxWaitDealsSectors      int
+	RejectOverWaitDealsLimit bool
+
+	// UnverifiedDealSectors limits how many sectors accept unverified deals
+	// at once, the rest of MaxWaitDealsSectors is left for verified deals.
+	// With the limit set, sectors accept either verified or unverified deals.
+	// Unverified deals over the limit wait like deals over
+	// MaxWaitDealsSectors, or get ErrQuotaExhausted if
+	// RejectOverWaitDealsLimit is set. 0 means no limit
+	UnverifiedDealSectors int
+
+	// SectorSizes are the sizes sectors accepting deals can have. Pieces
+	// needing a new sector get the smallest size they fit in. The sealer has
+	// to handle sectors of all of them. Only the sealer sector size if not
+	// set
+	SectorSizes []abi.SectorSize
+
+	// SealProofType is the seal proof sectors of the sealer sector size are
+	// created with, e.g. a newer proof version of the same size. Derived from
+	// the sector size if not set
+	SealProofType *abi.RegisteredSealProof
+
+	// TrustPrecomputedPieces skips checking piece commitments passed to
+	// AddPrecomputedPiece against the piece data, for sealers implementing
+	// PrecomputedPieceSealer. Other sealers compute them anyway, so they're
+	// always checked
+	TrustPrecomputedPieces bool
+
+	// SectorsPerMinute paces the creation of sectors accepting deals, with
+	// up to SectorBurst (1 if not set) created at once. Pieces needing a new
+	// sector wait for their turn. 0 means no limit
+	SectorsPerMinute int
+	SectorBurst      int
+
+	// RejectEmptySectors makes StartPacking fail with *ErrEmptySector for
+	// sectors without pieces, instead of sealing them filled with filler
+	// pieces like CC sectors
+	RejectEmptySectors bool
+
+	// MaxPiecesPerSector limits how many pieces a sector holds, counting the
+	// padding pieces written before deals which need alignment. A sector
+	// reaching it starts packing; the filler pieces added then don't count.
+	// 0 means no limit
+	MaxPiecesPerSector int
+
+	// MaxFee caps gasPrice * gasLimit of chain messages. When the gas estimate
+	// is above it, the gas price is lowered to fit. Not capped if not set
+	MaxFee abi.TokenAmount
+
+	// RandomnessCacheSize is the number of chain randomness lookups to cache.
+	// Only randomness at epochs past chain finality is cached. 0 disables the
+	// cache
+	RandomnessCacheSize int
+
+	// DataCommitmentCache stores data commitments computed for sector deal
+	// sets. An in-memory cache of defaultCommDCacheSize entries if not set
+	DataCommitmentCache DataCommitmentCache
+
+	// SendRetry retries PreCommit and ProveCommit messages which failed to
+	// send, instead of moving the sector to a failed state right away. Not
+	// retried if not set
+	SendRetry RetryPolicy
+
+	// Metrics receives sector state transitions and added pieces. See
+	// StateMetrics for an implementation
+	Metrics SealingMetrics
+
+	// SealerCapacity is asked whether the sealer can take another piece
+	// before AddPiece calls reserve space in a sector. When nil, the
+	// SectorManager is used if it implements SealerCapacity, otherwise pieces
+	// are always handed to the sealer. While the sealer is busy, AddPiece calls
+	// wait, checking again every SealerBusyRecheck (10 seconds if not set), or
+	// fail with *ErrSealerBusy if RejectWhenSealerBusy is set
+	SealerCapacity       SealerCapacity
+	SealerBusyRecheck    time.Duration
+	RejectWhenSealerBusy bool
+
+	// UnsealedPieces reports the pieces written to unsealed sectors. When
+	// set, or implemented by the SectorManager, sectors accepting deals are
+	// checked against it on startup, to recover pieces which were being
+	// written when the node went down, see InterruptedDeals
+	UnsealedPieces UnsealedPieceReader
+
+	// PieceAcceptance is asked to accept every deal piece before it's
+	// written, AddPiece calls fail with its error otherwise. AcceptAllPieces
+	// if not set
+	PieceAcceptance PieceAcceptancePolicy
+
+	// PledgeShortfallThreshold is how much the available miner balance may be
+	// short of the initial pledge of a sector, for it to still be
+	// precommitted. Sectors with a bigger shortfall wait in
+	// PledgeInsufficient. 0 if not set
+	PledgeShortfallThreshold abi.TokenAmount
+	// PledgeRecheckInterval is how often the balance of PledgeInsufficient
+	// sectors is checked again (defaultPledgeRecheck if not set)
+	PledgeRecheckInterval time.Duration
+
+	// CommitCollateralOverride is the value sent with ProveCommit messages
+	// instead of the initial pledge of the sector, e.g. zero when the miner
+	// actor balance is funded beforehand. The available balance has to cover
+	// the rest of the pledge, otherwise the sector moves to CommitFailed and
+	// retries. The initial pledge is sent if not set
+	CommitCollateralOverride abi.TokenAmount
+
+	// MessageWaitTimeout is how long PreCommitWait and CommitWait wait for
+	// their message to land on chain. Sectors whose message doesn't land in
+	// time move to MessageStuck, which resends it. Waits forever if not set
+	MessageWaitTimeout time.Duration
+
+	// MessageLookupRecheck is how often PreCommitWait and CommitWait look up
+	// their message again while the node doesn't know it yet (30 seconds if
+	// not set). Lookups which failed to reach the node are retried with the
+	// delays of SendRetry
+	MessageLookupRecheck time.Duration
+
+	// CommitWaitConfidence is how many epochs past the inclusion of the commit
+	// message CommitWait waits before moving the sector on. Sectors whose
+	// commit is reverted in the meantime resubmit it. 0 if not set
+	CommitWaitConfidence abi.ChainEpoch
+
+	// CCBackfillInterval is how often StartCCBackfill checks the number of
+	// sealing CC sectors (defaultCCBackfillInterval if not set)
+	CCBackfillInterval time.Duration
+
+	// MinSectorFillRatio starts packing sectors accepting deals once their
+	// pieces take up at least this part of the sector; MaxWaitTime once their
+	// first piece was added that long ago. The rest of the sector is filled
+	// with filler pieces. Sectors are checked every PackingSweepInterval
+	// (defaultPackingSweepInterval if not set). Both are off if not set.
+	// MaxWaitTime is the per-sector deal wait deadline, there is no separate
+	// MaxDealWaitTime: the first piece time is kept in the sector log across
+	// restarts, and compared against Clock
+	MinSectorFillRatio   float64
+	MaxWaitTime          time.Duration
+	PackingSweepInterval time.Duration
+
+	// SealDurationEstimate is how many epochs it takes to seal and commit a
+	// sector of the given proof type. Pieces of deals starting before the
+	// current height plus the estimate are rejected with
+	// *ErrDealStartTooSoon. Not checked for proof types not set here
+	SealDurationEstimate map[abi.RegisteredSealProof]abi.ChainEpoch
+
+	// PhaseEstimates are the expected sealing phase durations
+	// EstimateSealCompletion is based on
+	PhaseEstimates PhaseEstimates
+
+	// SkipCommitVerification disables verifying commit proofs before they are
+	// submitted. Proofs are verified by default, sectors with a proof which
+	// doesn't verify move to BadProof instead of submitting it, and it's
+	// recomputed once. Verification takes a while, so it can be disabled when
+	// the prover is trusted
+	SkipCommitVerification bool
+
+	// SealMode is the commit proof generation mode of new sectors,
+	// SealModeClassic if not set. SealModeSynthetic needs a sealer
+	// implementing SyntheticSealer
+	SealMode SealMode
+
+	// GCAge is how long a sector has to be in one of GCStates, for
+	// GarbageCollect to remove it (defaultGCAge if not set). GCStates is
+	// defaultGCStates if not set
+	GCAge    time.Duration
+	GCStates []SectorState
+
+	// Clock is used for all time reads and waits. The system clock if not
+	// set
+	Clock Clock
+
+	// Priorities are the scheduling priorities of sealer work writing pieces
+	Priorities SectorPriorities
+}
+
+// SectorPriorities sets the priority of AddPiece calls in the sealer
+// scheduler
+type SectorPriorities struct {
+	// Deals is used for deal pieces, and padding between them
+	// (DealSectorPriority if not set)
+	Deals int
+	// CC is used for pieces of pledged, committed capacity sectors
+	// (the scheduler default if not set)
+	CC int
+}
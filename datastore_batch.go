@@ -0,0 +1,205 @@
+package sealing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// batchedDatastore coalesces writes made within an interval into a single
+// batch, see Config.StateWriteBatchInterval. Reads see the pending writes
+type batchedDatastore struct {
+	datastore.Batching
+
+	interval time.Duration
+	clock    Clock
+
+	// held while committing, so that reads don't miss writes which left
+	// pending but aren't committed yet
+	lk      sync.Mutex
+	pending map[datastore.Key]pendingWrite
+	next    *batchFlush // commit of the pending writes, nil if none are pending
+}
+
+type pendingWrite struct {
+	value   []byte
+	deleted bool
+}
+
+// batchFlush is closed once the writes pending when it was scheduled are
+// committed, or failed to be
+type batchFlush struct {
+	done chan struct{}
+	err  error
+}
+
+func newBatchedDatastore(ds datastore.Batching, interval time.Duration, clock Clock) *batchedDatastore {
+	return &batchedDatastore{
+		Batching: ds,
+		interval: interval,
+		clock:    clock,
+		pending:  map[datastore.Key]pendingWrite{},
+	}
+}
+
+// add queues a write, and schedules a flush if it's the first pending one.
+// Must be called with lk held
+func (d *batchedDatastore) add(key datastore.Key, w pendingWrite) {
+	d.pending[key] = w
+	if d.next == nil {
+		d.schedule()
+	}
+}
+
+// schedule flushes the pending writes after the interval. Must be called with
+// lk held
+func (d *batchedDatastore) schedule() {
+	d.next = &batchFlush{done: make(chan struct{})}
+	go func() {
+		<-d.clock.After(d.interval)
+		if err := d.flush(); err != nil {
+			log.Errorf("writing sector records: %+v", err)
+		}
+	}()
+}
+
+func (d *batchedDatastore) Put(key datastore.Key, value []byte) error {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	d.add(key, pendingWrite{value: value})
+	return nil
+}
+
+func (d *batchedDatastore) Delete(key datastore.Key) error {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	d.add(key, pendingWrite{deleted: true})
+	return nil
+}
+
+func (d *batchedDatastore) Get(key datastore.Key) ([]byte, error) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	if w, ok := d.pending[key]; ok {
+		if w.deleted {
+			return nil, datastore.ErrNotFound
+		}
+		return w.value, nil
+	}
+	return d.Batching.Get(key)
+}
+
+func (d *batchedDatastore) Has(key datastore.Key) (bool, error) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	if w, ok := d.pending[key]; ok {
+		return !w.deleted, nil
+	}
+	return d.Batching.Has(key)
+}
+
+func (d *batchedDatastore) GetSize(key datastore.Key) (int, error) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	if w, ok := d.pending[key]; ok {
+		if w.deleted {
+			return -1, datastore.ErrNotFound
+		}
+		return len(w.value), nil
+	}
+	return d.Batching.GetSize(key)
+}
+
+// Query commits the pending writes first, so that they are listed
+func (d *batchedDatastore) Query(q query.Query) (query.Results, error) {
+	if err := d.flush(); err != nil {
+		return nil, err
+	}
+	return d.Batching.Query(q)
+}
+
+func (d *batchedDatastore) Sync(prefix datastore.Key) error {
+	if err := d.flush(); err != nil {
+		return err
+	}
+	return d.Batching.Sync(prefix)
+}
+
+func (d *batchedDatastore) Batch() (datastore.Batch, error) {
+	if err := d.flush(); err != nil {
+		return nil, err
+	}
+	return d.Batching.Batch()
+}
+
+func (d *batchedDatastore) Close() error {
+	if err := d.flush(); err != nil {
+		return err
+	}
+	return d.Batching.Close()
+}
+
+// flush commits the pending writes now. Writes which failed to be committed
+// stay pending, and are retried after the interval
+func (d *batchedDatastore) flush() error {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	f := d.next
+	if f == nil {
+		return nil
+	}
+	d.next = nil
+
+	f.err = d.commit()
+	close(f.done)
+
+	if f.err != nil {
+		d.schedule()
+		return f.err
+	}
+
+	d.pending = map[datastore.Key]pendingWrite{}
+	return nil
+}
+
+func (d *batchedDatastore) commit() error {
+	b, err := d.Batching.Batch()
+	if err != nil {
+		return err
+	}
+
+	for key, w := range d.pending {
+		if w.deleted {
+			err = b.Delete(key)
+		} else {
+			err = b.Put(key, w.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.Commit()
+}
+
+// sync waits until the writes pending now are committed
+func (d *batchedDatastore) sync() error {
+	d.lk.Lock()
+	f := d.next
+	d.lk.Unlock()
+
+	if f == nil {
+		return nil
+	}
+
+	<-f.done
+	return f.err
+}
@@ -0,0 +1,114 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchedDatastoreFlush(t *testing.T) {
+	base := dssync.MutexWrap(datastore.NewMapDatastore())
+	ds := newBatchedDatastore(base, time.Hour, realClock{})
+
+	a, b := datastore.NewKey("a"), datastore.NewKey("b")
+	require.NoError(t, base.Put(b, []byte("old")))
+
+	require.NoError(t, ds.Put(a, []byte("1")))
+	require.NoError(t, ds.Delete(b))
+
+	// pending writes are visible, but not written yet
+	v, err := ds.Get(a)
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+	_, err = ds.Get(b)
+	require.Equal(t, datastore.ErrNotFound, err)
+
+	has, err := base.Has(a)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	require.NoError(t, ds.flush())
+
+	v, err = base.Get(a)
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+	has, err = base.Has(b)
+	require.NoError(t, err)
+	require.False(t, has)
+}
+
+func TestBatchedDatastoreSync(t *testing.T) {
+	base := dssync.MutexWrap(datastore.NewMapDatastore())
+	ds := newBatchedDatastore(base, 5*time.Millisecond, realClock{})
+
+	require.NoError(t, ds.sync()) // nothing pending
+
+	require.NoError(t, ds.Put(datastore.NewKey("a"), []byte("1")))
+	require.NoError(t, ds.sync())
+
+	has, err := base.Has(datastore.NewKey("a"))
+	require.NoError(t, err)
+	require.True(t, has)
+}
+
+func TestBatchedStateWrites(t *testing.T) {
+	h := newTestHarness(t, Config{StateWriteBatchInterval: 5 * time.Millisecond})
+
+	h.start(h.committingSector(1))
+	h.waitState(1, Proving)
+	require.NoError(t, h.m.Stop(context.Background()))
+
+	// the final state is durable in the underlying datastore
+	prefix, _ := datastoreKeys(h.m.cfg, h.m.maddr)
+	b, err := h.ds.Get(prefix.ChildString("1"))
+	require.NoError(t, err)
+
+	var si SectorInfo
+	require.NoError(t, si.UnmarshalCBOR(bytes.NewReader(b)))
+	require.Equal(t, Proving, si.State)
+}
+
+func benchmarkStateWrites(b *testing.B, batched bool) {
+	var ds datastore.Batching = dssync.MutexWrap(datastore.NewMapDatastore())
+	wait := func() error { return nil }
+	if batched {
+		bds := newBatchedDatastore(ds, time.Millisecond, realClock{})
+		ds, wait = bds, bds.sync
+	}
+
+	const sectors = 64
+	value := make([]byte, 1024)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for s := 0; s < sectors; s++ {
+			wg.Add(1)
+			go func(s int) {
+				defer wg.Done()
+				if err := ds.Put(datastore.NewKey(fmt.Sprint(s)), value); err != nil {
+					b.Error(err)
+				}
+				if err := wait(); err != nil {
+					b.Error(err)
+				}
+			}(s)
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkStateWritesDirect(b *testing.B) {
+	benchmarkStateWrites(b, false)
+}
+
+func BenchmarkStateWritesBatched(b *testing.B) {
+	benchmarkStateWrites(b, true)
+}
@@ -0,0 +1,86 @@
+package sealing
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// number of largest records reported by DatastoreStats
+const dsStatsLargest = 10
+
+// DSStats describes datastore usage of the sector state records
+type DSStats struct {
+	Records int
+	// Bytes is the total size of record values; key and datastore overhead
+	// isn't included
+	Bytes int64
+
+	// Largest lists the biggest records, largest first
+	Largest []DSRecord
+}
+
+// DSRecord is a single sector state record
+type DSRecord struct {
+	SectorNumber abi.SectorNumber
+	Size         int
+}
+
+// DatastoreStats scans the sector state records, without decoding them
+func (m *Sealing) DatastoreStats() (DSStats, error) {
+	res, err := m.ds.Query(query.Query{KeysOnly: true, ReturnsSizes: true})
+	if err != nil {
+		return DSStats{}, xerrors.Errorf("querying sector records: %w", err)
+	}
+	defer res.Close() // nolint:errcheck
+
+	var out DSStats
+	for r := range res.Next() {
+		if r.Error != nil {
+			return DSStats{}, xerrors.Errorf("iterating sector records: %w", r.Error)
+		}
+
+		size := r.Size
+		if size < 0 {
+			size, err = m.ds.GetSize(datastore.NewKey(r.Key))
+			if err != nil {
+				return DSStats{}, xerrors.Errorf("getting size of record %s: %w", r.Key, err)
+			}
+		}
+
+		sn, err := strconv.ParseUint(strings.TrimPrefix(r.Key, "/"), 10, 64)
+		if err != nil {
+			return DSStats{}, xerrors.Errorf("parsing sector number from key %s: %w", r.Key, err)
+		}
+
+		out.Records++
+		out.Bytes += int64(size)
+		out.Largest = addLargest(out.Largest, DSRecord{SectorNumber: abi.SectorNumber(sn), Size: size})
+	}
+
+	return out, nil
+}
+
+func addLargest(l []DSRecord, r DSRecord) []DSRecord {
+	if len(l) == dsStatsLargest && l[len(l)-1].Size >= r.Size {
+		return l
+	}
+
+	i := sort.Search(len(l), func(i int) bool {
+		return l[i].Size < r.Size
+	})
+
+	if len(l) < dsStatsLargest {
+		l = append(l, DSRecord{})
+	}
+	copy(l[i+1:], l[i:])
+	l[i] = r
+
+	return l
+}
@@ -0,0 +1,46 @@
+package sealing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestDatastoreStats(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	stats, err := h.m.DatastoreStats()
+	require.NoError(t, err)
+	require.Equal(t, DSStats{}, stats)
+
+	var total int64
+	for sn := abi.SectorNumber(1); sn <= 12; sn++ {
+		si := SectorInfo{State: Proving, SectorNumber: sn}
+		for i := abi.SectorNumber(0); i < sn%5; i++ {
+			si.Log = append(si.Log, Log{Kind: "event;sealing.SectorRestart", Message: "{}"})
+		}
+		if sn == 7 {
+			si.Log = make([]Log, 100)
+		}
+
+		b, err := cborutil.Dump(&si)
+		require.NoError(t, err)
+		total += int64(len(b))
+
+		h.put(si)
+	}
+
+	stats, err = h.m.DatastoreStats()
+	require.NoError(t, err)
+	require.Equal(t, 12, stats.Records)
+	require.Equal(t, total, stats.Bytes)
+
+	require.Len(t, stats.Largest, dsStatsLargest)
+	require.Equal(t, abi.SectorNumber(7), stats.Largest[0].SectorNumber)
+	for i := 1; i < len(stats.Largest); i++ {
+		require.GreaterOrEqual(t, stats.Largest[i-1].Size, stats.Largest[i].Size)
+	}
+}
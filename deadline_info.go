@@ -0,0 +1,103 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// sectorDeadline finds the proving deadline of the sector, and the partition
+// of the deadline it's in. ok is false for sectors which aren't assigned to a
+// deadline yet
+func sectorDeadline(dls *miner.Deadlines, sector SectorInfo) (dl uint64, partition uint64, ok bool, err error) {
+	sn := uint64(sector.SectorNumber)
+
+	for i, due := range dls.Due {
+		if due == nil {
+			continue
+		}
+
+		set, err := due.IsSet(sn)
+		if err != nil {
+			return 0, 0, false, xerrors.Errorf("checking deadline %d: %w", i, err)
+		}
+		if !set {
+			continue
+		}
+
+		partSize, err := sector.SectorType.WindowPoStPartitionSectors()
+		if err != nil {
+			return 0, 0, false, xerrors.Errorf("getting partition size: %w", err)
+		}
+
+		// partitions are filled with the deadline sectors in order
+		var pos uint64
+		if err := due.ForEach(func(s uint64) error {
+			if s < sn {
+				pos++
+			}
+			return nil
+		}); err != nil {
+			return 0, 0, false, xerrors.Errorf("iterating deadline %d: %w", i, err)
+		}
+
+		return uint64(i), pos / partSize, true, nil
+	}
+
+	return 0, 0, false, nil
+}
+
+// assignDeadlines looks the sectors up in the miner deadlines, and records
+// the deadline and partition of the ones which were assigned one
+func (m *Sealing) assignDeadlines(ctx context.Context, sectors []SectorInfo) error {
+	if len(sectors) == 0 {
+		return nil
+	}
+
+	tok, _, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	dls, err := m.api.StateMinerDeadlines(ctx, m.maddr, tok)
+	if err != nil {
+		return xerrors.Errorf("getting miner deadlines: %w", err)
+	}
+
+	for _, sector := range sectors {
+		dl, partition, ok, err := sectorDeadline(dls, sector)
+		if err != nil {
+			return xerrors.Errorf("sector %d: %w", sector.SectorNumber, err)
+		}
+		if !ok {
+			continue
+		}
+
+		if err := m.send(sector.SectorNumber, SectorDeadlineAssigned{Deadline: dl, Partition: partition}); err != nil {
+			return xerrors.Errorf("sector %d: %w", sector.SectorNumber, err)
+		}
+	}
+
+	return nil
+}
+
+// assignPendingDeadlines records deadlines of committed sectors which didn't
+// have one when they started proving. The miner actor assigns new sectors to
+// deadlines at the end of the proving period
+func (m *Sealing) assignPendingDeadlines(ctx context.Context) error {
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	var pending []SectorInfo
+	for _, sector := range sectors {
+		if _, ok := committedStates[sector.State]; ok && !sector.DeadlineAssigned {
+			pending = append(pending, sector)
+		}
+	}
+
+	return m.assignDeadlines(ctx, pending)
+}
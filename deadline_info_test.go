@@ -0,0 +1,69 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestSectorDeadlineAssigned(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	partSize, err := abi.RegisteredSealProof_StackedDrg2KiBV1.WindowPoStPartitionSectors()
+	require.NoError(t, err)
+
+	h.api.lk.Lock()
+	for sn := abi.SectorNumber(10); sn < 10+abi.SectorNumber(partSize); sn++ {
+		h.api.deadlines[sn] = 5
+	}
+	h.api.deadlines[1] = 5
+	h.api.deadlines[10+abi.SectorNumber(partSize)+1] = 5
+	h.api.lk.Unlock()
+
+	// the only lower sector in its deadline
+	h.start(h.committingSector(1))
+	h.waitState(1, Proving)
+	require.Eventually(t, func() bool {
+		return h.sector(1).DeadlineAssigned
+	}, time.Second, time.Millisecond)
+	si := h.sector(1)
+	require.Equal(t, uint64(5), si.Deadline)
+	require.Equal(t, uint64(0), si.Partition)
+
+	// after the first partition of the deadline
+	sn := 10 + abi.SectorNumber(partSize) + 1
+	h.start(h.committingSector(sn))
+	h.waitState(sn, Proving)
+	require.Eventually(t, func() bool {
+		return h.sector(sn).DeadlineAssigned
+	}, time.Second, time.Millisecond)
+	si = h.sector(sn)
+	require.Equal(t, uint64(5), si.Deadline)
+	require.Equal(t, uint64(1), si.Partition)
+}
+
+func TestPendingDeadlines(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.start(h.committingSector(1))
+	h.waitState(1, Proving)
+	time.Sleep(20 * time.Millisecond)
+	require.False(t, h.sector(1).DeadlineAssigned)
+
+	// assigned at the end of the proving period
+	h.api.lk.Lock()
+	h.api.deadlines[1] = 7
+	h.api.lk.Unlock()
+
+	require.NoError(t, h.m.assignPendingDeadlines(context.Background()))
+	require.Eventually(t, func() bool {
+		return h.sector(1).DeadlineAssigned
+	}, time.Second, time.Millisecond)
+	si := h.sector(1)
+	require.Equal(t, uint64(7), si.Deadline)
+	require.Equal(t, Proving, si.State)
+}
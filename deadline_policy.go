@@ -0,0 +1,155 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// CommitDeadlinePolicy decides when a sector which is ready to commit should
+// submit its ProveCommit message
+type CommitDeadlinePolicy interface {
+	// ShouldCommit reports whether the sector should submit its commit at the
+	// given tipset. Returning false holds the sector back until the next check
+	ShouldCommit(ctx context.Context, sector abi.SectorNumber, tok TipSetToken, height abi.ChainEpoch) (bool, error)
+}
+
+// CommitDeadlineForgetter can be implemented by CommitDeadlinePolicies which
+// keep state for the sectors asking to commit. Forget is called when a sector
+// stops asking without the policy allowing it to commit: when it's committed
+// close to its prove-commit deadline, or after a policy error, and when it's
+// aborted or removed
+type CommitDeadlineForgetter interface {
+	Forget(sector abi.SectorNumber)
+}
+
+type DeadlineAPI interface {
+	StateMinerDeadlines(ctx context.Context, maddr address.Address, tok TipSetToken) (*miner.Deadlines, error)
+	StateMinerProvingDeadline(ctx context.Context, maddr address.Address, tok TipSetToken) (*miner.DeadlineInfo, error)
+}
+
+// DeadlineBalancer satisfies CommitDeadlinePolicy. It tries to spread new
+// sectors evenly across proving deadlines by timing commits.
+//
+// The first time a sector asks to commit, the balancer picks a target
+// deadline for it - the one with the fewest sectors due, counting sectors
+// already waiting for that deadline, with ties going to the deadline which
+// opens soonest. The sector is then held back until that deadline is the
+// current one, or until it has waited maxDelay epochs.
+//
+// Which deadline a sector ends up in is decided by the miner actor, so this is
+// best-effort influence only; maxDelay must leave enough room for the commit
+// to land before the precommit expires.
+type DeadlineBalancer struct {
+	api   DeadlineAPI
+	maddr address.Address
+
+	maxDelay abi.ChainEpoch
+
+	lk      sync.Mutex
+	waiting map[abi.SectorNumber]balancedCommit
+}
+
+type balancedCommit struct {
+	deadline uint64
+	since    abi.ChainEpoch
+}
+
+// NewDeadlineBalancer produces a DeadlineBalancer
+func NewDeadlineBalancer(api DeadlineAPI, maddr address.Address, maxDelay abi.ChainEpoch) *DeadlineBalancer {
+	return &DeadlineBalancer{
+		api:      api,
+		maddr:    maddr,
+		maxDelay: maxDelay,
+		waiting:  map[abi.SectorNumber]balancedCommit{},
+	}
+}
+
+func (b *DeadlineBalancer) ShouldCommit(ctx context.Context, sector abi.SectorNumber, tok TipSetToken, height abi.ChainEpoch) (bool, error) {
+	di, err := b.api.StateMinerProvingDeadline(ctx, b.maddr, tok)
+	if err != nil {
+		return false, xerrors.Errorf("getting proving deadline: %w", err)
+	}
+	current := di.Index % miner.WPoStPeriodDeadlines
+
+	b.lk.Lock()
+	w, ok := b.waiting[sector]
+	b.lk.Unlock()
+
+	if !ok {
+		dls, err := b.api.StateMinerDeadlines(ctx, b.maddr, tok)
+		if err != nil {
+			return false, xerrors.Errorf("getting miner deadlines: %w", err)
+		}
+
+		b.lk.Lock()
+		w, ok = b.waiting[sector]
+		if !ok {
+			target, err := b.pickDeadline(dls, height, current)
+			if err != nil {
+				b.lk.Unlock()
+				return false, err
+			}
+
+			w = balancedCommit{deadline: target, since: height}
+			b.waiting[sector] = w
+		}
+		b.lk.Unlock()
+	}
+
+	if w.deadline != current && height-w.since < b.maxDelay {
+		return false, nil
+	}
+
+	b.Forget(sector)
+	return true, nil
+}
+
+// Forget drops the target deadline of the sector, see CommitDeadlineForgetter
+func (b *DeadlineBalancer) Forget(sector abi.SectorNumber) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	delete(b.waiting, sector)
+}
+
+// pickDeadline returns the least filled deadline. Caller must hold lk
+func (b *DeadlineBalancer) pickDeadline(dls *miner.Deadlines, height abi.ChainEpoch, current uint64) (uint64, error) {
+	var fill [miner.WPoStPeriodDeadlines]uint64
+	for i, due := range dls.Due {
+		if due == nil {
+			continue
+		}
+
+		n, err := due.Count()
+		if err != nil {
+			return 0, xerrors.Errorf("counting sectors in deadline %d: %w", i, err)
+		}
+		fill[i] = n
+	}
+
+	for _, w := range b.waiting {
+		if height-w.since >= b.maxDelay {
+			continue // will commit on the next check, don't count it
+		}
+		fill[w.deadline]++
+	}
+
+	best := current
+	for i := uint64(1); i < miner.WPoStPeriodDeadlines; i++ {
+		dl := (current + i) % miner.WPoStPeriodDeadlines
+		if fill[dl] < fill[best] {
+			best = dl
+		}
+	}
+
+	return best, nil
+}
+
+var _ CommitDeadlinePolicy = &DeadlineBalancer{}
+var _ CommitDeadlineForgetter = &DeadlineBalancer{}
@@ -0,0 +1,136 @@
+package sealing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+
+	sealing "github.com/filecoin-project/storage-fsm"
+)
+
+type fakeDeadlines struct {
+	h    abi.ChainEpoch
+	fill map[uint64]uint64
+}
+
+func (f *fakeDeadlines) StateMinerDeadlines(ctx context.Context, maddr address.Address, tok sealing.TipSetToken) (*miner.Deadlines, error) {
+	dls := miner.ConstructDeadlines()
+	next := uint64(0)
+	for dl, n := range f.fill {
+		for i := uint64(0); i < n; i++ {
+			if err := dls.AddToDeadline(dl, next); err != nil {
+				return nil, err
+			}
+			next++
+		}
+	}
+	return dls, nil
+}
+
+func (f *fakeDeadlines) StateMinerProvingDeadline(ctx context.Context, maddr address.Address, tok sealing.TipSetToken) (*miner.DeadlineInfo, error) {
+	return miner.ComputeProvingPeriodDeadline(0, f.h), nil
+}
+
+func TestDeadlineBalancerFavorsUnderFilledDeadlines(t *testing.T) {
+	ctx := context.Background()
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	// every deadline has 3 sectors, except deadlines 2 and 4 which are empty
+	fill := map[uint64]uint64{}
+	for dl := uint64(0); dl < miner.WPoStPeriodDeadlines; dl++ {
+		fill[dl] = 3
+	}
+	fill[2] = 0
+	fill[4] = 0
+
+	api := &fakeDeadlines{fill: fill}
+	b := sealing.NewDeadlineBalancer(api, maddr, 10*miner.WPoStChallengeWindow)
+
+	at := func(dl uint64) abi.ChainEpoch {
+		return abi.ChainEpoch(dl) * miner.WPoStChallengeWindow
+	}
+
+	// sector 1 targets deadline 2, sector 2 then sees deadline 2 as taken and targets deadline 4
+	var committed []abi.SectorNumber
+	for dl := uint64(0); dl < 6; dl++ {
+		api.h = at(dl)
+		for _, sn := range []abi.SectorNumber{1, 2} {
+			if contains(committed, sn) {
+				continue
+			}
+
+			ok, err := b.ShouldCommit(ctx, sn, nil, api.h)
+			require.NoError(t, err)
+			if ok {
+				require.Contains(t, []uint64{2, 4}, dl)
+				committed = append(committed, sn)
+			}
+		}
+	}
+
+	require.Equal(t, []abi.SectorNumber{1, 2}, committed)
+}
+
+func TestDeadlineBalancerMaxDelay(t *testing.T) {
+	ctx := context.Background()
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	api := &fakeDeadlines{fill: map[uint64]uint64{0: 5}}
+	b := sealing.NewDeadlineBalancer(api, maddr, 20)
+
+	ok, err := b.ShouldCommit(ctx, 1, nil, 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	api.h = 19
+	ok, err = b.ShouldCommit(ctx, 1, nil, api.h)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	api.h = 20
+	ok, err = b.ShouldCommit(ctx, 1, nil, api.h)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestDeadlineBalancerForget(t *testing.T) {
+	ctx := context.Background()
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	// every deadline has a sector, except deadline 2
+	fill := map[uint64]uint64{}
+	for dl := uint64(0); dl < miner.WPoStPeriodDeadlines; dl++ {
+		fill[dl] = 1
+	}
+	fill[2] = 0
+
+	api := &fakeDeadlines{fill: fill}
+	b := sealing.NewDeadlineBalancer(api, maddr, 10*miner.WPoStChallengeWindow)
+
+	ok, err := b.ShouldCommit(ctx, 1, nil, 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// sector 1 was removed, sector 2 gets the deadline it targeted
+	b.Forget(1)
+	ok, err = b.ShouldCommit(ctx, 2, nil, 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func contains(l []abi.SectorNumber, sn abi.SectorNumber) bool {
+	for _, s := range l {
+		if s == sn {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+)
+
+// SealingAPIDealStates can be implemented by the SealingAPI passed to New, to
+// report the market state of deals. It's used to find deals which weren't
+// activated when their sector was proven, without it all deals of proven
+// sectors are assumed to be active
+type SealingAPIDealStates interface {
+	// StateMarketDealState returns nil for deals without state
+	StateMarketDealState(ctx context.Context, deal abi.DealID, tok TipSetToken) (*market.DealState, error)
+}
+
+// inactiveDeals returns the deals of a proven sector which weren't activated
+func (m *Sealing) inactiveDeals(ctx context.Context, sector SectorInfo, tok TipSetToken) ([]DealInfo, error) {
+	if m.dealStates == nil {
+		return nil, nil
+	}
+
+	var out []DealInfo
+	for _, p := range sector.Pieces {
+		if p.DealInfo == nil {
+			continue
+		}
+
+		st, err := m.dealStates.StateMarketDealState(ctx, p.DealInfo.DealID, tok)
+		if err != nil {
+			return nil, xerrors.Errorf("getting state of deal %d: %w", p.DealInfo.DealID, err)
+		}
+		if st == nil || st.SectorStartEpoch < 0 {
+			out = append(out, *p.DealInfo)
+		}
+	}
+
+	return out, nil
+}
+
+// sectorProven returns the event moving a proven sector on. Deals which
+// weren't activated are dropped from the sector, which is still proven with
+// their pieces as capacity. If the deal states can't be read, all deals are
+// kept
+func (m *Sealing) sectorProven(ctx context.Context, sector SectorInfo, tok TipSetToken) SectorProving {
+	log := m.stateLog(sector)
+
+	inactive, err := m.inactiveDeals(ctx, sector, tok)
+	if err != nil {
+		log.Errorf("checking deal activation of sector %d, keeping all deals: %+v", sector.SectorNumber, err)
+		return SectorProving{}
+	}
+	for _, d := range inactive {
+		log.Warnf("deal %d wasn't activated with sector %d, dropping it", d.DealID, sector.SectorNumber)
+	}
+
+	return SectorProving{InactiveDeals: inactive}
+}
+
+// InactiveDeals returns the deals dropped from proven sectors as they weren't
+// activated, by sector. Their clients weren't served, and can be refunded or
+// notified
+func (m *Sealing) InactiveDeals() (map[abi.SectorNumber][]DealInfo, error) {
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return nil, xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	out := map[abi.SectorNumber][]DealInfo{}
+	for _, si := range sectors {
+		if len(si.InactiveDeals) > 0 {
+			out[si.SectorNumber] = si.InactiveDeals
+		}
+	}
+	return out, nil
+}
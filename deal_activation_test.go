@@ -0,0 +1,106 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// dealStateAPI reports all deals as activated, except the inactive ones
+type dealStateAPI struct {
+	*fakeAPI
+
+	inactive map[abi.DealID]bool
+	err      error
+}
+
+func (a *dealStateAPI) StateMarketDealState(ctx context.Context, deal abi.DealID, tok TipSetToken) (*market.DealState, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	if a.inactive[deal] {
+		return &market.DealState{SectorStartEpoch: -1}, nil
+	}
+	return &market.DealState{SectorStartEpoch: 10}, nil
+}
+
+func (h *testHarness) withDealStates(api *dealStateAPI) {
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+	h.m = NewWithConfig(api, h.api, h.m.maddr, h.ds, h.sealer, &fakeCounter{}, fakeVerifier{}, &pcp, Config{})
+}
+
+// commitWaitSector returns a sector with three deals, whose commit message
+// landed
+func commitWaitSector(h *testHarness) SectorInfo {
+	h.api.lk.Lock()
+	h.api.sectors[1] = &miner.SectorOnChainInfo{}
+	h.api.lk.Unlock()
+
+	msg := builtin.CronActorCodeID
+	var pieces []Piece
+	for id := abi.DealID(1); id <= 3; id++ {
+		pieces = append(pieces, Piece{
+			Piece:    abi.PieceInfo{Size: 512, PieceCID: testCommD},
+			DealInfo: &DealInfo{DealID: id, DealSchedule: DealSchedule{StartEpoch: 100, EndEpoch: 200}},
+		})
+	}
+
+	si := SectorInfo{
+		State:         CommitWait,
+		SectorNumber:  1,
+		SectorType:    abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces:        pieces,
+		CommitMessage: &msg,
+	}
+	si.updateDealWeights()
+	return si
+}
+
+func TestPartiallyActivatedDeals(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.withDealStates(&dealStateAPI{fakeAPI: h.api, inactive: map[abi.DealID]bool{2: true}})
+
+	h.start(commitWaitSector(h))
+	si := h.waitState(1, Proving)
+
+	require.Equal(t, []abi.DealID{1, 3}, si.dealIDs())
+	require.Len(t, si.Pieces, 3)
+	require.Len(t, si.InactiveDeals, 1)
+	require.Equal(t, abi.DealID(2), si.InactiveDeals[0].DealID)
+	require.Equal(t, big.NewInt(2*512*100), si.DealWeight)
+
+	inactive, err := h.m.InactiveDeals()
+	require.NoError(t, err)
+	require.Len(t, inactive, 1)
+	require.Equal(t, si.InactiveDeals, inactive[1])
+
+	_, _, _, err = h.m.GetPieceInfo(2)
+	require.True(t, xerrors.As(err, new(*ErrPieceNotFound)), "%+v", err)
+}
+
+func TestDealStatesUnavailable(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.withDealStates(&dealStateAPI{fakeAPI: h.api, err: xerrors.New("market state unavailable")})
+
+	h.start(commitWaitSector(h))
+	si := h.waitState(1, Proving)
+
+	require.Equal(t, []abi.DealID{1, 2, 3}, si.dealIDs())
+	require.Empty(t, si.InactiveDeals)
+}
+
+func TestDealStatesNotReported(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.start(commitWaitSector(h))
+	si := h.waitState(1, Proving)
+	require.Equal(t, []abi.DealID{1, 2, 3}, si.dealIDs())
+}
@@ -0,0 +1,87 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// states of sectors which are committed on chain, and can be terminated
+var committedStates = map[SectorState]struct{}{
+	Proving:         {},
+	Faulty:          {},
+	FaultReported:   {},
+	FaultedFinal:    {},
+	RecoveringFault: {},
+	RecoveryWait:    {},
+	RecoveryFailed:  {},
+}
+
+// SectorDealExpiry is a committed sector reported by SectorsExpiringDeals
+type SectorDealExpiry struct {
+	SectorNumber abi.SectorNumber
+	State        SectorState
+	Deals        []abi.DealID
+
+	// DealsEnd is the end epoch of the last deal in the sector, 0 for
+	// sectors without deals
+	DealsEnd abi.ChainEpoch
+}
+
+// SectorsExpiringDeals lists committed sectors whose deals all end within the
+// given number of epochs from the current head, including sectors whose deals
+// ended already. Sectors without deals are always listed, with no DealsEnd.
+// Deal end epochs are read from the market actor for deals added without a
+// schedule
+func (m *Sealing) SectorsExpiringDeals(within abi.ChainEpoch) ([]SectorDealExpiry, error) {
+	ctx := context.TODO()
+
+	tok, height, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return nil, xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	var out []SectorDealExpiry
+	for _, si := range sectors {
+		if _, ok := committedStates[si.State]; !ok {
+			continue
+		}
+
+		e := SectorDealExpiry{
+			SectorNumber: si.SectorNumber,
+			State:        si.State,
+			Deals:        si.dealIDs(),
+		}
+		for _, p := range si.Pieces {
+			if p.DealInfo == nil {
+				continue
+			}
+
+			end := p.DealInfo.DealSchedule.EndEpoch
+			if end == 0 {
+				proposal, err := m.api.StateMarketStorageDeal(ctx, p.DealInfo.DealID, tok)
+				if err != nil {
+					return nil, xerrors.Errorf("getting deal %d of sector %d: %w", p.DealInfo.DealID, si.SectorNumber, err)
+				}
+				end = proposal.EndEpoch
+			}
+			if end > e.DealsEnd {
+				e.DealsEnd = end
+			}
+		}
+
+		if len(e.Deals) > 0 && e.DealsEnd > height+within {
+			continue
+		}
+		out = append(out, e)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,42 @@
+package sealing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+)
+
+func TestSectorsExpiringDeals(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.setHead(100)
+	h.api.setDeal(5, market.DealProposal{EndEpoch: 140})
+
+	deal := func(id abi.DealID, end abi.ChainEpoch) Piece {
+		return Piece{
+			Piece:    abi.PieceInfo{Size: 256, PieceCID: testCommD},
+			DealInfo: &DealInfo{DealID: id, DealSchedule: DealSchedule{EndEpoch: end}},
+		}
+	}
+	filler := Piece{Piece: abi.PieceInfo{Size: 256, PieceCID: testCommD}}
+
+	h.put(SectorInfo{SectorNumber: 1, State: Proving, Pieces: []Piece{deal(1, 120), filler}})
+	h.put(SectorInfo{SectorNumber: 2, State: Proving, Pieces: []Piece{deal(2, 200)}})
+	h.put(SectorInfo{SectorNumber: 3, State: Proving, Pieces: []Piece{filler}})
+	h.put(SectorInfo{SectorNumber: 4, State: FaultReported, Pieces: []Piece{deal(3, 120), deal(4, 300)}})
+	h.put(SectorInfo{SectorNumber: 5, State: Proving, Pieces: []Piece{deal(5, 0)}}) // end read from chain
+	h.put(SectorInfo{SectorNumber: 6, State: Proving, Pieces: []Piece{deal(6, 50)}})
+	h.put(SectorInfo{SectorNumber: 7, State: WaitDeals, Pieces: []Piece{deal(7, 110)}})
+	h.put(SectorInfo{SectorNumber: 8, State: Removed, Pieces: []Piece{deal(8, 110)}})
+
+	expiring, err := h.m.SectorsExpiringDeals(50)
+	require.NoError(t, err)
+	require.Equal(t, []SectorDealExpiry{
+		{SectorNumber: 1, State: Proving, Deals: []abi.DealID{1}, DealsEnd: 120},
+		{SectorNumber: 3, State: Proving, Deals: []abi.DealID{}},
+		{SectorNumber: 5, State: Proving, Deals: []abi.DealID{5}, DealsEnd: 140},
+		{SectorNumber: 6, State: Proving, Deals: []abi.DealID{6}, DealsEnd: 50},
+	}, expiring)
+}
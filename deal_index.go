@@ -0,0 +1,218 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	statemachine "github.com/filecoin-project/go-statemachine"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// DealLocation tells where the data of a deal is in a sealed sector
+type DealLocation struct {
+	DealID       abi.DealID
+	PieceCID     cid.Cid
+	SectorNumber abi.SectorNumber
+
+	// Offset of the piece from the start of the sector
+	Offset abi.PaddedPieceSize
+	Length abi.PaddedPieceSize
+}
+
+// DealIndexer receives deal locations of sectors which reached Proving. Used
+// with Config.DealIndexer
+type DealIndexer interface {
+	// IndexDeals is called each time a sector enters Proving, and again for
+	// proving sectors on restart, so it has to tolerate duplicates
+	IndexDeals(ctx context.Context, deals []DealLocation) error
+}
+
+// PieceLayout describes a piece of a sector, see SectorLayout
+type PieceLayout struct {
+	PieceCID cid.Cid
+	// nil for filler pieces
+	DealID *abi.DealID
+
+	Size abi.UnpaddedPieceSize
+	// Offset of the piece from the start of the sector
+	Offset abi.PaddedPieceSize
+}
+
+// layout lists the pieces of the sector in the order they were written. The
+// sealer writes each piece right after the previous ones, alignment is
+// taken care of by the filler pieces written before pieces which need it
+func (t *SectorInfo) layout() []PieceLayout {
+	out := make([]PieceLayout, 0, len(t.Pieces))
+	var offset abi.PaddedPieceSize
+	for _, p := range t.Pieces {
+		pl := PieceLayout{
+			PieceCID: p.Piece.PieceCID,
+			Size:     p.Piece.Size.Unpadded(),
+			Offset:   offset,
+		}
+		if p.DealInfo != nil {
+			id := p.DealInfo.DealID
+			pl.DealID = &id
+		}
+		out = append(out, pl)
+		offset += p.Piece.Size
+	}
+	return out
+}
+
+func (t *SectorInfo) dealLocations() []DealLocation {
+	var out []DealLocation
+	for _, pl := range t.layout() {
+		if pl.DealID != nil {
+			out = append(out, DealLocation{
+				DealID:       *pl.DealID,
+				PieceCID:     pl.PieceCID,
+				SectorNumber: t.SectorNumber,
+				Offset:       pl.Offset,
+				Length:       pl.Size.Padded(),
+			})
+		}
+	}
+	return out
+}
+
+// ErrPieceNotFound is returned when looking up a piece which isn't in any
+// tracked sector
+type ErrPieceNotFound struct{ error }
+
+// pieceIndex maps deals and piece CIDs to their location in sectors. It's
+// derived from the pieces of sector infos, which are persisted, so it's kept
+// in memory and rebuilt when sectors are restarted
+type pieceIndex struct {
+	lk       sync.Mutex
+	byDeal   map[abi.DealID]DealLocation
+	byCID    map[cid.Cid][]DealLocation
+	bySector map[abi.SectorNumber][]DealLocation
+	states   map[abi.SectorNumber]SectorState // of sectors with deals
+}
+
+// update replaces the index entries of the sector with its current deals
+func (pi *pieceIndex) update(sector *SectorInfo) {
+	pi.lk.Lock()
+	defer pi.lk.Unlock()
+
+	if pi.byDeal == nil {
+		pi.byDeal = map[abi.DealID]DealLocation{}
+		pi.byCID = map[cid.Cid][]DealLocation{}
+		pi.bySector = map[abi.SectorNumber][]DealLocation{}
+		pi.states = map[abi.SectorNumber]SectorState{}
+	}
+
+	for _, dl := range pi.bySector[sector.SectorNumber] {
+		delete(pi.byDeal, dl.DealID)
+
+		locs := pi.byCID[dl.PieceCID][:0]
+		for _, l := range pi.byCID[dl.PieceCID] {
+			if l.SectorNumber != sector.SectorNumber {
+				locs = append(locs, l)
+			}
+		}
+		if len(locs) == 0 {
+			delete(pi.byCID, dl.PieceCID)
+		} else {
+			pi.byCID[dl.PieceCID] = locs
+		}
+	}
+	delete(pi.bySector, sector.SectorNumber)
+	delete(pi.states, sector.SectorNumber)
+
+	if sector.State == Removed || sector.State == Aborted {
+		return
+	}
+
+	deals := sector.dealLocations()
+	if len(deals) == 0 {
+		return
+	}
+
+	pi.bySector[sector.SectorNumber] = deals
+	pi.states[sector.SectorNumber] = sector.State
+	for _, dl := range deals {
+		pi.byDeal[dl.DealID] = dl
+		pi.byCID[dl.PieceCID] = append(pi.byCID[dl.PieceCID], dl)
+	}
+}
+
+// GetPieceInfo returns where the piece of a deal is stored. offset is the
+// padded offset of the piece in the sector, as returned by AddPieceToAnySector
+func (m *Sealing) GetPieceInfo(dealID abi.DealID) (sid abi.SectorNumber, offset uint64, size abi.UnpaddedPieceSize, err error) {
+	m.pieces.lk.Lock()
+	defer m.pieces.lk.Unlock()
+
+	dl, ok := m.pieces.byDeal[dealID]
+	if !ok {
+		return 0, 0, 0, &ErrPieceNotFound{xerrors.Errorf("no piece for deal %d", dealID)}
+	}
+	return dl.SectorNumber, uint64(dl.Offset), dl.Length.Unpadded(), nil
+}
+
+// IsDealSealed tells whether the piece of the deal is in a sector which was
+// committed, and returns that sector. Deals in sectors which are still being
+// sealed return false with their sector number, deals which aren't in any
+// sector, or only in removed ones, return false and sector 0
+func (m *Sealing) IsDealSealed(dealID abi.DealID) (bool, abi.SectorNumber, error) {
+	m.pieces.lk.Lock()
+	defer m.pieces.lk.Unlock()
+
+	dl, ok := m.pieces.byDeal[dealID]
+	if !ok {
+		return false, 0, nil
+	}
+	return isCommittedState(m.pieces.states[dl.SectorNumber]), dl.SectorNumber, nil
+}
+
+// FindPiece returns the locations of all deals with the given piece CID
+func (m *Sealing) FindPiece(pieceCID cid.Cid) ([]DealLocation, error) {
+	m.pieces.lk.Lock()
+	defer m.pieces.lk.Unlock()
+
+	locs := m.pieces.byCID[pieceCID]
+	if len(locs) == 0 {
+		return nil, &ErrPieceNotFound{xerrors.Errorf("no deals with piece %s", pieceCID)}
+	}
+	return append([]DealLocation{}, locs...), nil
+}
+
+// SectorLayout returns all pieces of the sector, including filler pieces, in
+// the order they were written. Offsets are the ones accepted by UnsealPiece
+func (m *Sealing) SectorLayout(sid abi.SectorNumber) ([]PieceLayout, error) {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return nil, err
+	}
+
+	return si.layout(), nil
+}
+
+func (m *Sealing) handleProving(ctx statemachine.Context, sector SectorInfo) error {
+	if !sector.DeadlineAssigned {
+		if err := m.assignDeadlines(ctx.Context(), []SectorInfo{sector}); err != nil {
+			log.Errorf("%+v", xerrors.Errorf("getting deadline of sector %d: %w", sector.SectorNumber, err))
+		}
+	}
+
+	m.reopeners.drop(sector.dealIDs())
+
+	if m.cfg.DealIndexer == nil {
+		return nil
+	}
+
+	deals := sector.dealLocations()
+	if len(deals) == 0 {
+		return nil
+	}
+
+	if err := m.cfg.DealIndexer.IndexDeals(ctx.Context(), deals); err != nil {
+		log.Errorf("%+v", xerrors.Errorf("indexing deals of sector %d: %w", sector.SectorNumber, err))
+	}
+
+	return nil
+}
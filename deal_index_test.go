@@ -0,0 +1,192 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+type fakeIndexer struct {
+	deals chan []DealLocation
+}
+
+func (f *fakeIndexer) IndexDeals(ctx context.Context, deals []DealLocation) error {
+	f.deals <- deals
+	return nil
+}
+
+func TestIndexDealsOnProving(t *testing.T) {
+	idx := &fakeIndexer{deals: make(chan []DealLocation, 1)}
+	h := newTestHarness(t, Config{DealIndexer: idx})
+
+	// deal 1 (1024), filler (1024), deal 2 (2048)
+	si := finalizingSector(h)
+	si.Pieces = append(si.Pieces, Piece{
+		Piece:    abi.PieceInfo{Size: 2048, PieceCID: testCommR},
+		DealInfo: &DealInfo{DealID: 2, DealSchedule: DealSchedule{StartEpoch: 5, EndEpoch: 1000}},
+	})
+
+	h.start(si)
+	h.waitState(1, Proving)
+
+	require.Equal(t, []DealLocation{
+		{DealID: 1, PieceCID: testCommD, SectorNumber: 1, Offset: 0, Length: 1024},
+		{DealID: 2, PieceCID: testCommR, SectorNumber: 1, Offset: 2048, Length: 2048},
+	}, <-idx.deals)
+}
+
+func TestGetPieceInfo(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	_, _, _, err := h.m.GetPieceInfo(1)
+	require.True(t, xerrors.As(err, new(*ErrPieceNotFound)), "%+v", err)
+
+	h.addDeal(1, 256)
+	h.addDeal(2, 512)
+	h.waitPieces(1, 3)
+
+	sid, offset, size, err := h.m.GetPieceInfo(2)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	require.Equal(t, uint64(512), offset) // after 256 bytes of padding
+	require.Equal(t, abi.PaddedPieceSize(512).Unpadded(), size)
+
+	locs, err := h.m.FindPiece(zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(256).Unpadded()))
+	require.NoError(t, err)
+	require.Equal(t, []DealLocation{
+		{DealID: 1, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(256).Unpadded()), SectorNumber: 1, Offset: 0, Length: 256},
+	}, locs) // padding pieces have no deals
+
+	_, err = h.m.FindPiece(testCommR)
+	require.True(t, xerrors.As(err, new(*ErrPieceNotFound)), "%+v", err)
+}
+
+func TestGetPieceInfoAfterRestart(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	si := finalizingSector(h)
+	si.State = Proving
+	h.put(si)
+	h.put(SectorInfo{State: Removed, SectorNumber: 2, Pieces: []Piece{{
+		Piece:    abi.PieceInfo{Size: 2048, PieceCID: testCommR},
+		DealInfo: &DealInfo{DealID: 2},
+	}}})
+
+	require.NoError(t, h.m.Run(context.Background()))
+
+	sid, offset, size, err := h.m.GetPieceInfo(1)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	require.Equal(t, uint64(0), offset)
+	require.Equal(t, abi.PaddedPieceSize(1024).Unpadded(), size)
+
+	// removed sectors don't hold any data
+	_, _, _, err = h.m.GetPieceInfo(2)
+	require.True(t, xerrors.As(err, new(*ErrPieceNotFound)), "%+v", err)
+
+	require.NoError(t, h.m.Remove(context.Background(), 1))
+	h.waitState(1, Removed)
+	_, _, _, err = h.m.GetPieceInfo(1)
+	require.True(t, xerrors.As(err, new(*ErrPieceNotFound)), "%+v", err)
+}
+
+func TestSectorLayout(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	blockPreCommit1(h)
+
+	type write struct {
+		offset abi.PaddedPieceSize
+		size   abi.UnpaddedPieceSize
+	}
+	var lk sync.Mutex
+	var writes []write
+	h.sealer.addPieceAt = func(offset abi.PaddedPieceSize, size abi.UnpaddedPieceSize) {
+		lk.Lock()
+		defer lk.Unlock()
+		writes = append(writes, write{offset, size})
+	}
+
+	// deal 1 (256), padding (256), deal 2 (512), filler (1024)
+	h.addDeal(1, 256)
+	h.addDeal(2, 512)
+	h.waitPieces(1, 3)
+	require.NoError(t, h.m.StartPacking(1))
+	h.waitPacked(1)
+
+	layout, err := h.m.SectorLayout(1)
+	require.NoError(t, err)
+	require.Len(t, layout, 4)
+
+	deal1, deal2 := abi.DealID(1), abi.DealID(2)
+	for i, expect := range []struct {
+		deal   *abi.DealID
+		offset abi.PaddedPieceSize
+		size   abi.PaddedPieceSize
+	}{
+		{&deal1, 0, 256},
+		{nil, 256, 256},
+		{&deal2, 512, 512},
+		{nil, 1024, 1024},
+	} {
+		require.Equal(t, expect.deal, layout[i].DealID, "piece %d", i)
+		require.Equal(t, expect.offset, layout[i].Offset, "piece %d", i)
+		require.Equal(t, expect.size.Unpadded(), layout[i].Size, "piece %d", i)
+		require.Equal(t, zerocomm.ZeroPieceCommitment(expect.size.Unpadded()), layout[i].PieceCID, "piece %d", i)
+	}
+
+	// offsets are the ones the sealer wrote the pieces at
+	lk.Lock()
+	defer lk.Unlock()
+	require.Len(t, writes, len(layout))
+	for i, w := range writes {
+		require.Equal(t, w.offset, layout[i].Offset, "piece %d", i)
+		require.Equal(t, w.size, layout[i].Size, "piece %d", i)
+	}
+
+	_, err = h.m.SectorLayout(2)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)), "%+v", err)
+}
+
+func TestIsDealSealed(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	si := finalizingSector(h)
+	si.State = Proving
+	si.SectorNumber = 10
+	h.put(si)
+	h.put(SectorInfo{State: Removed, SectorNumber: 11, Pieces: []Piece{{
+		Piece:    abi.PieceInfo{Size: 2048, PieceCID: testCommR},
+		DealInfo: &DealInfo{DealID: 2},
+	}}})
+	require.NoError(t, h.m.Run(context.Background()))
+
+	sealed, sid, err := h.m.IsDealSealed(1)
+	require.NoError(t, err)
+	require.True(t, sealed)
+	require.Equal(t, abi.SectorNumber(10), sid)
+
+	// removed sectors don't hold the deal anymore
+	sealed, sid, err = h.m.IsDealSealed(2)
+	require.NoError(t, err)
+	require.False(t, sealed)
+	require.Zero(t, sid)
+
+	// still accepting deals
+	added, _ := h.addDeal(3, 256)
+	h.waitPieces(added, 1)
+	sealed, sid, err = h.m.IsDealSealed(3)
+	require.NoError(t, err)
+	require.False(t, sealed)
+	require.Equal(t, added, sid)
+
+	sealed, sid, err = h.m.IsDealSealed(4)
+	require.NoError(t, err)
+	require.False(t, sealed)
+	require.Zero(t, sid)
+}
@@ -0,0 +1,50 @@
+package sealing
+
+import (
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// ErrQuotaExhausted is returned by AddPieceToAnySector for unverified deals
+// when none of the sectors accepting them has room, UnverifiedDealSectors of
+// them are already open, and RejectOverWaitDealsLimit is set. PlanPiece
+// returns it whether it's set or not
+type ErrQuotaExhausted struct{ error }
+
+// dealClasses tells whether sectors accept either verified or unverified
+// deals, which is the case when UnverifiedDealSectors is set
+func (m *Sealing) dealClasses() bool {
+	return m.cfg.UnverifiedDealSectors > 0
+}
+
+// classSectors returns the sectors accepting deals of the class. Caller must
+// hold unsealedLk
+func (m *Sealing) classSectors(verified bool) map[abi.SectorNumber]UnsealedSectorInfo {
+	if !m.dealClasses() {
+		return m.unsealedInfos
+	}
+
+	out := map[abi.SectorNumber]UnsealedSectorInfo{}
+	for sn, ui := range m.unsealedInfos {
+		if ui.verified == verified {
+			out[sn] = ui
+		}
+	}
+	return out
+}
+
+// inQuota tells whether a new sector can be opened for deals of the class.
+// Verified deals can use all of MaxWaitDealsSectors. Caller must hold
+// unsealedLk
+func (m *Sealing) inQuota(verified bool) bool {
+	if verified || !m.dealClasses() {
+		return true
+	}
+
+	open := len(m.classSectors(false))
+	for kind := range m.creating {
+		if !kind.verified {
+			open++
+		}
+	}
+	return open < m.cfg.UnverifiedDealSectors
+}
@@ -0,0 +1,131 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// addClassDeal adds a zero piece of a verified or unverified deal to any
+// sector
+func (h *testHarness) addClassDeal(ctx context.Context, id abi.DealID, size abi.PaddedPieceSize, verified bool) (abi.SectorNumber, error) {
+	h.setZeroDeal(id, size)
+	sid, _, err := h.m.AddPieceToAnySector(ctx, size.Unpadded(), bytes.NewReader(make([]byte, size.Unpadded())), DealInfo{DealID: id, VerifiedDeal: verified})
+	return sid, err
+}
+
+func TestUnverifiedDealQuota(t *testing.T) {
+	h := newTestHarness(t, Config{MaxWaitDealsSectors: 3, UnverifiedDealSectors: 1, RejectOverWaitDealsLimit: true})
+	ctx := context.Background()
+
+	sid, err := h.addClassDeal(ctx, 1, 1024, false)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	h.waitPieces(sid, 1)
+
+	_, err = h.addClassDeal(ctx, 2, 2048, false)
+	require.True(t, xerrors.As(err, new(*ErrQuotaExhausted)), "%+v", err)
+	require.True(t, xerrors.Is(err, ErrSectorAllocFailed), "%+v", err)
+
+	// verified deals don't go into the unverified sector, and still get new
+	// sectors
+	sid, err = h.addClassDeal(ctx, 3, 1024, true)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(2), sid)
+
+	sid, err = h.addClassDeal(ctx, 4, 512, true)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(2), sid)
+
+	sid, err = h.addClassDeal(ctx, 5, 1024, true)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(3), sid)
+
+	sid, err = h.addClassDeal(ctx, 6, 512, false)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(1), sid)
+
+	// the total limit still applies to verified deals
+	_, err = h.addClassDeal(ctx, 7, 2048, true)
+	require.True(t, xerrors.As(err, new(*ErrTooManyOpenSectors)), "%+v", err)
+}
+
+func TestUnverifiedDealQuotaWait(t *testing.T) {
+	h := newTestHarness(t, Config{UnverifiedDealSectors: 1})
+	ctx := context.Background()
+
+	sid, err := h.addClassDeal(ctx, 1, 1024, false)
+	require.NoError(t, err)
+	h.waitPieces(sid, 1)
+
+	done := make(chan abi.SectorNumber)
+	go func() {
+		sid, err := h.addClassDeal(ctx, 2, 2048, false)
+		require.NoError(t, err)
+		done <- sid
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("new sector created over the unverified quota")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sid, err = h.addClassDeal(ctx, 3, 2048, true)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(2), sid)
+
+	require.NoError(t, h.m.StartPacking(1))
+	require.Equal(t, abi.SectorNumber(3), <-done)
+}
+
+func TestDealClassAfterRestart(t *testing.T) {
+	si := SectorInfo{
+		SectorType: abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces:     []Piece{{Piece: abi.PieceInfo{Size: 256}, DealInfo: &DealInfo{DealID: 1, VerifiedDeal: true}}},
+	}
+	ui, err := si.unsealedInfo(time.Now())
+	require.NoError(t, err)
+	require.True(t, ui.verified)
+
+	si.Pieces[0].DealInfo.VerifiedDeal = false
+	ui, err = si.unsealedInfo(time.Now())
+	require.NoError(t, err)
+	require.False(t, ui.verified)
+}
+
+func TestPlanPieceDealClass(t *testing.T) {
+	h := newTestHarness(t, Config{MaxWaitDealsSectors: 2, UnverifiedDealSectors: 1})
+	ctx := context.Background()
+
+	sid, err := h.addClassDeal(ctx, 1, 1024, false)
+	require.NoError(t, err)
+	h.waitPieces(sid, 1)
+
+	// verified deals don't go into the unverified sector
+	_, _, isNew, err := h.m.PlanPiece(ctx, abi.PaddedPieceSize(512).Unpadded(), true)
+	require.NoError(t, err)
+	require.True(t, isNew)
+
+	planned, offset, isNew, err := h.m.PlanPiece(ctx, abi.PaddedPieceSize(512).Unpadded(), false)
+	require.NoError(t, err)
+	require.False(t, isNew)
+	require.Equal(t, sid, planned)
+	require.Equal(t, uint64(1024), offset)
+
+	// AddPieceToAnySector would wait for a sector to be packed
+	_, _, _, err = h.m.PlanPiece(ctx, abi.PaddedPieceSize(2048).Unpadded(), false)
+	require.True(t, xerrors.As(err, new(*ErrQuotaExhausted)), "%+v", err)
+
+	_, err = h.addClassDeal(ctx, 2, 1024, true)
+	require.NoError(t, err)
+
+	_, _, _, err = h.m.PlanPiece(ctx, abi.PaddedPieceSize(2048).Unpadded(), true)
+	require.True(t, xerrors.As(err, new(*ErrTooManyOpenSectors)), "%+v", err)
+}
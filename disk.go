@@ -0,0 +1,134 @@
+package sealing
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-statemachine"
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+const defaultDiskRecheck = 1 * time.Minute
+
+// DiskSpaceProbe reports free disk space available for sealing files of a
+// sector. It can be set in Config, or implemented by the SectorManager
+type DiskSpaceProbe interface {
+	AvailableSpace(ctx context.Context, sector abi.SectorID) (uint64, error)
+}
+
+func (m *Sealing) diskProbe() DiskSpaceProbe {
+	if m.cfg.DiskSpaceProbe != nil {
+		return m.cfg.DiskSpaceProbe
+	}
+
+	p, _ := m.sealer.(DiskSpaceProbe)
+	return p
+}
+
+// ErrInsufficientStorage is returned when creating a sector while there isn't
+// enough free disk space to seal it
+type ErrInsufficientStorage struct{ error }
+
+// checkSectorSpace checks that there is enough free disk space to seal a new
+// sector, see Config.NewSectorHeadroom. Without a DiskSpaceProbe this always
+// passes
+func (m *Sealing) checkSectorSpace(ctx context.Context, sid abi.SectorNumber, spt abi.RegisteredSealProof) error {
+	probe := m.diskProbe()
+	if probe == nil {
+		return nil
+	}
+
+	use, err := (stores.FTUnsealed | stores.FTSealed | stores.FTCache).SealSpaceUse(spt)
+	if err != nil {
+		return xerrors.Errorf("estimating disk use: %w", err)
+	}
+	headroom := m.cfg.NewSectorHeadroom
+	if headroom == 0 {
+		headroom = 1
+	}
+	need := uint64(float64(use) * headroom)
+
+	avail, err := probe.AvailableSpace(ctx, m.minerSector(sid))
+	if err != nil {
+		return xerrors.Errorf("getting available disk space: %w", err)
+	}
+
+	if avail < need {
+		return &ErrInsufficientStorage{xerrors.Errorf("sealing sector %d needs %d bytes of disk, only %d available", sid, need, avail)}
+	}
+	return nil
+}
+
+// phaseSpaceUse estimates the disk space a sealing phase writes
+func phaseSpaceUse(phase SectorState, spt abi.RegisteredSealProof) (uint64, error) {
+	switch phase {
+	case PreCommit1:
+		return (stores.FTSealed | stores.FTCache).SealSpaceUse(spt)
+	case PreCommit2:
+		// tree-c and tree-r-last, layers are already on disk
+		ssize, err := spt.SectorSize()
+		if err != nil {
+			return 0, err
+		}
+		return 2 * uint64(ssize), nil
+	default:
+		return 0, nil
+	}
+}
+
+// enoughDisk checks if there is enough free disk space to start the given
+// phase. Without a DiskSpaceProbe this is always true
+func (m *Sealing) enoughDisk(ctx context.Context, sector SectorInfo, phase SectorState) (bool, error) {
+	log := m.stateLog(sector)
+
+	probe := m.diskProbe()
+	if probe == nil {
+		return true, nil
+	}
+
+	need, err := phaseSpaceUse(phase, sector.SectorType)
+	if err != nil {
+		return false, xerrors.Errorf("estimating disk use: %w", err)
+	}
+	need += m.cfg.DiskSafetyMargin
+
+	avail, err := probe.AvailableSpace(ctx, m.minerSector(sector.SectorNumber))
+	if err != nil {
+		return false, xerrors.Errorf("getting available disk space: %w", err)
+	}
+
+	if avail < need {
+		log.Warnf("sector %d needs %d bytes of disk for %s, only %d available", sector.SectorNumber, need, phase, avail)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (m *Sealing) handleWaitDisk(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
+	recheck := m.cfg.DiskRecheckInterval
+	if recheck == 0 {
+		recheck = defaultDiskRecheck
+	}
+
+	for {
+		ok, err := m.enoughDisk(ctx.Context(), sector, sector.DiskWaitPhase)
+		if err != nil {
+			log.Errorf("handleWaitDisk(%d): %+v", sector.SectorNumber, err)
+		}
+		if ok {
+			return ctx.Send(SectorDiskAvailable{})
+		}
+
+		select {
+		case <-m.clock.After(recheck):
+		case <-ctx.Context().Done():
+			return ctx.Context().Err()
+		}
+	}
+}
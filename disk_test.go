@@ -0,0 +1,141 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+type fakeDisk struct {
+	lk    sync.Mutex
+	avail uint64
+	fails int // number of probes failing before the next one succeeds
+}
+
+func (f *fakeDisk) AvailableSpace(ctx context.Context, sector abi.SectorID) (uint64, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	if f.fails > 0 {
+		f.fails--
+		return 0, xerrors.New("statfs: input/output error")
+	}
+	return f.avail, nil
+}
+
+func (f *fakeDisk) set(avail uint64) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.avail = avail
+}
+
+func TestWaitDisk(t *testing.T) {
+	disk := &fakeDisk{avail: 10000}
+	h := newTestHarness(t, Config{
+		DiskSpaceProbe:      disk,
+		DiskRecheckInterval: 5 * time.Millisecond,
+		DiskSafetyMargin:    1000,
+	})
+	h.api.setHead(2000)
+
+	need, err := phaseSpaceUse(PreCommit1, abi.RegisteredSealProof_StackedDrg2KiBV1)
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	h.start(SectorInfo{
+		State:        PreCommit1,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+	})
+
+	si := h.waitState(1, WaitDisk)
+	require.Equal(t, PreCommit1, si.DiskWaitPhase)
+
+	// the safety margin is still missing
+	disk.set(need)
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, WaitDisk, h.sector(1).State)
+
+	disk.set(need + 1000)
+	<-started
+
+	si = h.sector(1)
+	require.Equal(t, PreCommit1, si.State)
+	require.Equal(t, UndefinedSectorState, si.DiskWaitPhase)
+}
+
+func TestWaitDiskProbeError(t *testing.T) {
+	disk := &fakeDisk{avail: 1 << 30, fails: 1}
+	h := newTestHarness(t, Config{
+		DiskSpaceProbe:      disk,
+		DiskRecheckInterval: 5 * time.Millisecond,
+	})
+	h.api.setHead(2000)
+
+	started := make(chan struct{})
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	h.start(SectorInfo{
+		State:        PreCommit1,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+	})
+
+	// the failed probe sends the sector to WaitDisk, which rechecks
+	<-started
+
+	si := h.sector(1)
+	require.Equal(t, PreCommit1, si.State)
+	require.True(t, hasEvent(si, SectorWaitDisk{}))
+	require.True(t, hasEvent(si, SectorDiskAvailable{}))
+}
+
+func TestNewSectorInsufficientStorage(t *testing.T) {
+	disk := &fakeDisk{}
+	h := newTestHarness(t, Config{DiskSpaceProbe: disk, NewSectorHeadroom: 2})
+	ctx := context.Background()
+
+	use, err := (stores.FTUnsealed | stores.FTSealed | stores.FTCache).SealSpaceUse(abi.RegisteredSealProof_StackedDrg2KiBV1)
+	require.NoError(t, err)
+
+	created := 0
+	h.sealer.newSector = func(ctx context.Context, sector abi.SectorID) error {
+		created++
+		return nil
+	}
+
+	// enough to seal the sector, but not with the headroom
+	disk.set(use * 3 / 2)
+
+	_, err = h.m.PledgeSector(ctx)
+	require.True(t, xerrors.As(err, new(*ErrInsufficientStorage)), "%+v", err)
+
+	h.setZeroDeal(1, 1024)
+	_, _, err = h.m.AddPieceToAnySector(ctx, 1016, bytes.NewReader(make([]byte, 1016)), DealInfo{DealID: 1})
+	require.True(t, xerrors.As(err, new(*ErrInsufficientStorage)), "%+v", err)
+	require.Zero(t, created)
+
+	disk.set(2 * use)
+
+	_, err = h.m.PledgeSector(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, created)
+}
@@ -0,0 +1,41 @@
+package sealing
+
+import (
+	"bytes"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// EntropyBuilder builds the entropy mixed into the chain randomness drawn for
+// sector tickets and seeds. It has to match the entropy the miner actor uses
+// to check them - randomness drawn with other entropy looks valid, but
+// produces proofs the chain rejects
+type EntropyBuilder interface {
+	TicketEntropy(maddr address.Address, sector abi.SectorNumber) ([]byte, error)
+	SeedEntropy(maddr address.Address, sector abi.SectorNumber) ([]byte, error)
+}
+
+// FilecoinEntropy is the entropy of the Filecoin miner actor, the CBOR encoded
+// miner address for both tickets and seeds
+type FilecoinEntropy struct{}
+
+func (FilecoinEntropy) TicketEntropy(maddr address.Address, sector abi.SectorNumber) ([]byte, error) {
+	return cborAddress(maddr)
+}
+
+func (FilecoinEntropy) SeedEntropy(maddr address.Address, sector abi.SectorNumber) ([]byte, error) {
+	return cborAddress(maddr)
+}
+
+func cborAddress(maddr address.Address) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := maddr.MarshalCBOR(buf); err != nil {
+		return nil, xerrors.Errorf("encoding miner address: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+var _ EntropyBuilder = FilecoinEntropy{}
@@ -0,0 +1,78 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+)
+
+func TestFilecoinEntropy(t *testing.T) {
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	// CBOR byte string of the ID address protocol byte, and 1000 as uvarint
+	expected := []byte{0x43, 0x00, 0xe8, 0x07}
+
+	ticket, err := FilecoinEntropy{}.TicketEntropy(maddr, 1)
+	require.NoError(t, err)
+	require.Equal(t, expected, ticket)
+
+	seed, err := FilecoinEntropy{}.SeedEntropy(maddr, 1)
+	require.NoError(t, err)
+	require.Equal(t, expected, seed)
+}
+
+// sectorEntropy uses the sector number as entropy, prefixed by 't' for
+// tickets and 's' for seeds
+type sectorEntropy struct{}
+
+func (sectorEntropy) TicketEntropy(maddr address.Address, sector abi.SectorNumber) ([]byte, error) {
+	return []byte{'t', byte(sector)}, nil
+}
+
+func (sectorEntropy) SeedEntropy(maddr address.Address, sector abi.SectorNumber) ([]byte, error) {
+	return []byte{'s', byte(sector)}, nil
+}
+
+// entropyAPI records the entropy randomness is drawn with
+type entropyAPI struct {
+	*fakeAPI
+
+	lk      sync.Mutex
+	entropy map[crypto.DomainSeparationTag][]byte
+}
+
+func (a *entropyAPI) ChainGetRandomness(ctx context.Context, tok TipSetToken, personalization crypto.DomainSeparationTag, randEpoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error) {
+	a.lk.Lock()
+	a.entropy[personalization] = entropy
+	a.lk.Unlock()
+
+	return a.fakeAPI.ChainGetRandomness(ctx, tok, personalization, randEpoch, entropy)
+}
+
+func TestCustomEntropy(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	api := &entropyAPI{fakeAPI: h.api, entropy: map[crypto.DomainSeparationTag][]byte{}}
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+	h.m = NewWithConfig(api, h.api, h.m.maddr, h.ds, h.sealer, &fakeCounter{}, fakeVerifier{}, &pcp, Config{Entropy: sectorEntropy{}})
+
+	h.start(SectorInfo{
+		State:        PreCommit1,
+		SectorNumber: 7,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces:       []Piece{{Piece: abi.PieceInfo{Size: 2048, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(2048).Unpadded())}}},
+	})
+	h.waitState(7, Proving)
+
+	api.lk.Lock()
+	defer api.lk.Unlock()
+	require.Equal(t, []byte{'t', 7}, api.entropy[crypto.DomainSeparationTag_SealRandomness])
+	require.Equal(t, []byte{'s', 7}, api.entropy[crypto.DomainSeparationTag_InteractiveSealChallengeSeed])
+}
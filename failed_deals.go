@@ -0,0 +1,153 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// states of sectors which can't be sealed anymore, leaving their deals
+// stranded. Entering one of them is reported to Config.FailedSectorHandler
+var terminalFailedStates = map[SectorState]struct{}{
+	FailedUnrecoverable:    {},
+	PackingFailed:          {},
+	DealsExpired:           {},
+	DataCommitmentMismatch: {},
+	ExpiredPreCommit:       {},
+}
+
+// FailedSectorHandler is told about sectors which failed before they were
+// proven. Used with Config.FailedSectorHandler
+type FailedSectorHandler interface {
+	// OnSectorFailed is called once the sector enters the failed state
+	// reason, with all deals in the sector. Deals added since the last
+	// restart have Reopen set. With Config.RequeueFailedDeals it's called
+	// after the deals were requeued
+	OnSectorFailed(sid abi.SectorNumber, deals []DealInfo, reason SectorState)
+}
+
+// dealReopeners keeps DealInfo.Reopen of deals added since startup, as it
+// isn't persisted with the sector pieces
+type dealReopeners struct {
+	lk     sync.Mutex
+	reopen map[abi.DealID]DealReopener
+}
+
+func (dr *dealReopeners) add(d DealInfo) {
+	if d.Reopen == nil {
+		return
+	}
+
+	dr.lk.Lock()
+	defer dr.lk.Unlock()
+
+	if dr.reopen == nil {
+		dr.reopen = map[abi.DealID]DealReopener{}
+	}
+	dr.reopen[d.DealID] = d.Reopen
+}
+
+func (dr *dealReopeners) get(id abi.DealID) DealReopener {
+	dr.lk.Lock()
+	defer dr.lk.Unlock()
+
+	return dr.reopen[id]
+}
+
+// drop forgets the deals of a sector which was proven
+func (dr *dealReopeners) drop(ids []abi.DealID) {
+	dr.lk.Lock()
+	defer dr.lk.Unlock()
+
+	for _, id := range ids {
+		delete(dr.reopen, id)
+	}
+}
+
+// sectorFailed handles the deals of a sector which entered one of
+// terminalFailedStates. It's called from Plan, so the work is done in the
+// background
+func (m *Sealing) sectorFailed(sector SectorInfo) {
+	if m.cfg.FailedSectorHandler == nil && !m.cfg.RequeueFailedDeals {
+		return
+	}
+
+	var deals []DealInfo
+	for _, p := range sector.Pieces {
+		if p.DealInfo == nil {
+			continue
+		}
+		d := *p.DealInfo
+		d.Reopen = m.reopeners.get(d.DealID)
+		deals = append(deals, d)
+	}
+
+	go func() {
+		if m.cfg.RequeueFailedDeals {
+			m.requeueDeals(context.TODO(), sector, deals)
+		}
+		if m.cfg.FailedSectorHandler != nil {
+			m.cfg.FailedSectorHandler.OnSectorFailed(sector.SectorNumber, deals, sector.State)
+		}
+	}()
+}
+
+// requeueDeals adds the deals of the failed sector which can be reopened,
+// and didn't start yet, to other sectors
+func (m *Sealing) requeueDeals(ctx context.Context, sector SectorInfo, deals []DealInfo) {
+	log := m.sectorLog(sector.SectorNumber)
+
+	tok, height, err := m.api.ChainHead(ctx)
+	if err != nil {
+		log.Errorf("not requeueing deals of failed sector %d: getting chain head: %+v", sector.SectorNumber, err)
+		return
+	}
+
+	sizes := map[abi.DealID]abi.UnpaddedPieceSize{}
+	for _, p := range sector.Pieces {
+		if p.DealInfo != nil {
+			sizes[p.DealInfo.DealID] = p.Piece.Size.Unpadded()
+		}
+	}
+
+	for _, d := range deals {
+		if d.Reopen == nil {
+			log.Warnf("not requeueing deal %d of failed sector %d, its data can't be reopened", d.DealID, sector.SectorNumber)
+			continue
+		}
+
+		proposal, err := m.api.StateMarketStorageDeal(ctx, d.DealID, tok)
+		if err != nil {
+			log.Errorf("not requeueing deal %d of failed sector %d: getting deal: %+v", d.DealID, sector.SectorNumber, err)
+			continue
+		}
+		if height >= proposal.StartEpoch {
+			log.Warnf("not requeueing deal %d of failed sector %d, it should have started at %d, head %d", d.DealID, sector.SectorNumber, proposal.StartEpoch, height)
+			continue
+		}
+
+		sid, err := m.requeueDeal(ctx, sizes[d.DealID], d)
+		if err != nil {
+			log.Errorf("requeueing deal %d of failed sector %d: %+v", d.DealID, sector.SectorNumber, err)
+			continue
+		}
+		log.Infof("requeued deal %d of failed sector %d to sector %d", d.DealID, sector.SectorNumber, sid)
+	}
+}
+
+func (m *Sealing) requeueDeal(ctx context.Context, size abi.UnpaddedPieceSize, d DealInfo) (abi.SectorNumber, error) {
+	r, err := d.Reopen(ctx)
+	if err != nil {
+		return 0, xerrors.Errorf("reopening deal data: %w", err)
+	}
+	defer r.Close() // nolint:errcheck
+
+	sid, _, err := m.AddPieceToAnySector(ctx, size, r, d)
+	if err != nil {
+		return 0, xerrors.Errorf("adding piece: %w", err)
+	}
+	return sid, nil
+}
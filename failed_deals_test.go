@@ -0,0 +1,114 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+)
+
+type failedSector struct {
+	sid    abi.SectorNumber
+	deals  []DealInfo
+	reason SectorState
+}
+
+// failedSectors records the sectors reported to OnSectorFailed
+type failedSectors chan failedSector
+
+func (f failedSectors) OnSectorFailed(sid abi.SectorNumber, deals []DealInfo, reason SectorState) {
+	f <- failedSector{sid: sid, deals: deals, reason: reason}
+}
+
+func (f failedSectors) wait(t *testing.T) failedSector {
+	select {
+	case fs := <-f:
+		return fs
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnSectorFailed not called")
+		return failedSector{}
+	}
+}
+
+// addReopenableDeal adds a zero piece of a deal which can be reopened, and
+// starts at the given epoch
+func (h *testHarness) addReopenableDeal(id abi.DealID, size abi.PaddedPieceSize, start abi.ChainEpoch) abi.SectorNumber {
+	h.api.setDeal(id, market.DealProposal{
+		PieceCID:   zerocomm.ZeroPieceCommitment(size.Unpadded()),
+		PieceSize:  size,
+		StartEpoch: start,
+		EndEpoch:   20000,
+	})
+
+	d := DealInfo{
+		DealID: id,
+		Reopen: func(ctx context.Context) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(make([]byte, size.Unpadded()))), nil
+		},
+	}
+	sid, _, err := h.m.AddPieceToAnySector(context.Background(), size.Unpadded(), bytes.NewReader(make([]byte, size.Unpadded())), d)
+	require.NoError(h.t, err)
+	return sid
+}
+
+// failDeals packs a sector with deal 1, which is still valid, and deal 2,
+// which already started, so that the sector moves to DealsExpired
+func failDeals(h *testHarness) abi.SectorNumber {
+	h.api.setHead(10)
+
+	sid := h.addReopenableDeal(1, 512, 10000)
+	require.Equal(h.t, sid, h.addReopenableDeal(2, 512, 5))
+	h.waitPieces(sid, 2)
+
+	require.NoError(h.t, h.m.StartPacking(sid))
+	return sid
+}
+
+func TestFailedSectorNotify(t *testing.T) {
+	failed := make(failedSectors, 1)
+	h := newTestHarness(t, Config{FailedSectorHandler: failed})
+
+	sid := failDeals(h)
+	fs := failed.wait(t)
+	require.Equal(t, sid, fs.sid)
+	require.Equal(t, DealsExpired, fs.reason)
+	require.Len(t, fs.deals, 2)
+	require.Equal(t, abi.DealID(1), fs.deals[0].DealID)
+	require.Equal(t, abi.DealID(2), fs.deals[1].DealID)
+	require.NotNil(t, fs.deals[0].Reopen)
+	require.Equal(t, DealsExpired, h.sector(sid).State)
+
+	// deals aren't requeued
+	sectors, err := h.m.ListSectors()
+	require.NoError(t, err)
+	require.Len(t, sectors, 1)
+}
+
+func TestFailedSectorRequeue(t *testing.T) {
+	failed := make(failedSectors, 1)
+	h := newTestHarness(t, Config{FailedSectorHandler: failed, RequeueFailedDeals: true})
+
+	sid := failDeals(h)
+	fs := failed.wait(t)
+	require.Equal(t, DealsExpired, fs.reason)
+	require.Len(t, fs.deals, 2)
+
+	// only the deal which didn't start yet is requeued
+	sectors, err := h.m.ListSectors()
+	require.NoError(t, err)
+	require.Len(t, sectors, 2)
+
+	requeued := sid + 1
+	h.waitPieces(requeued, 1)
+	si := h.sector(requeued)
+	require.Equal(t, WaitDeals, si.State)
+	require.Equal(t, abi.DealID(1), si.Pieces[0].DealInfo.DealID)
+}
@@ -0,0 +1,67 @@
+package sealing
+
+import (
+	"context"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// FinalizePolicy controls which files are kept when a proven sector is
+// finalized. By default the unsealed copy is dropped, and the cache is trimmed
+// to the files needed for proving
+type FinalizePolicy struct {
+	// KeepUnsealed keeps the unsealed copy of the deal pieces, for serving
+	// retrievals. Sectors without deals never keep it
+	KeepUnsealed bool
+
+	// KeepCache keeps all sealing cache files. Only done by sealers
+	// implementing CacheKeepingFinalizer
+	KeepCache bool
+}
+
+// CacheKeepingFinalizer can be implemented by SectorManagers which can finalize
+// sectors without trimming their cache, see FinalizePolicy.KeepCache
+type CacheKeepingFinalizer interface {
+	FinalizeSectorKeepCache(ctx context.Context, sector abi.SectorID, keepUnsealed []storage.Range) error
+}
+
+// keepUnsealed returns the unsealed ranges kept when finalizing the sector
+func (p FinalizePolicy) keepUnsealed(sector SectorInfo) []storage.Range {
+	if !p.KeepUnsealed {
+		return nil
+	}
+
+	var out []storage.Range
+	for _, pl := range sector.layout() {
+		if pl.DealID == nil {
+			continue
+		}
+		out = append(out, storage.Range{
+			Offset: pl.Offset.Unpadded(),
+			Size:   pl.Size,
+		})
+	}
+	return out
+}
+
+// finalizeSector finalizes the sector according to Config.Finalize
+func (m *Sealing) finalizeSector(ctx context.Context, sector SectorInfo) error {
+	policy := m.cfg.Finalize
+	keep := policy.keepUnsealed(sector)
+
+	done, err := m.beginIntent(sector.SectorNumber, IntentFinalize)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if policy.KeepCache {
+		if kc, ok := m.sealer.(CacheKeepingFinalizer); ok {
+			return kc.FinalizeSectorKeepCache(ctx, m.minerSector(sector.SectorNumber), keep)
+		}
+		m.stateLog(sector).Warnf("sealer can't keep the cache of sector %d, trimming it", sector.SectorNumber)
+	}
+
+	return m.sealer.FinalizeSector(ctx, m.minerSector(sector.SectorNumber), keep)
+}
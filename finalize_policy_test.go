@@ -0,0 +1,94 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+type cacheKeepingSealer struct {
+	*fakeSealer
+
+	lk   sync.Mutex
+	kept [][]storage.Range
+}
+
+func (s *cacheKeepingSealer) FinalizeSectorKeepCache(ctx context.Context, sector abi.SectorID, keepUnsealed []storage.Range) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.kept = append(s.kept, keepUnsealed)
+	return nil
+}
+
+// finalizePolicySector returns a sector ready to be finalized, with a deal piece
+// between two filler pieces if deal is set
+func finalizePolicySector(sn abi.SectorNumber, deal bool) SectorInfo {
+	si := SectorInfo{
+		State:        FinalizeSector,
+		SectorNumber: sn,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces: []Piece{
+			{Piece: abi.PieceInfo{Size: 512, PieceCID: testCommD}},
+			{Piece: abi.PieceInfo{Size: 512, PieceCID: testCommD}},
+			{Piece: abi.PieceInfo{Size: 1024, PieceCID: testCommD}},
+		},
+	}
+	if deal {
+		si.Pieces[1].DealInfo = &DealInfo{DealID: 1}
+	}
+	return si
+}
+
+// finalizeRanges finalizes the sector, and returns the unsealed ranges the
+// sealer was asked to keep
+func finalizeRanges(t *testing.T, cfg Config, si SectorInfo) []storage.Range {
+	h := newTestHarness(t, cfg)
+
+	kept := make(chan []storage.Range, 1)
+	h.sealer.finalizeKeep = func(keepUnsealed []storage.Range) {
+		kept <- keepUnsealed
+	}
+
+	h.start(si)
+	h.waitState(si.SectorNumber, Proving)
+	return <-kept
+}
+
+func TestFinalizeKeepUnsealed(t *testing.T) {
+	cfg := Config{Finalize: FinalizePolicy{KeepUnsealed: true}}
+
+	kept := finalizeRanges(t, cfg, finalizePolicySector(1, true))
+	require.Equal(t, []storage.Range{{Offset: abi.PaddedPieceSize(512).Unpadded(), Size: abi.PaddedPieceSize(512).Unpadded()}}, kept)
+
+	// nothing worth keeping in CC sectors
+	require.Empty(t, finalizeRanges(t, cfg, finalizePolicySector(1, false)))
+}
+
+func TestFinalizeDefaultPolicy(t *testing.T) {
+	require.Empty(t, finalizeRanges(t, Config{}, finalizePolicySector(1, true)))
+}
+
+func TestFinalizeKeepCache(t *testing.T) {
+	h := newTestHarness(t, Config{Finalize: FinalizePolicy{KeepUnsealed: true, KeepCache: true}})
+	sealer := &cacheKeepingSealer{fakeSealer: h.sealer}
+	h.m.sealer = sealer
+
+	trimmed := false
+	h.sealer.finalizeKeep = func([]storage.Range) {
+		trimmed = true
+	}
+
+	h.start(finalizePolicySector(1, true))
+	h.waitState(1, Proving)
+
+	sealer.lk.Lock()
+	defer sealer.lk.Unlock()
+	require.Len(t, sealer.kept, 1)
+	require.Len(t, sealer.kept[0], 1)
+	require.False(t, trimmed)
+}
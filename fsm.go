@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"time"
 
 	"golang.org/x/xerrors"
 
@@ -15,12 +14,41 @@ import (
 )
 
 func (m *Sealing) Plan(events []statemachine.Event, user interface{}) (interface{}, uint64, error) {
-	next, err := m.plan(events, user.(*SectorInfo))
+	state := user.(*SectorInfo)
+	from := state.State
+
+	next, err := m.plan(events, state)
+	if err == nil {
+		m.pieces.update(state)
+	}
+	if err == nil && state.State != from {
+		m.metrics.SectorStateChanged(state.SectorNumber, from, state.State)
+		m.counts.changed(from, state.State)
+		m.publishStateChange(SectorStateChange{SectorNumber: state.SectorNumber, From: from, To: state.State})
+		if _, failed := terminalFailedStates[state.State]; failed {
+			m.sectorFailed(*state)
+		}
+	}
 	if err != nil || next == nil {
 		return nil, uint64(len(events)), err
 	}
 
 	return func(ctx statemachine.Context, si SectorInfo) error {
+		if m.writes != nil {
+			// the state must be durable before the handler acts on it
+			if err := m.writes.sync(); err != nil {
+				log.Errorf("writing sector %d record, not running the %s handler: %+v", si.SectorNumber, si.State, err)
+				return nil
+			}
+		}
+
+		if si.Paused {
+			m.held.add(si.SectorNumber)
+			m.stateLog(si).Infof("sector %d is paused, not running the %s handler", si.SectorNumber, si.State)
+			return nil
+		}
+
+		si.tick = newChainTick(m.api)
 		err := next(ctx, si)
 		if err != nil {
 			log.Errorf("unhandled sector error (%d): %+v", si.SectorNumber, err)
@@ -34,41 +62,79 @@ func (m *Sealing) Plan(events []statemachine.Event, user interface{}) (interface
 var fsmPlanners = map[SectorState]func(events []statemachine.Event, state *SectorInfo) error{
 	// Sealing
 
-	UndefinedSectorState: planOne(on(SectorStart{}, Packing)),
-	Packing:              planOne(on(SectorPacked{}, PreCommit1)),
+	UndefinedSectorState: planWaitDeals,
+	WaitDeals:            planWaitDeals,
+	Packing: planOne(
+		on(SectorPacked{}, PreCommit1),
+		on(SectorDropUnpublishedDeals{}, Packing),
+		on(SectorPackingFailed{}, PackingFailed),
+		on(SectorDealsExpired{}, DealsExpired),
+		on(SectorDropExpiredDeals{}, Packing),
+	),
 	PreCommit1: planOne(
 		on(SectorPreCommit1{}, PreCommit2),
 		on(SectorSealPreCommit1Failed{}, SealPreCommit1Failed),
 		on(SectorPackingFailed{}, PackingFailed),
+		on(SectorDealsExpired{}, DealsExpired),
+		on(SectorDropExpiredDeals{}, Packing),
+		on(SectorWaitDisk{}, WaitDisk),
 	),
 	PreCommit2: planOne(
 		on(SectorPreCommit2{}, PreCommitting),
 		on(SectorSealPreCommit2Failed{}, SealPreCommit2Failed),
 		on(SectorPackingFailed{}, PackingFailed),
+		on(SectorWaitDisk{}, WaitDisk),
 	),
+	WaitDisk: planWaitDisk,
 	PreCommitting: planOne(
 		on(SectorSealPreCommit1Failed{}, SealPreCommit1Failed),
+		on(SectorTicketExpired{}, PreCommit1),
 		on(SectorPreCommitted{}, PreCommitWait),
 		on(SectorChainPreCommitFailed{}, PreCommitFailed),
 		on(SectorPreCommitLanded{}, WaitSeed),
+		on(SectorHoldCommit{}, CommitHold),
+		on(SectorDealsExpired{}, DealsExpired),
+		on(SectorPledgeInsufficient{}, PledgeInsufficient),
+		on(SectorDataCommitmentMismatch{}, DataCommitmentMismatch),
+	),
+	PledgeInsufficient: planOne(
+		on(SectorPledgeAvailable{}, PreCommitting),
 	),
 	PreCommitWait: planOne(
 		on(SectorChainPreCommitFailed{}, PreCommitFailed),
 		on(SectorPreCommitLanded{}, WaitSeed),
+		on(SectorHoldCommit{}, CommitHold),
+		on(SectorMessageStuck{}, MessageStuck),
+		on(SectorMessageFailed{}, MessageFailed),
+	),
+	CommitHold: planOne(
+		on(SectorCommitTrigger{}, WaitSeed),
+		on(SectorChainPreCommitFailed{}, PreCommitFailed),
 	),
 	WaitSeed: planOne(
 		on(SectorSeedReady{}, Committing),
 		on(SectorChainPreCommitFailed{}, PreCommitFailed),
+		on(SectorPreCommitExpired{}, ExpiredPreCommit),
 	),
 	Committing: planCommitting,
 	CommitWait: planOne(
 		on(SectorProving{}, FinalizeSector),
 		on(SectorCommitFailed{}, CommitFailed),
+		on(SectorMessageStuck{}, MessageStuck),
+		on(SectorMessageFailed{}, MessageFailed),
+		on(SectorCommitReverted{}, Committing),
+	),
+	MessageStuck: planMessageStuck,
+	MessageFailed: planOne(
+		on(SectorChainPreCommitFailed{}, PreCommitFailed),
+		on(SectorCommitFailed{}, CommitFailed),
 	),
 
 	FinalizeSector: planOne(
 		on(SectorFinalized{}, Proving),
 		on(SectorFinalizeFailed{}, FinalizeFailed),
+		on(SectorUnsealedCorrupt{}, UnsealedCorrupt),
+		on(SectorCommitReverted{}, Committing),
 	),
 
 	// Sealing errors
@@ -85,21 +151,41 @@ var fsmPlanners = map[SectorState]func(events []statemachine.Event, state *Secto
 		on(SectorRetryWaitSeed{}, WaitSeed),
 		on(SectorSealPreCommit1Failed{}, SealPreCommit1Failed),
 		on(SectorPreCommitLanded{}, WaitSeed),
+		on(SectorHoldCommit{}, CommitHold),
+		on(SectorDataCommitmentMismatch{}, DataCommitmentMismatch),
 	),
 	ComputeProofFailed: planOne(
 		on(SectorRetryComputeProof{}, Committing),
 		on(SectorSealPreCommit1Failed{}, SealPreCommit1Failed),
 	),
+	BadProof: planOne(
+		on(SectorRetryInvalidProof{}, Committing),
+		on(SectorSealPreCommit1Failed{}, SealPreCommit1Failed),
+	),
 	CommitFailed: planOne(
 		on(SectorSealPreCommit1Failed{}, SealPreCommit1Failed),
 		on(SectorRetryWaitSeed{}, WaitSeed),
 		on(SectorRetryComputeProof{}, Committing),
 		on(SectorRetryInvalidProof{}, Committing),
 		on(SectorRetryPreCommitWait{}, PreCommitWait),
+		on(SectorDataCommitmentMismatch{}, DataCommitmentMismatch),
 	),
 	FinalizeFailed: planOne(
 		on(SectorRetryFinalize{}, FinalizeSector),
 	),
+	DealsExpired: planOne(
+		on(SectorRemove{}, Removing),
+	),
+	DataCommitmentMismatch: planOne(
+		on(SectorRemove{}, Removing),
+	),
+	ExpiredPreCommit: planOne(
+		on(SectorRemove{}, Removing),
+	),
+	UnsealedCorrupt: planOne(
+		on(SectorRetryUnsealedCheck{}, FinalizeSector),
+		on(SectorRemove{}, Removing),
+	),
 
 	// Post-seal
 
@@ -107,6 +193,21 @@ var fsmPlanners = map[SectorState]func(events []statemachine.Event, state *Secto
 		on(SectorFaultReported{}, FaultReported),
 		on(SectorFaulty{}, Faulty),
 		on(SectorRemove{}, Removing),
+		on(SectorTerminate{}, Terminating),
+	),
+	Terminating: planOne(
+		on(SectorTerminating{}, TerminateWait),
+		on(SectorTerminateFailed{}, TerminateFailed),
+	),
+	TerminateWait: planOne(
+		on(SectorTerminated{}, Terminated),
+		on(SectorTerminateFailed{}, TerminateFailed),
+	),
+	TerminateFailed: planOne(
+		on(SectorTerminate{}, Terminating),
+	),
+	Terminated: planOne(
+		on(SectorRemove{}, Removing),
 	),
 	Removing: planOne(
 		on(SectorRemoved{}, Removed),
@@ -114,10 +215,35 @@ var fsmPlanners = map[SectorState]func(events []statemachine.Event, state *Secto
 	),
 	Faulty: planOne(
 		on(SectorFaultReported{}, FaultReported),
+		on(SectorRecoverFault{}, RecoveringFault),
+		on(SectorTerminate{}, Terminating),
+	),
+	FaultReported: planOne(
+		on(SectorFaultedFinal{}, FaultedFinal),
+		on(SectorTerminate{}, Terminating),
+	),
+	FaultedFinal: planOne(
+		on(SectorRecoverFault{}, RecoveringFault),
+		on(SectorTerminate{}, Terminating),
+	),
+	RecoveringFault: planOne(
+		on(SectorRecovering{}, RecoveryWait),
+		on(SectorRecoveryFailed{}, RecoveryFailed),
+	),
+	RecoveryWait: planOne(
+		on(SectorRecovered{}, Proving),
+		on(SectorRecoveryFailed{}, RecoveryFailed),
 	),
+	RecoveryFailed: planOne(
+		on(SectorRecoverFault{}, RecoveringFault),
+		on(SectorTerminate{}, Terminating),
+	),
+
+	Aborting:    planAborting,
+	AbortFailed: planOne(), // Abort is handled in plan
+	Aborted:     planAborting,
 
-	FaultedFinal: final,
-	Removed:      final,
+	Removed: final,
 }
 
 func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(statemachine.Context, SectorInfo) error, error) {
@@ -132,7 +258,7 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		}
 
 		l := Log{
-			Timestamp: uint64(time.Now().Unix()),
+			Timestamp: uint64(m.clock.Now().Unix()),
 			Message:   string(e),
 			Kind:      fmt.Sprintf("event;%T", event.User),
 		}
@@ -144,6 +270,81 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		state.Log = append(state.Log, l)
 	}
 
+	// priority changes, storage moves, deadline assignments and forced
+	// tickets apply in every state, and don't re-run the state handler, which
+	// may be waiting on chain or sealer work already. Guarded events which are
+	// illegal in the state the sector will be in are dropped, failing the
+	// planner would stop the state machine. Aborting drops everything sent
+	// by the cancelled sealer work
+	var rest []statemachine.Event
+	st, checking, aborting := state.State, true, false
+	for _, event := range events {
+		if _, ok := event.User.(SectorAbort); ok {
+			m.jobs.clear(state.SectorNumber)
+			if _, ok := abortStates[state.State]; !ok {
+				m.stateLog(*state).Warnf("dropping abort in state %s", state.State)
+				continue
+			}
+			aborting = true
+			continue
+		}
+		if sp, ok := event.User.(SectorSetPriority); ok {
+			sp.apply(state)
+			continue
+		}
+		if sp, ok := event.User.(SectorPause); ok {
+			sp.apply(state)
+			continue
+		}
+		if sr, ok := event.User.(SectorResume); ok && !m.held.take(state.SectorNumber) {
+			// no handler to run, it's still running or the sector wasn't paused
+			sr.applyGlobal(state)
+			continue
+		}
+		if sm, ok := event.User.(SectorStorageMoved); ok {
+			sm.apply(state)
+			continue
+		}
+		if da, ok := event.User.(SectorDeadlineAssigned); ok {
+			da.apply(state)
+			continue
+		}
+		if ee, ok := event.User.(SectorExpirationExtended); ok {
+			ee.apply(state)
+			continue
+		}
+		if cn, ok := event.User.(SectorCommitNotified); ok {
+			cn.apply(state)
+			continue
+		}
+		if ft, ok := event.User.(SectorForceTicket); ok {
+			if err := checkForceTicket(*state); err != nil {
+				m.stateLog(*state).Warnf("dropping forced ticket: %+v", err)
+				continue
+			}
+			ft.apply(state)
+			continue
+		}
+		if checking {
+			next, guarded, err := checkTransition(st, event.User)
+			if err != nil {
+				m.stateLog(*state).Warnf("dropping event: %+v", err)
+				continue
+			}
+			// the state after unguarded events is up to the planner
+			st, checking = next, guarded
+		}
+		rest = append(rest, event)
+	}
+	if aborting {
+		state.State = Aborting
+		return m.handleAborting, nil
+	}
+	if len(rest) == 0 {
+		return nil, nil
+	}
+	events = rest
+
 	p := fsmPlanners[state.State]
 	if p == nil {
 		return nil, xerrors.Errorf("planner for state %s not found", state.State)
@@ -161,7 +362,10 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		*   Empty
 		|   |
 		|   v
-		*<- Packing <- incoming
+		*<- WaitDeals <- AddPieceToAnySector
+		|   |
+		|   v
+		*<- Packing <- incoming (CC)
 		|   |
 		|   v
 		*<- PreCommit1 <--> SealPreCommit1Failed
@@ -178,7 +382,7 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		|   |||  ^              |
 		|   |||  \--------*-----/
 		|   |||           |
-		|   vvv      v----+----> ComputeProofFailed
+		|   vvv      v----+----> ComputeProofFailed, BadProof
 		*<- Committing    |
 		|   |        ^--> CommitFailed
 		|   v             ^
@@ -186,9 +390,18 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		|   |
 		|   v
 		|   FinalizeSector <--> FinalizeFailed
+		|   |          ^
+		|   |          v
+		|   |          UnsealedCorrupt
 		|   |
 		|   v
-		*<- Proving
+		*<- Proving --> Terminating <--> TerminateFailed
+		|               |     ^
+		|               v     |
+		|               TerminateWait
+		|               |
+		|               v
+		|               Terminated
 		|
 		v
 		FailedUnrecoverable
@@ -197,30 +410,59 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 			|                     ^
 			*---------------------/
 
+		PreCommitWait, CommitWait <--> MessageStuck
+		PreCommitWait, CommitWait --> MessageFailed --> PreCommitFailed, CommitFailed
+
+		sealing states --Abort--> Aborting <--> AbortFailed
+		                          |
+		                          v
+		                          Aborted
+
+		Faulty, FaultedFinal --> RecoveringFault --> RecoveryWait --> Proving
+		                         |      ^            |
+		                         v      |            |
+		                         RecoveryFailed <----/
+
 	*/
 
+	log := m.stateLog(*state)
+
 	switch state.State {
 	// Happy path
+	case WaitDeals:
+		log.Infof("Waiting for deals %d", state.SectorNumber)
 	case Packing:
 		return m.handlePacking, nil
 	case PreCommit1:
 		return m.handlePreCommit1, nil
 	case PreCommit2:
 		return m.handlePreCommit2, nil
+	case WaitDisk:
+		return m.handleWaitDisk, nil
 	case PreCommitting:
 		return m.handlePreCommitting, nil
+	case PledgeInsufficient:
+		return m.handlePledgeInsufficient, nil
 	case PreCommitWait:
 		return m.handlePreCommitWait, nil
+	case CommitHold:
+		return m.handleCommitHold, nil
 	case WaitSeed:
 		return m.handleWaitSeed, nil
 	case Committing:
 		return m.handleCommitting, nil
 	case CommitWait:
 		return m.handleCommitWait, nil
+	case MessageStuck:
+		return m.handleMessageStuck, nil
+	case MessageFailed:
+		return m.handleMessageFailed, nil
 	case FinalizeSector:
 		return m.handleFinalizeSector, nil
 
 	// Handled failure modes
+	case PackingFailed:
+		log.Errorf("sector %d has invalid or unpublished deals", state.SectorNumber)
 	case SealPreCommit1Failed:
 		return m.handleSealPrecommit1Failed, nil
 	case SealPreCommit2Failed:
@@ -229,23 +471,54 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		return m.handlePreCommitFailed, nil
 	case ComputeProofFailed:
 		return m.handleComputeProofFailed, nil
+	case BadProof:
+		return m.handleBadProof, nil
 	case CommitFailed:
 		return m.handleCommitFailed, nil
 	case FinalizeFailed:
 		return m.handleFinalizeFailed, nil
+	case DealsExpired:
+		log.Errorf("sector %d has deals which expired before it could be committed, or outlive the maximum sector lifetime", state.SectorNumber)
+	case DataCommitmentMismatch:
+		log.Errorf("data commitment of sector %d doesn't match its deals, the pieces may have been written out of order", state.SectorNumber)
+	case ExpiredPreCommit:
+		log.Errorf("precommit of sector %d expired before it was proven, lost deposit: %s", state.SectorNumber, state.PreCommitDeposit)
+	case UnsealedCorrupt:
+		log.Errorf("unsealed copy of sector %d doesn't match its deals, fix it and call RetryUnsealedCheck, or remove the sector", state.SectorNumber)
 
 	// Post-seal
 	case Proving:
 		// TODO: track sector health / expiration
 		log.Infof("Proving sector %d", state.SectorNumber)
+		return m.handleProving, nil
+	case Terminating:
+		return m.handleTerminating, nil
+	case TerminateWait:
+		return m.handleTerminateWait, nil
+	case TerminateFailed:
+		log.Errorf("terminating sector %d failed, call Terminate to retry", state.SectorNumber)
+	case Terminated:
+		log.Infof("Sector %d terminated", state.SectorNumber)
 	case Removing:
 		return m.handleRemoving, nil
+	case Aborting:
+		return m.handleAborting, nil
+	case AbortFailed:
+		log.Errorf("removing data of aborted sector %d failed, call Abort to retry", state.SectorNumber)
+	case Aborted:
+		log.Infof("Sector %d aborted", state.SectorNumber)
 
 		// Faults
 	case Faulty:
 		return m.handleFaulty, nil
 	case FaultReported:
 		return m.handleFaultReported, nil
+	case RecoveringFault:
+		return m.handleRecoveringFault, nil
+	case RecoveryWait:
+		return m.handleRecoveryWait, nil
+	case RecoveryFailed:
+		log.Errorf("recovering sector %d failed, call RecoverFault to retry, or Terminate", state.SectorNumber)
 
 	// Fatal errors
 	case UndefinedSectorState:
@@ -280,10 +553,17 @@ func planCommitting(events []statemachine.Event, state *SectorInfo) error {
 			return nil
 		case SectorComputeProofFailed:
 			state.State = ComputeProofFailed
+		case SectorBadProof:
+			state.State = BadProof
 		case SectorSealPreCommit1Failed:
 			state.State = SealPreCommit1Failed
+		case SectorChainPreCommitFailed:
+			state.State = PreCommitFailed
 		case SectorCommitFailed:
 			state.State = CommitFailed
+		case SectorPreCommitExpired:
+			e.apply(state)
+			state.State = ExpiredPreCommit
 		default:
 			return xerrors.Errorf("planCommitting got event of unknown type %T, events: %+v", event.User, events)
 		}
@@ -291,14 +571,131 @@ func planCommitting(events []statemachine.Event, state *SectorInfo) error {
 	return nil
 }
 
-func (m *Sealing) restartSectors(ctx context.Context) error {
+func planWaitDisk(events []statemachine.Event, state *SectorInfo) error {
+	for _, event := range events {
+		switch e := event.User.(type) {
+		case globalMutator:
+			if e.applyGlobal(state) {
+				return nil
+			}
+		case SectorDiskAvailable:
+			e.apply(state)
+			state.State = state.DiskWaitPhase
+			state.DiskWaitPhase = UndefinedSectorState
+		default:
+			return xerrors.Errorf("planWaitDisk got event of unknown type %T, events: %+v", event.User, events)
+		}
+	}
+	return nil
+}
+
+// planMessageStuck returns the sector to the wait state it got stuck in once
+// the message was resent
+func planMessageStuck(events []statemachine.Event, state *SectorInfo) error {
+	return planOne(
+		on(SectorMessageResent{}, state.StuckWait),
+		on(SectorPreCommitLanded{}, WaitSeed),
+		on(SectorHoldCommit{}, CommitHold),
+		on(SectorProving{}, FinalizeSector),
+		on(SectorChainPreCommitFailed{}, PreCommitFailed),
+		on(SectorCommitFailed{}, CommitFailed),
+	)(events, state)
+}
+
+// planWaitDeals handles sector creation, and pieces being added to the
+// sector. Pieces are added in quick succession, so unlike in other states
+// there can be many events to apply at once
+func planWaitDeals(events []statemachine.Event, state *SectorInfo) error {
+	for _, event := range events {
+		switch e := event.User.(type) {
+		case globalMutator:
+			if e.applyGlobal(state) {
+				return nil
+			}
+		case SectorStart:
+			if state.State != UndefinedSectorState {
+				return xerrors.Errorf("got %T in state %s", e, state.State)
+			}
+			e.apply(state)
+			state.State = WaitDeals
+		case SectorStartCC:
+			if state.State != UndefinedSectorState {
+				return xerrors.Errorf("got %T in state %s", e, state.State)
+			}
+			e.apply(state)
+			state.State = Packing
+		case SectorImport:
+			if state.State != UndefinedSectorState {
+				return xerrors.Errorf("got %T in state %s", e, state.State)
+			}
+			e.apply(state)
+			state.State = PreCommitting
+			if e.PreCommitted {
+				state.State = WaitSeed
+			}
+		case SectorAddPiece:
+			if state.State != WaitDeals {
+				return xerrors.Errorf("got %T in state %s", e, state.State)
+			}
+			e.apply(state)
+		case SectorPieceWriting:
+			if state.State != WaitDeals {
+				return xerrors.Errorf("got %T in state %s", e, state.State)
+			}
+			e.apply(state)
+		case SectorPiecesReconciled:
+			if state.State != WaitDeals {
+				return xerrors.Errorf("got %T in state %s", e, state.State)
+			}
+			e.apply(state)
+		case SectorStartPacking:
+			if state.State != WaitDeals {
+				return xerrors.Errorf("got %T in state %s", e, state.State)
+			}
+			e.apply(state)
+			state.State = Packing
+		default:
+			return xerrors.Errorf("planWaitDeals got event of unknown type %T, events: %+v", event.User, events)
+		}
+	}
+	return nil
+}
+
+func (m *Sealing) restartSectors(ctx context.Context, skip map[abi.SectorNumber]struct{}) error {
+	if err := m.reconcileIntents(ctx); err != nil {
+		log.Errorf("reconciling interrupted sealer operations: %+v", err)
+	}
+
 	trackedSectors, err := m.ListSectors()
 	if err != nil {
 		log.Errorf("loading sector list: %+v", err)
 	}
 
 	for _, sector := range trackedSectors {
-		if err := m.sectors.Send(uint64(sector.SectorNumber), SectorRestart{}); err != nil {
+		if _, ok := skip[sector.SectorNumber]; ok {
+			continue
+		}
+
+		if sector.State == WaitDeals {
+			if err := m.reconcilePieces(ctx, &sector); err != nil {
+				log.Errorf("sector %d: reconciling written pieces: %+v", sector.SectorNumber, err)
+			}
+
+			ui, err := sector.unsealedInfo(m.clock.Now())
+			if err != nil {
+				log.Errorf("sector %d isn't accepting pieces: %+v", sector.SectorNumber, err)
+			} else {
+				m.unsealedLk.Lock()
+				m.unsealedInfos[sector.SectorNumber] = ui
+				m.unsealedLk.Unlock()
+			}
+		}
+
+		m.pieces.update(&sector)
+		m.metrics.SectorLoaded(sector.SectorNumber, sector.State)
+		m.counts.loaded(sector.State)
+
+		if err := m.send(sector.SectorNumber, SectorRestart{}); err != nil {
 			log.Errorf("restarting sector %d: %+v", sector.SectorNumber, err)
 		}
 	}
@@ -309,7 +706,7 @@ func (m *Sealing) restartSectors(ctx context.Context) error {
 }
 
 func (m *Sealing) ForceSectorState(ctx context.Context, id abi.SectorNumber, state SectorState) error {
-	return m.sectors.Send(id, SectorForceState{state})
+	return m.send(id, SectorForceState{state})
 }
 
 func final(events []statemachine.Event, state *SectorInfo) error {
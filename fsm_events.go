@@ -1,7 +1,11 @@
 package sealing
 
 import (
+	"bytes"
+
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	"github.com/filecoin-project/specs-storage/storage"
 	"github.com/ipfs/go-cid"
 	"golang.org/x/xerrors"
@@ -45,18 +49,216 @@ func (evt SectorForceState) applyGlobal(state *SectorInfo) bool {
 	return true
 }
 
+// SectorCommitResubmitted records the commit proof and message sent by
+// ResubmitCommit, and moves the sector back to CommitWait
+type SectorCommitResubmitted struct {
+	Proof     []byte
+	Message   cid.Cid
+	SeedValue abi.InteractiveSealRandomness
+	SeedEpoch abi.ChainEpoch
+}
+
+func (evt SectorCommitResubmitted) applyGlobal(state *SectorInfo) bool {
+	state.SeedValue = evt.SeedValue
+	state.SeedEpoch = evt.SeedEpoch
+	state.Proof = evt.Proof
+	state.ProofSeedEpoch = evt.SeedEpoch
+	state.ProofCommR = state.CommR
+	state.CommitMessage = &evt.Message
+	state.StuckWait = UndefinedSectorState
+	state.State = CommitWait
+	return true
+}
+
+// SectorSetPriority overrides the sealer priority of the sector, see
+// SetSectorPriority. Handled in every state by plan
+type SectorSetPriority struct {
+	Priority int64
+}
+
+func (evt SectorSetPriority) apply(state *SectorInfo) {
+	state.Priority = evt.Priority
+}
+
+// SectorPause stops the sector from running state handlers, see Pause.
+// Handled in every state by plan
+type SectorPause struct{}
+
+func (evt SectorPause) apply(state *SectorInfo) {
+	state.Paused = true
+}
+
+// SectorResume lets the sector run state handlers again, see Resume. When a
+// handler was held back while paused, plan passes the event on to the planner
+// so that the handler of the current state runs
+type SectorResume struct{}
+
+func (evt SectorResume) applyGlobal(state *SectorInfo) bool {
+	state.Paused = false
+	return false
+}
+
+// SectorStorageMoved records the sealer relocating the sector files, see
+// MoveStorage. Handled in every state by plan
+type SectorStorageMoved struct {
+	Worker string
+}
+
+func (evt SectorStorageMoved) apply(state *SectorInfo) {
+	if evt.Worker != "" {
+		state.Worker = evt.Worker
+	}
+}
+
+// SectorDeadlineAssigned records the proving deadline and partition of the
+// sector, see assignDeadlines. Handled in every state by plan
+type SectorDeadlineAssigned struct {
+	Deadline  uint64
+	Partition uint64
+}
+
+func (evt SectorDeadlineAssigned) apply(state *SectorInfo) {
+	state.DeadlineAssigned = true
+	state.Deadline = evt.Deadline
+	state.Partition = evt.Partition
+}
+
+// SectorCommitNotified records that Config.OnSectorCommitted was called for
+// the sector. Handled in every state by plan
+type SectorCommitNotified struct{}
+
+func (evt SectorCommitNotified) apply(state *SectorInfo) {
+	state.CommitNotified = true
+}
+
+// SectorExpirationExtended records the expiration set by
+// ExtendSectorExpiration. Handled in every state by plan
+type SectorExpirationExtended struct {
+	Expiration abi.ChainEpoch
+}
+
+func (evt SectorExpirationExtended) apply(state *SectorInfo) {
+	if state.PreCommitInfo == nil {
+		return
+	}
+	pci := *state.PreCommitInfo
+	pci.Expiration = evt.Expiration
+	state.PreCommitInfo = &pci
+}
+
+// SectorForceTicket sets the ticket PreCommit1 uses, see ForceTicket. Handled
+// in every state by plan, ignored once the sector drew its ticket
+type SectorForceTicket struct {
+	Ticket abi.SealRandomness
+	Epoch  abi.ChainEpoch
+}
+
+func (evt SectorForceTicket) apply(state *SectorInfo) {
+	state.ForcedTicket = evt.Ticket
+	state.ForcedTicketEpoch = evt.Epoch
+}
+
 // Normal path
 
 type SectorStart struct {
 	ID         abi.SectorNumber
 	SectorType abi.RegisteredSealProof
-	Pieces     []Piece
+	SealMode   SealMode
 }
 
 func (evt SectorStart) apply(state *SectorInfo) {
+	state.SectorNumber = evt.ID
+	state.SectorType = evt.SectorType
+	state.SealMode = evt.SealMode
+}
+
+// SectorImport creates a sector sealed elsewhere, see ImportSector
+type SectorImport struct {
+	Info         ImportSectorInfo
+	PreCommitted bool
+	TipSet       TipSetToken // the precommit was found at
+}
+
+func (evt SectorImport) apply(state *SectorInfo) {
+	commD, commR := evt.Info.CommD, evt.Info.CommR
+
+	state.SectorNumber = evt.Info.SectorNumber
+	state.SectorType = evt.Info.SealProof
+	state.Pieces = evt.Info.Pieces
+	state.updateDealWeights()
+	state.TicketValue = evt.Info.TicketValue
+	state.TicketEpoch = evt.Info.TicketEpoch
+	state.SeedValue = evt.Info.SeedValue
+	state.SeedEpoch = evt.Info.SeedEpoch
+	state.CommD = &commD
+	state.CommR = &commR
+	state.Proof = evt.Info.Proof
+	state.ProofSeedEpoch = evt.Info.SeedEpoch
+	state.ProofCommR = &commR
+	if evt.PreCommitted {
+		state.PreCommitTipSet = evt.TipSet
+	}
+}
+
+type SectorStartCC struct {
+	ID         abi.SectorNumber
+	SectorType abi.RegisteredSealProof
+	SealMode   SealMode
+	Pieces     []Piece
+}
+
+func (evt SectorStartCC) apply(state *SectorInfo) {
 	state.SectorNumber = evt.ID
 	state.Pieces = evt.Pieces
+	state.updateDealWeights()
 	state.SectorType = evt.SectorType
+	state.SealMode = evt.SealMode
+}
+
+type SectorAddPiece struct {
+	NewPiece Piece
+}
+
+func (evt SectorAddPiece) apply(state *SectorInfo) {
+	state.Pieces = append(state.Pieces, evt.NewPiece)
+	state.updateDealWeights()
+	if evt.NewPiece.DealInfo != nil {
+		state.WritingDeal = nil
+		state.WritingSize = 0
+	}
+}
+
+// SectorPieceWriting records the deal piece about to be written, along with
+// the padding before it
+type SectorPieceWriting struct {
+	Deal DealInfo
+	Size abi.UnpaddedPieceSize
+}
+
+func (evt SectorPieceWriting) apply(state *SectorInfo) {
+	state.WritingDeal = &evt.Deal
+	state.WritingSize = evt.Size
+}
+
+// SectorPiecesReconciled records the pieces found written to the unsealed
+// sector on restart, see reconcilePieces
+type SectorPiecesReconciled struct {
+	Adopted []Piece
+}
+
+func (evt SectorPiecesReconciled) apply(state *SectorInfo) {
+	state.Pieces = append(state.Pieces, evt.Adopted...)
+	state.updateDealWeights()
+	state.WritingDeal = nil
+	state.WritingSize = 0
+}
+
+type SectorStartPacking struct{}
+
+func (evt SectorStartPacking) apply(state *SectorInfo) {
+	// a piece which failed to write isn't added anymore
+	state.WritingDeal = nil
+	state.WritingSize = 0
 }
 
 type SectorPacked struct{ FillerPieces []abi.PieceInfo }
@@ -72,12 +274,72 @@ func (evt SectorPacked) apply(state *SectorInfo) {
 
 type SectorPackingFailed struct{ error }
 
-func (evt SectorPackingFailed) apply(*SectorInfo) {}
+func (evt SectorPackingFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorPackingFailed) apply(*SectorInfo)                        {}
+
+// SectorPreCommitExpired is sent when the precommit of the sector can't be
+// proven anymore
+type SectorPreCommitExpired struct {
+	Deposit abi.TokenAmount
+}
+
+func (evt SectorPreCommitExpired) apply(state *SectorInfo) {
+	state.PreCommitDeposit = evt.Deposit
+}
+
+type SectorDataCommitmentMismatch struct{ error }
+
+func (evt SectorDataCommitmentMismatch) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorDataCommitmentMismatch) apply(*SectorInfo)                        {}
+
+type SectorDealsExpired struct{ error }
+
+func (evt SectorDealsExpired) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorDealsExpired) apply(*SectorInfo)                        {}
+
+// SectorDropExpiredDeals drops all pieces from a sector which hasn't started
+// sealing, so that it gets repacked with filler data
+type SectorDropExpiredDeals struct{}
+
+func (evt SectorDropExpiredDeals) apply(state *SectorInfo) {
+	state.Pieces = nil
+	state.updateDealWeights()
+}
+
+// SectorDropUnpublishedDeals drops all pieces from a packing sector whose
+// deals were never published
+type SectorDropUnpublishedDeals struct{}
+
+func (evt SectorDropUnpublishedDeals) apply(state *SectorInfo) {
+	state.Pieces = nil
+	state.updateDealWeights()
+}
+
+type SectorWaitDisk struct {
+	Phase SectorState
+}
+
+func (evt SectorWaitDisk) apply(state *SectorInfo) {
+	state.DiskWaitPhase = evt.Phase
+}
+
+type SectorDiskAvailable struct{}
+
+func (evt SectorDiskAvailable) apply(*SectorInfo) {}
+
+type SectorPledgeInsufficient struct{}
+
+func (evt SectorPledgeInsufficient) apply(*SectorInfo) {}
+
+type SectorPledgeAvailable struct{}
+
+func (evt SectorPledgeAvailable) apply(*SectorInfo) {}
 
 type SectorPreCommit1 struct {
 	PreCommit1Out storage.PreCommit1Out
 	TicketValue   abi.SealRandomness
 	TicketEpoch   abi.ChainEpoch
+	Worker        string
 }
 
 func (evt SectorPreCommit1) apply(state *SectorInfo) {
@@ -85,11 +347,26 @@ func (evt SectorPreCommit1) apply(state *SectorInfo) {
 	state.TicketEpoch = evt.TicketEpoch
 	state.TicketValue = evt.TicketValue
 	state.PreCommit2Fails = 0
+	state.Worker = evt.Worker
+}
+
+// SectorTicketExpired restarts sealing of a sector whose ticket got too old to
+// be precommitted, PreCommit1 draws a new one
+type SectorTicketExpired struct{ error }
+
+func (evt SectorTicketExpired) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorTicketExpired) apply(state *SectorInfo) {
+	state.TicketValue = nil
+	state.TicketEpoch = 0
+	state.ForcedTicket = nil
+	state.ForcedTicketEpoch = 0
+	state.PreCommit1Out = nil
 }
 
 type SectorPreCommit2 struct {
 	Sealed   cid.Cid
 	Unsealed cid.Cid
+	Worker   string
 }
 
 func (evt SectorPreCommit2) apply(state *SectorInfo) {
@@ -97,6 +374,7 @@ func (evt SectorPreCommit2) apply(state *SectorInfo) {
 	state.CommD = &commd
 	commr := evt.Sealed
 	state.CommR = &commr
+	state.Worker = evt.Worker
 }
 
 type SectorPreCommitLanded struct {
@@ -107,6 +385,20 @@ func (evt SectorPreCommitLanded) apply(si *SectorInfo) {
 	si.PreCommitTipSet = evt.TipSet
 }
 
+// SectorHoldCommit is SectorPreCommitLanded for sectors which should wait for
+// a commit trigger
+type SectorHoldCommit struct {
+	TipSet TipSetToken
+}
+
+func (evt SectorHoldCommit) apply(si *SectorInfo) {
+	si.PreCommitTipSet = evt.TipSet
+}
+
+type SectorCommitTrigger struct{}
+
+func (evt SectorCommitTrigger) apply(*SectorInfo) {}
+
 type SectorSealPreCommit1Failed struct{ error }
 
 func (evt SectorSealPreCommit1Failed) FormatError(xerrors.Printer) (next error) { return evt.error }
@@ -129,11 +421,52 @@ func (evt SectorChainPreCommitFailed) FormatError(xerrors.Printer) (next error)
 func (evt SectorChainPreCommitFailed) apply(*SectorInfo)                        {}
 
 type SectorPreCommitted struct {
-	Message cid.Cid
+	Message       cid.Cid
+	PreCommitInfo miner.SectorPreCommitInfo
 }
 
 func (evt SectorPreCommitted) apply(state *SectorInfo) {
 	state.PreCommitMessage = &evt.Message
+	state.PreCommitInfo = &evt.PreCommitInfo
+}
+
+// SectorMessageStuck is sent when the message waited for in the Wait state
+// didn't land within MessageWaitTimeout
+type SectorMessageStuck struct {
+	Wait SectorState
+}
+
+func (evt SectorMessageStuck) apply(state *SectorInfo) {
+	state.StuckWait = evt.Wait
+}
+
+// SectorMessageFailed is sent when the message waited for in the Wait state
+// was executed with a non-zero exit code
+type SectorMessageFailed struct {
+	Wait     SectorState
+	ExitCode exitcode.ExitCode
+	GasUsed  int64
+}
+
+func (evt SectorMessageFailed) apply(state *SectorInfo) {
+	state.FailedWait = evt.Wait
+	state.MessageExitCode = evt.ExitCode
+	state.MessageGasUsed = evt.GasUsed
+}
+
+// SectorMessageResent replaces the stuck message with a resent one
+type SectorMessageResent struct {
+	Message cid.Cid
+}
+
+func (evt SectorMessageResent) apply(state *SectorInfo) {
+	switch state.StuckWait {
+	case PreCommitWait:
+		state.PreCommitMessage = &evt.Message
+	case CommitWait:
+		state.CommitMessage = &evt.Message
+	}
+	state.StuckWait = UndefinedSectorState
 }
 
 type SectorSeedReady struct {
@@ -142,6 +475,9 @@ type SectorSeedReady struct {
 }
 
 func (evt SectorSeedReady) apply(state *SectorInfo) {
+	if evt.SeedEpoch != state.SeedEpoch || !bytes.Equal(evt.SeedValue, state.SeedValue) {
+		state.Proof = nil // computed for the old seed
+	}
 	state.SeedEpoch = evt.SeedEpoch
 	state.SeedValue = evt.SeedValue
 }
@@ -151,28 +487,67 @@ type SectorComputeProofFailed struct{ error }
 func (evt SectorComputeProofFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
 func (evt SectorComputeProofFailed) apply(*SectorInfo)                        {}
 
+// SectorBadProof is sent when the computed commit proof doesn't verify
+type SectorBadProof struct{ error }
+
+func (evt SectorBadProof) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorBadProof) apply(*SectorInfo)                        {}
+
 type SectorCommitFailed struct{ error }
 
 func (evt SectorCommitFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
 func (evt SectorCommitFailed) apply(*SectorInfo)                        {}
 
 type SectorCommitted struct {
-	Message cid.Cid
-	Proof   []byte
+	Message   cid.Cid
+	Proof     []byte
+	SeedEpoch abi.ChainEpoch // the proof was computed for
+	Worker    string
 }
 
 func (evt SectorCommitted) apply(state *SectorInfo) {
 	state.Proof = evt.Proof
+	state.ProofSeedEpoch = evt.SeedEpoch
+	state.ProofCommR = state.CommR
 	state.CommitMessage = &evt.Message
+	state.Worker = evt.Worker
 }
 
-type SectorProving struct{}
+type SectorCommitReverted struct{}
 
-func (evt SectorProving) apply(*SectorInfo) {}
+func (evt SectorCommitReverted) apply(state *SectorInfo) {
+	state.CommitMessage = nil
+}
 
-type SectorFinalized struct{}
+type SectorProving struct {
+	InactiveDeals []DealInfo // deals which weren't activated, see sectorProven
+}
 
-func (evt SectorFinalized) apply(*SectorInfo) {}
+func (evt SectorProving) apply(state *SectorInfo) {
+	if len(evt.InactiveDeals) == 0 {
+		return
+	}
+
+	inactive := map[abi.DealID]bool{}
+	for _, d := range evt.InactiveDeals {
+		inactive[d.DealID] = true
+	}
+	for i, p := range state.Pieces {
+		if p.DealInfo != nil && inactive[p.DealInfo.DealID] {
+			state.Pieces[i].DealInfo = nil
+		}
+	}
+	state.InactiveDeals = append(state.InactiveDeals, evt.InactiveDeals...)
+	state.updateDealWeights()
+}
+
+type SectorFinalized struct {
+	Worker string
+}
+
+func (evt SectorFinalized) apply(state *SectorInfo) {
+	state.Worker = evt.Worker
+}
 
 type SectorRetryFinalize struct{}
 
@@ -183,6 +558,18 @@ type SectorFinalizeFailed struct{ error }
 func (evt SectorFinalizeFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
 func (evt SectorFinalizeFailed) apply(*SectorInfo)                        {}
 
+// SectorUnsealedCorrupt is sent when the unsealed copy of a sector doesn't
+// match the pieces of its deals. Checking it again won't help until the copy
+// is fixed, see RetryUnsealedCheck
+type SectorUnsealedCorrupt struct{ error }
+
+func (evt SectorUnsealedCorrupt) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorUnsealedCorrupt) apply(*SectorInfo)                        {}
+
+type SectorRetryUnsealedCheck struct{}
+
+func (evt SectorRetryUnsealedCheck) apply(*SectorInfo) {}
+
 // Failed state recovery
 
 type SectorRetrySealPreCommit1 struct{}
@@ -215,6 +602,7 @@ type SectorRetryInvalidProof struct{}
 
 func (evt SectorRetryInvalidProof) apply(state *SectorInfo) {
 	state.InvalidProofs++
+	state.Proof = nil // don't reuse it
 }
 
 // Faults
@@ -231,8 +619,48 @@ func (evt SectorFaultReported) apply(state *SectorInfo) {
 
 type SectorFaultedFinal struct{}
 
+func (evt SectorFaultedFinal) apply(state *SectorInfo) {}
+
+type SectorRecovering struct{ Message cid.Cid }
+
+func (evt SectorRecovering) apply(state *SectorInfo) {
+	state.RecoveryMessage = &evt.Message
+}
+
+type SectorRecovered struct{}
+
+func (evt SectorRecovered) apply(state *SectorInfo) {}
+
+type SectorRecoveryFailed struct{ error }
+
+func (evt SectorRecoveryFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorRecoveryFailed) apply(*SectorInfo)                        {}
+
 // External events
 
+type SectorRecoverFault struct{}
+
+func (evt SectorRecoverFault) apply(state *SectorInfo) {}
+
+type SectorTerminate struct{}
+
+func (evt SectorTerminate) apply(state *SectorInfo) {}
+
+type SectorTerminating struct{ Message cid.Cid }
+
+func (evt SectorTerminating) apply(state *SectorInfo) {
+	state.TerminateMessage = &evt.Message
+}
+
+type SectorTerminated struct{}
+
+func (evt SectorTerminated) apply(state *SectorInfo) {}
+
+type SectorTerminateFailed struct{ error }
+
+func (evt SectorTerminateFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorTerminateFailed) apply(*SectorInfo)                        {}
+
 type SectorRemove struct{}
 
 func (evt SectorRemove) apply(state *SectorInfo) {}
@@ -245,3 +673,16 @@ type SectorRemoveFailed struct{ error }
 
 func (evt SectorRemoveFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
 func (evt SectorRemoveFailed) apply(*SectorInfo)                        {}
+
+type SectorAbort struct{}
+
+func (evt SectorAbort) apply(state *SectorInfo) {}
+
+type SectorAborted struct{}
+
+func (evt SectorAborted) apply(state *SectorInfo) {}
+
+type SectorAbortFailed struct{ error }
+
+func (evt SectorAbortFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorAbortFailed) apply(*SectorInfo)                        {}
@@ -26,7 +26,7 @@ type test struct {
 
 func TestHappyPath(t *testing.T) {
 	m := test{
-		s:     &Sealing{},
+		s:     &Sealing{clock: realClock{}},
 		t:     t,
 		state: &SectorInfo{State: Packing},
 	}
@@ -61,7 +61,7 @@ func TestHappyPath(t *testing.T) {
 
 func TestSeedRevert(t *testing.T) {
 	m := test{
-		s:     &Sealing{},
+		s:     &Sealing{clock: realClock{}},
 		t:     t,
 		state: &SectorInfo{State: Packing},
 	}
@@ -102,7 +102,7 @@ func TestSeedRevert(t *testing.T) {
 
 func TestPlanCommittingHandlesSectorCommitFailed(t *testing.T) {
 	m := test{
-		s:     &Sealing{},
+		s:     &Sealing{clock: realClock{}},
 		t:     t,
 		state: &SectorInfo{State: Committing},
 	}
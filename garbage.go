@@ -6,7 +6,7 @@ import (
 
 	"golang.org/x/xerrors"
 
-	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	sectorstorage "github.com/filecoin-project/sector-storage"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 
 	nr "github.com/filecoin-project/storage-fsm/lib/nullreader"
@@ -25,7 +25,7 @@ func (m *Sealing) pledgeSector(ctx context.Context, sectorID abi.SectorID, exist
 
 	out := make([]abi.PieceInfo, len(sizes))
 	for i, size := range sizes {
-		ppi, err := m.sealer.AddPiece(ctx, sectorID, existingPieceSizes, size, m.pledgeReader(size))
+		ppi, err := m.sealerAddPiece(ctx, sectorID, existingPieceSizes, size, m.pledgeReader(size))
 		if err != nil {
 			return nil, xerrors.Errorf("add piece: %w", err)
 		}
@@ -38,49 +38,59 @@ func (m *Sealing) pledgeSector(ctx context.Context, sectorID abi.SectorID, exist
 	return out, nil
 }
 
-func (m *Sealing) PledgeSector() error {
-	go func() {
-		ctx := context.TODO() // we can't use the context from command which invokes
-		// this, as we run everything here async, and it's cancelled when the
-		// command exits
+// PledgeSector creates a committed capacity sector, fills it with a junk
+// piece, and starts sealing it. Returns once the sector state machine is
+// started; ctx is only used for creating the sector, and writing the piece
+func (m *Sealing) PledgeSector(ctx context.Context) (abi.SectorNumber, error) {
+	if m.cfg.Priorities.CC != 0 {
+		ctx = sectorstorage.WithPriority(ctx, m.cfg.Priorities.CC)
+	}
 
-		size := abi.PaddedPieceSize(m.sealer.SectorSize()).Unpadded()
+	size := abi.PaddedPieceSize(m.sealer.SectorSize()).Unpadded()
+	rt, err := m.sealProofType(m.sealer.SectorSize())
+	if err != nil {
+		return 0, xerrors.Errorf("bad sector size: %w", err)
+	}
 
-		rt, err := ffiwrapper.SealProofTypeFromSectorSize(m.sealer.SectorSize())
-		if err != nil {
-			log.Error(err)
-			return
-		}
+	res, err := m.sc.Reserve()
+	if err != nil {
+		return 0, xerrors.Errorf("getting sector number: %w", err)
+	}
+	sid := res.Number()
 
-		sid, err := m.sc.Next()
-		if err != nil {
-			log.Errorf("%+v", err)
-			return
-		}
-		err = m.sealer.NewSector(ctx, m.minerSector(sid))
-		if err != nil {
-			log.Errorf("%+v", err)
-			return
+	if err := m.checkSectorSpace(ctx, sid, rt); err != nil {
+		if err := res.Abort(); err != nil {
+			log.Errorf("aborting sector number %d reservation: %+v", sid, err)
 		}
+		return 0, err
+	}
 
-		pieces, err := m.pledgeSector(ctx, m.minerSector(sid), []abi.UnpaddedPieceSize{}, size)
-		if err != nil {
-			log.Errorf("%+v", err)
-			return
+	if err := m.sealer.NewSector(ctx, m.minerSector(sid)); err != nil {
+		if err := res.Abort(); err != nil {
+			log.Errorf("aborting sector number %d reservation: %+v", sid, err)
 		}
+		return 0, xerrors.Errorf("initializing sector: %w", err)
+	}
+	if err := res.Commit(); err != nil {
+		return 0, xerrors.Errorf("committing sector number: %w", err)
+	}
 
-		ps := make([]Piece, len(pieces))
-		for idx := range ps {
-			ps[idx] = Piece{
-				Piece:    pieces[idx],
-				DealInfo: nil,
-			}
-		}
+	pieces, err := m.pledgeSector(ctx, m.minerSector(sid), []abi.UnpaddedPieceSize{}, size)
+	if err != nil {
+		return 0, xerrors.Errorf("pledging sector %d: %w", sid, err)
+	}
 
-		if err := m.newSector(sid, rt, ps); err != nil {
-			log.Errorf("%+v", err)
-			return
+	ps := make([]Piece, len(pieces))
+	for idx := range ps {
+		ps[idx] = Piece{
+			Piece:    pieces[idx],
+			DealInfo: nil,
 		}
-	}()
-	return nil
+	}
+
+	if err := m.newSectorCC(ctx, sid, ps); err != nil {
+		return 0, xerrors.Errorf("starting sector %d: %w", sid, err)
+	}
+
+	return sid, nil
 }
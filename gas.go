@@ -0,0 +1,34 @@
+package sealing
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+)
+
+// used when gas estimation fails
+var (
+	fallbackGasPrice       = big.NewInt(1)
+	fallbackGasLimit int64 = 1000000
+)
+
+// messageGas returns the gas price and limit to send a message with. Estimation
+// errors aren't fatal, conservative defaults are used instead
+func (m *Sealing) messageGas(ctx context.Context, from, to address.Address, method abi.MethodNum, value big.Int, params []byte) (big.Int, int64) {
+	gasPrice, gasLimit, err := m.api.EstimateMessageGas(ctx, from, to, method, value, params)
+	if err != nil {
+		log.Warnf("estimating gas for method %d failed, using defaults: %+v", method, err)
+		gasPrice, gasLimit = fallbackGasPrice, fallbackGasLimit
+	}
+
+	if m.cfg.MaxFee.Int != nil && gasLimit > 0 {
+		if fee := big.Mul(gasPrice, big.NewInt(gasLimit)); fee.GreaterThan(m.cfg.MaxFee) {
+			log.Warnf("estimated fee %s for method %d is over the limit of %s, lowering gas price", fee, method, m.cfg.MaxFee)
+			gasPrice = big.Div(m.cfg.MaxFee, big.NewInt(gasLimit))
+		}
+	}
+
+	return gasPrice, gasLimit
+}
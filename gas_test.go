@@ -0,0 +1,49 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+)
+
+func TestCommitUsesGasEstimate(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.gasPrice, h.api.gasLimit = big.NewInt(7), 1234
+
+	h.start(h.committingSector(1))
+	h.waitState(1, Proving)
+
+	sent := h.api.sentMsgs()
+	require.Len(t, sent, 1)
+	require.Equal(t, builtin.MethodsMiner.ProveCommitSector, sent[0].method)
+	require.Equal(t, big.NewInt(7), sent[0].gasPrice)
+	require.Equal(t, int64(1234), sent[0].gasLimit)
+}
+
+func TestMessageGas(t *testing.T) {
+	h := newTestHarness(t, Config{MaxFee: big.NewInt(50000)})
+	addr := h.m.maddr
+	method := builtin.MethodsMiner.PreCommitSector
+
+	h.api.gasPrice, h.api.gasLimit = big.NewInt(40), 1000
+	price, limit := h.m.messageGas(context.Background(), addr, addr, method, big.Zero(), nil)
+	require.Equal(t, big.NewInt(40), price)
+	require.Equal(t, int64(1000), limit)
+
+	// gas spike
+	h.api.gasPrice = big.NewInt(100)
+	price, limit = h.m.messageGas(context.Background(), addr, addr, method, big.Zero(), nil)
+	require.Equal(t, big.NewInt(50), price)
+	require.Equal(t, int64(1000), limit)
+
+	h.m.cfg.MaxFee = big.Int{}
+	h.api.gasErr = xerrors.New("estimation unavailable")
+	price, limit = h.m.messageGas(context.Background(), addr, addr, method, big.Zero(), nil)
+	require.Equal(t, fallbackGasPrice, price)
+	require.Equal(t, fallbackGasLimit, limit)
+}
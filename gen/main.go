@@ -16,6 +16,8 @@ func main() {
 		sealing.DealSchedule{},
 		sealing.SectorInfo{},
 		sealing.Log{},
+		sealing.SectorTimings{},
+		sealing.PhaseTiming{},
 	)
 	if err != nil {
 		fmt.Println(err)
@@ -0,0 +1,83 @@
+package sealing
+
+import (
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// Health is a snapshot of conditions which affect sealing progress
+type Health struct {
+	Chain ChainHealth
+
+	// CommitDeadlines lists sectors held in CommitHold which are getting close
+	// to their prove-commit deadline
+	CommitDeadlines []CommitDeadlineAlert
+}
+
+// ChainHealth reports whether the chain node is usable. The node is considered
+// unavailable when Config.ChainFailureThreshold chain calls failed in a row
+type ChainHealth struct {
+	Available bool
+
+	// Error is the last chain call error, set when the node is unavailable
+	Error string
+	// RetryAt is when chain calls will be let through again
+	RetryAt time.Time
+}
+
+// CommitDeadlineAlert is raised CommitHoldMargin epochs before a held sector
+// is committed automatically
+type CommitDeadlineAlert struct {
+	SectorNumber abi.SectorNumber
+
+	// TriggerAt is the epoch at which the sector will be committed
+	TriggerAt abi.ChainEpoch
+	// Deadline is the last epoch at which the commit can land on chain
+	Deadline abi.ChainEpoch
+}
+
+// Health reports conditions which affect sealing progress
+func (m *Sealing) Health() Health {
+	return Health{
+		Chain:           m.breaker.health(),
+		CommitDeadlines: m.commitAlerts(),
+	}
+}
+
+func (m *Sealing) addCommitAlert(a CommitDeadlineAlert) {
+	m.alertLk.Lock()
+	defer m.alertLk.Unlock()
+
+	if m.commitDeadlineAlerts == nil {
+		m.commitDeadlineAlerts = map[abi.SectorNumber]CommitDeadlineAlert{}
+	}
+	m.commitDeadlineAlerts[a.SectorNumber] = a
+}
+
+// commitAlerts returns alerts of sectors which are still held, and drops the
+// rest
+func (m *Sealing) commitAlerts() []CommitDeadlineAlert {
+	m.alertLk.Lock()
+	defer m.alertLk.Unlock()
+
+	var out []CommitDeadlineAlert
+	for sn, a := range m.commitDeadlineAlerts {
+		si, err := m.GetSectorInfo(sn)
+		if err != nil {
+			log.Errorf("getting info of sector %d: %+v", sn, err)
+		}
+		if err != nil || si.State != CommitHold {
+			delete(m.commitDeadlineAlerts, sn)
+			continue
+		}
+		out = append(out, a)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].TriggerAt < out[j].TriggerAt
+	})
+
+	return out
+}
@@ -0,0 +1,125 @@
+package sealing
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// ErrSectorExists is returned by ImportSector for sector numbers which are
+// already used by a sector of this node, or on chain
+type ErrSectorExists struct{ error }
+
+// ImportSectorInfo describes a sector sealed outside of this node, see
+// ImportSector
+type ImportSectorInfo struct {
+	SectorNumber abi.SectorNumber
+	SealProof    abi.RegisteredSealProof
+	Pieces       []Piece
+
+	TicketValue abi.SealRandomness
+	TicketEpoch abi.ChainEpoch
+	SeedValue   abi.InteractiveSealRandomness
+	SeedEpoch   abi.ChainEpoch
+
+	CommD cid.Cid
+	CommR cid.Cid
+	Proof []byte
+}
+
+// ImportSector registers a sector sealed elsewhere, whose sealed and unsealed
+// data were already moved to the sealer storage. The proof is verified
+// before the sector is created. Sectors not yet precommitted continue from
+// PreCommitting, the others from WaitSeed - either way the commit proof is
+// computed again against the seed on chain.
+//
+// The number has to be one the SectorIDCounter won't hand out anymore. Only
+// a ReconcilingSectorIDCounter is advanced past it
+func (m *Sealing) ImportSector(ctx context.Context, info ImportSectorInfo) error {
+	log := m.sectorLog(info.SectorNumber)
+
+	if _, err := m.GetSectorInfo(info.SectorNumber); err == nil {
+		return &ErrSectorExists{xerrors.Errorf("sector %d already exists", info.SectorNumber)}
+	} else if !xerrors.As(err, new(*ErrSectorNotFound)) {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	ss, err := info.SealProof.SectorSize()
+	if err != nil {
+		return xerrors.Errorf("bad seal proof type: %w", err)
+	}
+	if !m.dealSectorSize(ss) {
+		return xerrors.Errorf("sector %d has size %d, which isn't one of the sector sizes %v", info.SectorNumber, ss, m.dealSectorSizes())
+	}
+
+	var size abi.PaddedPieceSize
+	for _, p := range info.Pieces {
+		size += p.Piece.Size
+	}
+	if size != abi.PaddedPieceSize(ss) {
+		return xerrors.Errorf("pieces of sector %d take %d bytes, the sector has %d", info.SectorNumber, size, ss)
+	}
+
+	tok, _, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	onChain, err := m.api.StateSectorGetInfo(ctx, m.maddr, info.SectorNumber, tok)
+	if err != nil {
+		return xerrors.Errorf("getting on chain sector info: %w", err)
+	}
+	if onChain != nil {
+		return &ErrSectorExists{xerrors.Errorf("sector %d is already committed on chain", info.SectorNumber)}
+	}
+
+	pci, err := m.api.StateSectorPreCommitInfo(ctx, m.maddr, info.SectorNumber, tok)
+	if err != nil {
+		return xerrors.Errorf("getting precommit info: %w", err)
+	}
+	if pci != nil {
+		if pci.Info.SealedCID != info.CommR {
+			return &ErrSectorExists{xerrors.Errorf("sector %d is precommitted with a different sealed CID: %s != %s", info.SectorNumber, pci.Info.SealedCID, info.CommR)}
+		}
+		if pci.Info.SealRandEpoch != info.TicketEpoch {
+			return &ErrBadTicket{xerrors.Errorf("ticket epoch %d doesn't match the precommit %d", info.TicketEpoch, pci.Info.SealRandEpoch)}
+		}
+	}
+
+	ok, err := m.verif.VerifySeal(abi.SealVerifyInfo{
+		SectorID:              m.minerSector(info.SectorNumber),
+		SealedCID:             info.CommR,
+		SealProof:             info.SealProof,
+		Proof:                 info.Proof,
+		Randomness:            info.TicketValue,
+		InteractiveRandomness: info.SeedValue,
+		UnsealedCID:           info.CommD,
+	})
+	if err != nil {
+		return xerrors.Errorf("verify seal: %w", err)
+	}
+	if !ok {
+		return &ErrInvalidProof{xerrors.Errorf("invalid proof of sector %d", info.SectorNumber)}
+	}
+
+	si := SectorInfo{SectorNumber: info.SectorNumber, Pieces: info.Pieces}
+	if err := checkPieces(ctx, si, m.api); err != nil {
+		return xerrors.Errorf("checking pieces: %w", err)
+	}
+
+	if m.reconciler != nil {
+		if err := m.reconciler.Reconcile(info.SectorNumber); err != nil {
+			return xerrors.Errorf("advancing sector counter: %w", err)
+		}
+	}
+
+	log.Infow("Importing sector", "precommitted", pci != nil)
+	return m.send(info.SectorNumber, SectorImport{
+		Info:         info,
+		PreCommitted: pci != nil,
+		TipSet:       tok,
+	})
+}
@@ -0,0 +1,109 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+type rejectVerifier struct {
+	ffiwrapper.Verifier
+}
+
+func (rejectVerifier) VerifySeal(abi.SealVerifyInfo) (bool, error) {
+	return false, nil
+}
+
+func importInfo(sn abi.SectorNumber) ImportSectorInfo {
+	return ImportSectorInfo{
+		SectorNumber: sn,
+		SealProof:    abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces: []Piece{{
+			Piece: abi.PieceInfo{Size: 2048, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(2048).Unpadded())},
+		}},
+		TicketValue: abi.SealRandomness(testRand),
+		TicketEpoch: 1500,
+		SeedValue:   abi.InteractiveSealRandomness(testRand),
+		SeedEpoch:   1600,
+		CommD:       testCommD,
+		CommR:       testCommR,
+		Proof:       []byte{1},
+	}
+}
+
+func TestImportSector(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.setHead(2000)
+	ctx := context.Background()
+
+	require.NoError(t, h.m.ImportSector(ctx, importInfo(1)))
+	si := h.waitState(1, WaitSeed)
+	require.Equal(t, abi.ChainEpoch(1500), si.TicketEpoch)
+
+	h.api.lk.Lock()
+	require.Equal(t, abi.ChainEpoch(1500), h.api.precommits[1].Info.SealRandEpoch)
+	h.api.lk.Unlock()
+
+	h.api.setHead(2000 + miner.PreCommitChallengeDelay)
+	h.waitState(1, Proving)
+
+	err := h.m.ImportSector(ctx, importInfo(1))
+	require.True(t, xerrors.As(err, new(*ErrSectorExists)), "%+v", err)
+}
+
+func TestImportPreCommittedSector(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.setHead(2000)
+
+	h.api.lk.Lock()
+	h.api.precommits[1] = &miner.SectorPreCommitOnChainInfo{
+		Info: miner.SectorPreCommitInfo{
+			SealProof:     abi.RegisteredSealProof_StackedDrg2KiBV1,
+			SectorNumber:  1,
+			SealedCID:     testCommR,
+			SealRandEpoch: 1500,
+		},
+		PreCommitEpoch: 1600,
+	}
+	h.api.lk.Unlock()
+
+	require.NoError(t, h.m.ImportSector(context.Background(), importInfo(1)))
+	si := h.waitState(1, Proving)
+	require.Nil(t, si.PreCommitMessage)
+	require.Equal(t, 1600+miner.PreCommitChallengeDelay, si.SeedEpoch)
+}
+
+func TestImportSectorRejected(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.setHead(2000)
+	ctx := context.Background()
+
+	h.api.lk.Lock()
+	h.api.sectors[1] = &miner.SectorOnChainInfo{}
+	h.api.lk.Unlock()
+
+	err := h.m.ImportSector(ctx, importInfo(1))
+	require.True(t, xerrors.As(err, new(*ErrSectorExists)), "%+v", err)
+
+	sid, _ := h.addDeal(1, 1024)
+	err = h.m.ImportSector(ctx, importInfo(sid))
+	require.True(t, xerrors.As(err, new(*ErrSectorExists)), "%+v", err)
+
+	info := importInfo(2)
+	info.Pieces[0].Piece.Size = 1024
+	require.Error(t, h.m.ImportSector(ctx, info))
+
+	h.m.verif = rejectVerifier{}
+	err = h.m.ImportSector(ctx, importInfo(2))
+	require.True(t, xerrors.As(err, new(*ErrInvalidProof)), "%+v", err)
+
+	_, err = h.m.GetSectorInfo(2)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)), "%+v", err)
+}
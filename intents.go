@@ -0,0 +1,169 @@
+package sealing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+const SectorIntentPrefix = "/sector-intents"
+
+// IntentKind is a sealer operation which changes the files of a sector
+// outside of the sector record
+type IntentKind string
+
+const (
+	IntentAddPiece    IntentKind = "AddPiece"
+	IntentFinalize    IntentKind = "Finalize"
+	IntentMoveStorage IntentKind = "MoveStorage"
+	IntentRemove      IntentKind = "Remove"
+)
+
+// Intent is recorded with Config.SealerIntents before a sealer operation
+// starts, and dropped once it returns. An intent left over on startup means
+// the node went down during the operation, see reconcileIntents
+type Intent struct {
+	Sector  abi.SectorNumber
+	Kind    IntentKind
+	Started time.Time
+}
+
+func intentKey(sid abi.SectorNumber, kind IntentKind) datastore.Key {
+	return datastore.NewKey(fmt.Sprint(uint64(sid))).ChildString(string(kind))
+}
+
+// beginIntent records the intent to run the sealer operation on the sector.
+// The returned func drops it, and has to be called once the operation
+// returned, whether it failed or not
+func (m *Sealing) beginIntent(sid abi.SectorNumber, kind IntentKind) (func(), error) {
+	if m.intents == nil {
+		return func() {}, nil
+	}
+
+	b, err := json.Marshal(Intent{Sector: sid, Kind: kind, Started: m.clock.Now()})
+	if err != nil {
+		return nil, xerrors.Errorf("encoding %s intent: %w", kind, err)
+	}
+	key := intentKey(sid, kind)
+	if err := m.intents.Put(key, b); err != nil {
+		return nil, xerrors.Errorf("recording %s intent of sector %d: %w", kind, sid, err)
+	}
+
+	return func() {
+		if err := m.intents.Delete(key); err != nil {
+			m.sectorLog(sid).Errorf("dropping %s intent of sector %d: %+v", kind, sid, err)
+		}
+	}, nil
+}
+
+// DanglingIntents returns the intents of sealer operations which didn't
+// return, ordered by sector. Empty unless Config.SealerIntents is set
+func (m *Sealing) DanglingIntents() ([]Intent, error) {
+	if m.intents == nil {
+		return nil, nil
+	}
+
+	res, err := m.intents.Query(query.Query{Orders: []query.Order{query.OrderByKey{}}})
+	if err != nil {
+		return nil, xerrors.Errorf("querying intents: %w", err)
+	}
+	defer res.Close() // nolint:errcheck
+
+	var out []Intent
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating intents: %w", r.Error)
+		}
+
+		var in Intent
+		if err := json.Unmarshal(r.Value, &in); err != nil {
+			return nil, xerrors.Errorf("decoding intent %s: %w", r.Key, err)
+		}
+		out = append(out, in)
+	}
+
+	return out, nil
+}
+
+// reconcileIntents resolves the intents left over by interrupted operations,
+// before sectors are restarted. Sectors accepting deals reconcile their
+// written pieces anyway, see reconcilePieces, the filler pieces of Packing
+// sectors are rolled back so that handlePacking writes them again. Finalize
+// and Remove are run again by the state handler if the sector didn't move on,
+// MoveStorage is run again. Intents which can't be resolved are kept, and
+// retried on the next startup
+func (m *Sealing) reconcileIntents(ctx context.Context) error {
+	intents, err := m.DanglingIntents()
+	if err != nil {
+		return err
+	}
+
+	var moves []abi.SectorNumber
+	for _, in := range intents {
+		log := m.sectorLog(in.Sector)
+		log.Warnf("sector %d: %s started at %s didn't finish, reconciling", in.Sector, in.Kind, in.Started)
+
+		if err := m.resolveIntent(ctx, in); err != nil {
+			log.Errorf("sector %d: reconciling interrupted %s: %+v", in.Sector, in.Kind, err)
+			continue
+		}
+		if err := m.intents.Delete(intentKey(in.Sector, in.Kind)); err != nil {
+			return xerrors.Errorf("dropping %s intent of sector %d: %w", in.Kind, in.Sector, err)
+		}
+		if in.Kind == IntentMoveStorage {
+			moves = append(moves, in.Sector)
+		}
+	}
+
+	// moves record their own intent again, and can take a while
+	for _, sid := range moves {
+		go func(sid abi.SectorNumber) {
+			if err := m.MoveStorage(ctx, sid); err != nil {
+				m.sectorLog(sid).Errorf("moving storage of sector %d again: %+v", sid, err)
+			}
+		}(sid)
+	}
+
+	return nil
+}
+
+func (m *Sealing) resolveIntent(ctx context.Context, in Intent) error {
+	si, err := m.GetSectorInfo(in.Sector)
+	if xerrors.As(err, new(*ErrSectorNotFound)) {
+		// the sector was never recorded, or already removed
+		return nil
+	}
+	if err != nil {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	switch in.Kind {
+	case IntentAddPiece:
+		if si.State != Packing {
+			return nil
+		}
+
+		r := m.unsealedPieces()
+		if r == nil {
+			m.stateLog(si).Warnf("sealer can't truncate the unsealed copy of sector %d, filler pieces may be written twice", si.SectorNumber)
+			return nil
+		}
+
+		var size abi.PaddedPieceSize
+		for _, p := range si.Pieces {
+			size += p.Piece.Size
+		}
+		return r.TruncateUnsealed(ctx, m.minerSector(si.SectorNumber), size)
+	case IntentFinalize, IntentRemove, IntentMoveStorage:
+		return nil
+	default:
+		return xerrors.Errorf("unknown intent %s", in.Kind)
+	}
+}
@@ -0,0 +1,140 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// dangle records an intent which is never dropped, as if the node went down
+// during the operation
+func dangle(h *testHarness, sid abi.SectorNumber, kind IntentKind) {
+	_, err := h.m.beginIntent(sid, kind)
+	require.NoError(h.t, err)
+}
+
+func (h *testHarness) waitIntents(n int) []Intent {
+	var intents []Intent
+	require.Eventually(h.t, func() bool {
+		var err error
+		intents, err = h.m.DanglingIntents()
+		require.NoError(h.t, err)
+		return len(intents) == n
+	}, 5*time.Second, 5*time.Millisecond)
+	return intents
+}
+
+func TestIntentDuringFinalize(t *testing.T) {
+	h := newTestHarness(t, Config{SealerIntents: true})
+
+	during := make(chan []Intent, 1)
+	h.sealer.finalize = func(sector abi.SectorID) error {
+		intents, err := h.m.DanglingIntents()
+		require.NoError(t, err)
+		during <- intents
+		return nil
+	}
+
+	h.start(finalizingSector(h))
+	h.waitState(1, Proving)
+
+	intents := <-during
+	require.Len(t, intents, 1)
+	require.Equal(t, abi.SectorNumber(1), intents[0].Sector)
+	require.Equal(t, IntentFinalize, intents[0].Kind)
+	h.waitIntents(0)
+}
+
+func TestIntentAddPieceDropped(t *testing.T) {
+	h := newTestHarness(t, Config{SealerIntents: true})
+
+	sid, _ := h.addDeal(1, 512)
+	h.waitPieces(sid, 1)
+	h.waitIntents(0)
+}
+
+func TestReconcileIntentPacking(t *testing.T) {
+	unsealed := &fakeUnsealed{}
+	h := newTestHarness(t, Config{SealerIntents: true, UnsealedPieces: unsealed})
+
+	h.put(SectorInfo{
+		State:        Packing,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces:       []Piece{{Piece: zeroPiece(512)}},
+	})
+	dangle(h, 1, IntentAddPiece)
+
+	require.NoError(t, h.m.restartSectors(context.Background(), nil))
+
+	// the fillers written before the crash are rolled back, and written again
+	unsealed.lk.Lock()
+	require.NotNil(t, unsealed.truncated)
+	require.Equal(t, abi.PaddedPieceSize(512), *unsealed.truncated)
+	unsealed.lk.Unlock()
+
+	require.Eventually(t, func() bool {
+		return h.sector(1).State != Packing
+	}, 5*time.Second, 5*time.Millisecond)
+	require.Len(t, h.sector(1).Pieces, 3)
+	h.waitIntents(0)
+}
+
+func TestReconcileIntentFinalize(t *testing.T) {
+	h := newTestHarness(t, Config{SealerIntents: true})
+
+	finalized := make(chan abi.SectorID, 1)
+	h.sealer.finalize = func(sector abi.SectorID) error {
+		finalized <- sector
+		return nil
+	}
+
+	h.put(finalizingSector(h))
+	dangle(h, 1, IntentFinalize)
+
+	require.NoError(t, h.m.restartSectors(context.Background(), nil))
+
+	require.Equal(t, h.m.minerSector(1), <-finalized)
+	h.waitState(1, Proving)
+	h.waitIntents(0)
+}
+
+func TestReconcileIntentMoveStorage(t *testing.T) {
+	h := newTestHarness(t, Config{SealerIntents: true})
+	ms := &movingSealer{fakeSealer: h.sealer}
+	h.m.sealer = ms
+
+	h.put(SectorInfo{SectorNumber: 1, State: Proving})
+	dangle(h, 1, IntentMoveStorage)
+
+	require.NoError(t, h.m.restartSectors(context.Background(), nil))
+
+	require.Eventually(t, func() bool {
+		return len(ms.movedSectors()) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	require.Equal(t, h.m.minerSector(1), ms.movedSectors()[0])
+	h.waitIntents(0)
+}
+
+func TestReconcileIntentRemovedSector(t *testing.T) {
+	h := newTestHarness(t, Config{SealerIntents: true})
+
+	dangle(h, 3, IntentRemove)
+	require.Len(t, h.waitIntents(1), 1)
+
+	require.NoError(t, h.m.restartSectors(context.Background(), nil))
+	h.waitIntents(0)
+}
+
+func TestIntentsDisabled(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	dangle(h, 1, IntentAddPiece)
+	intents, err := h.m.DanglingIntents()
+	require.NoError(t, err)
+	require.Empty(t, intents)
+}
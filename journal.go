@@ -0,0 +1,124 @@
+package sealing
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+const SectorJournalPrefix = "/sector-journal"
+
+// JournalEntry is an event sent to a sector from outside of its state
+// handlers, recorded with Config.JournalEvents
+type JournalEntry struct {
+	Timestamp time.Time
+	Kind      string // event;<type>, the same as in Log
+	Event     string // JSON encoded
+}
+
+// journalSeq orders entries recorded within the same clock tick
+var journalSeq uint64
+
+// send sends the event to the sector, journaling it first if
+// Config.JournalEvents is set
+func (m *Sealing) send(sid abi.SectorNumber, evt interface{}) error {
+	if m.journal != nil {
+		if err := m.journalEvent(sid, evt); err != nil {
+			m.sectorLog(sid).Errorf("journaling %T: %+v", evt, err)
+		}
+	}
+
+	return m.sectors.Send(uint64(sid), evt)
+}
+
+func (m *Sealing) journalEvent(sid abi.SectorNumber, evt interface{}) error {
+	e, err := json.Marshal(evt)
+	if err != nil {
+		return xerrors.Errorf("encoding event: %w", err)
+	}
+
+	now := m.clock.Now()
+	b, err := json.Marshal(JournalEntry{
+		Timestamp: now,
+		Kind:      logKind(evt),
+		Event:     string(e),
+	})
+	if err != nil {
+		return xerrors.Errorf("encoding journal entry: %w", err)
+	}
+
+	// keys sort in the order entries were recorded
+	key := datastore.NewKey(fmt.Sprint(uint64(sid))).ChildString(fmt.Sprintf("%020d-%020d", now.UnixNano(), atomic.AddUint64(&journalSeq, 1)))
+	return m.journal.Put(key, b)
+}
+
+// journalPrefix matches the journal entries of the sector, and not of sectors
+// whose number it's a prefix of
+func journalPrefix(sid abi.SectorNumber) string {
+	return datastore.NewKey(fmt.Sprint(uint64(sid))).String() + "/"
+}
+
+// SectorJournal returns the events journaled for the sector, oldest first.
+// Events are only journaled with Config.JournalEvents set
+func (m *Sealing) SectorJournal(sid abi.SectorNumber) ([]JournalEntry, error) {
+	if m.journal == nil {
+		return nil, xerrors.New("event journal is disabled")
+	}
+
+	res, err := m.journal.Query(query.Query{
+		Prefix: journalPrefix(sid),
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("querying journal of sector %d: %w", sid, err)
+	}
+	defer res.Close() // nolint:errcheck
+
+	var out []JournalEntry
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating journal of sector %d: %w", sid, r.Error)
+		}
+
+		var e JournalEntry
+		if err := json.Unmarshal(r.Value, &e); err != nil {
+			return nil, xerrors.Errorf("decoding journal entry %s: %w", r.Key, err)
+		}
+		out = append(out, e)
+	}
+
+	return out, nil
+}
+
+// dropJournal removes the journal of a garbage collected sector
+func (m *Sealing) dropJournal(sid abi.SectorNumber) error {
+	if m.journal == nil {
+		return nil
+	}
+
+	res, err := m.journal.Query(query.Query{
+		Prefix:   journalPrefix(sid),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return xerrors.Errorf("querying journal of sector %d: %w", sid, err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return xerrors.Errorf("listing journal of sector %d: %w", sid, err)
+	}
+
+	for _, e := range entries {
+		if err := m.journal.Delete(datastore.NewKey(e.Key)); err != nil {
+			return xerrors.Errorf("deleting journal entry %s: %w", e.Key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package sealing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSectorJournal(t *testing.T) {
+	h := newTestHarness(t, Config{JournalEvents: true})
+
+	sid, _ := h.addDeal(1, 512)
+	h.addDeal(2, 512)
+	h.waitPieces(sid, 2)
+	require.NoError(t, h.m.StartPacking(sid))
+
+	entries, err := h.m.SectorJournal(sid)
+	require.NoError(t, err)
+
+	var kinds []string
+	var added []JournalEntry
+	for _, e := range entries {
+		if e.Kind == logKind(SectorPieceWriting{}) {
+			continue
+		}
+		kinds = append(kinds, e.Kind)
+		if e.Kind == logKind(SectorAddPiece{}) {
+			added = append(added, e)
+		}
+	}
+
+	require.Equal(t, []string{
+		logKind(SectorStart{}),
+		logKind(SectorAddPiece{}),
+		logKind(SectorAddPiece{}),
+		logKind(SectorStartPacking{}),
+	}, kinds)
+	require.Contains(t, added[0].Event, `"DealID":1`)
+	require.Contains(t, added[1].Event, `"DealID":2`)
+
+	// other sectors have their own journal
+	other, err := h.m.SectorJournal(sid + 10)
+	require.NoError(t, err)
+	require.Empty(t, other)
+}
+
+func TestSectorJournalDisabled(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	sid, _ := h.addDeal(1, 512)
+	_, err := h.m.SectorJournal(sid)
+	require.Error(t, err)
+}
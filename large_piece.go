@@ -0,0 +1,100 @@
+package sealing
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// PiecePlacement is where AddLargePiece wrote a chunk of the piece
+type PiecePlacement struct {
+	Sector abi.SectorNumber
+	Offset uint64 // padded offset of the chunk in the sector
+	Size   abi.UnpaddedPieceSize
+}
+
+// largePieceChunks splits a piece into chunks of the largest sector size,
+// the rest is split into the fewest valid piece sizes, largest first
+func largePieceChunks(total abi.UnpaddedPieceSize, ss abi.SectorSize) ([]abi.UnpaddedPieceSize, error) {
+	if total%127 != 0 {
+		return nil, xerrors.Errorf("piece of %d bytes isn't a multiple of 127 bytes: %w", total, ErrPieceNotPadded)
+	}
+
+	chunk := abi.PaddedPieceSize(ss).Unpadded()
+
+	var out []abi.UnpaddedPieceSize
+	for ; total >= chunk; total -= chunk {
+		out = append(out, chunk)
+	}
+	if total == 0 {
+		return out, nil
+	}
+
+	rest, err := fillersFromRem(total)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		return rest[i] > rest[j]
+	})
+	for _, size := range rest {
+		if err := checkPieceSize(size); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(out, rest...), nil
+}
+
+// shortReader fails reads with io.ErrUnexpectedEOF when the reader ends
+// before the chunk was read, so that the chunk doesn't get written
+type shortReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (sr *shortReader) Read(p []byte) (int, error) {
+	if sr.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > sr.left {
+		p = p[:sr.left]
+	}
+
+	n, err := sr.r.Read(p)
+	sr.left -= int64(n)
+	if err == io.EOF && sr.left > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// AddLargePiece writes a piece which may be larger than a sector, split into
+// chunks of the largest sector size placed with AddPieceToAnySector. Each
+// chunk is added with the deal info d. The placements of the chunks written
+// so far are returned along with an error, including when r ends before
+// totalSize bytes were read
+func (m *Sealing) AddLargePiece(ctx context.Context, totalSize abi.UnpaddedPieceSize, r io.Reader, d DealInfo) ([]PiecePlacement, error) {
+	chunks, err := largePieceChunks(totalSize, m.maxSectorSize())
+	if err != nil {
+		return nil, err
+	}
+
+	// a chunk can't be reopened on its own
+	d.Reopen = nil
+
+	var out []PiecePlacement
+	for i, size := range chunks {
+		sid, offset, err := m.AddPieceToAnySector(ctx, size, &shortReader{r: r, left: int64(size)}, d)
+		if err != nil {
+			return out, xerrors.Errorf("adding chunk %d of %d (%d bytes): %w", i+1, len(chunks), size, err)
+		}
+		out = append(out, PiecePlacement{Sector: sid, Offset: offset, Size: size})
+	}
+
+	return out, nil
+}
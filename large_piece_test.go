@@ -0,0 +1,78 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// readingSealer reads the piece data like a real sealer would
+type readingSealer struct {
+	*fakeSealer
+}
+
+func (s *readingSealer) AddPiece(ctx context.Context, sector abi.SectorID, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (abi.PieceInfo, error) {
+	n, err := io.Copy(ioutil.Discard, pieceData)
+	if err != nil {
+		return abi.PieceInfo{}, err
+	}
+	if n != int64(newPieceSize) {
+		return abi.PieceInfo{}, xerrors.Errorf("read %d bytes, expected %d", n, newPieceSize)
+	}
+	return s.fakeSealer.AddPiece(ctx, sector, pieceSizes, newPieceSize, pieceData)
+}
+
+func TestLargePieceChunks(t *testing.T) {
+	chunks, err := largePieceChunks(2*2032, 2048)
+	require.NoError(t, err)
+	require.Equal(t, []abi.UnpaddedPieceSize{2032, 2032}, chunks)
+
+	chunks, err = largePieceChunks(2*2032+1016+254, 2048)
+	require.NoError(t, err)
+	require.Equal(t, []abi.UnpaddedPieceSize{2032, 2032, 1016, 254}, chunks)
+
+	_, err = largePieceChunks(2032+100, 2048)
+	require.True(t, xerrors.Is(err, ErrPieceNotPadded), "%+v", err)
+}
+
+func TestAddLargePiece(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.m.sealer = &readingSealer{fakeSealer: h.sealer}
+
+	out, err := h.m.AddLargePiece(context.Background(), 2*2032, bytes.NewReader(make([]byte, 2*2032)), DealInfo{DealID: 1})
+	require.NoError(t, err)
+	require.Equal(t, []PiecePlacement{
+		{Sector: 1, Offset: 0, Size: 2032},
+		{Sector: 2, Offset: 0, Size: 2032},
+	}, out)
+}
+
+func TestAddLargePieceThreeSectors(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.m.sealer = &readingSealer{fakeSealer: h.sealer}
+
+	out, err := h.m.AddLargePiece(context.Background(), 2*2032+1016, bytes.NewReader(make([]byte, 2*2032+1016)), DealInfo{DealID: 1})
+	require.NoError(t, err)
+	require.Equal(t, []PiecePlacement{
+		{Sector: 1, Offset: 0, Size: 2032},
+		{Sector: 2, Offset: 0, Size: 2032},
+		{Sector: 3, Offset: 0, Size: 1016},
+	}, out)
+}
+
+func TestAddLargePieceShortReader(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.m.sealer = &readingSealer{fakeSealer: h.sealer}
+
+	out, err := h.m.AddLargePiece(context.Background(), 2*2032, bytes.NewReader(make([]byte, 2032+100)), DealInfo{DealID: 1})
+	require.True(t, xerrors.Is(err, ErrAddPieceFailed), "%+v", err)
+	require.Equal(t, []PiecePlacement{{Sector: 1, Offset: 0, Size: 2032}}, out)
+}
@@ -0,0 +1,172 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// phaseLimiter bounds the number of sectors running a sealing phase at once.
+// Free slots go to waiting sectors in limitOrder. A nil limiter, or one with a
+// limit of 0, doesn't limit anything, but still tracks utilization
+type phaseLimiter struct {
+	limit int
+
+	lk      sync.Mutex
+	running int
+	waiting []*limitWaiter
+	seq     uint64
+}
+
+// limitOrder ranks sectors waiting for a slot: higher priority first, then
+// the sector with the earliest deal start, then the one waiting the longest
+type limitOrder struct {
+	priority  int
+	dealStart abi.ChainEpoch // 0 for sectors without deals
+}
+
+type limitWaiter struct {
+	order limitOrder
+	seq   uint64
+
+	ready   chan struct{}
+	granted bool
+}
+
+func (w *limitWaiter) before(o *limitWaiter) bool {
+	if w.order.priority != o.order.priority {
+		return w.order.priority > o.order.priority
+	}
+	if w.order.dealStart != o.order.dealStart {
+		if w.order.dealStart == 0 || o.order.dealStart == 0 {
+			return o.order.dealStart == 0
+		}
+		return w.order.dealStart < o.order.dealStart
+	}
+	return w.seq < o.seq
+}
+
+func newPhaseLimiter(limit int) *phaseLimiter {
+	return &phaseLimiter{limit: limit}
+}
+
+// acquire blocks until a slot is free, or the context is cancelled. Callers
+// get slots in the order they started waiting
+func (l *phaseLimiter) acquire(ctx context.Context) error {
+	return l.acquireOrdered(ctx, limitOrder{})
+}
+
+// acquireOrdered blocks until a slot is free, and no waiter ranked before
+// order is left, or the context is cancelled
+func (l *phaseLimiter) acquireOrdered(ctx context.Context, order limitOrder) error {
+	if l == nil {
+		return nil
+	}
+
+	l.lk.Lock()
+	if l.limit <= 0 || (l.running < l.limit && len(l.waiting) == 0) {
+		l.running++
+		l.lk.Unlock()
+		return nil
+	}
+
+	w := &limitWaiter{order: order, seq: l.seq, ready: make(chan struct{})}
+	l.seq++
+	l.waiting = append(l.waiting, w)
+	l.lk.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	if w.granted {
+		// got the slot while giving up, pass it on
+		l.running--
+		l.grant()
+		return ctx.Err()
+	}
+	for i, o := range l.waiting {
+		if o == w {
+			l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+			break
+		}
+	}
+	return ctx.Err()
+}
+
+func (l *phaseLimiter) release() {
+	if l == nil {
+		return
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	l.running--
+	l.grant()
+}
+
+// grant hands free slots to the first waiters. Caller must hold lk
+func (l *phaseLimiter) grant() {
+	for len(l.waiting) > 0 && l.running < l.limit {
+		first := 0
+		for i, w := range l.waiting {
+			if w.before(l.waiting[first]) {
+				first = i
+			}
+		}
+
+		w := l.waiting[first]
+		l.waiting = append(l.waiting[:first], l.waiting[first+1:]...)
+		w.granted = true
+		l.running++
+		close(w.ready)
+	}
+}
+
+func (l *phaseLimiter) utilization() PhaseUtilization {
+	if l == nil {
+		return PhaseUtilization{}
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	return PhaseUtilization{
+		Limit:   l.limit,
+		Running: l.running,
+		Waiting: len(l.waiting),
+	}
+}
+
+// PhaseUtilization describes how many sectors are running, and waiting to run
+// a rate-limited sealing phase. Limit is 0 when the phase isn't limited
+type PhaseUtilization struct {
+	Limit   int
+	Running int
+	Waiting int
+}
+
+// QueueReport is a snapshot of the sealing phases limited by the FSM
+type QueueReport struct {
+	AddPiece   PhaseUtilization
+	PreCommit1 PhaseUtilization
+	PreCommit2 PhaseUtilization
+	Commit2    PhaseUtilization
+}
+
+// QueueDepths reports utilization of the concurrency-limited sealing phases
+func (m *Sealing) QueueDepths() QueueReport {
+	return QueueReport{
+		AddPiece:   m.apLimit.utilization(),
+		PreCommit1: m.p1Limit.utilization(),
+		PreCommit2: m.p2Limit.utilization(),
+		Commit2:    m.c2Limit.utilization(),
+	}
+}
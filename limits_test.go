@@ -0,0 +1,382 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+func TestPhaseLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := newPhaseLimiter(2)
+
+	var lk sync.Mutex
+	var running, maxRunning int
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			require.NoError(t, l.acquire(ctx))
+			defer l.release()
+
+			lk.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lk.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			lk.Lock()
+			running--
+			lk.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 2, maxRunning)
+	require.Equal(t, PhaseUtilization{Limit: 2}, l.utilization())
+}
+
+func TestPhaseLimiterCancel(t *testing.T) {
+	l := newPhaseLimiter(1)
+	require.NoError(t, l.acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- l.acquire(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return l.utilization().Waiting == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	require.Equal(t, context.Canceled, <-done)
+	require.Equal(t, PhaseUtilization{Limit: 1, Running: 1}, l.utilization())
+}
+
+func TestPhaseLimiterOrder(t *testing.T) {
+	l := newPhaseLimiter(1)
+	require.NoError(t, l.acquire(context.Background()))
+
+	orders := []limitOrder{
+		{},
+		{dealStart: 300},
+		{priority: 10},
+		{dealStart: 200},
+		{priority: 10, dealStart: 100},
+		{},
+	}
+
+	got := make(chan int, len(orders))
+	for i, order := range orders {
+		i, order := i, order
+		go func() {
+			require.NoError(t, l.acquireOrdered(context.Background(), order))
+			got <- i
+		}()
+
+		// waiters enqueue in order
+		require.Eventually(t, func() bool {
+			return l.utilization().Waiting == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	var out []int
+	for range orders {
+		l.release()
+		out = append(out, <-got)
+		require.Equal(t, PhaseUtilization{Limit: 1, Running: 1, Waiting: len(orders) - len(out)}, l.utilization())
+	}
+	require.Equal(t, []int{4, 2, 3, 1, 0, 5}, out)
+}
+
+func TestCommit2Priority(t *testing.T) {
+	h := newTestHarness(t, Config{MaxConcurrentCommit2: 1})
+
+	started := make(chan abi.SectorNumber, 3)
+	unblock := make(chan struct{})
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		started <- sector.Number
+		<-unblock
+		return storage.Proof{1}, nil
+	}
+
+	h.start(h.committingSector(1))
+	require.Equal(t, abi.SectorNumber(1), <-started)
+
+	h.start(h.committingSector(2))
+	require.Eventually(t, func() bool {
+		return h.m.QueueDepths().Commit2.Waiting == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	urgent := h.committingSector(3)
+	urgent.Priority = 100
+	h.start(urgent)
+	require.Eventually(t, func() bool {
+		return h.m.QueueDepths().Commit2.Waiting == 2
+	}, 5*time.Second, 5*time.Millisecond)
+
+	unblock <- struct{}{}
+	require.Equal(t, abi.SectorNumber(3), <-started)
+	unblock <- struct{}{}
+	require.Equal(t, abi.SectorNumber(2), <-started)
+	close(unblock)
+
+	for sn := abi.SectorNumber(1); sn <= 3; sn++ {
+		h.waitState(sn, Proving)
+	}
+}
+
+func TestPhaseLimiterNil(t *testing.T) {
+	var l *phaseLimiter
+	require.NoError(t, l.acquire(context.Background()))
+	l.release()
+	require.Equal(t, PhaseUtilization{}, l.utilization())
+
+	var m Sealing
+	require.Equal(t, QueueReport{}, m.QueueDepths())
+}
+
+func TestMaxConcurrentCommit2(t *testing.T) {
+	h := newTestHarness(t, Config{MaxConcurrentCommit2: 1})
+
+	started := make(chan abi.SectorNumber, 2)
+	unblock := make(chan struct{})
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		started <- sector.Number
+		<-unblock
+		return storage.Proof{1}, nil
+	}
+
+	h.start(h.committingSector(1))
+	h.start(h.committingSector(2))
+
+	<-started
+	require.Eventually(t, func() bool {
+		return h.m.QueueDepths().Commit2 == PhaseUtilization{Limit: 1, Running: 1, Waiting: 1}
+	}, 5*time.Second, 5*time.Millisecond)
+
+	select {
+	case sn := <-started:
+		t.Fatalf("sector %d started SealCommit2 while the only slot was taken", sn)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(unblock)
+	<-started
+
+	h.waitState(1, Proving)
+	h.waitState(2, Proving)
+	require.Equal(t, PhaseUtilization{Limit: 1}, h.m.QueueDepths().Commit2)
+}
+
+func TestMaxConcurrentAddPiece(t *testing.T) {
+	h := newTestHarness(t, Config{MaxConcurrentAddPiece: 2})
+
+	var lk sync.Mutex
+	var running, maxRunning, calls int
+	h.sealer.addPiece = func(ctx context.Context, sector abi.SectorID, size abi.UnpaddedPieceSize) {
+		lk.Lock()
+		running++
+		calls++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lk.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		lk.Lock()
+		running--
+		lk.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := h.m.PledgeSector(context.Background())
+			require.NoError(t, err)
+		}()
+	}
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(id abi.DealID) {
+			defer wg.Done()
+			h.addDeal(id, 1024)
+		}(abi.DealID(i + 1))
+	}
+	wg.Wait()
+
+	require.Eventually(t, func() bool {
+		lk.Lock()
+		defer lk.Unlock()
+		return calls == 10
+	}, 5*time.Second, 5*time.Millisecond)
+
+	lk.Lock()
+	require.Equal(t, 2, maxRunning)
+	lk.Unlock()
+	require.Equal(t, PhaseUtilization{Limit: 2}, h.m.QueueDepths().AddPiece)
+}
+
+func TestMaxWaitDealsSectors(t *testing.T) {
+	h := newTestHarness(t, Config{MaxWaitDealsSectors: 1})
+
+	sid, _ := h.addDeal(1, 1024)
+	h.waitPieces(sid, 1)
+
+	// fits into the open sector
+	sid, _ = h.addDeal(2, 512)
+	require.Equal(t, abi.SectorNumber(1), sid)
+
+	// needs a new sector
+	h.setZeroDeal(3, 1024)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := h.m.AddPieceToAnySector(ctx, 1016, bytes.NewReader(make([]byte, 1016)), DealInfo{DealID: 3})
+	require.True(t, xerrors.Is(err, context.DeadlineExceeded), "%+v", err)
+
+	done := make(chan abi.SectorNumber)
+	go func() {
+		sid, _, err := h.m.AddPieceToAnySector(context.Background(), 1016, bytes.NewReader(make([]byte, 1016)), DealInfo{DealID: 3})
+		require.NoError(t, err)
+		done <- sid
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("new sector created over the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, h.m.StartPacking(1))
+	require.Equal(t, abi.SectorNumber(2), <-done)
+}
+
+func TestMaxWaitDealsSectorsReject(t *testing.T) {
+	h := newTestHarness(t, Config{MaxWaitDealsSectors: 1, RejectOverWaitDealsLimit: true})
+
+	sid, _ := h.addDeal(1, 1024)
+	h.waitPieces(sid, 1)
+
+	h.setZeroDeal(2, 2048)
+	_, _, err := h.m.AddPieceToAnySector(context.Background(), 2032, bytes.NewReader(make([]byte, 2032)), DealInfo{DealID: 2})
+	require.True(t, xerrors.As(err, new(*ErrTooManyOpenSectors)), "%+v", err)
+	require.True(t, xerrors.Is(err, ErrSectorAllocFailed), "%+v", err)
+
+	// accepted once the open sector is packed
+	require.NoError(t, h.m.StartPacking(sid))
+	sid, _ = h.addDeal(3, 2048)
+	require.Equal(t, abi.SectorNumber(2), sid)
+}
+
+// concurrency tracks how many calls of a sealer phase run at once
+type concurrency struct {
+	lk                  sync.Mutex
+	running, max, calls int
+}
+
+func (c *concurrency) run() {
+	c.lk.Lock()
+	c.running++
+	c.calls++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	c.lk.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.lk.Lock()
+	c.running--
+	c.lk.Unlock()
+}
+
+func (c *concurrency) stats() (max, calls int) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.max, c.calls
+}
+
+func TestMaxConcurrentPreCommit(t *testing.T) {
+	h := newTestHarness(t, Config{MaxConcurrentPreCommit1: 3, MaxConcurrentPreCommit2: 1})
+
+	var p1, p2 concurrency
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		p1.run()
+		return storage.PreCommit1Out{}, nil
+	}
+	h.sealer.preCommit2 = func(sector abi.SectorID) {
+		p2.run()
+	}
+
+	for i := 0; i < 8; i++ {
+		_, err := h.m.PledgeSector(context.Background())
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		_, calls := p2.stats()
+		return calls == 8
+	}, 5*time.Second, 5*time.Millisecond)
+
+	max1, calls1 := p1.stats()
+	require.Equal(t, 8, calls1)
+	require.Equal(t, 3, max1)
+	max2, _ := p2.stats()
+	require.Equal(t, 1, max2)
+
+	require.Equal(t, PhaseUtilization{Limit: 3}, h.m.QueueDepths().PreCommit1)
+	require.Equal(t, PhaseUtilization{Limit: 1}, h.m.QueueDepths().PreCommit2)
+}
+
+func TestMaxConcurrentPreCommit2Queued(t *testing.T) {
+	h := newTestHarness(t, Config{MaxConcurrentPreCommit2: 1})
+
+	started := make(chan abi.SectorNumber, 2)
+	unblock := make(chan struct{})
+	h.sealer.preCommit2 = func(sector abi.SectorID) {
+		started <- sector.Number
+		<-unblock
+	}
+
+	s1 := dealSector(h, PreCommit2)
+	h.start(s1)
+	s2 := dealSector(h, PreCommit2)
+	s2.SectorNumber = 2
+	h.start(s2)
+
+	<-started
+	require.Eventually(t, func() bool {
+		return h.m.QueueDepths().PreCommit2 == PhaseUtilization{Limit: 1, Running: 1, Waiting: 1}
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// the queued sector stays in PreCommit2, and PreCommit1 isn't limited
+	require.Equal(t, PreCommit2, h.sector(1).State)
+	require.Equal(t, PreCommit2, h.sector(2).State)
+	require.Equal(t, PhaseUtilization{}, h.m.QueueDepths().PreCommit1)
+
+	close(unblock)
+	<-started
+	require.Eventually(t, func() bool {
+		return h.m.QueueDepths().PreCommit2 == PhaseUtilization{Limit: 1}
+	}, 5*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,79 @@
+package sealing
+
+import (
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// StorageLocality can be implemented by SectorManagers with storage spread
+// over paths or workers, to keep pieces away from sectors whose data would
+// have to be moved to write them
+type StorageLocality interface {
+	// LocalSectors returns the sectors whose unsealed data is on a path local
+	// to the worker which would write a new piece to them
+	LocalSectors(ctx context.Context, sectors []abi.SectorID) ([]abi.SectorID, error)
+}
+
+// sectorLocality is the set of sectors accepting deals which StorageLocality
+// reports as local, nil if there is no locality info
+type sectorLocality map[abi.SectorNumber]struct{}
+
+// pickSector selects the sector for a piece with the packing strategy, out of
+// the sectors in local if there are any with room, and out of all sectors
+// otherwise. Caller must hold unsealedLk
+func (m *Sealing) pickSector(local sectorLocality, infos map[abi.SectorNumber]UnsealedSectorInfo, size abi.PaddedPieceSize) (abi.SectorNumber, []abi.PaddedPieceSize, bool) {
+	if len(local) > 0 {
+		localInfos := map[abi.SectorNumber]UnsealedSectorInfo{}
+		for sn, ui := range infos {
+			if _, ok := local[sn]; ok {
+				localInfos[sn] = ui
+			}
+		}
+
+		if sid, pads, ok := selectSector(m.packingStrategy, localInfos, m.cfg.MaxPiecesPerSector, size); ok {
+			return sid, pads, true
+		}
+	}
+
+	return selectSector(m.packingStrategy, infos, m.cfg.MaxPiecesPerSector, size)
+}
+
+// localSectors asks the sealer which of the sectors accepting deals are local
+// to the worker which would write a new piece, if it implements
+// StorageLocality. The sealer may be remote, so this must be called without
+// unsealedLk held; sectors opened in the meantime are treated as not local
+func (m *Sealing) localSectors(ctx context.Context) sectorLocality {
+	sl, ok := m.sealer.(StorageLocality)
+	if !ok {
+		return nil
+	}
+
+	// ordered, so that the sealer sees the same query for the same sectors
+	m.unsealedLk.Lock()
+	sectors := make([]abi.SectorID, 0, len(m.unsealedInfos))
+	for sn := range m.unsealedInfos {
+		sectors = append(sectors, m.minerSector(sn))
+	}
+	m.unsealedLk.Unlock()
+
+	if len(sectors) < 2 {
+		return nil
+	}
+	sort.Slice(sectors, func(i, j int) bool {
+		return sectors[i].Number < sectors[j].Number
+	})
+
+	local, err := sl.LocalSectors(ctx, sectors)
+	if err != nil {
+		log.Warnf("getting storage locality of sectors accepting deals, ignoring it: %+v", err)
+		return nil
+	}
+
+	out := sectorLocality{}
+	for _, sid := range local {
+		out[sid.Number] = struct{}{}
+	}
+	return out
+}
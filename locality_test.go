@@ -0,0 +1,127 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// localSealer reports the local sectors as local to the AddPiece worker
+type localSealer struct {
+	*fakeSealer
+
+	local  []abi.SectorNumber
+	err    error
+	asked  [][]abi.SectorID
+	during func()
+}
+
+func (s *localSealer) LocalSectors(ctx context.Context, sectors []abi.SectorID) ([]abi.SectorID, error) {
+	if s.during != nil {
+		s.during()
+	}
+	s.asked = append(s.asked, sectors)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var out []abi.SectorID
+	for _, sn := range s.local {
+		out = append(out, abi.SectorID{Miner: 1000, Number: sn})
+	}
+	return out, nil
+}
+
+// openSectors makes the sectors accept deals, with the given bytes stored
+func (h *testHarness) openSectors(stored map[abi.SectorNumber]abi.PaddedPieceSize) {
+	h.m.unsealedLk.Lock()
+	defer h.m.unsealedLk.Unlock()
+	for sn, s := range stored {
+		h.m.unsealedInfos[sn] = UnsealedSectorInfo{size: 2048, stored: s}
+	}
+}
+
+func (h *testHarness) availableSector(size abi.PaddedPieceSize) abi.SectorNumber {
+	local := h.m.localSectors(context.Background())
+
+	h.m.unsealedLk.Lock()
+	defer h.m.unsealedLk.Unlock()
+
+	sid, _, err := h.m.getAvailableSector(context.Background(), size.Unpadded(), false, local)
+	require.NoError(h.t, err)
+	return sid
+}
+
+func TestLocalSectorPreferred(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	ls := &localSealer{fakeSealer: h.sealer, local: []abi.SectorNumber{3}}
+	h.m.sealer = ls
+
+	h.openSectors(map[abi.SectorNumber]abi.PaddedPieceSize{1: 512, 2: 512, 3: 1024})
+	require.Equal(t, abi.SectorNumber(3), h.availableSector(512))
+	require.Len(t, ls.asked, 1)
+	require.Equal(t, []abi.SectorID{h.m.minerSector(1), h.m.minerSector(2), h.m.minerSector(3)}, ls.asked[0])
+
+	// PlanPiece predicts the same sector
+	sid, _, fresh, err := h.m.PlanPiece(context.Background(), abi.PaddedPieceSize(512).Unpadded(), false)
+	require.NoError(t, err)
+	require.False(t, fresh)
+	require.Equal(t, abi.SectorNumber(3), sid)
+}
+
+func TestLocalSectorsWithStrategy(t *testing.T) {
+	h := newTestHarness(t, Config{PackingStrategy: BestFit})
+	h.m.sealer = &localSealer{fakeSealer: h.sealer, local: []abi.SectorNumber{1, 2}}
+
+	// the fullest of the local sectors, not the fullest overall
+	h.openSectors(map[abi.SectorNumber]abi.PaddedPieceSize{1: 512, 2: 1024, 3: 1536})
+	require.Equal(t, abi.SectorNumber(2), h.availableSector(512))
+}
+
+func TestLocalSectorsFallback(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	ls := &localSealer{fakeSealer: h.sealer, local: []abi.SectorNumber{3}}
+	h.m.sealer = ls
+
+	// the local sector has no room
+	h.openSectors(map[abi.SectorNumber]abi.PaddedPieceSize{1: 1024, 2: 512, 3: 2048})
+	require.Equal(t, abi.SectorNumber(1), h.availableSector(512))
+
+	// no locality info
+	ls.err = xerrors.New("unknown paths")
+	require.Equal(t, abi.SectorNumber(1), h.availableSector(512))
+}
+
+func TestLocalSectorsUnlocked(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	ls := &localSealer{fakeSealer: h.sealer, local: []abi.SectorNumber{1}}
+	h.m.sealer = ls
+
+	// the sealer may be remote, pieces shouldn't wait for it
+	ls.during = func() {
+		locked := make(chan struct{})
+		go func() {
+			h.m.unsealedLk.Lock()
+			h.m.unsealedLk.Unlock()
+			close(locked)
+		}()
+
+		select {
+		case <-locked:
+		case <-time.After(time.Second):
+			t.Error("storage locality queried with unsealedLk held")
+		}
+	}
+
+	h.openSectors(map[abi.SectorNumber]abi.PaddedPieceSize{1: 512, 2: 1024})
+	sid, _, _, err := h.m.PlanPiece(context.Background(), abi.PaddedPieceSize(512).Unpadded(), false)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	require.Equal(t, abi.SectorNumber(1), h.availableSector(512))
+	require.Len(t, ls.asked, 2)
+}
@@ -0,0 +1,182 @@
+package sealing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// SealingMetrics receives sealing events, to be exported to a metrics system
+type SealingMetrics interface {
+	// SectorStateChanged is called on every state transition of a sector. New
+	// sectors transition from UndefinedSectorState
+	SectorStateChanged(sn abi.SectorNumber, old, new SectorState)
+
+	// SectorLoaded is called for sectors restarted on startup, with their
+	// current state
+	SectorLoaded(sn abi.SectorNumber, state SectorState)
+
+	// PieceAdded is called for every deal piece added to a sector
+	PieceAdded(size abi.UnpaddedPieceSize)
+
+	// CommitWaited is called when the commit message of a sector landed, with
+	// how long CommitWait waited for it
+	CommitWaited(sn abi.SectorNumber, d time.Duration)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) SectorStateChanged(abi.SectorNumber, SectorState, SectorState) {}
+func (noopMetrics) SectorLoaded(abi.SectorNumber, SectorState)                    {}
+func (noopMetrics) PieceAdded(abi.UnpaddedPieceSize)                              {}
+func (noopMetrics) CommitWaited(abi.SectorNumber, time.Duration)                  {}
+
+// TimeInStateBuckets are the upper bounds of the StateMetrics time-in-state
+// histogram buckets
+var TimeInStateBuckets = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+	72 * time.Hour,
+}
+
+// Histogram has cumulative bucket counts, the same way Prometheus histograms do
+type Histogram struct {
+	// Counts[i] is the number of observations up to TimeInStateBuckets[i]
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	if h.Counts == nil {
+		h.Counts = make([]uint64, len(TimeInStateBuckets))
+	}
+
+	for i, b := range TimeInStateBuckets {
+		if d <= b {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += d
+}
+
+// MetricsSnapshot is the state of StateMetrics at a point in time
+type MetricsSnapshot struct {
+	// Sectors is the number of sectors currently in each state
+	Sectors map[SectorState]int64
+	// TimeInState has the time spent in each state, by sectors which left it
+	TimeInState map[SectorState]Histogram
+
+	Pieces     uint64
+	PieceBytes uint64
+
+	// CommitWait has the time sectors waited for their commit message to land
+	CommitWait Histogram
+}
+
+// StateMetrics is a SealingMetrics implementation keeping state counts and
+// time-in-state histograms, for exporting with Snapshot. Time in state is
+// measured on the Config.Clock it's configured with. It isn't measured for the
+// states sectors were loaded in, as it's not known when they were entered
+type StateMetrics struct {
+	lk sync.Mutex
+
+	entered map[abi.SectorNumber]time.Time
+	snap    MetricsSnapshot
+
+	now func() time.Time
+}
+
+func NewStateMetrics() *StateMetrics {
+	return &StateMetrics{
+		entered: map[abi.SectorNumber]time.Time{},
+		snap: MetricsSnapshot{
+			Sectors:     map[SectorState]int64{},
+			TimeInState: map[SectorState]Histogram{},
+		},
+		now: time.Now,
+	}
+}
+
+// setClock measures time in state on the clock of Sealing, see Config.Clock
+func (sm *StateMetrics) setClock(clk Clock) {
+	sm.lk.Lock()
+	defer sm.lk.Unlock()
+	sm.now = clk.Now
+}
+
+func (sm *StateMetrics) SectorStateChanged(sn abi.SectorNumber, old, new SectorState) {
+	sm.lk.Lock()
+	defer sm.lk.Unlock()
+
+	now := sm.now()
+
+	if old != UndefinedSectorState {
+		sm.snap.Sectors[old]--
+
+		if at, ok := sm.entered[sn]; ok {
+			h := sm.snap.TimeInState[old]
+			h.observe(now.Sub(at))
+			sm.snap.TimeInState[old] = h
+		}
+	}
+
+	sm.snap.Sectors[new]++
+	sm.entered[sn] = now
+}
+
+func (sm *StateMetrics) SectorLoaded(sn abi.SectorNumber, state SectorState) {
+	sm.lk.Lock()
+	defer sm.lk.Unlock()
+
+	sm.snap.Sectors[state]++
+	delete(sm.entered, sn)
+}
+
+func (sm *StateMetrics) PieceAdded(size abi.UnpaddedPieceSize) {
+	sm.lk.Lock()
+	defer sm.lk.Unlock()
+
+	sm.snap.Pieces++
+	sm.snap.PieceBytes += uint64(size)
+}
+
+func (sm *StateMetrics) CommitWaited(sn abi.SectorNumber, d time.Duration) {
+	sm.lk.Lock()
+	defer sm.lk.Unlock()
+
+	sm.snap.CommitWait.observe(d)
+}
+
+// Snapshot returns a copy of the current metrics
+func (sm *StateMetrics) Snapshot() MetricsSnapshot {
+	sm.lk.Lock()
+	defer sm.lk.Unlock()
+
+	out := MetricsSnapshot{
+		Sectors:     map[SectorState]int64{},
+		TimeInState: map[SectorState]Histogram{},
+		Pieces:      sm.snap.Pieces,
+		PieceBytes:  sm.snap.PieceBytes,
+		CommitWait:  sm.snap.CommitWait,
+	}
+	out.CommitWait.Counts = append([]uint64{}, sm.snap.CommitWait.Counts...)
+	for st, n := range sm.snap.Sectors {
+		out.Sectors[st] = n
+	}
+	for st, h := range sm.snap.TimeInState {
+		h.Counts = append([]uint64{}, h.Counts...)
+		out.TimeInState[st] = h
+	}
+	return out
+}
+
+var _ SealingMetrics = &StateMetrics{}
@@ -0,0 +1,119 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+type transition struct {
+	sn       abi.SectorNumber
+	old, new SectorState
+}
+
+type recordingMetrics struct {
+	lk          sync.Mutex
+	transitions []transition
+	pieces      []abi.UnpaddedPieceSize
+}
+
+func (r *recordingMetrics) SectorStateChanged(sn abi.SectorNumber, old, new SectorState) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.transitions = append(r.transitions, transition{sn, old, new})
+}
+
+func (r *recordingMetrics) SectorLoaded(sn abi.SectorNumber, state SectorState) {}
+
+func (r *recordingMetrics) PieceAdded(size abi.UnpaddedPieceSize) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.pieces = append(r.pieces, size)
+}
+
+func (r *recordingMetrics) CommitWaited(sn abi.SectorNumber, d time.Duration) {}
+
+func TestMetricsHook(t *testing.T) {
+	rm := &recordingMetrics{}
+	h := newTestHarness(t, Config{Metrics: rm})
+
+	sealing := make(chan struct{})
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		close(sealing)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	h.start(SectorInfo{
+		State:        Packing,
+		SectorNumber: 10,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces:       []Piece{{Piece: abi.PieceInfo{Size: 2048, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(2048).Unpadded())}}},
+	})
+	<-sealing
+
+	rm.lk.Lock()
+	require.Equal(t, []transition{{10, Packing, PreCommit1}}, rm.transitions)
+	rm.lk.Unlock()
+
+	// padding isn't reported
+	h.addDeal(1, 256)
+	h.addDeal(2, 512)
+	h.waitPieces(1, 3)
+
+	rm.lk.Lock()
+	require.Equal(t, []abi.UnpaddedPieceSize{254, 508}, rm.pieces)
+	require.Equal(t, transition{1, UndefinedSectorState, WaitDeals}, rm.transitions[1])
+	rm.lk.Unlock()
+}
+
+func TestStateMetrics(t *testing.T) {
+	sm := NewStateMetrics()
+	now := time.Unix(1000, 0)
+	sm.now = func() time.Time { return now }
+
+	sm.SectorLoaded(1, PreCommit1)
+	sm.SectorStateChanged(2, UndefinedSectorState, Packing)
+
+	now = now.Add(2 * time.Minute)
+	sm.SectorStateChanged(1, PreCommit1, PreCommit2)
+	sm.SectorStateChanged(2, Packing, PreCommit1)
+
+	now = now.Add(2 * time.Hour)
+	sm.SectorStateChanged(2, PreCommit1, PreCommit2)
+	sm.PieceAdded(1016)
+	sm.CommitWaited(2, 10*time.Minute)
+
+	snap := sm.Snapshot()
+	require.Equal(t, map[SectorState]int64{Packing: 0, PreCommit1: 0, PreCommit2: 2}, snap.Sectors)
+	require.Equal(t, uint64(1), snap.Pieces)
+	require.Equal(t, uint64(1016), snap.PieceBytes)
+
+	// sector 1 was loaded in PreCommit1, its time there is unknown
+	require.Equal(t, Histogram{
+		Counts: []uint64{0, 1, 1, 1, 1, 1, 1, 1, 1},
+		Count:  1,
+		Sum:    2 * time.Minute,
+	}, snap.TimeInState[Packing])
+	require.Equal(t, Histogram{
+		Counts: []uint64{0, 0, 0, 0, 1, 1, 1, 1, 1},
+		Count:  1,
+		Sum:    2 * time.Hour,
+	}, snap.TimeInState[PreCommit1])
+	require.Equal(t, Histogram{
+		Counts: []uint64{0, 0, 1, 1, 1, 1, 1, 1, 1},
+		Count:  1,
+		Sum:    10 * time.Minute,
+	}, snap.CommitWait)
+
+	// snapshots are copies
+	snap.TimeInState[Packing].Counts[0] = 10
+	require.Zero(t, sm.Snapshot().TimeInState[Packing].Counts[0])
+}
@@ -0,0 +1,23 @@
+package sealing
+
+import (
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// minExpiration extends an expiration set by the PreCommitPolicy by whole
+// proving periods, keeping its proving period alignment, until it's at least
+// Config.MinSectorExpiration epochs past the current height. Expirations are
+// kept within MaxSectorLifetime
+func (m *Sealing) minExpiration(expiration, height abi.ChainEpoch) abi.ChainEpoch {
+	min := m.cfg.MinSectorExpiration
+	if min <= 0 || expiration >= height+min {
+		return expiration
+	}
+
+	out := expiration
+	for out < height+min && out+miner.WPoStProvingPeriod <= height+MaxSectorLifetime {
+		out += miner.WPoStProvingPeriod
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// fixedExpiration is a PreCommitPolicy returning the same expiration for all
+// sectors
+type fixedExpiration abi.ChainEpoch
+
+func (f fixedExpiration) Expiration(ctx context.Context, ps ...Piece) (abi.ChainEpoch, error) {
+	return abi.ChainEpoch(f), nil
+}
+
+func TestMinExpiration(t *testing.T) {
+	period := miner.WPoStProvingPeriod
+	m := &Sealing{cfg: Config{MinSectorExpiration: 3 * period}}
+
+	// extended by whole proving periods
+	require.Equal(t, 110+3*period, m.minExpiration(110, 100))
+	// long enough already
+	require.Equal(t, 100+4*period, m.minExpiration(100+4*period, 100))
+	// not past the maximum lifetime
+	m.cfg.MinSectorExpiration = MaxSectorLifetime + period
+	exp := m.minExpiration(110, 100)
+	require.LessOrEqual(t, int64(exp), int64(100+MaxSectorLifetime))
+	require.Greater(t, int64(exp), int64(100+MaxSectorLifetime-period))
+	require.Zero(t, (exp-110)%period)
+
+	require.Equal(t, abi.ChainEpoch(110), (&Sealing{}).minExpiration(110, 100))
+}
+
+func TestMinExpirationPreCommit(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	pcp := fixedExpiration(20)
+	h.m = NewWithConfig(h.api, h.api, h.m.maddr, h.ds, h.sealer, &fakeCounter{}, fakeVerifier{}, pcp, Config{MinSectorExpiration: miner.WPoStProvingPeriod})
+
+	h.start(precommittingSector(h))
+	h.waitState(1, Proving)
+
+	h.api.lk.Lock()
+	defer h.api.lk.Unlock()
+	require.Equal(t, 20+miner.WPoStProvingPeriod, h.api.precommits[1].Info.Expiration)
+}
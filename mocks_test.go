@@ -0,0 +1,639 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	sectorstorage "github.com/filecoin-project/sector-storage"
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/sector-storage/storiface"
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	nr "github.com/filecoin-project/storage-fsm/lib/nullreader"
+)
+
+var (
+	testCommD = builtin.AccountActorCodeID
+	testCommR = builtin.StorageMinerActorCodeID
+	testRand  = abi.Randomness{1, 2, 3}
+)
+
+type sentMsg struct {
+	to       address.Address
+	method   abi.MethodNum
+	value    big.Int
+	gasPrice big.Int
+	gasLimit int64
+	params   []byte
+}
+
+// fakeAPI is an in-memory SealingAPI. Messages land immediately with exit
+// code 0 unless waitMsg is overridden
+type fakeAPI struct {
+	lk sync.Mutex
+
+	head       abi.ChainEpoch
+	precommits map[abi.SectorNumber]*miner.SectorPreCommitOnChainInfo
+	sectors    map[abi.SectorNumber]*miner.SectorOnChainInfo
+	deadlines  map[abi.SectorNumber]uint64 // proving deadlines of sectors
+	deals      map[abi.DealID]market.DealProposal
+	sent       []sentMsg
+
+	waitMsg func(cid.Cid) (MsgLookup, error)
+
+	sendErrs  []error // returned by the next SendMsg calls, in order
+	sendCalls int
+	// the next dropMsgs messages are dropped from the mempool; they get the
+	// testCommR cid, and never land
+	dropMsgs int
+
+	chainHeadErr   error
+	chainHeadCalls int
+	stateCalls     int // StateSectorPreCommitInfo, StateSectorGetInfo and StateMinerSectorSize
+	randCalls      int
+	commDCalls     int
+	commDErr       error
+
+	// initial pledge and available balance, 0 unless set
+	pledge  big.Int
+	balance big.Int
+
+	// gas estimates, 1 / 1000 unless set
+	gasPrice big.Int
+	gasLimit int64
+	gasErr   error
+
+	heightHandlers map[abi.ChainEpoch][]HeightHandler
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{
+		precommits: map[abi.SectorNumber]*miner.SectorPreCommitOnChainInfo{},
+		sectors:    map[abi.SectorNumber]*miner.SectorOnChainInfo{},
+		deadlines:  map[abi.SectorNumber]uint64{},
+		deals:      map[abi.DealID]market.DealProposal{},
+
+		heightHandlers: map[abi.ChainEpoch][]HeightHandler{},
+	}
+}
+
+// ChainAt makes fakeAPI implement Events too. Handlers are called when the
+// head reaches their height, confidence is ignored
+func (f *fakeAPI) ChainAt(hnd HeightHandler, rev RevertHandler, confidence int, h abi.ChainEpoch) error {
+	f.lk.Lock()
+	if f.head < h {
+		f.heightHandlers[h] = append(f.heightHandlers[h], hnd)
+		f.lk.Unlock()
+		return nil
+	}
+	f.lk.Unlock()
+
+	return hnd(context.TODO(), TipSetToken{1, 2, 3}, f.head)
+}
+
+func (f *fakeAPI) StateWaitMsg(ctx context.Context, c cid.Cid) (MsgLookup, error) {
+	f.lk.Lock()
+	wm := f.waitMsg
+	f.lk.Unlock()
+
+	if c == testCommR {
+		<-ctx.Done()
+		return MsgLookup{}, ctx.Err()
+	}
+	if wm != nil {
+		return wm(c)
+	}
+	return MsgLookup{}, nil
+}
+
+func (f *fakeAPI) StateComputeDataCommitment(ctx context.Context, maddr address.Address, sectorType abi.RegisteredSealProof, deals []abi.DealID, tok TipSetToken) (cid.Cid, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.commDCalls++
+	if f.commDErr != nil {
+		return cid.Undef, f.commDErr
+	}
+	return testCommD, nil
+}
+
+func (f *fakeAPI) StateSectorPreCommitInfo(ctx context.Context, maddr address.Address, sectorNumber abi.SectorNumber, tok TipSetToken) (*miner.SectorPreCommitOnChainInfo, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.stateCalls++
+	return f.precommits[sectorNumber], nil
+}
+
+func (f *fakeAPI) StateSectorGetInfo(ctx context.Context, maddr address.Address, sectorNumber abi.SectorNumber, tok TipSetToken) (*miner.SectorOnChainInfo, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.stateCalls++
+	return f.sectors[sectorNumber], nil
+}
+
+func (f *fakeAPI) StateMinerSectorSize(context.Context, address.Address, TipSetToken) (abi.SectorSize, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.stateCalls++
+	return 2048, nil
+}
+
+func (f *fakeAPI) StateMinerWorkerAddress(ctx context.Context, maddr address.Address, tok TipSetToken) (address.Address, error) {
+	return maddr, nil
+}
+
+func (f *fakeAPI) StateMinerDeadlines(ctx context.Context, maddr address.Address, tok TipSetToken) (*miner.Deadlines, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	dls := miner.ConstructDeadlines()
+	for sn, dl := range f.deadlines {
+		dls.Due[dl].Set(uint64(sn))
+	}
+	return dls, nil
+}
+
+func (f *fakeAPI) StateMinerInitialPledgeCollateral(context.Context, address.Address, abi.SectorNumber, TipSetToken) (big.Int, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	if f.pledge.Int == nil {
+		return big.Zero(), nil
+	}
+	return f.pledge, nil
+}
+
+func (f *fakeAPI) StateMinerAvailableBalance(context.Context, address.Address, TipSetToken) (big.Int, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	if f.balance.Int == nil {
+		return big.Zero(), nil
+	}
+	return f.balance, nil
+}
+
+func (f *fakeAPI) StateMarketStorageDeal(ctx context.Context, id abi.DealID, tok TipSetToken) (market.DealProposal, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.deals[id], nil
+}
+
+func (f *fakeAPI) EstimateMessageGas(ctx context.Context, from, to address.Address, method abi.MethodNum, value big.Int, params []byte) (big.Int, int64, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	if f.gasErr != nil {
+		return big.Int{}, 0, f.gasErr
+	}
+	if f.gasLimit == 0 {
+		return big.NewInt(1), 1000, nil
+	}
+	return f.gasPrice, f.gasLimit, nil
+}
+
+func (f *fakeAPI) SendMsg(ctx context.Context, from, to address.Address, method abi.MethodNum, value, gasPrice big.Int, gasLimit int64, params []byte) (cid.Cid, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.sendCalls++
+	if len(f.sendErrs) > 0 {
+		err := f.sendErrs[0]
+		f.sendErrs = f.sendErrs[1:]
+		return cid.Undef, err
+	}
+	f.sent = append(f.sent, sentMsg{to: to, method: method, value: value, gasPrice: gasPrice, gasLimit: gasLimit, params: params})
+
+	if f.dropMsgs > 0 {
+		f.dropMsgs--
+		return testCommR, nil
+	}
+
+	// messages land right away
+	switch method {
+	case builtin.MethodsMiner.PreCommitSector:
+		var pci miner.SectorPreCommitInfo
+		if err := pci.UnmarshalCBOR(bytes.NewReader(params)); err != nil {
+			return cid.Undef, err
+		}
+		f.precommits[pci.SectorNumber] = &miner.SectorPreCommitOnChainInfo{
+			Info:           pci,
+			PreCommitEpoch: f.head,
+		}
+	case builtin.MethodsMiner.ProveCommitSector:
+		var pcp miner.ProveCommitSectorParams
+		if err := pcp.UnmarshalCBOR(bytes.NewReader(params)); err != nil {
+			return cid.Undef, err
+		}
+		if pc := f.precommits[pcp.SectorNumber]; pc != nil {
+			f.sectors[pcp.SectorNumber] = &miner.SectorOnChainInfo{Info: pc.Info}
+		}
+	}
+
+	return builtin.CronActorCodeID, nil
+}
+
+func (f *fakeAPI) ChainHead(ctx context.Context) (TipSetToken, abi.ChainEpoch, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.chainHeadCalls++
+	if f.chainHeadErr != nil {
+		return nil, 0, f.chainHeadErr
+	}
+	return TipSetToken{1, 2, 3}, f.head, nil
+}
+
+func (f *fakeAPI) ChainGetRandomness(ctx context.Context, tok TipSetToken, personalization crypto.DomainSeparationTag, randEpoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.randCalls++
+	return testRand, nil
+}
+
+func (f *fakeAPI) ChainReadObj(context.Context, cid.Cid) ([]byte, error) {
+	return nil, nil
+}
+
+func (f *fakeAPI) setDeal(id abi.DealID, proposal market.DealProposal) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.deals[id] = proposal
+}
+
+// setOutage makes ChainHead fail with the given error, nil ends the outage
+func (f *fakeAPI) setOutage(err error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.chainHeadErr = err
+}
+
+func (f *fakeAPI) headCalls() int {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.chainHeadCalls
+}
+
+func (f *fakeAPI) setHead(h abi.ChainEpoch) {
+	f.lk.Lock()
+	f.head = h
+
+	var due []HeightHandler
+	for at, hnds := range f.heightHandlers {
+		if at <= h {
+			due = append(due, hnds...)
+			delete(f.heightHandlers, at)
+		}
+	}
+	f.lk.Unlock()
+
+	for _, hnd := range due {
+		if err := hnd(context.TODO(), TipSetToken{1, 2, 3}, h); err != nil {
+			log.Errorf("height handler: %+v", err)
+		}
+	}
+}
+
+// waitHandlers waits until n height handlers are registered
+func (f *fakeAPI) waitHandlers(t *testing.T, n int) {
+	require.Eventually(t, func() bool {
+		f.lk.Lock()
+		defer f.lk.Unlock()
+
+		var c int
+		for _, hnds := range f.heightHandlers {
+			c += len(hnds)
+		}
+		return c == n
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func (f *fakeAPI) sentMsgs() []sentMsg {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return append([]sentMsg{}, f.sent...)
+}
+
+// fakeSealer implements the parts of the SectorManager the tests exercise;
+// calling anything else panics
+type fakeSealer struct {
+	sectorstorage.SectorManager
+
+	sectorSize abi.SectorSize
+
+	preCommit1    func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error)
+	preCommit2    func(sector abi.SectorID)
+	commit1       func(sector abi.SectorID)
+	commit2       func(ctx context.Context, sector abi.SectorID) (storage.Proof, error)
+	checkProvable func(sectors []abi.SectorID) ([]abi.SectorID, error)
+	readPiece     func(w io.Writer, sector abi.SectorID, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) error
+	readKey       func(ticket abi.SealRandomness, unsealed cid.Cid) // unseal inputs of ReadPiece calls
+	finalize      func(sector abi.SectorID) error
+	finalizeKeep  func(keepUnsealed []storage.Range) // unsealed ranges kept by FinalizeSector calls
+	addPiece      func(ctx context.Context, sector abi.SectorID, size abi.UnpaddedPieceSize)
+	addPieceAt    func(offset abi.PaddedPieceSize, size abi.UnpaddedPieceSize) // offset as computed by ffiwrapper
+	addPieceErr   error
+	newSector     func(ctx context.Context, sector abi.SectorID) error
+	remove        func(sector abi.SectorID) error
+}
+
+func (f *fakeSealer) SectorSize() abi.SectorSize {
+	if f.sectorSize == 0 {
+		return 2048
+	}
+	return f.sectorSize
+}
+
+func (f *fakeSealer) NewSector(ctx context.Context, sector abi.SectorID) error {
+	if f.newSector != nil {
+		return f.newSector(ctx, sector)
+	}
+	return nil
+}
+
+func (f *fakeSealer) AddPiece(ctx context.Context, sector abi.SectorID, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (abi.PieceInfo, error) {
+	if f.addPiece != nil {
+		f.addPiece(ctx, sector, newPieceSize)
+	}
+	if f.addPieceAt != nil {
+		var offset abi.UnpaddedPieceSize
+		for _, size := range pieceSizes {
+			offset += size
+		}
+		f.addPieceAt(offset.Padded(), newPieceSize)
+	}
+	if f.addPieceErr != nil {
+		return abi.PieceInfo{}, f.addPieceErr
+	}
+	return abi.PieceInfo{
+		Size:     newPieceSize.Padded(),
+		PieceCID: zerocomm.ZeroPieceCommitment(newPieceSize),
+	}, nil
+}
+
+func (f *fakeSealer) SealPreCommit1(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+	if f.preCommit1 != nil {
+		return f.preCommit1(ctx, sector, ticket, pieces)
+	}
+	return storage.PreCommit1Out{}, nil
+}
+
+func (f *fakeSealer) SealPreCommit2(ctx context.Context, sector abi.SectorID, pc1o storage.PreCommit1Out) (storage.SectorCids, error) {
+	if f.preCommit2 != nil {
+		f.preCommit2(sector)
+	}
+	return storage.SectorCids{Unsealed: testCommD, Sealed: testCommR}, nil
+}
+
+func (f *fakeSealer) SealCommit1(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (storage.Commit1Out, error) {
+	if f.commit1 != nil {
+		f.commit1(sector)
+	}
+	return storage.Commit1Out{}, nil
+}
+
+func (f *fakeSealer) SealCommit2(ctx context.Context, sector abi.SectorID, c1o storage.Commit1Out) (storage.Proof, error) {
+	if f.commit2 != nil {
+		return f.commit2(ctx, sector)
+	}
+	return storage.Proof{1}, nil
+}
+
+func (f *fakeSealer) FinalizeSector(ctx context.Context, sector abi.SectorID, keepUnsealed []storage.Range) error {
+	if f.finalizeKeep != nil {
+		f.finalizeKeep(keepUnsealed)
+	}
+	if f.finalize != nil {
+		return f.finalize(sector)
+	}
+	return nil
+}
+
+func (f *fakeSealer) ReadPiece(ctx context.Context, w io.Writer, sector abi.SectorID, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize, ticket abi.SealRandomness, unsealed cid.Cid) error {
+	if f.readKey != nil {
+		f.readKey(ticket, unsealed)
+	}
+	if f.readPiece != nil {
+		return f.readPiece(w, sector, offset, size)
+	}
+	_, err := io.CopyN(w, &nr.Reader{}, int64(size))
+	return err
+}
+
+func (f *fakeSealer) CheckProvable(ctx context.Context, spt abi.RegisteredSealProof, sectors []abi.SectorID) ([]abi.SectorID, error) {
+	if f.checkProvable != nil {
+		return f.checkProvable(sectors)
+	}
+	return nil, nil
+}
+
+func (f *fakeSealer) Remove(ctx context.Context, sector abi.SectorID) error {
+	if f.remove != nil {
+		return f.remove(sector)
+	}
+	return nil
+}
+
+type fakeVerifier struct {
+	ffiwrapper.Verifier
+}
+
+func (fakeVerifier) VerifySeal(abi.SealVerifyInfo) (bool, error) {
+	return true, nil
+}
+
+type fakeCounter struct {
+	lk   sync.Mutex
+	next abi.SectorNumber
+}
+
+func (f *fakeCounter) Next() (abi.SectorNumber, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.next++
+	return f.next, nil
+}
+
+type testHarness struct {
+	t *testing.T
+
+	ds     datastore.Batching
+	api    *fakeAPI
+	sealer *fakeSealer
+	m      *Sealing
+}
+
+func newTestHarness(t *testing.T, cfg Config) *testHarness {
+	return newTestHarnessOn(t, dssync.MutexWrap(datastore.NewMapDatastore()), 1000, cfg)
+}
+
+// newTestHarnessOn creates a harness for the miner with the given ID, over an
+// existing datastore
+func newTestHarnessOn(t *testing.T, ds datastore.Batching, miner uint64, cfg Config) *testHarness {
+	maddr, err := address.NewIDAddress(miner)
+	require.NoError(t, err)
+
+	h := &testHarness{
+		t:      t,
+		ds:     ds,
+		api:    newFakeAPI(),
+		sealer: &fakeSealer{},
+	}
+
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+
+	h.m = NewWithConfig(h.api, h.api, maddr, h.ds, h.sealer, &fakeCounter{}, fakeVerifier{}, &pcp, cfg)
+	t.Cleanup(func() {
+		_ = h.m.Stop(context.Background())
+	})
+
+	return h
+}
+
+// committingSector returns a sector ready to compute the commit proof, and
+// makes its precommit visible on chain
+func (h *testHarness) committingSector(sn abi.SectorNumber) SectorInfo {
+	commD, commR := testCommD, testCommR
+
+	h.api.lk.Lock()
+	h.api.precommits[sn] = &miner.SectorPreCommitOnChainInfo{
+		Info: miner.SectorPreCommitInfo{
+			SealProof:    abi.RegisteredSealProof_StackedDrg2KiBV1,
+			SectorNumber: sn,
+			SealedCID:    commR,
+		},
+		PreCommitEpoch: 10,
+	}
+	h.api.sectors[sn] = &miner.SectorOnChainInfo{}
+	h.api.lk.Unlock()
+
+	return SectorInfo{
+		State:        Committing,
+		SectorNumber: sn,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		CommD:        &commD,
+		CommR:        &commR,
+		TicketValue:  abi.SealRandomness(testRand),
+		SeedValue:    abi.InteractiveSealRandomness(testRand),
+		SeedEpoch:    10 + miner.PreCommitChallengeDelay,
+	}
+}
+
+// put persists the sector info without starting its state machine
+func (h *testHarness) put(si SectorInfo) {
+	b, err := cborutil.Dump(&si)
+	require.NoError(h.t, err)
+
+	prefix, _ := datastoreKeys(h.m.cfg, h.m.maddr)
+	key := prefix.ChildString(fmt.Sprint(uint64(si.SectorNumber)))
+	require.NoError(h.t, h.ds.Put(key, b))
+}
+
+// start persists the sector info and restarts its state machine, the same way
+// restartSectors does after a node restart
+func (h *testHarness) start(si SectorInfo) {
+	h.put(si)
+	require.NoError(h.t, h.m.sectors.Send(uint64(si.SectorNumber), SectorRestart{}))
+}
+
+func (h *testHarness) sector(sn abi.SectorNumber) SectorInfo {
+	si, err := h.m.GetSectorInfo(sn)
+	require.NoError(h.t, err)
+	return si
+}
+
+// waitState waits for the sector to reach the given state
+func (h *testHarness) waitState(sn abi.SectorNumber, state SectorState) SectorInfo {
+	var si SectorInfo
+	require.Eventually(h.t, func() bool {
+		si = h.sector(sn)
+		return si.State == state
+	}, 5*time.Second, 5*time.Millisecond, "sector %d didn't reach %s (state: %s)", sn, state, si.State)
+	return si
+}
+
+// fakeClock only moves when advanced
+type fakeClock struct {
+	lk      sync.Mutex
+	now     time.Time
+	waiters []clockWaiter
+}
+
+type clockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1600000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, clockWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// advance moves the clock forward, firing due timers
+func (c *fakeClock) advance(d time.Duration) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// waitTimers waits for n timers to be pending. Polls by hand, as
+// require.Eventually can panic when a slow check outlives it
+func (c *fakeClock) waitTimers(t *testing.T, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.lk.Lock()
+		pending := len(c.waiters)
+		c.lk.Unlock()
+
+		if pending >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d timers pending, expected %d", pending, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
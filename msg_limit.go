@@ -0,0 +1,73 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+)
+
+const defaultMessageRateInterval = 1 * time.Minute
+
+// msgLimiter spaces out chain messages evenly, so that at most `n` are sent
+// per interval. Senders are let through in the order they arrived
+type msgLimiter struct {
+	spacing time.Duration
+	clock   Clock
+
+	lk   sync.Mutex
+	next time.Time
+}
+
+func newMsgLimiter(n int, interval time.Duration, clock Clock) *msgLimiter {
+	return &msgLimiter{
+		spacing: interval / time.Duration(n),
+		clock:   clock,
+	}
+}
+
+// wait blocks until the caller's send slot. A caller giving up returns its
+// slot, as long as no one reserved a later one
+func (l *msgLimiter) wait(ctx context.Context) error {
+	l.lk.Lock()
+	at := l.next
+	now := l.clock.Now()
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.spacing)
+	l.lk.Unlock()
+
+	select {
+	case <-l.clock.After(at.Sub(now)):
+		return nil
+	case <-ctx.Done():
+		l.lk.Lock()
+		if l.next.Equal(at.Add(l.spacing)) {
+			l.next = at
+		}
+		l.lk.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// limitedAPI rate limits SendMsg calls, other calls go straight through
+type limitedAPI struct {
+	SealingAPI
+	l *msgLimiter
+}
+
+func (a *limitedAPI) SendMsg(ctx context.Context, from, to address.Address, method abi.MethodNum, value, gasPrice big.Int, gasLimit int64, params []byte) (cid.Cid, error) {
+	if err := a.l.wait(ctx); err != nil {
+		return cid.Undef, err
+	}
+	return a.SealingAPI.SendMsg(ctx, from, to, method, value, gasPrice, gasLimit, params)
+}
+
+var _ SealingAPI = &limitedAPI{}
@@ -0,0 +1,71 @@
+package sealing
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+)
+
+func TestMessageRateLimit(t *testing.T) {
+	h := newTestHarness(t, Config{MessageRateLimit: 2, MessageRateInterval: 100 * time.Millisecond})
+
+	var lk sync.Mutex
+	var sent []time.Duration
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := h.m.api.SendMsg(context.Background(), h.m.maddr, h.m.maddr, 0, big.Zero(), big.Zero(), 0, nil)
+			require.NoError(t, err)
+
+			lk.Lock()
+			sent = append(sent, time.Since(start))
+			lk.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, h.api.sentMsgs(), 5)
+
+	sort.Slice(sent, func(i, j int) bool { return sent[i] < sent[j] })
+	require.Less(t, int64(sent[0]), int64(50*time.Millisecond))
+	for i := 1; i < len(sent); i++ {
+		require.GreaterOrEqual(t, int64(sent[i]), int64(time.Duration(i)*50*time.Millisecond-5*time.Millisecond), "send %d", i)
+	}
+}
+
+func TestMessageRateLimitCancel(t *testing.T) {
+	clk := newFakeClock()
+	l := newMsgLimiter(1, time.Minute, clk)
+
+	require.NoError(t, l.wait(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, context.Canceled, l.wait(ctx))
+
+	// the cancelled send gave its slot back
+	done := make(chan error, 1)
+	go func() {
+		done <- l.wait(context.Background())
+	}()
+	clk.waitTimers(t, 2)
+	clk.advance(time.Minute)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("send didn't get the cancelled slot")
+	}
+}
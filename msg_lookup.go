@@ -0,0 +1,57 @@
+package sealing
+
+import (
+	"time"
+
+	"golang.org/x/xerrors"
+
+	statemachine "github.com/filecoin-project/go-statemachine"
+)
+
+const defaultMessageLookupRecheck = 30 * time.Second
+
+// ErrMsgNotFound should be returned (or wrapped) by StateWaitMsg
+// implementations when the node doesn't know the message (yet). The lookup is
+// repeated every Config.MessageLookupRecheck
+type ErrMsgNotFound struct{ error }
+
+// ErrNodeUnreachable should be returned (or wrapped) by StateWaitMsg
+// implementations when the node couldn't be reached. The lookup is retried
+// with backoff. Other SealingAPI calls can return it too, it counts towards
+// Config.ChainFailureThreshold
+type ErrNodeUnreachable struct{ error }
+
+type lookupErrorClass int
+
+const (
+	lookupFailed lookupErrorClass = iota
+	lookupNotFound
+	lookupUnreachable
+)
+
+// classifyLookupError tells how waitMsg handles an error returned by
+// StateWaitMsg. Errors which weren't classified by the API fail the wait
+func classifyLookupError(err error) lookupErrorClass {
+	switch {
+	case xerrors.As(err, new(*ErrMsgNotFound)):
+		return lookupNotFound
+	case xerrors.As(err, new(*ErrNodeUnreachable)):
+		return lookupUnreachable
+	default:
+		return lookupFailed
+	}
+}
+
+// handleMessageFailed hands a sector whose precommit or commit message was
+// executed with a non-zero exit code over to the failure handling of the wait
+// state. The exit code is kept in the sector info
+func (m *Sealing) handleMessageFailed(ctx statemachine.Context, sector SectorInfo) error {
+	switch sector.FailedWait {
+	case PreCommitWait:
+		return ctx.Send(SectorChainPreCommitFailed{xerrors.Errorf("sector precommit failed (exit=%d, gas used=%d, msg=%s)", sector.MessageExitCode, sector.MessageGasUsed, sector.PreCommitMessage)})
+	case CommitWait:
+		return ctx.Send(SectorCommitFailed{xerrors.Errorf("submitting sector proof failed (exit=%d, gas used=%d, msg=%s) (t:%x; s:%x(%d); p:%x)", sector.MessageExitCode, sector.MessageGasUsed, sector.CommitMessage, sector.TicketValue, sector.SeedValue, sector.SeedEpoch, sector.Proof)})
+	default:
+		return xerrors.Errorf("sector %d: message failed in unexpected wait state %q", sector.SectorNumber, sector.FailedWait)
+	}
+}
@@ -0,0 +1,89 @@
+package sealing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+// failLookups makes the first lookups fail with the given errors
+func failLookups(h *testHarness, errs ...error) *int {
+	calls := new(int)
+	h.api.waitMsg = func(cid.Cid) (MsgLookup, error) {
+		h.api.lk.Lock()
+		defer h.api.lk.Unlock()
+		*calls++
+		if *calls <= len(errs) {
+			return MsgLookup{}, errs[*calls-1]
+		}
+		return MsgLookup{}, nil
+	}
+	return calls
+}
+
+func TestClassifyLookupError(t *testing.T) {
+	require.Equal(t, lookupNotFound, classifyLookupError(xerrors.Errorf("lookup: %w", &ErrMsgNotFound{xerrors.New("not found")})))
+	require.Equal(t, lookupUnreachable, classifyLookupError(xerrors.Errorf("lookup: %w", &ErrNodeUnreachable{xerrors.New("connection refused")})))
+	require.Equal(t, lookupFailed, classifyLookupError(xerrors.New("bad message")))
+}
+
+func TestMessageLookupNotFound(t *testing.T) {
+	h := newTestHarness(t, Config{MessageLookupRecheck: time.Millisecond})
+	calls := failLookups(h,
+		&ErrMsgNotFound{xerrors.New("not found")},
+		&ErrMsgNotFound{xerrors.New("not found")},
+	)
+
+	h.start(h.committingSector(1))
+	si := h.waitState(1, Proving)
+	require.False(t, hasEvent(si, SectorCommitFailed{}))
+
+	h.api.lk.Lock()
+	require.Equal(t, 3, *calls)
+	h.api.lk.Unlock()
+}
+
+func TestMessageLookupUnreachable(t *testing.T) {
+	h := newTestHarness(t, Config{SendRetry: RetryPolicy{BaseDelay: time.Millisecond}})
+	calls := failLookups(h,
+		&ErrNodeUnreachable{xerrors.New("connection refused")},
+		&ErrNodeUnreachable{xerrors.New("connection refused")},
+	)
+
+	h.start(h.committingSector(1))
+	si := h.waitState(1, Proving)
+	require.False(t, hasEvent(si, SectorCommitFailed{}))
+
+	h.api.lk.Lock()
+	require.Equal(t, 3, *calls)
+	h.api.lk.Unlock()
+}
+
+func TestMessageLookupFailed(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	failLookups(h, xerrors.New("bad message"))
+
+	h.start(stuckCommitSector(h))
+	si := h.waitState(1, CommitFailed)
+	require.False(t, hasEvent(si, SectorMessageFailed{}))
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "bad message")
+}
+
+func TestMessageExecutionFailed(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.waitMsg = func(cid.Cid) (MsgLookup, error) {
+		return MsgLookup{Receipt: MessageReceipt{ExitCode: exitcode.ErrInsufficientFunds, GasUsed: 1234}}, nil
+	}
+
+	h.start(stuckCommitSector(h))
+	si := h.waitState(1, CommitFailed)
+	require.True(t, hasEvent(si, SectorMessageFailed{}))
+	require.Equal(t, CommitWait, si.FailedWait)
+	require.Equal(t, exitcode.ErrInsufficientFunds, si.MessageExitCode)
+	require.Equal(t, int64(1234), si.MessageGasUsed)
+}
@@ -0,0 +1,179 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	statemachine "github.com/filecoin-project/go-statemachine"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// waitMsg waits for the message to land on chain. landed is false if it
+// didn't within MessageWaitTimeout. Lookups of messages the node doesn't know
+// yet, and lookups which failed to reach the node are repeated, see
+// classifyLookupError
+func (m *Sealing) waitMsg(ctx context.Context, c cid.Cid) (mw MsgLookup, landed bool, err error) {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	timedOut := make(chan struct{})
+	if m.cfg.MessageWaitTimeout != 0 {
+		go func() {
+			select {
+			case <-m.clock.After(m.cfg.MessageWaitTimeout):
+				close(timedOut)
+				cancel()
+			case <-wctx.Done():
+			}
+		}()
+	}
+
+	unreachable := 0
+	for {
+		mw, err = m.api.StateWaitMsg(wctx, c)
+		if err == nil || ctx.Err() != nil {
+			return mw, true, err
+		}
+
+		var delay time.Duration
+		switch classifyLookupError(err) {
+		case lookupNotFound:
+			delay = m.cfg.MessageLookupRecheck
+			if delay == 0 {
+				delay = defaultMessageLookupRecheck
+			}
+			log.Debugf("message %s not found yet, looking it up again in %s", c, delay)
+		case lookupUnreachable:
+			unreachable++
+			delay = m.cfg.SendRetry.delay(unreachable)
+			log.Warnf("looking up message %s failed, retrying in %s (attempt %d): %+v", c, delay, unreachable, err)
+		default:
+			select {
+			case <-timedOut:
+				return MsgLookup{}, false, nil
+			default:
+			}
+			return mw, true, err
+		}
+
+		select {
+		case <-m.clock.After(delay):
+		case <-timedOut:
+			return MsgLookup{}, false, nil
+		case <-ctx.Done():
+			return MsgLookup{}, true, ctx.Err()
+		}
+	}
+}
+
+// handleMessageStuck resends the precommit or commit message which didn't
+// land in time, with the same params and a fresh gas estimate. If the stuck
+// message landed after all, the sector moves on without resending
+func (m *Sealing) handleMessageStuck(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
+	tok, _, err := m.tickAPI(sector).ChainHead(ctx.Context())
+	if err != nil {
+		log.Errorf("handleMessageStuck: api error, not proceeding: %+v", err)
+		return nil
+	}
+
+	switch sector.StuckWait {
+	case PreCommitWait:
+		return m.resendPreCommit(ctx, sector, tok)
+	case CommitWait:
+		return m.resendCommit(ctx, sector, tok)
+	default:
+		return xerrors.Errorf("sector %d stuck in unexpected wait state %q", sector.SectorNumber, sector.StuckWait)
+	}
+}
+
+func (m *Sealing) resendPreCommit(ctx statemachine.Context, sector SectorInfo, tok TipSetToken) error {
+	log := m.stateLog(sector)
+
+	pci, err := m.api.StateSectorPreCommitInfo(ctx.Context(), m.maddr, sector.SectorNumber, tok)
+	if err != nil {
+		log.Errorf("handleMessageStuck: api error, not proceeding: %+v", err)
+		return nil
+	}
+	if pci != nil && sector.CommR != nil && pci.Info.SealedCID == *sector.CommR {
+		log.Infof("stuck precommit message of sector %d landed", sector.SectorNumber)
+		return ctx.Send(m.preCommitLanded(tok))
+	}
+
+	if sector.PreCommitInfo == nil {
+		return ctx.Send(SectorChainPreCommitFailed{xerrors.Errorf("precommit message stuck, and its params weren't recorded")})
+	}
+
+	enc := new(bytes.Buffer)
+	if err := sector.PreCommitInfo.MarshalCBOR(enc); err != nil {
+		return ctx.Send(SectorChainPreCommitFailed{xerrors.Errorf("could not serialize pre-commit sector parameters: %w", err)})
+	}
+
+	waddr, err := m.api.StateMinerWorkerAddress(ctx.Context(), m.maddr, tok)
+	if err != nil {
+		log.Errorf("handleMessageStuck: api error, not proceeding: %+v", err)
+		return nil
+	}
+
+	log.Warnf("resending precommit of sector %d, stuck message: %s", sector.SectorNumber, sector.PreCommitMessage)
+	gasPrice, gasLimit := m.messageGas(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.PreCommitSector, big.NewInt(0), enc.Bytes())
+	mcid, err := m.sendMsg(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.PreCommitSector, big.NewInt(0), gasPrice, gasLimit, enc.Bytes())
+	if err != nil {
+		return ctx.Send(SectorChainPreCommitFailed{xerrors.Errorf("pushing message to mpool: %w", err)})
+	}
+
+	return ctx.Send(SectorMessageResent{Message: mcid})
+}
+
+func (m *Sealing) resendCommit(ctx statemachine.Context, sector SectorInfo, tok TipSetToken) error {
+	log := m.stateLog(sector)
+
+	si, err := m.api.StateSectorGetInfo(ctx.Context(), m.maddr, sector.SectorNumber, tok)
+	if err != nil {
+		log.Errorf("handleMessageStuck: api error, not proceeding: %+v", err)
+		return nil
+	}
+	if si != nil {
+		log.Infof("stuck commit message of sector %d landed", sector.SectorNumber)
+		return ctx.Send(m.sectorProven(ctx.Context(), sector, tok))
+	}
+
+	params := &miner.ProveCommitSectorParams{
+		SectorNumber: sector.SectorNumber,
+		Proof:        sector.Proof,
+	}
+
+	enc := new(bytes.Buffer)
+	if err := params.MarshalCBOR(enc); err != nil {
+		return ctx.Send(SectorCommitFailed{xerrors.Errorf("could not serialize commit sector parameters: %w", err)})
+	}
+
+	waddr, err := m.api.StateMinerWorkerAddress(ctx.Context(), m.maddr, tok)
+	if err != nil {
+		log.Errorf("handleMessageStuck: api error, not proceeding: %+v", err)
+		return nil
+	}
+
+	collateral, err := m.commitCollateral(ctx.Context(), sector.SectorNumber, tok)
+	if _, short := err.(*ErrInsufficientCollateral); short {
+		return ctx.Send(SectorCommitFailed{err})
+	} else if err != nil {
+		return err
+	}
+
+	log.Warnf("resending commit of sector %d, stuck message: %s", sector.SectorNumber, sector.CommitMessage)
+	gasPrice, gasLimit := m.messageGas(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.ProveCommitSector, collateral, enc.Bytes())
+	mcid, err := m.sendMsg(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.ProveCommitSector, collateral, gasPrice, gasLimit, enc.Bytes())
+	if err != nil {
+		return ctx.Send(SectorCommitFailed{xerrors.Errorf("pushing message to mpool: %w", err)})
+	}
+
+	return ctx.Send(SectorMessageResent{Message: mcid})
+}
@@ -0,0 +1,126 @@
+package sealing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+func (h *testHarness) waitSent(n int) {
+	require.Eventually(h.t, func() bool {
+		return len(h.api.sentMsgs()) >= n
+	}, 5*time.Second, time.Millisecond)
+}
+
+func hasEvent(si SectorInfo, evt interface{}) bool {
+	for _, l := range si.Log {
+		if l.Kind == logKind(evt) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPreCommitMessageStuck(t *testing.T) {
+	h := newTestHarness(t, Config{MessageWaitTimeout: 100 * time.Millisecond})
+	h.api.gasPrice, h.api.gasLimit = big.NewInt(7), 1000
+	h.api.dropMsgs = 1
+
+	h.start(precommittingSector(h))
+
+	h.waitSent(1)
+	h.api.lk.Lock()
+	h.api.gasPrice = big.NewInt(9)
+	h.api.lk.Unlock()
+
+	si := h.waitState(1, WaitSeed)
+	require.True(t, hasEvent(si, SectorMessageStuck{}))
+	require.Equal(t, builtin.CronActorCodeID, *si.PreCommitMessage)
+
+	sent := h.api.sentMsgs()
+	require.Len(t, sent, 2)
+	for _, msg := range sent {
+		require.Equal(t, builtin.MethodsMiner.PreCommitSector, msg.method)
+	}
+	require.Equal(t, sent[0].params, sent[1].params)
+	require.Equal(t, big.NewInt(7), sent[0].gasPrice)
+	require.Equal(t, big.NewInt(9), sent[1].gasPrice)
+}
+
+func stuckCommitSector(h *testHarness) SectorInfo {
+	si := h.committingSector(1)
+
+	h.api.lk.Lock()
+	delete(h.api.sectors, 1) // proven by the commit message
+	h.api.lk.Unlock()
+
+	return si
+}
+
+func TestCommitMessageStuck(t *testing.T) {
+	h := newTestHarness(t, Config{MessageWaitTimeout: 100 * time.Millisecond})
+	h.api.dropMsgs = 1
+
+	h.start(stuckCommitSector(h))
+
+	si := h.waitState(1, Proving)
+	require.True(t, hasEvent(si, SectorMessageStuck{}))
+
+	sent := h.api.sentMsgs()
+	require.Len(t, sent, 2)
+	require.Equal(t, builtin.MethodsMiner.ProveCommitSector, sent[1].method)
+	require.Equal(t, sent[0].params, sent[1].params)
+}
+
+func TestStuckMessageLandsBeforeResend(t *testing.T) {
+	h := newTestHarness(t, Config{MessageWaitTimeout: 100 * time.Millisecond})
+	h.api.dropMsgs = 1
+
+	h.start(stuckCommitSector(h))
+
+	// the node lost track of the message, but it made it on chain
+	h.waitSent(1)
+	h.api.lk.Lock()
+	h.api.sectors[1] = &miner.SectorOnChainInfo{}
+	h.api.lk.Unlock()
+
+	si := h.waitState(1, Proving)
+	require.True(t, hasEvent(si, SectorMessageStuck{}))
+	require.Len(t, h.api.sentMsgs(), 1)
+}
+
+func TestStuckMessageLandsAfterResend(t *testing.T) {
+	h := newTestHarness(t, Config{MessageWaitTimeout: 100 * time.Millisecond})
+	h.api.dropMsgs = 1
+
+	// the resent message fails, as the sector was proven by the stuck one
+	h.api.waitMsg = func(cid.Cid) (MsgLookup, error) {
+		return MsgLookup{Receipt: MessageReceipt{ExitCode: exitcode.ErrIllegalArgument}}, nil
+	}
+
+	h.start(stuckCommitSector(h))
+
+	si := h.waitState(1, Proving)
+	require.True(t, hasEvent(si, SectorMessageStuck{}))
+	require.False(t, hasEvent(si, SectorCommitFailed{}))
+	require.Len(t, h.api.sentMsgs(), 2)
+}
+
+func TestMessageWaitNoTimeout(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.dropMsgs = 1
+
+	h.start(stuckCommitSector(h))
+	h.waitState(1, CommitWait)
+
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, CommitWait, h.sector(1).State)
+	require.Len(t, h.api.sentMsgs(), 1)
+}
@@ -0,0 +1,97 @@
+package sealing
+
+import (
+	"context"
+	"sort"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// StoredSectorLister can be implemented by SectorManagers which can list the
+// sectors they have files of
+type StoredSectorLister interface {
+	StoredSectors(ctx context.Context) ([]abi.SectorID, error)
+}
+
+// ErrNotOrphan is returned when reclaiming a sector which is tracked, or is
+// precommitted or committed on chain
+type ErrNotOrphan struct{ error }
+
+// FindOrphans returns the sectors of the miner which the sealer has files of,
+// but which have no sector record, e.g. because the node went down while the
+// sector was created, or the record was garbage collected without the files.
+// Their files can be removed with ReclaimOrphan
+func (m *Sealing) FindOrphans(ctx context.Context) ([]abi.SectorNumber, error) {
+	lister, ok := m.sealer.(StoredSectorLister)
+	if !ok {
+		return nil, xerrors.Errorf("sealer doesn't support listing stored sectors")
+	}
+
+	stored, err := lister.StoredSectors(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("listing stored sectors: %w", err)
+	}
+
+	miner := m.minerSector(0).Miner
+	var out []abi.SectorNumber
+	for _, sid := range stored {
+		if sid.Miner != miner {
+			continue
+		}
+
+		_, err := m.GetSectorInfo(sid.Number)
+		if xerrors.As(err, new(*ErrSectorNotFound)) {
+			out = append(out, sid.Number)
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("getting info of sector %d: %w", sid.Number, err)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i] < out[j]
+	})
+	return out, nil
+}
+
+// ReclaimOrphan removes the files of a sector without a sector record. Sectors
+// which are precommitted or committed on chain are kept even without a
+// record, their files may still be needed for proving
+func (m *Sealing) ReclaimOrphan(ctx context.Context, sid abi.SectorNumber) error {
+	_, err := m.GetSectorInfo(sid)
+	if err == nil {
+		return &ErrNotOrphan{xerrors.Errorf("sector %d is tracked", sid)}
+	}
+	if !xerrors.As(err, new(*ErrSectorNotFound)) {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	tok, _, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	cs, err := m.sectorsChainState(ctx, []abi.SectorNumber{sid}, tok)
+	if err != nil {
+		return err
+	}
+	if onChain := cs.Sectors[sid]; onChain.PreCommit != nil || onChain.Sector != nil {
+		return &ErrNotOrphan{xerrors.Errorf("sector %d is precommitted or committed on chain", sid)}
+	}
+
+	done, err := m.beginIntent(sid, IntentRemove)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := m.sealer.Remove(ctx, m.minerSector(sid)); err != nil {
+		return xerrors.Errorf("removing files of sector %d: %w", sid, err)
+	}
+
+	m.sectorLog(sid).Infof("reclaimed files of orphaned sector %d", sid)
+	return nil
+}
@@ -0,0 +1,76 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// storingSealer has files of the stored sectors
+type storingSealer struct {
+	*fakeSealer
+
+	stored []abi.SectorID
+}
+
+func (s *storingSealer) StoredSectors(ctx context.Context) ([]abi.SectorID, error) {
+	return s.stored, nil
+}
+
+func TestFindOrphans(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	ss := &storingSealer{fakeSealer: h.sealer}
+	h.m.sealer = ss
+
+	h.put(SectorInfo{SectorNumber: 1, State: Proving})
+	h.put(SectorInfo{SectorNumber: 3, State: PreCommit1})
+	ss.stored = []abi.SectorID{
+		h.m.minerSector(5),
+		h.m.minerSector(1),
+		h.m.minerSector(2),
+		h.m.minerSector(3),
+		{Miner: 2000, Number: 4}, // another miner's sector
+	}
+
+	orphans, err := h.m.FindOrphans(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []abi.SectorNumber{2, 5}, orphans)
+}
+
+func TestFindOrphansUnsupported(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	_, err := h.m.FindOrphans(context.Background())
+	require.Error(t, err)
+}
+
+func TestReclaimOrphan(t *testing.T) {
+	h := newTestHarness(t, Config{SealerIntents: true})
+	removed := recordRemoves(h)
+
+	h.put(SectorInfo{SectorNumber: 1, State: Proving})
+
+	// precommitted and committed sectors are kept without a record
+	h.api.lk.Lock()
+	h.api.precommits[2] = &miner.SectorPreCommitOnChainInfo{}
+	h.api.sectors[3] = &miner.SectorOnChainInfo{}
+	h.api.lk.Unlock()
+
+	for _, sn := range []abi.SectorNumber{1, 2, 3} {
+		err := h.m.ReclaimOrphan(context.Background(), sn)
+		require.True(t, xerrors.As(err, new(*ErrNotOrphan)), "sector %d: %+v", sn, err)
+	}
+	require.Empty(t, *removed)
+
+	require.NoError(t, h.m.ReclaimOrphan(context.Background(), 4))
+	require.Equal(t, []abi.SectorNumber{4}, *removed)
+
+	intents, err := h.m.DanglingIntents()
+	require.NoError(t, err)
+	require.Empty(t, intents)
+}
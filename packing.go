@@ -0,0 +1,176 @@
+package sealing
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+const defaultPackingSweepInterval = time.Minute
+
+// PackingStrategy decides which of the sectors accepting deals a new piece
+// goes to
+type PackingStrategy int
+
+const (
+	// FirstFit puts the piece in the lowest numbered sector it fits in
+	FirstFit PackingStrategy = iota
+	// BestFit puts the piece in the sector with the least free space it
+	// still fits in, which leaves less space to be filled with filler pieces
+	BestFit
+)
+
+// selectSector picks a sector for a piece of the given size, and returns the
+// padding which has to be written before it. ok is false when the piece
+// doesn't fit in any of the sectors, or would take them over maxPieces pieces
+// (0 for no limit)
+func selectSector(strategy PackingStrategy, infos map[abi.SectorNumber]UnsealedSectorInfo, maxPieces int, size abi.PaddedPieceSize) (sid abi.SectorNumber, pads []abi.PaddedPieceSize, ok bool) {
+	candidates := make([]abi.SectorNumber, 0, len(infos))
+	for sn := range infos {
+		candidates = append(candidates, sn)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i] < candidates[j]
+	})
+
+	var bestFree abi.PaddedPieceSize
+	for _, sn := range candidates {
+		ui := infos[sn]
+
+		p, padLength := requiredPadding(ui.stored, size)
+		if ui.stored+padLength+size > ui.size {
+			continue
+		}
+		if maxPieces > 0 && len(ui.pieceSizes)+len(p)+1 > maxPieces {
+			continue
+		}
+
+		if strategy == FirstFit {
+			return sn, p, true
+		}
+
+		if free := ui.size - ui.stored; !ok || free < bestFree {
+			sid, pads, ok = sn, p, true
+			bestFree = free
+		}
+	}
+
+	return sid, pads, ok
+}
+
+// pieceLimitReached tells whether the sector can't take any more pieces
+// because of MaxPiecesPerSector
+func (m *Sealing) pieceLimitReached(ui UnsealedSectorInfo) bool {
+	return m.cfg.MaxPiecesPerSector > 0 && len(ui.pieceSizes) >= m.cfg.MaxPiecesPerSector
+}
+
+// packingDue tells whether MinSectorFillRatio or MaxWaitTime want the sector
+// accepting deals to be packed
+func (m *Sealing) packingDue(ui UnsealedSectorInfo, now time.Time) bool {
+	if m.cfg.MinSectorFillRatio > 0 && float64(ui.stored)/float64(ui.size) >= m.cfg.MinSectorFillRatio {
+		return true
+	}
+
+	return m.cfg.MaxWaitTime > 0 && !ui.firstPiece.IsZero() && now.Sub(ui.firstPiece) >= m.cfg.MaxWaitTime
+}
+
+// sweepUnsealed starts packing sectors accepting deals which are due
+func (m *Sealing) sweepUnsealed(now time.Time) {
+	due := map[abi.SectorNumber]*pieceWrite{}
+
+	m.unsealedLk.Lock()
+	for sid, ui := range m.unsealedInfos {
+		if !m.packingDue(ui, now) {
+			continue
+		}
+
+		last, _ := m.closeSector(sid)
+		due[sid] = last
+	}
+	m.unsealedLk.Unlock()
+
+	for sid, last := range due {
+		log.Infof("sector %d is due for packing", sid)
+		go func(sid abi.SectorNumber, last *pieceWrite) {
+			if err := m.packAfter(sid, last); err != nil {
+				log.Errorf("start packing sector %d: %+v", sid, err)
+			}
+		}(sid, last)
+	}
+}
+
+func (m *Sealing) runPackingSweep(ctx context.Context) {
+	interval := m.cfg.PackingSweepInterval
+	if interval == 0 {
+		interval = defaultPackingSweepInterval
+	}
+
+	for {
+		select {
+		case <-m.clock.After(interval):
+			m.sweepUnsealed(m.clock.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FlushOpenSectors starts packing all sectors accepting deals, which fills
+// their remaining space with filler pieces. Sectors are packed once the
+// pieces being written to them are done; if ctx is done first, the sectors
+// which weren't packed yet are packed in the background. Sectors without
+// pieces are left open
+func (m *Sealing) FlushOpenSectors(ctx context.Context) ([]abi.SectorNumber, error) {
+	flush := map[abi.SectorNumber]*pieceWrite{}
+
+	m.unsealedLk.Lock()
+	for sid, ui := range m.unsealedInfos {
+		if len(ui.pieceSizes) == 0 {
+			continue
+		}
+
+		last, _ := m.closeSector(sid)
+		flush[sid] = last
+	}
+	m.unsealedLk.Unlock()
+
+	type packed struct {
+		sid abi.SectorNumber
+		err error
+	}
+	results := make(chan packed, len(flush))
+	for sid, last := range flush {
+		go func(sid abi.SectorNumber, last *pieceWrite) {
+			results <- packed{sid: sid, err: m.packAfter(sid, last)}
+		}(sid, last)
+	}
+
+	var out []abi.SectorNumber
+	var err error
+wait:
+	for range flush {
+		select {
+		case res := <-results:
+			if res.err != nil {
+				log.Errorf("start packing sector %d: %+v", res.sid, res.err)
+				if err == nil {
+					err = xerrors.Errorf("start packing sector %d: %w", res.sid, res.err)
+				}
+				continue
+			}
+			out = append(out, res.sid)
+		case <-ctx.Done():
+			err = xerrors.Errorf("waiting for pieces being written: %w", ctx.Err())
+			break wait
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i] < out[j]
+	})
+	return out, err
+}
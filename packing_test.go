@@ -0,0 +1,356 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestSelectSector(t *testing.T) {
+	infos := map[abi.SectorNumber]UnsealedSectorInfo{
+		3: {size: 2048, stored: 1024},
+		1: {size: 2048, stored: 256},
+		7: {size: 2048, stored: 1536},
+		5: {size: 2048, stored: 1536},
+		9: {size: 2048, stored: 1792},
+	}
+
+	sid, pads, ok := selectSector(FirstFit, infos, 0, 256)
+	require.True(t, ok)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	require.Empty(t, pads)
+
+	sid, pads, ok = selectSector(BestFit, infos, 0, 256)
+	require.True(t, ok)
+	require.Equal(t, abi.SectorNumber(9), sid)
+	require.Empty(t, pads)
+
+	// sector 9 would need padding first, and the lower numbered of the two
+	// equally full sectors wins
+	for i := 0; i < 10; i++ {
+		sid, pads, ok = selectSector(BestFit, infos, 0, 512)
+		require.True(t, ok)
+		require.Equal(t, abi.SectorNumber(5), sid)
+		require.Empty(t, pads)
+	}
+
+	sid, pads, ok = selectSector(FirstFit, infos, 0, 512)
+	require.True(t, ok)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	require.Equal(t, []abi.PaddedPieceSize{256}, pads)
+
+	_, _, ok = selectSector(BestFit, infos, 0, 2048)
+	require.False(t, ok)
+}
+
+// packUtilization packs a stream of random pieces into 32GiB sectors, and
+// returns the fraction of sealed space taken by pieces, instead of padding and
+// fillers. At most 8 sectors are accepting pieces at once, when another one
+// is needed the oldest one is sealed
+func packUtilization(strategy PackingStrategy, pieces int) float64 {
+	const ss = abi.PaddedPieceSize(32 << 30)
+	const maxOpen = 8
+
+	r := rand.New(rand.NewSource(1))
+
+	infos := map[abi.SectorNumber]UnsealedSectorInfo{}
+	var sectors abi.SectorNumber
+	var data abi.PaddedPieceSize
+
+	for i := 0; i < pieces; i++ {
+		size := abi.PaddedPieceSize(128<<20) << r.Intn(8) // 128MiB - 16GiB
+
+		sid, pads, ok := selectSector(strategy, infos, 0, size)
+		if !ok {
+			if len(infos) == maxOpen {
+				oldest := sectors
+				for sn := range infos {
+					if sn < oldest {
+						oldest = sn
+					}
+				}
+				delete(infos, oldest)
+			}
+
+			sectors++
+			sid = sectors
+			infos[sid] = UnsealedSectorInfo{size: ss}
+		}
+
+		ui := infos[sid]
+		for _, p := range pads {
+			ui.stored += p
+		}
+		ui.stored += size
+		infos[sid] = ui
+
+		if ui.stored == ss {
+			delete(infos, sid)
+		}
+		data += size
+	}
+
+	return float64(data) / float64(abi.PaddedPieceSize(sectors)*ss)
+}
+
+func benchmarkPacking(b *testing.B, strategy PackingStrategy) {
+	var util float64
+	for i := 0; i < b.N; i++ {
+		util = packUtilization(strategy, 10000)
+	}
+	b.ReportMetric(util*100, "%used")
+}
+
+func BenchmarkPackingFirstFit(b *testing.B) {
+	benchmarkPacking(b, FirstFit)
+}
+
+func BenchmarkPackingBestFit(b *testing.B) {
+	benchmarkPacking(b, BestFit)
+}
+
+// waitPacked waits for the sector to be filled up for sealing
+func (h *testHarness) waitPacked(sn abi.SectorNumber) {
+	require.Eventually(h.t, func() bool {
+		var stored abi.PaddedPieceSize
+		for _, p := range h.sector(sn).Pieces {
+			stored += p.Piece.Size
+		}
+		return stored == 2048
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestPackingSweepFillRatio(t *testing.T) {
+	h := newTestHarness(t, Config{MinSectorFillRatio: 0.5, PackingSweepInterval: 5 * time.Millisecond})
+	require.NoError(t, h.m.Run(context.Background()))
+
+	h.addDeal(1, 256)
+	h.waitPieces(1, 1)
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, WaitDeals, h.sector(1).State)
+
+	h.addDeal(2, 512) // 1024 bytes with padding
+	h.waitPacked(1)
+	require.Empty(t, h.m.unsealedInfos)
+
+	// new deals go to a new sector
+	sid, _ := h.addDeal(3, 256)
+	require.Equal(t, abi.SectorNumber(2), sid)
+}
+
+func TestPackingSweepMaxWait(t *testing.T) {
+	h := newTestHarness(t, Config{MaxWaitTime: 100 * time.Millisecond, PackingSweepInterval: 5 * time.Millisecond})
+	require.NoError(t, h.m.Run(context.Background()))
+
+	start := time.Now()
+	h.addDeal(1, 256)
+	h.waitPacked(1)
+	require.True(t, time.Since(start) >= 100*time.Millisecond)
+
+	si := h.sector(1)
+	require.Equal(t, []abi.DealID{1}, si.dealIDs())
+}
+
+func TestUnsealedInfoFirstPiece(t *testing.T) {
+	si := SectorInfo{
+		Pieces: []Piece{{Piece: abi.PieceInfo{Size: 256}}},
+		Log: []Log{
+			{Kind: logKind(SectorStart{}), Timestamp: 100},
+			{Kind: logKind(SectorAddPiece{}), Timestamp: 200},
+			{Kind: logKind(SectorAddPiece{}), Timestamp: 300},
+		},
+	}
+	ui, err := si.unsealedInfo(time.Now())
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(200, 0), ui.firstPiece)
+
+	ui, err = (&SectorInfo{}).unsealedInfo(time.Now())
+	require.NoError(t, err)
+	require.True(t, ui.firstPiece.IsZero())
+}
+
+func TestMaxPiecesPerSector(t *testing.T) {
+	h := newTestHarness(t, Config{MaxPiecesPerSector: 3})
+	blockPreCommit1(h)
+
+	for id := abi.DealID(1); id <= 3; id++ {
+		sid, _ := h.addDeal(id, 128)
+		require.Equal(t, abi.SectorNumber(1), sid, "deal %d", id)
+	}
+
+	// sector 1 has room, but holds 3 pieces already
+	sid, offset := h.addDeal(4, 128)
+	require.Equal(t, abi.SectorNumber(2), sid)
+	require.Equal(t, uint64(0), offset)
+	h.waitPacked(1)
+
+	// the padding before deal 6 counts as well
+	sid, _ = h.addDeal(5, 128)
+	require.Equal(t, abi.SectorNumber(2), sid)
+	sid, _ = h.addDeal(6, 512)
+	require.Equal(t, abi.SectorNumber(3), sid)
+
+	_, err := h.m.AddPieceToSector(context.Background(), 2, abi.PaddedPieceSize(512).Unpadded(), bytes.NewReader(make([]byte, abi.PaddedPieceSize(512).Unpadded())), DealInfo{DealID: 7})
+	require.True(t, xerrors.As(err, new(*ErrSectorFull)), "%+v", err)
+}
+
+func TestFlushOpenSectors(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	for sn, size := range map[abi.SectorNumber]abi.PaddedPieceSize{1: 1024, 2: 512, 3: 0} {
+		si := SectorInfo{State: WaitDeals, SectorNumber: sn, SectorType: abi.RegisteredSealProof_StackedDrg2KiBV1}
+		if size > 0 {
+			id := abi.DealID(sn)
+			h.setZeroDeal(id, size)
+			si.Pieces = []Piece{{
+				Piece:    abi.PieceInfo{Size: size, PieceCID: zerocomm.ZeroPieceCommitment(size.Unpadded())},
+				DealInfo: &DealInfo{DealID: id, DealSchedule: DealSchedule{StartEpoch: 10000, EndEpoch: 20000}},
+			}}
+		}
+		h.put(si)
+	}
+	require.NoError(t, h.m.Run(context.Background()))
+
+	flushed, err := h.m.FlushOpenSectors(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []abi.SectorNumber{1, 2}, flushed)
+
+	h.waitPacked(1)
+	h.waitPacked(2)
+
+	// the empty sector is left accepting deals
+	require.Equal(t, WaitDeals, h.sector(3).State)
+	h.m.unsealedLk.Lock()
+	defer h.m.unsealedLk.Unlock()
+	require.Len(t, h.m.unsealedInfos, 1)
+	require.Contains(t, h.m.unsealedInfos, abi.SectorNumber(3))
+}
+
+func TestFlushOpenSectorsWaitsForWrites(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	written := make(chan struct{})
+	var once sync.Once
+	h.sealer.addPiece = func(ctx context.Context, sector abi.SectorID, size abi.UnpaddedPieceSize) {
+		once.Do(func() { <-written }) // only the deal piece, not the fillers
+	}
+
+	added := make(chan struct{})
+	go func() {
+		h.addDeal(1, 512)
+		close(added)
+	}()
+	require.Eventually(t, func() bool {
+		h.m.unsealedLk.Lock()
+		defer h.m.unsealedLk.Unlock()
+		return len(h.m.unsealedInfos[1].pieceSizes) == 1
+	}, time.Second, time.Millisecond)
+
+	var sectors []abi.SectorNumber
+	flushed := make(chan error, 1)
+	go func() {
+		var err error
+		sectors, err = h.m.FlushOpenSectors(context.Background())
+		flushed <- err
+	}()
+
+	select {
+	case <-flushed:
+		t.Fatal("flushed before the piece was written")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(written)
+	<-added
+	require.NoError(t, <-flushed)
+	require.Equal(t, []abi.SectorNumber{1}, sectors)
+
+	h.waitPacked(1)
+	si := h.sector(1)
+	require.Equal(t, []abi.DealID{1}, si.dealIDs())
+	require.Equal(t, abi.PaddedPieceSize(512), si.Pieces[0].Piece.Size)
+}
+
+// emptySector restarts an empty sector waiting for deals
+func emptySector(h *testHarness) {
+	h.put(SectorInfo{State: WaitDeals, SectorNumber: 1, SectorType: abi.RegisteredSealProof_StackedDrg2KiBV1})
+	require.NoError(h.t, h.m.Run(context.Background()))
+
+	h.m.unsealedLk.Lock()
+	defer h.m.unsealedLk.Unlock()
+	require.Contains(h.t, h.m.unsealedInfos, abi.SectorNumber(1))
+}
+
+func TestStartPackingEmpty(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	emptySector(h)
+
+	// sealed with filler data only
+	require.NoError(t, h.m.StartPacking(1))
+	h.waitPacked(1)
+	for _, p := range h.sector(1).Pieces {
+		require.Nil(t, p.DealInfo)
+	}
+}
+
+func TestStartPackingEmptyRejected(t *testing.T) {
+	h := newTestHarness(t, Config{RejectEmptySectors: true})
+	emptySector(h)
+
+	err := h.m.StartPacking(1)
+	require.True(t, xerrors.As(err, new(*ErrEmptySector)), "%+v", err)
+	require.Equal(t, WaitDeals, h.sector(1).State)
+
+	// the sector still accepts deals, and can be packed once it has some
+	sid, _ := h.addDeal(1, 512)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	h.waitPieces(1, 1)
+	require.NoError(t, h.m.StartPacking(1))
+	h.waitPacked(1)
+}
+
+func TestStartPackingNotFound(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	sid, _ := h.addDeal(1, 512)
+
+	err := h.m.StartPacking(sid + 1)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)), "%+v", err)
+
+	// the open sector isn't affected
+	h.m.unsealedLk.Lock()
+	require.Contains(t, h.m.unsealedInfos, sid)
+	h.m.unsealedLk.Unlock()
+}
+
+func TestSectorAssignmentReproducible(t *testing.T) {
+	sizes := []abi.PaddedPieceSize{1024, 512, 1024, 256, 512, 256, 1024, 512, 256, 128}
+
+	type assignment struct {
+		sid    abi.SectorNumber
+		offset uint64
+	}
+	run := func() []assignment {
+		h := newTestHarness(t, Config{PackingStrategy: BestFit})
+
+		var out []assignment
+		for i, size := range sizes {
+			sid, offset := h.addDeal(abi.DealID(i+1), size)
+			out = append(out, assignment{sid: sid, offset: offset})
+		}
+		return out
+	}
+
+	first := run()
+	for i := 0; i < 5; i++ {
+		require.Equal(t, first, run(), "run %d", i+1)
+	}
+}
@@ -0,0 +1,31 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// PieceAcceptancePolicy decides whether a deal piece may be added to a
+// sector. It is consulted before any data is written
+type PieceAcceptancePolicy interface {
+	// Accept returns an error for pieces which should be rejected, it's
+	// passed on to the AddPiece caller
+	Accept(ctx context.Context, d DealInfo, size abi.UnpaddedPieceSize) error
+}
+
+// AcceptAllPieces is the default PieceAcceptancePolicy, accepting every piece
+type AcceptAllPieces struct{}
+
+func (AcceptAllPieces) Accept(context.Context, DealInfo, abi.UnpaddedPieceSize) error {
+	return nil
+}
+
+func (m *Sealing) acceptPiece(ctx context.Context, d DealInfo, size abi.UnpaddedPieceSize) error {
+	if err := m.acceptance.Accept(ctx, d, size); err != nil {
+		return xerrors.Errorf("piece of deal %d rejected by the acceptance policy: %w", d.DealID, err)
+	}
+	return nil
+}
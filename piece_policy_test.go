@@ -0,0 +1,66 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+var errBlocked = xerrors.New("client blocked")
+
+// blockDeals rejects pieces of the listed deals, and pieces under minSize
+type blockDeals struct {
+	blocked map[abi.DealID]bool
+	minSize abi.UnpaddedPieceSize
+}
+
+func (p blockDeals) Accept(ctx context.Context, d DealInfo, size abi.UnpaddedPieceSize) error {
+	if p.blocked[d.DealID] {
+		return errBlocked
+	}
+	if size < p.minSize {
+		return xerrors.Errorf("piece of %d bytes under the %d byte minimum", size, p.minSize)
+	}
+	return nil
+}
+
+func TestPieceAcceptancePolicy(t *testing.T) {
+	h := newTestHarness(t, Config{PieceAcceptance: blockDeals{
+		blocked: map[abi.DealID]bool{2: true},
+		minSize: 508,
+	}})
+
+	sid, _ := h.addDeal(1, 1024)
+	h.waitPieces(sid, 1)
+
+	h.setZeroDeal(2, 512)
+	_, _, err := h.m.AddPieceToAnySector(context.Background(), 508, bytes.NewReader(make([]byte, 508)), DealInfo{DealID: 2})
+	require.True(t, xerrors.Is(err, errBlocked), "%+v", err)
+
+	_, err = h.m.AddPieceToSector(context.Background(), sid, 508, bytes.NewReader(make([]byte, 508)), DealInfo{DealID: 2})
+	require.True(t, xerrors.Is(err, errBlocked), "%+v", err)
+
+	h.setZeroDeal(3, 256)
+	_, _, err = h.m.AddPieceToAnySector(context.Background(), 254, bytes.NewReader(make([]byte, 254)), DealInfo{DealID: 3})
+	require.Error(t, err)
+
+	// nothing was written for rejected pieces
+	require.Len(t, h.sector(sid).Pieces, 1)
+	sectors, err := h.m.ListSectors()
+	require.NoError(t, err)
+	require.Len(t, sectors, 1)
+}
+
+func TestAcceptAllPieces(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	require.IsType(t, AcceptAllPieces{}, h.m.acceptance)
+
+	sid, _ := h.addDeal(1, 512)
+	h.addDeal(2, 512)
+	h.waitPieces(sid, 2)
+}
@@ -0,0 +1,120 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// UnsealedPieceReader reports what was written to the unsealed copy of a
+// sector. It can be set in Config, or implemented by the SectorManager
+type UnsealedPieceReader interface {
+	// WrittenPieces returns the pieces completely written to the unsealed
+	// sector, in the order they were written, and the number of bytes of an
+	// incomplete piece written after them
+	WrittenPieces(ctx context.Context, sector abi.SectorID) (pieces []abi.PieceInfo, partial abi.PaddedPieceSize, err error)
+	// TruncateUnsealed drops everything past the first size bytes of the
+	// unsealed sector
+	TruncateUnsealed(ctx context.Context, sector abi.SectorID, size abi.PaddedPieceSize) error
+}
+
+func (m *Sealing) unsealedPieces() UnsealedPieceReader {
+	if m.cfg.UnsealedPieces != nil {
+		return m.cfg.UnsealedPieces
+	}
+
+	r, _ := m.sealer.(UnsealedPieceReader)
+	return r
+}
+
+// InterruptedDeals returns the deals whose pieces were being written when the
+// node went down, and couldn't be recovered when it was started again. They
+// aren't in any sector, and have to be added again
+func (m *Sealing) InterruptedDeals() []DealInfo {
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	return append([]DealInfo{}, m.interrupted...)
+}
+
+// reconcilePieces compares the pieces of a sector accepting deals with the
+// pieces written to its unsealed copy, which has more of them if the node went
+// down while a piece was being written.
+//
+// Padding pieces, and the complete piece of the deal recorded as being written,
+// are added to the sector. Anything written after them, a partially written
+// piece or data of an unknown deal, is truncated. A deal whose piece isn't
+// complete is reported by InterruptedDeals
+func (m *Sealing) reconcilePieces(ctx context.Context, sector *SectorInfo) error {
+	r := m.unsealedPieces()
+	if r == nil {
+		return nil
+	}
+	log := m.stateLog(*sector)
+	sid := m.minerSector(sector.SectorNumber)
+
+	written, partial, err := r.WrittenPieces(ctx, sid)
+	if err != nil {
+		return xerrors.Errorf("getting written pieces: %w", err)
+	}
+
+	if len(written) < len(sector.Pieces) {
+		return xerrors.Errorf("%d pieces recorded, only %d written", len(sector.Pieces), len(written))
+	}
+	var size abi.PaddedPieceSize
+	for i, p := range sector.Pieces {
+		if written[i].PieceCID != p.Piece.PieceCID || written[i].Size != p.Piece.Size {
+			return xerrors.Errorf("written piece %d (%s, %d bytes) doesn't match the recorded one (%s, %d bytes)", i, written[i].PieceCID, written[i].Size, p.Piece.PieceCID, p.Piece.Size)
+		}
+		size += p.Piece.Size
+	}
+
+	orphans := written[len(sector.Pieces):]
+	if len(orphans) == 0 && partial == 0 && sector.WritingDeal == nil {
+		return nil
+	}
+
+	var adopted []Piece
+	var dealAdopted bool
+	for i, p := range orphans {
+		// the deal piece is written last, after the padding it needs
+		if sector.WritingDeal != nil && i == len(orphans)-1 && partial == 0 && p.Size == sector.WritingSize.Padded() {
+			adopted = append(adopted, Piece{Piece: p, DealInfo: sector.WritingDeal})
+			dealAdopted = true
+		} else if p.PieceCID == zerocomm.ZeroPieceCommitment(p.Size.Unpadded()) {
+			adopted = append(adopted, Piece{Piece: p})
+		} else {
+			log.Warnf("dropping written piece %s of an unknown deal", p.PieceCID)
+			break
+		}
+		size += p.Size
+	}
+
+	if len(adopted) < len(orphans) || partial > 0 {
+		log.Warnw("truncating unsealed sector", "size", size, "partial", partial)
+		if err := r.TruncateUnsealed(ctx, sid, size); err != nil {
+			return xerrors.Errorf("truncating unsealed sector to %d bytes: %w", size, err)
+		}
+	}
+
+	if sector.WritingDeal != nil && !dealAdopted {
+		log.Warnf("piece of deal %d wasn't completely written, it has to be added again", sector.WritingDeal.DealID)
+		m.unsealedLk.Lock()
+		m.interrupted = append(m.interrupted, *sector.WritingDeal)
+		m.unsealedLk.Unlock()
+	}
+	if len(adopted) > 0 {
+		log.Infof("recovered %d written pieces", len(adopted))
+	}
+
+	evt := SectorPiecesReconciled{Adopted: adopted}
+	if err := m.send(sector.SectorNumber, evt); err != nil {
+		return xerrors.Errorf("recording written pieces: %w", err)
+	}
+	evt.apply(sector)
+
+	return nil
+}
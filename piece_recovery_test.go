@@ -0,0 +1,127 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+type fakeUnsealed struct {
+	lk        sync.Mutex
+	pieces    []abi.PieceInfo
+	partial   abi.PaddedPieceSize
+	truncated *abi.PaddedPieceSize
+}
+
+func (f *fakeUnsealed) WrittenPieces(ctx context.Context, sector abi.SectorID) ([]abi.PieceInfo, abi.PaddedPieceSize, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.pieces, f.partial, nil
+}
+
+func (f *fakeUnsealed) TruncateUnsealed(ctx context.Context, sector abi.SectorID, size abi.PaddedPieceSize) error {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.truncated = &size
+	return nil
+}
+
+func zeroPiece(size abi.PaddedPieceSize) abi.PieceInfo {
+	return abi.PieceInfo{Size: size, PieceCID: zerocomm.ZeroPieceCommitment(size.Unpadded())}
+}
+
+// openSector is a sector accepting deals with one 512 byte deal piece, which
+// was writing a 1024 byte piece of deal 2 when the node went down
+func openSector() SectorInfo {
+	return SectorInfo{
+		State:        WaitDeals,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces: []Piece{{
+			Piece:    abi.PieceInfo{Size: 512, PieceCID: testCommD},
+			DealInfo: &DealInfo{DealID: 1},
+		}},
+		WritingDeal: &DealInfo{DealID: 2},
+		WritingSize: abi.PaddedPieceSize(1024).Unpadded(),
+	}
+}
+
+func TestReconcileOrphanedPiece(t *testing.T) {
+	unsealed := &fakeUnsealed{pieces: []abi.PieceInfo{
+		{Size: 512, PieceCID: testCommD},
+		zeroPiece(512),
+		{Size: 1024, PieceCID: testCommR},
+	}}
+	h := newTestHarness(t, Config{UnsealedPieces: unsealed})
+	h.put(openSector())
+
+	require.NoError(t, h.m.restartSectors(context.Background(), nil))
+
+	h.waitPieces(1, 3)
+	si := h.sector(1)
+	require.Nil(t, si.Pieces[1].DealInfo)
+	require.Equal(t, abi.DealID(2), si.Pieces[2].DealInfo.DealID)
+	require.Equal(t, testCommR, si.Pieces[2].Piece.PieceCID)
+	require.Nil(t, si.WritingDeal)
+
+	require.Nil(t, unsealed.truncated)
+	require.Empty(t, h.m.InterruptedDeals())
+	require.Equal(t, abi.PaddedPieceSize(2048), h.m.unsealedInfos[1].stored)
+}
+
+func TestReconcilePartialPiece(t *testing.T) {
+	unsealed := &fakeUnsealed{
+		pieces: []abi.PieceInfo{
+			{Size: 512, PieceCID: testCommD},
+			zeroPiece(512),
+		},
+		partial: 300,
+	}
+	h := newTestHarness(t, Config{UnsealedPieces: unsealed})
+	h.put(openSector())
+
+	require.NoError(t, h.m.restartSectors(context.Background(), nil))
+
+	h.waitPieces(1, 2)
+	require.Nil(t, h.sector(1).WritingDeal)
+
+	// the padding is kept, the partial deal piece is dropped
+	require.Equal(t, abi.PaddedPieceSize(1024), *unsealed.truncated)
+	require.Equal(t, []DealInfo{{DealID: 2}}, h.m.InterruptedDeals())
+	require.Equal(t, abi.PaddedPieceSize(1024), h.m.unsealedInfos[1].stored)
+}
+
+func TestReconcileUnknownPiece(t *testing.T) {
+	unsealed := &fakeUnsealed{pieces: []abi.PieceInfo{
+		{Size: 512, PieceCID: testCommD},
+		{Size: 512, PieceCID: testCommR},
+	}}
+	h := newTestHarness(t, Config{UnsealedPieces: unsealed})
+	si := openSector()
+	si.WritingDeal = nil
+	si.WritingSize = 0
+	h.put(si)
+
+	require.NoError(t, h.m.restartSectors(context.Background(), nil))
+
+	require.Equal(t, abi.PaddedPieceSize(512), *unsealed.truncated)
+	require.Empty(t, h.m.InterruptedDeals())
+	require.Len(t, h.sector(1).Pieces, 1)
+	require.Equal(t, abi.PaddedPieceSize(512), h.m.unsealedInfos[1].stored)
+}
+
+func TestPieceWritingRecorded(t *testing.T) {
+	h := newTestHarness(t, Config{UnsealedPieces: &fakeUnsealed{}})
+
+	sid, _ := h.addDeal(1, 512)
+	h.waitPieces(sid, 1)
+
+	si := h.sector(sid)
+	require.True(t, hasEvent(si, SectorPieceWriting{}))
+	require.Nil(t, si.WritingDeal)
+}
@@ -0,0 +1,114 @@
+package sealing
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// states of sectors waiting for a message to land on chain
+var msgWaitStates = map[SectorState]struct{}{
+	PreCommitWait: {},
+	CommitWait:    {},
+	MessageStuck:  {},
+	RecoveryWait:  {},
+	TerminateWait: {},
+}
+
+// states of sectors which failed, and are waiting to be retried or for
+// operator action
+var failedStates = map[SectorState]struct{}{
+	FailedUnrecoverable:    {},
+	SealPreCommit1Failed:   {},
+	SealPreCommit2Failed:   {},
+	PreCommitFailed:        {},
+	ComputeProofFailed:     {},
+	BadProof:               {},
+	CommitFailed:           {},
+	PackingFailed:          {},
+	FinalizeFailed:         {},
+	DealsExpired:           {},
+	DataCommitmentMismatch: {},
+	ExpiredPreCommit:       {},
+	UnsealedCorrupt:        {},
+	MessageFailed:          {},
+	RecoveryFailed:         {},
+	TerminateFailed:        {},
+	RemoveFailed:           {},
+	AbortFailed:            {},
+}
+
+// sectorCounts counts sectors by state. It's updated alongside
+// Config.Metrics, so Stats agrees with StateMetrics, except that garbage
+// collected sectors aren't counted anymore
+type sectorCounts struct {
+	lk     sync.Mutex
+	states map[SectorState]int64
+}
+
+func (c *sectorCounts) add(st SectorState, n int64) {
+	if st == UndefinedSectorState {
+		return
+	}
+
+	if c.states == nil {
+		c.states = map[SectorState]int64{}
+	}
+	c.states[st] += n
+	if c.states[st] == 0 {
+		delete(c.states, st)
+	}
+}
+
+func (c *sectorCounts) changed(old, new SectorState) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	c.add(old, -1)
+	c.add(new, 1)
+}
+
+func (c *sectorCounts) loaded(st SectorState) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	c.add(st, 1)
+}
+
+func (c *sectorCounts) removed(st SectorState) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	c.add(st, -1)
+}
+
+// fill sets the sector counts of the stats
+func (c *sectorCounts) fill(stats *SealingStats) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	stats.States = make(map[SectorState]int64, len(c.states))
+	for st, n := range c.states {
+		stats.States[st] = n
+
+		if _, ok := msgWaitStates[st]; ok {
+			stats.AwaitingMessages += n
+		}
+		if _, ok := failedStates[st]; ok {
+			stats.Failed += n
+		}
+	}
+}
+
+// openSectorSpace returns the number of sectors accepting deals, and the space
+// left in them
+func (m *Sealing) openSectorSpace() (int, abi.PaddedPieceSize) {
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	var free abi.PaddedPieceSize
+	for _, ui := range m.unsealedInfos {
+		free += ui.size - ui.stored
+	}
+	return len(m.unsealedInfos), free
+}
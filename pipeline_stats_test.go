@@ -0,0 +1,69 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestPipelineStats(t *testing.T) {
+	sm := NewStateMetrics()
+	h := newTestHarness(t, Config{Metrics: sm})
+
+	// the commit message of sector 12 never lands
+	stuck := h.committingSector(12)
+	stuck.State = CommitWait
+	msg := testCommR
+	stuck.CommitMessage = &msg
+
+	h.put(h.committingSector(10))
+	h.put(SectorInfo{State: FailedUnrecoverable, SectorNumber: 11, SectorType: abi.RegisteredSealProof_StackedDrg2KiBV1})
+	h.put(stuck)
+	require.NoError(t, h.m.restartSectors(context.Background(), nil))
+
+	h.waitState(10, Proving)
+
+	h.addDeal(1, 512)
+	h.waitPieces(1, 1)
+
+	require.Eventually(t, func() bool {
+		return h.m.Stats().States[WaitDeals] == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	stats := h.m.Stats()
+	require.Equal(t, map[SectorState]int64{
+		Proving:             1,
+		FailedUnrecoverable: 1,
+		CommitWait:          1,
+		WaitDeals:           1,
+	}, stats.States)
+	require.Equal(t, int64(1), stats.AwaitingMessages)
+	require.Equal(t, int64(1), stats.Failed)
+	require.Equal(t, 1, stats.OpenSectors)
+	require.Equal(t, abi.PaddedPieceSize(2048-512), stats.OpenFreeSpace)
+
+	// the counts agree with the metrics hook
+	snap := sm.Snapshot()
+	for st, n := range stats.States {
+		require.Equal(t, n, snap.Sectors[st], "state %s", st)
+	}
+}
+
+func TestSectorCounts(t *testing.T) {
+	var c sectorCounts
+	c.changed(UndefinedSectorState, Packing)
+	c.changed(Packing, PreCommit1)
+	c.loaded(CommitFailed)
+	c.loaded(PreCommitWait)
+	c.removed(CommitFailed)
+
+	var stats SealingStats
+	c.fill(&stats)
+	require.Equal(t, map[SectorState]int64{PreCommit1: 1, PreCommitWait: 1}, stats.States)
+	require.Equal(t, int64(1), stats.AwaitingMessages)
+	require.Zero(t, stats.Failed)
+}
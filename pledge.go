@@ -0,0 +1,105 @@
+package sealing
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-statemachine"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+)
+
+const defaultPledgeRecheck = 1 * time.Minute
+
+// enoughPledge checks if the miner has the funds for the sector's initial
+// pledge, short of at most cfg.PledgeShortfallThreshold
+func (m *Sealing) enoughPledge(ctx context.Context, sector SectorInfo, tok TipSetToken) (bool, error) {
+	log := m.stateLog(sector)
+
+	pledge, err := m.api.StateMinerInitialPledgeCollateral(ctx, m.maddr, sector.SectorNumber, tok)
+	if err != nil {
+		return false, xerrors.Errorf("getting initial pledge collateral: %w", err)
+	}
+
+	avail, err := m.api.StateMinerAvailableBalance(ctx, m.maddr, tok)
+	if err != nil {
+		return false, xerrors.Errorf("getting available balance: %w", err)
+	}
+
+	threshold := big.Zero()
+	if m.cfg.PledgeShortfallThreshold.Int != nil {
+		threshold = m.cfg.PledgeShortfallThreshold
+	}
+
+	if shortfall := big.Sub(pledge, avail); shortfall.GreaterThan(threshold) {
+		log.Warnf("sector %d needs %s initial pledge, only %s available", sector.SectorNumber, pledge, avail)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// ErrInsufficientCollateral is returned when Config.CommitCollateralOverride
+// and the available miner balance don't cover the initial pledge of a sector
+type ErrInsufficientCollateral struct{ error }
+
+// commitCollateral returns the value sent with the ProveCommit message of the
+// sector, its initial pledge unless Config.CommitCollateralOverride is set
+func (m *Sealing) commitCollateral(ctx context.Context, sid abi.SectorNumber, tok TipSetToken) (big.Int, error) {
+	pledge, err := m.api.StateMinerInitialPledgeCollateral(ctx, m.maddr, sid, tok)
+	if err != nil {
+		return big.Int{}, xerrors.Errorf("getting initial pledge collateral: %w", err)
+	}
+
+	override := m.cfg.CommitCollateralOverride
+	if override.Int == nil {
+		return pledge, nil
+	}
+	if !override.LessThan(pledge) {
+		return override, nil
+	}
+
+	// the miner actor takes the rest of the pledge from the available balance
+	avail, err := m.api.StateMinerAvailableBalance(ctx, m.maddr, tok)
+	if err != nil {
+		return big.Int{}, xerrors.Errorf("getting available balance: %w", err)
+	}
+	if big.Add(override, avail).LessThan(pledge) {
+		return big.Int{}, &ErrInsufficientCollateral{xerrors.Errorf("sector %d needs %s initial pledge, sending %s with %s available", sid, pledge, override, avail)}
+	}
+
+	return override, nil
+}
+
+func (m *Sealing) handlePledgeInsufficient(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
+	recheck := m.cfg.PledgeRecheckInterval
+	if recheck == 0 {
+		recheck = defaultPledgeRecheck
+	}
+
+	for {
+		select {
+		case <-m.clock.After(recheck):
+		case <-ctx.Context().Done():
+			return ctx.Context().Err()
+		}
+
+		tok, _, err := m.api.ChainHead(ctx.Context())
+		if err != nil {
+			log.Errorf("handlePledgeInsufficient(%d): api error: %+v", sector.SectorNumber, err)
+			continue
+		}
+
+		ok, err := m.enoughPledge(ctx.Context(), sector, tok)
+		if err != nil {
+			log.Errorf("handlePledgeInsufficient(%d): %+v", sector.SectorNumber, err)
+		}
+		if ok {
+			return ctx.Send(SectorPledgeAvailable{})
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+)
+
+func precommittingSector(h *testHarness) SectorInfo {
+	h.api.setHead(10)
+
+	commD, commR := testCommD, testCommR
+	return SectorInfo{
+		State:        PreCommitting,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces:       []Piece{{Piece: abi.PieceInfo{Size: 2048, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(2048).Unpadded())}}},
+		TicketValue:  abi.SealRandomness(testRand),
+		TicketEpoch:  5,
+		CommD:        &commD,
+		CommR:        &commR,
+	}
+}
+
+func (f *fakeAPI) setBalance(pledge, balance int64) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.pledge, f.balance = big.NewInt(pledge), big.NewInt(balance)
+}
+
+func TestPledgeSufficient(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.setBalance(10, 10)
+
+	h.start(precommittingSector(h))
+	h.waitState(1, WaitSeed)
+
+	msgs := h.api.sentMsgs()
+	require.Len(t, msgs, 1)
+	require.Equal(t, builtin.MethodsMiner.PreCommitSector, msgs[0].method)
+}
+
+func TestPledgeInsufficient(t *testing.T) {
+	h := newTestHarness(t, Config{PledgeRecheckInterval: 5 * time.Millisecond})
+	h.api.setBalance(10, 5)
+
+	h.start(precommittingSector(h))
+	h.waitState(1, PledgeInsufficient)
+
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, PledgeInsufficient, h.sector(1).State)
+	require.Empty(t, h.api.sentMsgs())
+
+	// topped up
+	h.api.setBalance(10, 10)
+	h.waitState(1, WaitSeed)
+	require.Len(t, h.api.sentMsgs(), 1)
+}
+
+func TestPledgeShortfallThreshold(t *testing.T) {
+	h := newTestHarness(t, Config{PledgeShortfallThreshold: big.NewInt(5)})
+	h.api.setBalance(10, 5)
+
+	h.start(precommittingSector(h))
+	h.waitState(1, WaitSeed)
+	require.Len(t, h.api.sentMsgs(), 1)
+}
+
+// commitValue returns the value sent with the ProveCommit message
+func commitValue(t *testing.T, h *testHarness) big.Int {
+	for _, msg := range h.api.sentMsgs() {
+		if msg.method == builtin.MethodsMiner.ProveCommitSector {
+			return msg.value
+		}
+	}
+	t.Fatal("no ProveCommit message sent")
+	return big.Int{}
+}
+
+func TestCommitCollateral(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.setBalance(100, 0)
+
+	h.start(h.committingSector(1))
+	h.waitState(1, Proving)
+	require.Equal(t, big.NewInt(100), commitValue(t, h))
+}
+
+func TestCommitCollateralOverride(t *testing.T) {
+	h := newTestHarness(t, Config{CommitCollateralOverride: big.Zero()})
+	h.api.setBalance(100, 100)
+
+	h.start(h.committingSector(1))
+	h.waitState(1, Proving)
+	require.Equal(t, big.Zero(), commitValue(t, h))
+}
+
+func TestCommitCollateralOverrideShort(t *testing.T) {
+	h := newTestHarness(t, Config{CommitCollateralOverride: big.NewInt(30)})
+	ctx := context.Background()
+
+	// the available balance covers the rest
+	h.api.setBalance(100, 70)
+	value, err := h.m.commitCollateral(ctx, 1, nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(30), value)
+
+	h.api.setBalance(100, 69)
+	_, err = h.m.commitCollateral(ctx, 1, nil)
+	require.True(t, xerrors.As(err, new(*ErrInsufficientCollateral)), "%+v", err)
+}
@@ -3,10 +3,17 @@ package sealing
 import (
 	"context"
 
+	"golang.org/x/xerrors"
+
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 )
 
+// MaxSectorLifetime bounds how far in the future sector expirations are set
+const MaxSectorLifetime = abi.ChainEpoch(540 * builtin.EpochsInDay)
+
 type PreCommitPolicy interface {
 	Expiration(ctx context.Context, ps ...Piece) (abi.ChainEpoch, error)
 }
@@ -15,6 +22,16 @@ type Chain interface {
 	ChainHead(ctx context.Context) (TipSetToken, abi.ChainEpoch, error)
 }
 
+// DealChain is a Chain which can also look up deal proposals
+type DealChain interface {
+	Chain
+	StateMarketStorageDeal(context.Context, abi.DealID, TipSetToken) (market.DealProposal, error)
+}
+
+// ErrDealsTooLong is returned when deals in a sector end past MaxSectorLifetime,
+// no expiration of the sector can cover them
+type ErrDealsTooLong struct{ error }
+
 // BasicPreCommitPolicy satisfies PreCommitPolicy. It has two modes:
 //
 // Mode 1: The sector contains a non-zero quantity of pieces with deal info
@@ -25,15 +42,24 @@ type Chain interface {
 // the first or second mode.
 //
 // If we're in Mode 1: The pre-commit expiration epoch will be the maximum
-// deal end epoch of a piece in the sector.
+// deal end epoch of a piece in the sector, plus the deal buffer. Deal end
+// epochs are read from chain if the policy was created with
+// NewDealPreCommitPolicy, and taken from the deal schedules otherwise. Deals
+// which already ended are rejected with ErrExpiredDeals, deals ending past
+// MaxSectorLifetime with ErrDealsTooLong.
 //
 // If we're in Mode 2: The pre-commit expiration epoch will be set to the
 // current epoch + the provided default duration.
+//
+// In both modes the expiration is then moved to the next proving period
+// boundary, and kept within MaxSectorLifetime from the current epoch.
 type BasicPreCommitPolicy struct {
-	api Chain
+	api   Chain
+	deals DealChain // nil if deal end epochs are taken from the deal schedules
 
 	provingBoundary abi.ChainEpoch
 	duration        abi.ChainEpoch
+	dealBuffer      abi.ChainEpoch
 }
 
 // NewBasicPreCommitPolicy produces a BasicPreCommitPolicy
@@ -45,38 +71,76 @@ func NewBasicPreCommitPolicy(api Chain, duration abi.ChainEpoch, provingBoundary
 	}
 }
 
+// NewDealPreCommitPolicy produces a BasicPreCommitPolicy which reads deal end
+// epochs from chain, and keeps sectors with deals for dealBuffer epochs past
+// the end of their deals
+func NewDealPreCommitPolicy(api DealChain, duration abi.ChainEpoch, provingBoundary abi.ChainEpoch, dealBuffer abi.ChainEpoch) BasicPreCommitPolicy {
+	p := NewBasicPreCommitPolicy(api, duration, provingBoundary)
+	p.deals = api
+	p.dealBuffer = dealBuffer
+	return p
+}
+
 // Expiration produces the pre-commit sector expiration epoch for an encoded
 // replica containing the provided enumeration of pieces and deals.
 func (p *BasicPreCommitPolicy) Expiration(ctx context.Context, ps ...Piece) (abi.ChainEpoch, error) {
-	_, epoch, err := p.api.ChainHead(ctx)
+	tok, epoch, err := p.api.ChainHead(ctx)
 	if err != nil {
-		return 0, nil
+		return 0, &ErrApi{xerrors.Errorf("getting chain head: %w", err)}
 	}
 
-	var end *abi.ChainEpoch
+	var dealEnd *abi.ChainEpoch
 
-	for _, p := range ps {
-		if p.DealInfo == nil {
+	for _, piece := range ps {
+		if piece.DealInfo == nil {
 			continue
 		}
 
-		if p.DealInfo.DealSchedule.EndEpoch < epoch {
-			log.Warnf("piece schedule %+v ended before current epoch %d", p, epoch)
-			continue
+		pieceEnd := piece.DealInfo.DealSchedule.EndEpoch
+		if p.deals != nil {
+			proposal, err := p.deals.StateMarketStorageDeal(ctx, piece.DealInfo.DealID, tok)
+			if err != nil {
+				return 0, &ErrApi{xerrors.Errorf("getting deal %d: %w", piece.DealInfo.DealID, err)}
+			}
+			pieceEnd = proposal.EndEpoch
 		}
 
-		if end == nil || *end < p.DealInfo.DealSchedule.EndEpoch {
-			tmp := p.DealInfo.DealSchedule.EndEpoch
-			end = &tmp
+		if pieceEnd <= epoch {
+			return 0, &ErrExpiredDeals{xerrors.Errorf("deal %d ended at epoch %d, before current epoch %d", piece.DealInfo.DealID, pieceEnd, epoch)}
+		}
+
+		if dealEnd == nil || *dealEnd < pieceEnd {
+			tmp := pieceEnd
+			dealEnd = &tmp
 		}
 	}
 
-	if end == nil {
-		tmp := epoch + p.duration
-		end = &tmp
+	limit := epoch + MaxSectorLifetime
+
+	var end abi.ChainEpoch
+	if dealEnd != nil {
+		if *dealEnd > limit {
+			return 0, &ErrDealsTooLong{xerrors.Errorf("deals end at epoch %d, past the maximum sector lifetime (epoch %d)", *dealEnd, limit)}
+		}
+
+		end = *dealEnd + p.dealBuffer
+	} else {
+		end = epoch + p.duration
 	}
+	if end > limit {
+		end = limit
+	}
+
+	end += miner.WPoStProvingPeriod - (end % miner.WPoStProvingPeriod) + p.provingBoundary - 1
 
-	*end += miner.WPoStProvingPeriod - (*end % miner.WPoStProvingPeriod) + p.provingBoundary - 1
+	// step back a proving period if that doesn't cut into the deals
+	if end > limit && (dealEnd == nil || end-miner.WPoStProvingPeriod >= *dealEnd) {
+		end -= miner.WPoStProvingPeriod
+	}
+	// off the boundary, but the actor rejects expirations past the lifetime
+	if end > limit {
+		end = limit
+	}
 
-	return *end, nil
+	return end, nil
 }
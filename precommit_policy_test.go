@@ -6,25 +6,52 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
 
 	commcid "github.com/filecoin-project/go-fil-commcid"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 
 	sealing "github.com/filecoin-project/storage-fsm"
 )
 
 type fakeChain struct {
-	h abi.ChainEpoch
+	h     abi.ChainEpoch
+	deals map[abi.DealID]abi.ChainEpoch // deal end epochs
 }
 
 func (f *fakeChain) ChainHead(ctx context.Context) (sealing.TipSetToken, abi.ChainEpoch, error) {
 	return []byte{1, 2, 3}, f.h, nil
 }
 
+func (f *fakeChain) StateMarketStorageDeal(ctx context.Context, id abi.DealID, tok sealing.TipSetToken) (market.DealProposal, error) {
+	end, ok := f.deals[id]
+	if !ok {
+		return market.DealProposal{}, xerrors.Errorf("deal %d not found", id)
+	}
+	return market.DealProposal{EndEpoch: end}, nil
+}
+
+func dealPiece(id abi.DealID, start, end abi.ChainEpoch) sealing.Piece {
+	return sealing.Piece{
+		Piece: abi.PieceInfo{
+			Size:     abi.PaddedPieceSize(1024),
+			PieceCID: commcid.ReplicaCommitmentV1ToCID([]byte{1, 2, 3}),
+		},
+		DealInfo: &sealing.DealInfo{
+			DealID: id,
+			DealSchedule: sealing.DealSchedule{
+				StartEpoch: start,
+				EndEpoch:   end,
+			},
+		},
+	}
+}
+
 func TestBasicPolicyEmptySector(t *testing.T) {
-	policy := sealing.NewBasicPreCommitPolicy(&fakeChain{
+	policy := sealing.NewDealPreCommitPolicy(&fakeChain{
 		h: abi.ChainEpoch(55),
-	}, 10, 0)
+	}, 10, 0, 1000)
 
 	exp, err := policy.Expiration(context.Background())
 	require.NoError(t, err)
@@ -33,37 +60,14 @@ func TestBasicPolicyEmptySector(t *testing.T) {
 }
 
 func TestBasicPolicyMostConstrictiveSchedule(t *testing.T) {
-	policy := sealing.NewBasicPreCommitPolicy(&fakeChain{
-		h: abi.ChainEpoch(55),
-	}, 100, 11)
+	policy := sealing.NewDealPreCommitPolicy(&fakeChain{
+		h:     abi.ChainEpoch(55),
+		deals: map[abi.DealID]abi.ChainEpoch{42: 75, 43: 100},
+	}, 100, 11, 0)
 
 	pieces := []sealing.Piece{
-		{
-			Piece: abi.PieceInfo{
-				Size:     abi.PaddedPieceSize(1024),
-				PieceCID: commcid.ReplicaCommitmentV1ToCID([]byte{1, 2, 3}),
-			},
-			DealInfo: &sealing.DealInfo{
-				DealID: abi.DealID(42),
-				DealSchedule: sealing.DealSchedule{
-					StartEpoch: abi.ChainEpoch(70),
-					EndEpoch:   abi.ChainEpoch(75),
-				},
-			},
-		},
-		{
-			Piece: abi.PieceInfo{
-				Size:     abi.PaddedPieceSize(1024),
-				PieceCID: commcid.ReplicaCommitmentV1ToCID([]byte{1, 2, 3}),
-			},
-			DealInfo: &sealing.DealInfo{
-				DealID: abi.DealID(43),
-				DealSchedule: sealing.DealSchedule{
-					StartEpoch: abi.ChainEpoch(80),
-					EndEpoch:   abi.ChainEpoch(100),
-				},
-			},
-		},
+		dealPiece(42, 70, 75),
+		dealPiece(43, 80, 100),
 	}
 
 	exp, err := policy.Expiration(context.Background(), pieces...)
@@ -72,59 +76,105 @@ func TestBasicPolicyMostConstrictiveSchedule(t *testing.T) {
 	assert.Equal(t, 3466, int(exp))
 }
 
-func TestBasicPolicyIgnoresExistingScheduleIfExpired(t *testing.T) {
-	policy := sealing.NewBasicPreCommitPolicy(&fakeChain{
+func TestBasicPolicyDealBuffer(t *testing.T) {
+	policy := sealing.NewDealPreCommitPolicy(&fakeChain{
 		h: abi.ChainEpoch(55),
-	}, 100, 0)
+		// on-chain proposals are authoritative over the recorded schedules
+		deals: map[abi.DealID]abi.ChainEpoch{42: 3000, 43: 4000, 44: 100},
+	}, 100, 11, 1000)
 
 	pieces := []sealing.Piece{
+		dealPiece(42, 70, 75),
+		dealPiece(43, 80, 100),
+		dealPiece(44, 80, 5000),
+	}
+
+	exp, err := policy.Expiration(context.Background(), pieces...)
+	require.NoError(t, err)
+
+	// 4000 + 1000, moved to the proving period boundary
+	assert.Equal(t, 2*3456+10, int(exp))
+}
+
+func TestBasicPolicyRejectsExpiredDeals(t *testing.T) {
+	policy := sealing.NewDealPreCommitPolicy(&fakeChain{
+		h:     abi.ChainEpoch(55),
+		deals: map[abi.DealID]abi.ChainEpoch{43: 1000, 44: 10},
+	}, 100, 0, 0)
+
+	pieces := []sealing.Piece{
+		dealPiece(43, 1, 1000),
+		dealPiece(44, 1, 10),
+	}
+
+	_, err := policy.Expiration(context.Background(), pieces...)
+	require.True(t, xerrors.As(err, new(*sealing.ErrExpiredDeals)), "%+v", err)
+}
+
+func TestMissingDealIsIgnored(t *testing.T) {
+	policy := sealing.NewDealPreCommitPolicy(&fakeChain{
+		h:     abi.ChainEpoch(55),
+		deals: map[abi.DealID]abi.ChainEpoch{44: 1000},
+	}, 100, 11, 0)
+
+	pieces := []sealing.Piece{
+		dealPiece(44, 1, 1000),
 		{
 			Piece: abi.PieceInfo{
 				Size:     abi.PaddedPieceSize(1024),
 				PieceCID: commcid.ReplicaCommitmentV1ToCID([]byte{1, 2, 3}),
 			},
-			DealInfo: &sealing.DealInfo{
-				DealID: abi.DealID(44),
-				DealSchedule: sealing.DealSchedule{
-					StartEpoch: abi.ChainEpoch(1),
-					EndEpoch:   abi.ChainEpoch(10),
-				},
-			},
+			DealInfo: nil,
 		},
 	}
 
 	exp, err := policy.Expiration(context.Background(), pieces...)
 	require.NoError(t, err)
 
-	assert.Equal(t, 3455, int(exp))
+	assert.Equal(t, 3466, int(exp))
 }
 
-func TestMissingDealIsIgnored(t *testing.T) {
+func TestBasicPolicyMaxLifetime(t *testing.T) {
+	chain := &fakeChain{
+		h:     abi.ChainEpoch(55),
+		deals: map[abi.DealID]abi.ChainEpoch{1: 1000},
+	}
+
+	// the buffer is cut down to the max lifetime
+	policy := sealing.NewDealPreCommitPolicy(chain, 100, 10, 2*sealing.MaxSectorLifetime)
+	exp, err := policy.Expiration(context.Background(), dealPiece(1, 70, 1000))
+	require.NoError(t, err)
+	assert.Equal(t, int(sealing.MaxSectorLifetime)+9, int(exp))
+
+	// so is the CC duration
+	policy = sealing.NewDealPreCommitPolicy(chain, 2*sealing.MaxSectorLifetime, 10, 0)
+	exp, err = policy.Expiration(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int(sealing.MaxSectorLifetime)+9, int(exp))
+
+	// deals can't outlive it
+	chain.deals[2] = 56 + sealing.MaxSectorLifetime
+	_, err = policy.Expiration(context.Background(), dealPiece(2, 70, 1000))
+	require.True(t, xerrors.As(err, new(*sealing.ErrDealsTooLong)), "%+v", err)
+
+	// the proving boundary past the lifetime can't be stepped back from
+	// without cutting into the deal
+	chain.deals[3] = 55 + sealing.MaxSectorLifetime
+	policy = sealing.NewDealPreCommitPolicy(chain, 100, 10, 0)
+	exp, err = policy.Expiration(context.Background(), dealPiece(3, 70, 1000))
+	require.NoError(t, err)
+	assert.Equal(t, int(55+sealing.MaxSectorLifetime), int(exp))
+}
+
+func TestBasicPolicyDealSchedules(t *testing.T) {
+	// without deal lookups the recorded schedules are used
 	policy := sealing.NewBasicPreCommitPolicy(&fakeChain{
 		h: abi.ChainEpoch(55),
 	}, 100, 11)
 
 	pieces := []sealing.Piece{
-		{
-			Piece: abi.PieceInfo{
-				Size:     abi.PaddedPieceSize(1024),
-				PieceCID: commcid.ReplicaCommitmentV1ToCID([]byte{1, 2, 3}),
-			},
-			DealInfo: &sealing.DealInfo{
-				DealID: abi.DealID(44),
-				DealSchedule: sealing.DealSchedule{
-					StartEpoch: abi.ChainEpoch(1),
-					EndEpoch:   abi.ChainEpoch(10),
-				},
-			},
-		},
-		{
-			Piece: abi.PieceInfo{
-				Size:     abi.PaddedPieceSize(1024),
-				PieceCID: commcid.ReplicaCommitmentV1ToCID([]byte{1, 2, 3}),
-			},
-			DealInfo: nil,
-		},
+		dealPiece(42, 70, 75),
+		dealPiece(43, 80, 100),
 	}
 
 	exp, err := policy.Expiration(context.Background(), pieces...)
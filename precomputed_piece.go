@@ -0,0 +1,60 @@
+package sealing
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// PrecomputedPieceSealer can be implemented by SectorManagers which can write
+// a piece without computing its piece commitment, see AddPrecomputedPiece
+type PrecomputedPieceSealer interface {
+	AddPieceWithCID(ctx context.Context, sector abi.SectorID, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data, pieceCID cid.Cid) (abi.PieceInfo, error)
+}
+
+// ErrPieceCIDMismatch is returned when the piece commitment computed by the
+// sealer doesn't match the one passed to AddPrecomputedPiece
+type ErrPieceCIDMismatch struct{ error }
+
+// AddPrecomputedPiece is AddPieceToAnySector for pieces whose piece
+// commitment the caller computed already. Unless Config.TrustPrecomputedPieces
+// is set, the sealer still computes the commitment, and the piece is rejected
+// with ErrPieceCIDMismatch when it doesn't match pieceCID
+func (m *Sealing) AddPrecomputedPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, pieceCID cid.Cid, d DealInfo) (abi.SectorNumber, uint64, error) {
+	return m.addPieceToAnySector(ctx, size, r, d, &pieceCID)
+}
+
+// sealerAddPrecomputed writes a piece with a precomputed piece commitment,
+// skipping the sealer commitment computation if it's trusted and the sealer
+// can do that
+func (m *Sealing) sealerAddPrecomputed(ctx context.Context, sector abi.SectorID, existing []abi.UnpaddedPieceSize, size abi.UnpaddedPieceSize, r io.Reader, pieceCID cid.Cid) (abi.PieceInfo, error) {
+	if ps, ok := m.sealer.(PrecomputedPieceSealer); ok && m.cfg.TrustPrecomputedPieces {
+		if err := m.apLimit.acquire(ctx); err != nil {
+			return abi.PieceInfo{}, xerrors.Errorf("waiting for an AddPiece slot: %w", err)
+		}
+		defer m.apLimit.release()
+
+		done, err := m.beginIntent(sector.Number, IntentAddPiece)
+		if err != nil {
+			return abi.PieceInfo{}, err
+		}
+		defer done()
+
+		return ps.AddPieceWithCID(ctx, sector, existing, size, r, pieceCID)
+	}
+
+	ppi, err := m.sealerAddPiece(ctx, sector, existing, size, r)
+	if err != nil {
+		return abi.PieceInfo{}, err
+	}
+	if ppi.PieceCID != pieceCID {
+		return abi.PieceInfo{}, &ErrPieceCIDMismatch{xerrors.Errorf("piece data has commitment %s, precomputed commitment is %s", ppi.PieceCID, pieceCID)}
+	}
+
+	return ppi, nil
+}
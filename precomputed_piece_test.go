@@ -0,0 +1,74 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+type precomputedSealer struct {
+	*fakeSealer
+
+	lk    sync.Mutex
+	calls int
+}
+
+func (s *precomputedSealer) AddPieceWithCID(ctx context.Context, sector abi.SectorID, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data, pieceCID cid.Cid) (abi.PieceInfo, error) {
+	s.lk.Lock()
+	s.calls++
+	s.lk.Unlock()
+	return abi.PieceInfo{Size: newPieceSize.Padded(), PieceCID: pieceCID}, nil
+}
+
+func (h *testHarness) addPrecomputed(id abi.DealID, size abi.PaddedPieceSize, pieceCID cid.Cid) (abi.SectorNumber, error) {
+	h.setZeroDeal(id, size)
+	sid, _, err := h.m.AddPrecomputedPiece(context.Background(), size.Unpadded(), bytes.NewReader(make([]byte, size.Unpadded())), pieceCID, DealInfo{DealID: id})
+	return sid, err
+}
+
+func TestAddPrecomputedPiece(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	sid, err := h.addPrecomputed(1, 1024, zerocomm.ZeroPieceCommitment(1016))
+	require.NoError(t, err)
+	h.waitPieces(sid, 1)
+	require.Equal(t, zerocomm.ZeroPieceCommitment(1016), h.sector(sid).Pieces[0].Piece.PieceCID)
+}
+
+func TestAddPrecomputedPieceMismatch(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	_, err := h.addPrecomputed(1, 1024, testCommR)
+	require.True(t, xerrors.As(err, new(*ErrPieceCIDMismatch)), "%+v", err)
+	require.True(t, xerrors.Is(err, ErrAddPieceFailed), "%+v", err)
+	si := h.sector(1)
+	require.Empty(t, si.dealIDs())
+}
+
+func TestAddPrecomputedPieceTrusted(t *testing.T) {
+	h := newTestHarness(t, Config{TrustPrecomputedPieces: true})
+	ps := &precomputedSealer{fakeSealer: h.sealer}
+	h.m.sealer = ps
+
+	var computed int
+	h.sealer.addPiece = func(ctx context.Context, sector abi.SectorID, size abi.UnpaddedPieceSize) {
+		computed++
+	}
+
+	// the sealer doesn't look at the data
+	sid, err := h.addPrecomputed(1, 1024, testCommD)
+	require.NoError(t, err)
+	h.waitPieces(sid, 1)
+	require.Equal(t, testCommD, h.sector(sid).Pieces[0].Piece.PieceCID)
+	require.Equal(t, 1, ps.calls)
+	require.Zero(t, computed)
+}
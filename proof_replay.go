@@ -0,0 +1,47 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// ErrNoProof is returned by VerifySectorProof for sectors which don't have a
+// commit proof yet
+type ErrNoProof struct{ error }
+
+// VerifySectorProof checks the commit proof stored with the sector against
+// the sealed and unsealed CIDs, ticket and seed stored with it, without
+// reading anything from the chain or the sealer. false means the stored
+// proof doesn't prove the stored sector
+func (m *Sealing) VerifySectorProof(ctx context.Context, sid abi.SectorNumber) (bool, error) {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case si.CommR == nil || si.CommD == nil:
+		return false, &ErrNoProof{xerrors.Errorf("sector %d (%s) wasn't sealed yet", sid, si.State)}
+	case len(si.SeedValue) == 0:
+		return false, &ErrNoProof{xerrors.Errorf("sector %d (%s) has no commit seed yet", sid, si.State)}
+	case len(si.Proof) == 0:
+		return false, &ErrNoProof{xerrors.Errorf("sector %d (%s) has no commit proof yet", sid, si.State)}
+	}
+
+	ok, err := m.verif.VerifySeal(abi.SealVerifyInfo{
+		SectorID:              m.minerSector(si.SectorNumber),
+		SealedCID:             *si.CommR,
+		SealProof:             si.SectorType,
+		Proof:                 si.Proof,
+		Randomness:            si.TicketValue,
+		InteractiveRandomness: si.SeedValue,
+		UnsealedCID:           *si.CommD,
+	})
+	if err != nil {
+		return false, xerrors.Errorf("verify seal: %w", err)
+	}
+	return ok, nil
+}
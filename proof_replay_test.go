@@ -0,0 +1,93 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// proofVerifier accepts only the given proof, and records what it was asked
+// to verify
+type proofVerifier struct {
+	fakeVerifier
+
+	proof []byte
+
+	lk   sync.Mutex
+	last abi.SealVerifyInfo
+}
+
+func (v *proofVerifier) VerifySeal(info abi.SealVerifyInfo) (bool, error) {
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	v.last = info
+	return bytes.Equal(info.Proof, v.proof), nil
+}
+
+func provenSector(proof []byte) SectorInfo {
+	commD, commR := testCommD, testCommR
+	return SectorInfo{
+		State:        Proving,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		CommD:        &commD,
+		CommR:        &commR,
+		TicketValue:  abi.SealRandomness{1},
+		SeedValue:    abi.InteractiveSealRandomness{2},
+		Proof:        proof,
+	}
+}
+
+func TestVerifySectorProof(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	v := &proofVerifier{proof: []byte{1, 2, 3}}
+	h.m.verif = v
+	h.put(provenSector([]byte{1, 2, 3}))
+
+	ok, err := h.m.VerifySectorProof(context.Background(), 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	require.Equal(t, abi.SealVerifyInfo{
+		SectorID:              abi.SectorID{Miner: 1000, Number: 1},
+		SealedCID:             testCommR,
+		SealProof:             abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Proof:                 []byte{1, 2, 3},
+		Randomness:            abi.SealRandomness{1},
+		InteractiveRandomness: abi.InteractiveSealRandomness{2},
+		UnsealedCID:           testCommD,
+	}, v.last)
+}
+
+func TestVerifySectorProofTampered(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.m.verif = &proofVerifier{proof: []byte{1, 2, 3}}
+	h.put(provenSector([]byte{1, 2, 4}))
+
+	ok, err := h.m.VerifySectorProof(context.Background(), 1)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestVerifySectorProofMissing(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	si := provenSector(nil)
+	si.State = WaitSeed
+	si.SeedValue = nil
+	h.put(si)
+
+	_, err := h.m.VerifySectorProof(context.Background(), 1)
+	require.True(t, xerrors.As(err, new(*ErrNoProof)), "%+v", err)
+
+	_, err = h.m.VerifySectorProof(context.Background(), 2)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)), "%+v", err)
+}
@@ -0,0 +1,94 @@
+package sealing
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+)
+
+// randomness at epochs this far behind the head can't change anymore
+const randCacheFinality = miner.ChainFinalityish
+
+type randKey struct {
+	personalization crypto.DomainSeparationTag
+	epoch           abi.ChainEpoch
+	entropy         string
+}
+
+type randEntry struct {
+	key  randKey
+	rand abi.Randomness
+}
+
+// randCacheAPI caches ChainGetRandomness results of final epochs, evicting the
+// least recently used ones. The head height is taken from ChainHead calls
+// going through it, until one was made nothing is cached
+type randCacheAPI struct {
+	SealingAPI
+	size int
+
+	lk      sync.Mutex
+	head    abi.ChainEpoch
+	entries map[randKey]*list.Element
+	lru     *list.List
+}
+
+func newRandCacheAPI(api SealingAPI, size int) *randCacheAPI {
+	return &randCacheAPI{
+		SealingAPI: api,
+		size:       size,
+		entries:    map[randKey]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+func (a *randCacheAPI) ChainHead(ctx context.Context) (TipSetToken, abi.ChainEpoch, error) {
+	tok, h, err := a.SealingAPI.ChainHead(ctx)
+	if err == nil {
+		a.lk.Lock()
+		if h > a.head {
+			a.head = h
+		}
+		a.lk.Unlock()
+	}
+	return tok, h, err
+}
+
+func (a *randCacheAPI) ChainGetRandomness(ctx context.Context, tok TipSetToken, personalization crypto.DomainSeparationTag, randEpoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error) {
+	key := randKey{personalization: personalization, epoch: randEpoch, entropy: string(entropy)}
+
+	a.lk.Lock()
+	final := a.head > 0 && randEpoch <= a.head-randCacheFinality
+	if e, ok := a.entries[key]; ok && final {
+		a.lru.MoveToFront(e)
+		out := e.Value.(*randEntry).rand
+		a.lk.Unlock()
+		return out, nil
+	}
+	a.lk.Unlock()
+
+	out, err := a.SealingAPI.ChainGetRandomness(ctx, tok, personalization, randEpoch, entropy)
+	if err != nil || !final {
+		return out, err
+	}
+
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	if _, ok := a.entries[key]; !ok {
+		a.entries[key] = a.lru.PushFront(&randEntry{key: key, rand: out})
+		if a.lru.Len() > a.size {
+			oldest := a.lru.Back()
+			a.lru.Remove(oldest)
+			delete(a.entries, oldest.Value.(*randEntry).key)
+		}
+	}
+
+	return out, nil
+}
+
+var _ SealingAPI = &randCacheAPI{}
@@ -0,0 +1,58 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+)
+
+func TestRandomnessCache(t *testing.T) {
+	h := newTestHarness(t, Config{RandomnessCacheSize: 2})
+	ctx := context.Background()
+
+	calls := func() int {
+		h.api.lk.Lock()
+		defer h.api.lk.Unlock()
+		return h.api.randCalls
+	}
+	get := func(epoch abi.ChainEpoch, entropy string) {
+		r, err := h.m.api.ChainGetRandomness(ctx, nil, crypto.DomainSeparationTag_SealRandomness, epoch, []byte(entropy))
+		require.NoError(t, err)
+		require.Equal(t, abi.Randomness(testRand), r)
+	}
+
+	// head not known yet
+	get(10, "a")
+	get(10, "a")
+	require.Equal(t, 2, calls())
+
+	h.api.setHead(2000)
+	_, _, err := h.m.api.ChainHead(ctx)
+	require.NoError(t, err)
+
+	get(10, "a")
+	get(10, "a")
+	require.Equal(t, 3, calls())
+
+	// different entropy is a different entry
+	get(10, "b")
+	require.Equal(t, 4, calls())
+
+	// near the head nothing is cached
+	get(2000-randCacheFinality+1, "a")
+	get(2000-randCacheFinality+1, "a")
+	require.Equal(t, 6, calls())
+
+	// evicts the least recently used entry
+	get(10, "a")
+	get(20, "a")
+	require.Equal(t, 7, calls())
+	get(10, "a")
+	require.Equal(t, 7, calls())
+	get(10, "b")
+	require.Equal(t, 8, calls())
+}
@@ -0,0 +1,177 @@
+package sealing
+
+import (
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// ErrNoEstimate is returned by EstimateSealCompletion for sectors which aren't
+// on their way to being sealed, like failed, faulty or removed ones
+type ErrNoEstimate struct{ error }
+
+// PhaseEstimates are the expected durations of sealing phases, in epochs.
+// Phases not set use defaultPhaseEstimates
+type PhaseEstimates struct {
+	AddPiece      abi.ChainEpoch
+	PreCommit1    abi.ChainEpoch
+	PreCommit2    abi.ChainEpoch
+	PreCommitWait abi.ChainEpoch // sending the precommit, and waiting for it to land
+	WaitSeed      abi.ChainEpoch
+	Commit        abi.ChainEpoch // computing the commit proof
+	CommitWait    abi.ChainEpoch
+
+	// FromStats replaces the estimates with phase averages of recently
+	// finished sectors, see Stats, for phases there are averages of
+	FromStats bool
+}
+
+var defaultPhaseEstimates = PhaseEstimates{
+	AddPiece:      builtin.EpochsInHour / 2,
+	PreCommit1:    6 * builtin.EpochsInHour,
+	PreCommit2:    builtin.EpochsInHour,
+	PreCommitWait: 10,
+	WaitSeed:      miner.PreCommitChallengeDelay + InteractivePoRepConfidence,
+	Commit:        builtin.EpochsInHour,
+	CommitWait:    10,
+}
+
+const (
+	phaseAddPiece = iota
+	phasePreCommit1
+	phasePreCommit2
+	phasePreCommitWait
+	phaseWaitSeed
+	phaseCommit
+	phaseCommitWait
+
+	phaseCount
+)
+
+func (e PhaseEstimates) phases() [phaseCount]abi.ChainEpoch {
+	return [phaseCount]abi.ChainEpoch{
+		phaseAddPiece:      e.AddPiece,
+		phasePreCommit1:    e.PreCommit1,
+		phasePreCommit2:    e.PreCommit2,
+		phasePreCommitWait: e.PreCommitWait,
+		phaseWaitSeed:      e.WaitSeed,
+		phaseCommit:        e.Commit,
+		phaseCommitWait:    e.CommitWait,
+	}
+}
+
+// Stats phases making up each estimated phase
+var phaseStats = [phaseCount][]SectorState{
+	phaseAddPiece:      {Packing},
+	phasePreCommit1:    {PreCommit1},
+	phasePreCommit2:    {PreCommit2},
+	phasePreCommitWait: {PreCommitting, PreCommitWait},
+	phaseWaitSeed:      {WaitSeed},
+	phaseCommit:        {Committing},
+	phaseCommitWait:    {CommitWait},
+}
+
+type estimateStart struct {
+	phase int
+	// the state belongs to the phase, time spent in it counts towards it
+	inPhase bool
+}
+
+// statePhases maps sealing states to the first phase still ahead of sectors
+// in them
+var statePhases = map[SectorState]estimateStart{
+	Empty:              {phaseAddPiece, false},
+	WaitDeals:          {phaseAddPiece, true},
+	Packing:            {phaseAddPiece, true},
+	PreCommit1:         {phasePreCommit1, true},
+	PreCommit2:         {phasePreCommit2, true},
+	PreCommitting:      {phasePreCommitWait, true},
+	PledgeInsufficient: {phasePreCommitWait, false},
+	PreCommitWait:      {phasePreCommitWait, true},
+	CommitHold:         {phaseWaitSeed, false},
+	WaitSeed:           {phaseWaitSeed, true},
+	Committing:         {phaseCommit, true},
+	CommitWait:         {phaseCommitWait, true},
+	MessageStuck:       {phaseCommitWait, false},
+}
+
+// phaseEstimates returns the configured estimates, with defaults and stats
+// applied
+func (m *Sealing) phaseEstimates() [phaseCount]abi.ChainEpoch {
+	cfg := m.cfg.PhaseEstimates
+	out := cfg.phases()
+	for i, e := range defaultPhaseEstimates.phases() {
+		if out[i] == 0 {
+			out[i] = e
+		}
+	}
+
+	if !cfg.FromStats {
+		return out
+	}
+
+	stats := m.Stats()
+	if stats.Sectors == 0 {
+		return out
+	}
+	for i, states := range phaseStats {
+		var total time.Duration
+		var found bool
+		for _, st := range states {
+			if d, ok := stats.Phases[st]; ok {
+				total += d
+				found = true
+			}
+		}
+		if found {
+			out[i] = durationEpochs(total)
+		}
+	}
+
+	return out
+}
+
+// durationEpochs rounds d up to whole epochs
+func durationEpochs(d time.Duration) abi.ChainEpoch {
+	epoch := builtin.EpochDurationSeconds * time.Second
+	return abi.ChainEpoch((d + epoch - 1) / epoch)
+}
+
+// EstimateSealCompletion estimates how many epochs it takes the sector to be
+// sealed and its commit to land on chain, from its current state and
+// PhaseEstimates. 0 for sectors which are already committed
+func (m *Sealing) EstimateSealCompletion(sid abi.SectorNumber) (epochsRemaining abi.ChainEpoch, err error) {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return 0, err
+	}
+
+	if si.State == FinalizeSector || si.State == Proving {
+		return 0, nil
+	}
+
+	start, ok := statePhases[si.State]
+	if !ok {
+		return 0, &ErrNoEstimate{xerrors.Errorf("sector %d in state %s isn't being sealed", sid, si.State)}
+	}
+
+	phases := m.phaseEstimates()
+	for _, e := range phases[start.phase:] {
+		epochsRemaining += e
+	}
+
+	if start.inPhase && len(si.Log) > 0 {
+		since := time.Unix(int64(si.Log[len(si.Log)-1].Timestamp), 0)
+		elapsed := abi.ChainEpoch(m.clock.Now().Sub(since) / (builtin.EpochDurationSeconds * time.Second))
+		if cur := phases[start.phase]; elapsed > cur {
+			elapsed = cur
+		}
+		epochsRemaining -= elapsed
+	}
+
+	return epochsRemaining, nil
+}
@@ -0,0 +1,68 @@
+package sealing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestEstimateSealCompletion(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk})
+
+	ago := func(d time.Duration) []Log {
+		return []Log{{Timestamp: uint64(clk.Now().Add(-d).Unix())}}
+	}
+
+	h.put(SectorInfo{SectorNumber: 1, State: WaitDeals})
+	h.put(SectorInfo{SectorNumber: 2, State: PreCommit2, Log: ago(30 * time.Minute)})
+	h.put(SectorInfo{SectorNumber: 3, State: PreCommit2, Log: ago(48 * time.Hour)})
+	h.put(SectorInfo{SectorNumber: 4, State: CommitHold, Log: ago(time.Hour)})
+	h.put(SectorInfo{SectorNumber: 5, State: Proving})
+	h.put(SectorInfo{SectorNumber: 6, State: PreCommitFailed})
+	h.put(SectorInfo{SectorNumber: 7, State: Removed})
+
+	for sid, expect := range map[abi.SectorNumber]abi.ChainEpoch{
+		1: 72 + 864 + 144 + 10 + 16 + 144 + 10,
+		2: 144 - 72 + 10 + 16 + 144 + 10, // half an hour into PreCommit2
+		3: 10 + 16 + 144 + 10,            // PreCommit2 over its estimate
+		4: 16 + 144 + 10,                 // time in CommitHold doesn't count
+		5: 0,
+	} {
+		est, err := h.m.EstimateSealCompletion(sid)
+		require.NoError(t, err, "sector %d", sid)
+		require.Equal(t, expect, est, "sector %d", sid)
+	}
+
+	for _, sid := range []abi.SectorNumber{6, 7} {
+		_, err := h.m.EstimateSealCompletion(sid)
+		require.True(t, xerrors.As(err, new(*ErrNoEstimate)), "sector %d: %+v", sid, err)
+	}
+
+	_, err := h.m.EstimateSealCompletion(8)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)), "%+v", err)
+}
+
+func TestEstimateSealCompletionConfig(t *testing.T) {
+	h := newTestHarness(t, Config{PhaseEstimates: PhaseEstimates{Commit: 20}})
+	h.put(SectorInfo{SectorNumber: 1, State: Committing})
+
+	est, err := h.m.EstimateSealCompletion(1)
+	require.NoError(t, err)
+	require.Equal(t, abi.ChainEpoch(20+10), est)
+
+	// stats aren't used unless enabled
+	h.m.stats = SealingStats{Sectors: 3, Phases: map[SectorState]time.Duration{Committing: 10 * time.Minute}}
+	est, err = h.m.EstimateSealCompletion(1)
+	require.NoError(t, err)
+	require.Equal(t, abi.ChainEpoch(20+10), est)
+
+	h.m.cfg.PhaseEstimates.FromStats = true
+	est, err = h.m.EstimateSealCompletion(1)
+	require.NoError(t, err)
+	require.Equal(t, abi.ChainEpoch(24+10), est) // 600s are 24 epochs
+}
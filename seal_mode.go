@@ -0,0 +1,63 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// SealMode selects how the commit proof of a sector is generated. It's
+// recorded when the sector is created, so sectors keep the mode they were
+// started with when Config.SealMode changes
+type SealMode string
+
+const (
+	SealModeClassic SealMode = "classic"
+	// SealModeSynthetic generates the commit proof from synthetic challenges,
+	// see SyntheticSealer
+	SealModeSynthetic SealMode = "synthetic"
+)
+
+// SyntheticSealer is implemented by sealers able to generate commit proofs
+// in SealModeSynthetic
+type SyntheticSealer interface {
+	SealCommit1Synthetic(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (storage.Commit1Out, error)
+}
+
+func (m *Sealing) sealMode() SealMode {
+	if m.cfg.SealMode == "" {
+		return SealModeClassic
+	}
+	return m.cfg.SealMode
+}
+
+// sealMode of sectors created before modes were recorded is SealModeClassic
+func (t *SectorInfo) sealMode() SealMode {
+	if t.SealMode == "" {
+		return SealModeClassic
+	}
+	return t.SealMode
+}
+
+// sealCommit1 runs the first commit phase in the mode of the sector
+func (m *Sealing) sealCommit1(ctx context.Context, sector SectorInfo, cids storage.SectorCids) (storage.Commit1Out, error) {
+	sctx, done := m.jobCtx(ctx, sector)
+	defer done()
+	sid := m.minerSector(sector.SectorNumber)
+
+	switch mode := sector.sealMode(); mode {
+	case SealModeClassic:
+		return m.sealer.SealCommit1(sctx, sid, sector.TicketValue, sector.SeedValue, sector.pieceInfos(), cids)
+	case SealModeSynthetic:
+		ss, ok := m.sealer.(SyntheticSealer)
+		if !ok {
+			return nil, xerrors.Errorf("sector %d is sealed in %s mode, which the sealer doesn't support", sector.SectorNumber, mode)
+		}
+		return ss.SealCommit1Synthetic(sctx, sid, sector.TicketValue, sector.SeedValue, sector.pieceInfos(), cids)
+	default:
+		return nil, xerrors.Errorf("sector %d has unknown seal mode %q", sector.SectorNumber, mode)
+	}
+}
@@ -0,0 +1,79 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+type synthSealer struct {
+	*fakeSealer
+	commit1 func(sector abi.SectorID)
+}
+
+func (s *synthSealer) SealCommit1Synthetic(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (storage.Commit1Out, error) {
+	s.commit1(sector)
+	return storage.Commit1Out{}, nil
+}
+
+func TestSealModeRecorded(t *testing.T) {
+	for _, mode := range []SealMode{"", SealModeClassic, SealModeSynthetic} {
+		h := newTestHarness(t, Config{SealMode: mode})
+		h.addDeal(1, 1024)
+
+		expect := mode
+		if expect == "" {
+			expect = SealModeClassic
+		}
+		require.Eventually(t, func() bool {
+			return h.sector(1).SealMode == expect
+		}, time.Second, time.Millisecond, "mode %q", mode)
+	}
+}
+
+func TestSealModeCommit(t *testing.T) {
+	for _, mode := range []SealMode{"", SealModeClassic, SealModeSynthetic} {
+		h := newTestHarness(t, Config{})
+
+		classic, synthetic := make(chan abi.SectorID, 1), make(chan abi.SectorID, 1)
+		h.sealer.commit1 = func(sector abi.SectorID) { classic <- sector }
+		sealer := &synthSealer{fakeSealer: h.sealer, commit1: func(sector abi.SectorID) { synthetic <- sector }}
+
+		// the mode of the sector counts, not the one configured
+		pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+		h.m = NewWithConfig(h.api, h.api, h.m.maddr, h.ds, sealer, &fakeCounter{}, fakeVerifier{}, &pcp, Config{})
+
+		si := h.committingSector(1)
+		si.SealMode = mode
+		h.start(si)
+
+		select {
+		case <-classic:
+			require.NotEqual(t, SealModeSynthetic, mode)
+		case <-synthetic:
+			require.Equal(t, SealModeSynthetic, mode)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no commit proof computed in mode %q", mode)
+		}
+	}
+}
+
+func TestSealModeUnsupported(t *testing.T) {
+	h := newTestHarness(t, Config{SealMode: SealModeSynthetic})
+	h.sealer.commit1 = func(sector abi.SectorID) {
+		t.Error("synthetic sector sealed in classic mode")
+	}
+
+	si := h.committingSector(1)
+	si.SealMode = SealModeSynthetic
+	h.start(si)
+
+	require.Eventually(t, func() bool {
+		return hasEvent(h.sector(1), SectorComputeProofFailed{})
+	}, 5*time.Second, 5*time.Millisecond)
+}
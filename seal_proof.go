@@ -0,0 +1,29 @@
+package sealing
+
+import (
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// sealProofType returns the seal proof new sectors of the size are created
+// with, Config.SealProofType for sectors of its size. The proof type of a
+// sector is recorded when it's created, changing the config doesn't affect
+// existing sectors
+func (m *Sealing) sealProofType(ss abi.SectorSize) (abi.RegisteredSealProof, error) {
+	if spt := m.cfg.SealProofType; spt != nil {
+		pss, err := spt.SectorSize()
+		if err != nil {
+			return 0, xerrors.Errorf("bad seal proof type override: %w", err)
+		}
+		if sss := m.sealer.SectorSize(); pss != sss {
+			return 0, xerrors.Errorf("seal proof type override %d is for sectors of %d bytes, the sealer sector size is %d", *spt, pss, sss)
+		}
+		if pss == ss {
+			return *spt, nil
+		}
+	}
+
+	return ffiwrapper.SealProofTypeFromSectorSize(ss)
+}
@@ -0,0 +1,53 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestSealProofTypeDefault(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	spt, err := h.m.sealProofType(2048)
+	require.NoError(t, err)
+	require.Equal(t, abi.RegisteredSealProof_StackedDrg2KiBV1, spt)
+
+	sid, _ := h.addDeal(1, 1024)
+	h.waitPieces(sid, 1)
+	require.Equal(t, abi.RegisteredSealProof_StackedDrg2KiBV1, h.sector(sid).SectorType)
+}
+
+func TestSealProofTypeOverride(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1
+	h := newTestHarness(t, Config{SealProofType: &spt})
+
+	got, err := h.m.sealProofType(2048)
+	require.NoError(t, err)
+	require.Equal(t, spt, got)
+
+	sid, _ := h.addDeal(1, 1024)
+	h.waitPieces(sid, 1)
+	require.Equal(t, spt, h.sector(sid).SectorType)
+
+	// the sector keeps its proof type when the override changes
+	bad := abi.RegisteredSealProof_StackedDrg8MiBV1
+	h.m.cfg.SealProofType = &bad
+	require.Equal(t, spt, h.sector(sid).SectorType)
+}
+
+func TestSealProofTypeOverrideSizeMismatch(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg8MiBV1
+	h := newTestHarness(t, Config{SealProofType: &spt})
+
+	_, err := h.m.sealProofType(2048)
+	require.Error(t, err)
+
+	h.setZeroDeal(1, 1024)
+	_, _, err = h.m.AddPieceToAnySector(context.Background(), 1016, bytes.NewReader(make([]byte, 1016)), DealInfo{DealID: 1})
+	require.Error(t, err)
+}
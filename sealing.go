@@ -3,15 +3,19 @@ package sealing
 import (
 	"context"
 	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
 	logging "github.com/ipfs/go-log/v2"
+	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
-	padreader "github.com/filecoin-project/go-padreader"
 	statemachine "github.com/filecoin-project/go-statemachine"
 	sectorstorage "github.com/filecoin-project/sector-storage"
 	"github.com/filecoin-project/sector-storage/ffiwrapper"
@@ -24,6 +28,21 @@ import (
 
 const SectorStorePrefix = "/sectors"
 
+// datastoreRoot returns the namespace all sealing records are kept under
+func datastoreRoot(cfg Config, maddr address.Address) datastore.Key {
+	root := datastore.NewKey(cfg.DatastorePrefix)
+	if cfg.DatastorePerMiner {
+		root = root.ChildString(maddr.String())
+	}
+	return root
+}
+
+// datastoreKeys returns the namespaces of sector records and timings
+func datastoreKeys(cfg Config, maddr address.Address) (sectors datastore.Key, timings datastore.Key) {
+	root := datastoreRoot(cfg, maddr)
+	return root.Child(datastore.NewKey(SectorStorePrefix)), root.Child(datastore.NewKey(SectorTimingsPrefix))
+}
+
 var log = logging.Logger("sectors")
 
 type SealingAPI interface {
@@ -35,111 +54,1109 @@ type SealingAPI interface {
 	StateMinerWorkerAddress(ctx context.Context, maddr address.Address, tok TipSetToken) (address.Address, error)
 	StateMinerDeadlines(ctx context.Context, maddr address.Address, tok TipSetToken) (*miner.Deadlines, error)
 	StateMinerInitialPledgeCollateral(context.Context, address.Address, abi.SectorNumber, TipSetToken) (big.Int, error)
+	StateMinerAvailableBalance(context.Context, address.Address, TipSetToken) (big.Int, error)
 	StateMarketStorageDeal(context.Context, abi.DealID, TipSetToken) (market.DealProposal, error)
+	EstimateMessageGas(ctx context.Context, from, to address.Address, method abi.MethodNum, value big.Int, params []byte) (gasPrice big.Int, gasLimit int64, err error)
 	SendMsg(ctx context.Context, from, to address.Address, method abi.MethodNum, value, gasPrice big.Int, gasLimit int64, params []byte) (cid.Cid, error)
 	ChainHead(ctx context.Context) (TipSetToken, abi.ChainEpoch, error)
 	ChainGetRandomness(ctx context.Context, tok TipSetToken, personalization crypto.DomainSeparationTag, randEpoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error)
 	ChainReadObj(context.Context, cid.Cid) ([]byte, error)
 }
 
+// UnsealedSectorInfo tracks space used in a sector which still accepts deals
+type UnsealedSectorInfo struct {
+	size abi.PaddedPieceSize // of the sector
+
+	// includes pieces with reserved space which are still being written
+	stored     abi.PaddedPieceSize
+	pieceSizes []abi.UnpaddedPieceSize
+
+	lastWrite *pieceWrite // nil when no writes are in flight
+
+	firstPiece time.Time // when space for the first piece was reserved
+
+	verified bool // the sector accepts verified deals, see Config.UnverifiedDealSectors
+}
+
 type Sealing struct {
-	api    SealingAPI
-	events Events
+	api        SealingAPI
+	events     Events
+	dealStates SealingAPIDealStates // the API passed to New, if it reports deal states
+	faults     SealingAPIFaults     // the API passed to New, if it reports miner faults
 
 	maddr address.Address
 
 	sealer  sectorstorage.SectorManager
+	ds      datastore.Batching // sector state records, namespaced under SectorStorePrefix, see Config.DatastorePrefix
+	writes  *batchedDatastore  // ds, if Config.StateWriteBatchInterval is set
 	sectors *statemachine.StateGroup
-	sc      SectorIDCounter
+	sc      ReservingSectorIDCounter
 	verif   ffiwrapper.Verifier
 
 	pcp PreCommitPolicy
+
+	cfg Config
+
+	reconciler ReconcilingSectorIDCounter // the counter passed to New, if it can skip numbers in use
+	peeker     PeekingSectorIDCounter     // the counter passed to New, if it can tell its next number
+
+	p1Limit *phaseLimiter
+	p2Limit *phaseLimiter
+	c2Limit *phaseLimiter
+	apLimit *phaseLimiter
+	jobs    sectorJobs // sealer calls in flight, cancelled by Abort
+	breaker *chainBreaker
+	snLimit *sectorLimiter     // nil if sector creation isn't rate limited
+	batch   SealingAPIBatch    // the API passed to New, if it implements batching
+	logger  *zap.SugaredLogger // sector loggers are derived from it, see sectorLog
+
+	timings datastore.Batching // phase timings of finished sectors, under SectorTimingsPrefix
+	journal datastore.Batching // events sent to sectors, under SectorJournalPrefix; nil unless Config.JournalEvents is set
+	intents datastore.Batching // sealer operations in flight, under SectorIntentPrefix; nil unless Config.SealerIntents is set
+	statsLk sync.Mutex
+	stats   SealingStats
+
+	stopMaintenance context.CancelFunc
+
+	shutdownLk   sync.Mutex
+	shuttingDown bool           // set by Stop, new pieces are rejected
+	addPieces    sync.WaitGroup // AddPiece calls in flight, waited for by Stop
+
+	alertLk              sync.Mutex
+	commitDeadlineAlerts map[abi.SectorNumber]CommitDeadlineAlert
+
+	metrics    SealingMetrics
+	counts     sectorCounts // sectors by state, for Stats
+	acceptance PieceAcceptancePolicy
+
+	subs      subscribers
+	pieces    pieceIndex
+	reopeners dealReopeners // see DealInfo.Reopen
+	held      heldSectors   // paused sectors which didn't run their state handler
+	clock     Clock
+
+	entropy EntropyBuilder // of tickets and seeds, see Config.Entropy
+
+	unsealedLk      sync.Mutex
+	unsealedInfos   map[abi.SectorNumber]UnsealedSectorInfo
+	interrupted     []DealInfo    // deals whose pieces were lost on restart, see InterruptedDeals
+	unsealedClosed  chan struct{} // closed when a sector stops accepting pieces, nil if nobody waits
+	packingStrategy PackingStrategy
+
+	allocated map[abi.SectorNumber][]*pieceReservation // reserved by AllocatePiece, written by SealPiece, under unsealedLk
+	creating  map[sectorCreation]chan struct{}         // sectors accepting deals being created, closed when done, under unsealedLk
+
+	// computes piece commitments when checking unsealed data
+	commP func(spt abi.RegisteredSealProof, piece io.Reader, size abi.UnpaddedPieceSize) (cid.Cid, error)
 }
 
 func New(api SealingAPI, events Events, maddr address.Address, ds datastore.Batching, sealer sectorstorage.SectorManager, sc SectorIDCounter, verif ffiwrapper.Verifier, pcp PreCommitPolicy) *Sealing {
+	return NewWithConfig(api, events, maddr, ds, sealer, sc, verif, pcp, Config{})
+}
+
+func NewWithConfig(api SealingAPI, events Events, maddr address.Address, ds datastore.Batching, sealer sectorstorage.SectorManager, sc SectorIDCounter, verif ffiwrapper.Verifier, pcp PreCommitPolicy, cfg Config) *Sealing {
 	s := &Sealing{
 		api:    api,
 		events: events,
 
 		maddr:  maddr,
 		sealer: sealer,
-		sc:     sc,
+		sc:     newReservingCounter(sc),
 		verif:  verif,
 		pcp:    pcp,
+
+		cfg: cfg,
+
+		p1Limit: newPhaseLimiter(cfg.MaxConcurrentPreCommit1),
+		p2Limit: newPhaseLimiter(cfg.MaxConcurrentPreCommit2),
+		c2Limit: newPhaseLimiter(cfg.MaxConcurrentCommit2),
+		apLimit: newPhaseLimiter(cfg.MaxConcurrentAddPiece),
+
+		unsealedInfos:   map[abi.SectorNumber]UnsealedSectorInfo{},
+		allocated:       map[abi.SectorNumber][]*pieceReservation{},
+		creating:        map[sectorCreation]chan struct{}{},
+		packingStrategy: cfg.PackingStrategy,
+
+		commP:  ffiwrapper.GeneratePieceCIDFromFile,
+		logger: &log.SugaredLogger,
+	}
+
+	s.metrics = cfg.Metrics
+	if s.metrics == nil {
+		s.metrics = noopMetrics{}
+	}
+
+	s.acceptance = cfg.PieceAcceptance
+	if s.acceptance == nil {
+		s.acceptance = AcceptAllPieces{}
+	}
+
+	s.clock = cfg.Clock
+	if s.clock == nil {
+		s.clock = realClock{}
+	}
+	if sm, ok := s.metrics.(*StateMetrics); ok {
+		sm.setClock(s.clock)
+	}
+
+	s.entropy = cfg.Entropy
+	if s.entropy == nil {
+		s.entropy = FilecoinEntropy{}
+	}
+
+	s.batch, _ = api.(SealingAPIBatch)
+	s.dealStates, _ = api.(SealingAPIDealStates)
+	s.faults, _ = api.(SealingAPIFaults)
+	s.reconciler, _ = sc.(ReconcilingSectorIDCounter)
+	s.peeker, _ = sc.(PeekingSectorIDCounter)
+
+	if cfg.ChainFailureThreshold > 0 {
+		backoff := cfg.ChainBackoff
+		if backoff == 0 {
+			backoff = defaultChainBackoff
+		}
+
+		s.breaker = newChainBreaker(cfg.ChainFailureThreshold, backoff, s.clock)
+		s.api = &breakerAPI{api: api, b: s.breaker}
+	}
+
+	if cfg.RandomnessCacheSize > 0 {
+		s.api = newRandCacheAPI(s.api, cfg.RandomnessCacheSize)
+	}
+
+	if cfg.SectorsPerMinute > 0 {
+		s.snLimit = newSectorLimiter(cfg.SectorsPerMinute, cfg.SectorBurst, s.clock)
+	}
+
+	commDCache := cfg.DataCommitmentCache
+	if commDCache == nil {
+		commDCache = NewDataCommitmentCache(defaultCommDCacheSize)
+	}
+	s.api = &commDCacheAPI{SealingAPI: s.api, cache: commDCache}
+
+	if cfg.MessageRateLimit > 0 {
+		interval := cfg.MessageRateInterval
+		if interval == 0 {
+			interval = defaultMessageRateInterval
+		}
+
+		s.api = &limitedAPI{SealingAPI: s.api, l: newMsgLimiter(cfg.MessageRateLimit, interval, s.clock)}
 	}
 
-	s.sectors = statemachine.New(namespace.Wrap(ds, datastore.NewKey(SectorStorePrefix)), s, SectorInfo{})
+	sectorsKey, timingsKey := datastoreKeys(cfg, maddr)
+	s.ds = namespace.Wrap(ds, sectorsKey)
+	if cfg.StateWriteBatchInterval > 0 {
+		s.writes = newBatchedDatastore(s.ds, cfg.StateWriteBatchInterval, s.clock)
+		s.ds = s.writes
+	}
+	s.sectors = statemachine.New(s.ds, s, SectorInfo{})
+	s.timings = namespace.Wrap(ds, timingsKey)
+	if cfg.JournalEvents {
+		s.journal = namespace.Wrap(ds, datastoreRoot(cfg, maddr).Child(datastore.NewKey(SectorJournalPrefix)))
+	}
+	if cfg.SealerIntents {
+		s.intents = namespace.Wrap(ds, datastoreRoot(cfg, maddr).Child(datastore.NewKey(SectorIntentPrefix)))
+	}
 
 	return s
 }
 
 func (m *Sealing) Run(ctx context.Context) error {
-	if err := m.restartSectors(ctx); err != nil {
+	report, err := m.CheckSectorStorage(ctx)
+	if err != nil {
+		log.Errorf("checking sector storage: %+v", err)
+	}
+	for _, ms := range report.Missing {
+		log.Errorf("sector %d (%s) can't find its %s file(s) with the current storage configuration, not restarting it", ms.SectorNumber, ms.State, strings.Join(ms.Files, ", "))
+	}
+
+	if err := m.reconcileSectorCounter(); err != nil {
+		return xerrors.Errorf("reconciling sector counter: %w", err)
+	}
+
+	if err := m.restartSectors(ctx, report.missing()); err != nil {
 		log.Errorf("%+v", err)
 		return xerrors.Errorf("failed load sector states: %w", err)
 	}
 
+	mctx, cancel := context.WithCancel(context.Background())
+	m.stopMaintenance = cancel
+	go m.runMaintenance(mctx)
+	if m.cfg.MinSectorFillRatio > 0 || m.cfg.MaxWaitTime > 0 {
+		go m.runPackingSweep(mctx)
+	}
+
 	return nil
 }
 
+// Stop rejects new pieces, waits for pieces which are being written, and stops
+// the sector state machines. If ctx is done first, the state machines are
+// stopped anyway. Calls after the first one do nothing
 func (m *Sealing) Stop(ctx context.Context) error {
-	return m.sectors.Stop(ctx)
+	m.shutdownLk.Lock()
+	stopping := m.shuttingDown
+	m.shuttingDown = true
+	m.shutdownLk.Unlock()
+
+	// the state group can't be stopped twice, even if stopping it timed out
+	if stopping {
+		return nil
+	}
+
+	if m.stopMaintenance != nil {
+		m.stopMaintenance()
+	}
+
+	written := make(chan struct{})
+	go func() {
+		m.addPieces.Wait()
+		close(written)
+	}()
+
+	var werr error
+	select {
+	case <-written:
+	case <-ctx.Done():
+		werr = xerrors.Errorf("waiting for pieces being written: %w", ctx.Err())
+		log.Warnf("stopping with pieces still being written: %+v", werr)
+	}
+
+	if err := m.sectors.Stop(ctx); err != nil {
+		return err
+	}
+
+	if m.writes != nil {
+		if err := m.writes.flush(); err != nil {
+			return xerrors.Errorf("writing sector records: %w", err)
+		}
+	}
+
+	return werr
 }
 
-func (m *Sealing) AllocatePiece(size abi.UnpaddedPieceSize) (sectorID abi.SectorNumber, offset uint64, err error) {
-	if (padreader.PaddedSize(uint64(size))) != size {
-		return 0, 0, xerrors.Errorf("cannot allocate unpadded piece")
+// CommitSector lets a sector held in CommitHold proceed to commit
+func (m *Sealing) CommitSector(sid abi.SectorNumber) error {
+	return m.sendChecked(sid, SectorCommitTrigger{})
+}
+
+// Errors returned by AddPieceToAnySector, matched with xerrors.Is
+var (
+	// ErrPieceNotPadded is matched by *ErrInvalidPieceSize
+	ErrPieceNotPadded = xerrors.New("piece size is not a valid unpadded piece size")
+	// ErrPieceTooLarge means the piece is bigger than a sector
+	ErrPieceTooLarge = xerrors.New("piece cannot fit into a sector")
+	// ErrSectorAllocFailed means no sector had room, and creating a new one
+	// failed
+	ErrSectorAllocFailed = xerrors.New("failed to allocate a sector")
+	// ErrAddPieceFailed means writing the piece to the sector failed
+	ErrAddPieceFailed = xerrors.New("failed to write the piece")
+)
+
+// addPieceError matches the sentinel error of the failed step, and keeps the
+// underlying error unwrappable
+type addPieceError struct {
+	kind error
+	err  error
+}
+
+func (e *addPieceError) Error() string        { return e.err.Error() }
+func (e *addPieceError) Unwrap() error        { return e.err }
+func (e *addPieceError) Is(target error) bool { return target == e.kind }
+
+// AddPieceToAnySector writes the piece to a sector which still accepts deals,
+// and has room for it. A new sector is created when none does. Returns the
+// sector number and the (padded) offset of the piece in the sector
+func (m *Sealing) AddPieceToAnySector(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d DealInfo) (abi.SectorNumber, uint64, error) {
+	sid, offset, err := m.addPieceToAnySector(ctx, size, r, d, nil)
+	if err == nil {
+		m.reopeners.add(d)
+	}
+	return sid, offset, err
+}
+
+func (m *Sealing) addPieceToAnySector(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d DealInfo, pieceCID *cid.Cid) (abi.SectorNumber, uint64, error) {
+	if err := m.acceptPiece(ctx, d, size); err != nil {
+		return 0, 0, err
+	}
+	if err := checkPieceSize(size); err != nil {
+		return 0, 0, err
+	}
+	if ss := m.maxSectorSize(); size > abi.PaddedPieceSize(ss).Unpadded() {
+		return 0, 0, xerrors.Errorf("piece of %d bytes, sector size %d: %w", size.Padded(), ss, ErrPieceTooLarge)
+	}
+	if err := m.checkDealStart(ctx, d); err != nil {
+		return 0, 0, err
+	}
+	if err := m.waitSealerCapacity(ctx); err != nil {
+		return 0, 0, err
 	}
 
-	sid, err := m.sc.Next()
+	done, err := m.startAddPiece()
 	if err != nil {
-		return 0, 0, xerrors.Errorf("getting sector number: %w", err)
+		return 0, 0, err
+	}
+	defer done()
+
+	ctx = sectorstorage.WithPriority(ctx, m.dealPriority())
+
+	local := m.localSectors(ctx)
+
+	m.unsealedLk.Lock()
+	sid, pads, err := m.getAvailableSector(ctx, size, d.VerifiedDeal, local)
+	if err != nil {
+		m.unsealedLk.Unlock()
+		return 0, 0, &addPieceError{ErrSectorAllocFailed, xerrors.Errorf("getting available sector: %w", err)}
+	}
+	res := m.reserve(sid, pads, size)
+	res.pieceCID = pieceCID
+	m.unsealedLk.Unlock()
+
+	m.sectorLog(sid).Infof("Adding piece for deal %d", d.DealID)
+
+	offset, err := m.writePiece(ctx, res, r, d)
+	if err != nil {
+		return 0, 0, &addPieceError{ErrAddPieceFailed, err}
+	}
+
+	return sid, offset, nil
+}
+
+// AllocatePiece reserves space for a piece of the given size in a sector which
+// still accepts deals, creating one if none has room, and returns the sector
+// number and the (padded) offset of the piece. The piece is written with
+// SealPiece; pieces reserved after it in the same sector, and packing the
+// sector, wait for that. The space counts as used by an unverified deal, see
+// Config.UnverifiedDealSectors
+//
+// Deprecated: use AddPieceToAnySector, which reserves the space and writes the
+// piece in one call
+func (m *Sealing) AllocatePiece(size abi.UnpaddedPieceSize) (sectorID abi.SectorNumber, offset uint64, err error) {
+	if err := checkPieceSize(size); err != nil {
+		return 0, 0, err
+	}
+	if ss := m.maxSectorSize(); size > abi.PaddedPieceSize(ss).Unpadded() {
+		return 0, 0, xerrors.Errorf("piece of %d bytes, sector size %d: %w", size.Padded(), ss, ErrPieceTooLarge)
 	}
 
-	err = m.sealer.NewSector(context.TODO(), m.minerSector(sid)) // TODO: Put more than one thing in a sector
+	ctx := context.TODO()
+	local := m.localSectors(ctx)
+
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	sid, pads, err := m.getAvailableSector(ctx, size, false, local)
 	if err != nil {
-		return 0, 0, xerrors.Errorf("initializing sector: %w", err)
+		return 0, 0, &addPieceError{ErrSectorAllocFailed, xerrors.Errorf("getting available sector: %w", err)}
 	}
+	res := m.reserve(sid, pads, size)
+	m.allocated[sid] = append(m.allocated[sid], res)
 
-	// offset hard-coded to 0 since we only put one thing in a sector for now
-	return sid, 0, nil
+	return sid, uint64(res.offset), nil
 }
 
+// SealPiece writes a piece allocated with AllocatePiece to its sector. The
+// sector is packed once it's full, or StartPacking is called
+//
+// Deprecated: use AddPieceToAnySector
 func (m *Sealing) SealPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, sectorID abi.SectorNumber, d DealInfo) error {
-	log.Infof("Seal piece for deal %d", d.DealID)
+	done, err := m.startAddPiece()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	m.unsealedLk.Lock()
+	res := m.takeAllocated(sectorID, size)
+	m.unsealedLk.Unlock()
+	if res == nil {
+		return xerrors.Errorf("no piece of %d bytes allocated in sector %d", size, sectorID)
+	}
+
+	ctx = sectorstorage.WithPriority(ctx, m.dealPriority())
+
+	m.sectorLog(sectorID).Infof("Seal piece for deal %d", d.DealID)
+
+	if _, err := m.writePiece(ctx, res, r, d); err != nil {
+		return &addPieceError{ErrAddPieceFailed, err}
+	}
+
+	m.reopeners.add(d)
+	return nil
+}
+
+// takeAllocated removes the first allocation of a piece of the given size in
+// the sector. Caller must hold unsealedLk
+func (m *Sealing) takeAllocated(sid abi.SectorNumber, size abi.UnpaddedPieceSize) *pieceReservation {
+	for i, res := range m.allocated[sid] {
+		if res.size != size {
+			continue
+		}
+
+		rest := append(m.allocated[sid][:i:i], m.allocated[sid][i+1:]...)
+		if len(rest) == 0 {
+			delete(m.allocated, sid)
+		} else {
+			m.allocated[sid] = rest
+		}
+		return res
+	}
+	return nil
+}
+
+// PlanPiece reports where AddPieceToAnySector would put a piece of the given
+// size, of a verified or unverified deal, right now, without writing anything.
+// The sector number isn't known before a new sector is created, so it's 0 when
+// wouldCreateNew is set. When a new sector would be needed, but
+// MaxWaitDealsSectors or the UnverifiedDealSectors quota is reached,
+// ErrTooManyOpenSectors or ErrQuotaExhausted is returned, as
+// AddPieceToAnySector would wait for a sector to be packed, or reject the
+// piece with RejectOverWaitDealsLimit
+func (m *Sealing) PlanPiece(ctx context.Context, size abi.UnpaddedPieceSize, verified bool) (sid abi.SectorNumber, offset uint64, wouldCreateNew bool, err error) {
+	if err := checkPieceSize(size); err != nil {
+		return 0, 0, false, err
+	}
+	if ss := m.maxSectorSize(); size > abi.PaddedPieceSize(ss).Unpadded() {
+		return 0, 0, false, xerrors.Errorf("piece of %d bytes, sector size %d: %w", size.Padded(), ss, ErrPieceTooLarge)
+	}
+
+	local := m.localSectors(ctx)
+
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	sid, pads, ok := m.pickSector(local, m.classSectors(verified), size.Padded())
+	if !ok {
+		if err := m.newSectorBlocked(size.Padded(), verified); err != nil {
+			return 0, 0, false, err
+		}
+		return 0, 0, true, nil
+	}
+
+	off := m.unsealedInfos[sid].stored
+	for _, p := range pads {
+		off += p
+	}
+
+	return sid, uint64(off), false, nil
+}
+
+// ErrDealStartTooSoon is returned when adding a piece of a deal which starts
+// before the sector could be sealed, see Config.SealDurationEstimate
+type ErrDealStartTooSoon struct{ error }
+
+// checkDealStart rejects deals starting before a new sector could be sealed
+// and committed
+func (m *Sealing) checkDealStart(ctx context.Context, d DealInfo) error {
+	spt, err := m.sealProofType(m.sealer.SectorSize())
+	if err != nil {
+		return xerrors.Errorf("bad sector size: %w", err)
+	}
+
+	estimate, ok := m.cfg.SealDurationEstimate[spt]
+	if !ok {
+		return nil
+	}
+
+	_, height, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	if height+estimate > d.DealSchedule.StartEpoch {
+		return &ErrDealStartTooSoon{xerrors.Errorf("deal %d starts at epoch %d, sealing takes until about epoch %d (current height %d)", d.DealID, d.DealSchedule.StartEpoch, height+estimate, height)}
+	}
+	return nil
+}
+
+// ErrSectorUnknown is returned when adding a piece to a sector which doesn't exist
+type ErrSectorUnknown struct{ error }
+
+// ErrSectorNotOpen is returned when adding a piece to a sector which doesn't
+// accept pieces anymore
+type ErrSectorNotOpen struct{ error }
+
+// ErrSectorFull is returned when the piece, with the padding it needs, doesn't
+// fit into the remaining space of the sector
+type ErrSectorFull struct{ error }
+
+// AddPieceToSector writes the piece to the given sector, which must still
+// accept deals. Returns the (padded) offset of the piece in the sector
+func (m *Sealing) AddPieceToSector(ctx context.Context, sid abi.SectorNumber, size abi.UnpaddedPieceSize, r io.Reader, d DealInfo) (uint64, error) {
+	if err := m.acceptPiece(ctx, d, size); err != nil {
+		return 0, err
+	}
+	if err := checkPieceSize(size); err != nil {
+		return 0, err
+	}
+	if err := m.checkDealStart(ctx, d); err != nil {
+		return 0, err
+	}
+	if err := m.waitSealerCapacity(ctx); err != nil {
+		return 0, err
+	}
+
+	done, err := m.startAddPiece()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	ctx = sectorstorage.WithPriority(ctx, m.dealPriority())
+
+	m.unsealedLk.Lock()
+	ui, ok := m.unsealedInfos[sid]
+	if !ok {
+		m.unsealedLk.Unlock()
+
+		_, err := m.GetSectorInfo(sid)
+		if xerrors.As(err, new(*ErrSectorNotFound)) {
+			return 0, &ErrSectorUnknown{xerrors.Errorf("sector %d not found", sid)}
+		}
+		if err != nil {
+			return 0, xerrors.Errorf("getting sector %d: %w", sid, err)
+		}
+		return 0, &ErrSectorNotOpen{xerrors.Errorf("sector %d is not accepting pieces", sid)}
+	}
+
+	pads, padLength := requiredPadding(ui.stored, size.Padded())
+	if ui.stored+padLength+size.Padded() > ui.size {
+		m.unsealedLk.Unlock()
+		return 0, &ErrSectorFull{xerrors.Errorf("piece of %d bytes doesn't fit into sector %d (%d of %d bytes used, %d bytes of padding needed)", size.Padded(), sid, ui.stored, ui.size, padLength)}
+	}
+	if limit := m.cfg.MaxPiecesPerSector; limit > 0 && len(ui.pieceSizes)+len(pads)+1 > limit {
+		m.unsealedLk.Unlock()
+		return 0, &ErrSectorFull{xerrors.Errorf("sector %d holds %d pieces, adding a piece with %d padding pieces would exceed the limit of %d", sid, len(ui.pieceSizes), len(pads), limit)}
+	}
+
+	res := m.reserve(sid, pads, size)
+	m.unsealedLk.Unlock()
+
+	m.sectorLog(sid).Infof("Adding piece for deal %d", d.DealID)
+
+	return m.writePiece(ctx, res, r, d)
+}
+
+// pieceWrite tracks writing a reserved piece to its sector
+type pieceWrite struct {
+	done chan struct{}
+	err  error // set before done is closed
+}
+
+// pieceReservation is space reserved in an open sector for a piece and the
+// padding before it. Writes to a sector happen in the order the space was
+// reserved in, each one waits for the previous one
+type pieceReservation struct {
+	sid      abi.SectorNumber
+	existing []abi.UnpaddedPieceSize // sizes of pieces reserved before
+	pads     []abi.PaddedPieceSize
+	offset   abi.PaddedPieceSize
+	size     abi.UnpaddedPieceSize
+	pieceCID *cid.Cid // precomputed by the caller, see AddPrecomputedPiece
+
+	prev *pieceWrite
+	w    *pieceWrite
+
+	full bool // the sector was closed with this reservation
+}
+
+// reserve reserves space for the padding and the piece at the end of the
+// sector. A sector filled up by the reservation doesn't accept pieces anymore.
+// Caller must hold unsealedLk
+func (m *Sealing) reserve(sid abi.SectorNumber, pads []abi.PaddedPieceSize, size abi.UnpaddedPieceSize) *pieceReservation {
+	ui := m.unsealedInfos[sid]
+
+	res := &pieceReservation{
+		sid:      sid,
+		existing: append([]abi.UnpaddedPieceSize{}, ui.pieceSizes...),
+		pads:     pads,
+		size:     size,
+		prev:     ui.lastWrite,
+		w:        &pieceWrite{done: make(chan struct{})},
+	}
+
+	for _, p := range pads {
+		ui.stored += p
+		ui.pieceSizes = append(ui.pieceSizes, p.Unpadded())
+	}
+	res.offset = ui.stored
+	ui.stored += size.Padded()
+	ui.pieceSizes = append(ui.pieceSizes, size)
+	ui.lastWrite = res.w
+	if ui.firstPiece.IsZero() {
+		ui.firstPiece = m.clock.Now()
+	}
+
+	if ui.stored == ui.size || m.pieceLimitReached(ui) {
+		res.full = true
+		delete(m.unsealedInfos, sid)
+		m.notifySectorClosed()
+	} else {
+		m.unsealedInfos[sid] = ui
+	}
+
+	return res
+}
+
+// writePiece writes the padding and the piece of a reservation. If that
+// fails, the sector stops accepting pieces and gets sealed with the pieces
+// written before
+func (m *Sealing) writePiece(ctx context.Context, res *pieceReservation, r io.Reader, d DealInfo) (uint64, error) {
+	var err, prevErr error
+	if res.prev != nil {
+		<-res.prev.done
+		prevErr = res.prev.err
+	}
+
+	if prevErr != nil {
+		err = xerrors.Errorf("writing an earlier piece to sector %d failed: %w", res.sid, prevErr)
+	} else {
+		err = m.writeReserved(ctx, res, r, d)
+	}
+
+	m.unsealedLk.Lock()
+	var last *pieceWrite
+	closed := res.full
+	if err != nil && prevErr == nil && !res.full {
+		last, closed = m.closeSector(res.sid)
+	}
+	if ui, ok := m.unsealedInfos[res.sid]; ok && ui.lastWrite == res.w {
+		ui.lastWrite = nil
+		m.unsealedInfos[res.sid] = ui
+	}
+	m.unsealedLk.Unlock()
+
+	res.w.err = err
+	close(res.w.done)
+
+	if closed {
+		if perr := m.packAfter(res.sid, last); perr != nil {
+			if err == nil {
+				return 0, xerrors.Errorf("start packing: %w", perr)
+			}
+			m.sectorLog(res.sid).Errorf("start packing: %+v", perr)
+		}
+	}
 
-	ppi, err := m.sealer.AddPiece(sectorstorage.WithPriority(ctx, DealSectorPriority), m.minerSector(sectorID), []abi.UnpaddedPieceSize{}, size, r)
 	if err != nil {
+		return 0, err
+	}
+
+	m.metrics.PieceAdded(res.size)
+	return uint64(res.offset), nil
+}
+
+func (m *Sealing) writeReserved(ctx context.Context, res *pieceReservation, r io.Reader, d DealInfo) error {
+	existing := res.existing
+
+	if m.unsealedPieces() != nil {
+		if err := m.send(res.sid, SectorPieceWriting{Deal: d, Size: res.size}); err != nil {
+			return xerrors.Errorf("recording the piece being written: %w", err)
+		}
+	}
+
+	for _, p := range res.pads {
+		if err := m.addPiece(ctx, res.sid, existing, p.Unpadded(), m.pledgeReader(p.Unpadded()), nil, nil); err != nil {
+			return xerrors.Errorf("writing padding: %w", err)
+		}
+		existing = append(existing, p.Unpadded())
+	}
+
+	if err := m.addPiece(ctx, res.sid, existing, res.size, r, &d, res.pieceCID); err != nil {
 		return xerrors.Errorf("adding piece to sector: %w", err)
 	}
 
-	rt, err := ffiwrapper.SealProofTypeFromSectorSize(m.sealer.SectorSize())
+	return nil
+}
+
+// addPiece writes a piece to an unsealed sector, and records it in the sector.
+// pieceCID is the piece commitment computed by the caller, if it did
+func (m *Sealing) addPiece(ctx context.Context, sid abi.SectorNumber, existing []abi.UnpaddedPieceSize, size abi.UnpaddedPieceSize, r io.Reader, di *DealInfo, pieceCID *cid.Cid) error {
+	log := m.sectorLog(sid)
+
+	log.Debugw("writing piece", "size", size, "existing", len(existing))
+	var ppi abi.PieceInfo
+	var err error
+	if pieceCID != nil {
+		ppi, err = m.sealerAddPrecomputed(ctx, m.minerSector(sid), existing, size, r, *pieceCID)
+	} else {
+		ppi, err = m.sealerAddPiece(ctx, m.minerSector(sid), existing, size, r)
+	}
+	if err != nil {
+		return xerrors.Errorf("writing piece: %w", err)
+	}
+	log.Debugw("wrote piece", "size", size, "pieceCID", ppi.PieceCID)
+
+	return m.send(sid, SectorAddPiece{NewPiece: Piece{
+		Piece:    ppi,
+		DealInfo: di,
+	}})
+}
+
+// sealerAddPiece writes a piece with the sealer, once there is a free
+// MaxConcurrentAddPiece slot
+func (m *Sealing) sealerAddPiece(ctx context.Context, sector abi.SectorID, existing []abi.UnpaddedPieceSize, size abi.UnpaddedPieceSize, r io.Reader) (abi.PieceInfo, error) {
+	if err := m.apLimit.acquire(ctx); err != nil {
+		return abi.PieceInfo{}, xerrors.Errorf("waiting for an AddPiece slot: %w", err)
+	}
+	defer m.apLimit.release()
+
+	done, err := m.beginIntent(sector.Number, IntentAddPiece)
 	if err != nil {
-		return xerrors.Errorf("bad sector size: %w", err)
+		return abi.PieceInfo{}, err
+	}
+	defer done()
+
+	return m.sealer.AddPiece(ctx, sector, existing, size, r)
+}
+
+// StartPacking stops adding deals to the sector, and fills the remaining
+// space with filler pieces so that it can be sealed. Pieces still being
+// written are waited for. Sectors without pieces are sealed with filler data
+// only, unless Config.RejectEmptySectors is set. If the sector can't be told
+// to start packing, it accepts pieces again
+func (m *Sealing) StartPacking(sid abi.SectorNumber) error {
+	m.unsealedLk.Lock()
+	ui, open := m.unsealedInfos[sid]
+	if open && ui.stored == 0 {
+		if err := m.packEmpty(sid); err != nil {
+			m.unsealedLk.Unlock()
+			return err
+		}
+	}
+	last, open := m.closeSector(sid)
+	m.unsealedLk.Unlock()
+
+	if !open {
+		// not accepting deals, only pack it if it's waiting for them anyway
+		si, err := m.GetSectorInfo(sid)
+		if err != nil {
+			return err
+		}
+		if si.State == WaitDeals && len(si.Pieces) == 0 {
+			if err := m.packEmpty(sid); err != nil {
+				return err
+			}
+		}
+
+		return m.sendChecked(sid, SectorStartPacking{})
+	}
+
+	if err := m.packAfter(sid, last); err != nil {
+		if last == nil || last.err == nil {
+			m.reopenSector(sid, ui)
+		}
+		return err
+	}
+	return nil
+}
+
+// reopenSector makes a sector closed by StartPacking accept pieces again.
+// Writes to it must be done
+func (m *Sealing) reopenSector(sid abi.SectorNumber, ui UnsealedSectorInfo) {
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	ui.lastWrite = nil
+	m.unsealedInfos[sid] = ui
+}
+
+// ErrEmptySector is returned by StartPacking for sectors without pieces, when
+// Config.RejectEmptySectors is set
+type ErrEmptySector struct{ error }
+
+// packEmpty checks whether a sector without pieces can be packed. Such
+// sectors are sealed as CC sectors, filled with filler pieces
+func (m *Sealing) packEmpty(sid abi.SectorNumber) error {
+	if m.cfg.RejectEmptySectors {
+		return &ErrEmptySector{xerrors.Errorf("sector %d has no pieces", sid)}
+	}
+
+	m.sectorLog(sid).Warnf("sector %d has no pieces, packing it with filler data", sid)
+	return nil
+}
+
+// closeSector stops the sector from accepting pieces, and returns the last
+// write to it which is still in flight. Caller must hold unsealedLk
+func (m *Sealing) closeSector(sid abi.SectorNumber) (*pieceWrite, bool) {
+	ui, ok := m.unsealedInfos[sid]
+	if !ok {
+		return nil, false
 	}
 
-	return m.newSector(sectorID, rt, []Piece{
-		{
-			Piece:    ppi,
-			DealInfo: &d,
-		},
+	delete(m.unsealedInfos, sid)
+	m.notifySectorClosed()
+	return ui.lastWrite, true
+}
+
+// notifySectorClosed wakes up AddPieceToAnySector calls waiting for the
+// number of sectors accepting deals to drop. Caller must hold unsealedLk
+func (m *Sealing) notifySectorClosed() {
+	if m.unsealedClosed != nil {
+		close(m.unsealedClosed)
+		m.unsealedClosed = nil
+	}
+}
+
+// packAfter starts packing the sector once the given write is done
+func (m *Sealing) packAfter(sid abi.SectorNumber, last *pieceWrite) error {
+	if last != nil {
+		<-last.done
+	}
+
+	m.sectorLog(sid).Info("Starting packing sector")
+	return m.send(sid, SectorStartPacking{})
+}
+
+// ErrTooManyOpenSectors is returned by AddPieceToAnySector when a new sector
+// would be needed, but MaxWaitDealsSectors sectors already accept deals, and
+// RejectOverWaitDealsLimit is set. PlanPiece returns it whether it's set or not
+type ErrTooManyOpenSectors struct{ error }
+
+// sectorCreation identifies the kind of sector accepting deals being created
+type sectorCreation struct {
+	size     abi.SectorSize
+	verified bool // only set if deal classes are separated, see Config.UnverifiedDealSectors
+}
+
+// getAvailableSector returns a sector which can hold a piece of the given
+// size, along with the padding which has to be written before the piece.
+// Sectors in local, see localSectors, are preferred.
+// Caller must hold unsealedLk; it's released while waiting for an open sector
+// to be packed, when MaxWaitDealsSectors or the UnverifiedDealSectors quota
+// is reached, while waiting for the sector creation rate limit, and while a
+// new sector is created. Concurrent callers needing the same kind of sector
+// wait for that creation, and use the sector if it has room for their piece
+func (m *Sealing) getAvailableSector(ctx context.Context, size abi.UnpaddedPieceSize, verified bool, local sectorLocality) (abi.SectorNumber, []abi.PaddedPieceSize, error) {
+	ss := m.newSectorSize(size)
+	kind := sectorCreation{size: ss, verified: verified && m.dealClasses()}
+
+	for {
+		if sid, pads, ok := m.pickSector(local, m.classSectors(verified), size.Padded()); ok {
+			return sid, pads, nil
+		}
+
+		if created, ok := m.creating[kind]; ok {
+			m.unsealedLk.Unlock()
+			select {
+			case <-created:
+			case <-ctx.Done():
+				m.unsealedLk.Lock()
+				return 0, nil, xerrors.Errorf("waiting for a new sector: %w", ctx.Err())
+			}
+			m.unsealedLk.Lock()
+			continue
+		}
+
+		blocked := m.newSectorBlocked(size.Padded(), verified)
+		if blocked == nil {
+			wait := m.snLimit.take()
+			if wait == 0 {
+				break
+			}
+
+			log.Infof("sector creation rate limit reached, waiting %s", wait)
+			m.unsealedLk.Unlock()
+			select {
+			case <-m.clock.After(wait):
+			case <-ctx.Done():
+				m.unsealedLk.Lock()
+				return 0, nil, xerrors.Errorf("waiting for sector creation rate limit: %w", ctx.Err())
+			}
+			m.unsealedLk.Lock()
+			continue
+		}
+		if m.cfg.RejectOverWaitDealsLimit {
+			return 0, nil, blocked
+		}
+
+		if m.unsealedClosed == nil {
+			m.unsealedClosed = make(chan struct{})
+		}
+		closed := m.unsealedClosed
+
+		log.Infof("%d sectors accepting deals, waiting for one to be packed", len(m.unsealedInfos))
+		m.unsealedLk.Unlock()
+		select {
+		case <-closed:
+		case <-ctx.Done():
+			m.unsealedLk.Lock()
+			return 0, nil, xerrors.Errorf("waiting for a sector to be packed: %w", ctx.Err())
+		}
+		m.unsealedLk.Lock()
+	}
+
+	created := make(chan struct{})
+	m.creating[kind] = created
+
+	m.unsealedLk.Unlock()
+	sid, err := m.newSectorOfSize(ctx, ss)
+	m.unsealedLk.Lock()
+
+	delete(m.creating, kind)
+	close(created)
+	if err != nil {
+		m.notifySectorClosed() // the sector doesn't count towards MaxWaitDealsSectors anymore
+		return 0, nil, err
+	}
+
+	m.unsealedInfos[sid] = UnsealedSectorInfo{size: abi.PaddedPieceSize(ss), verified: verified}
+	return sid, nil, nil
+}
+
+// newSectorBlocked tells why no new sector can be opened for a piece of a
+// deal of the class right now, nil if one can. Caller must hold unsealedLk
+func (m *Sealing) newSectorBlocked(size abi.PaddedPieceSize, verified bool) error {
+	if !m.inQuota(verified) {
+		return &ErrQuotaExhausted{xerrors.Errorf("%d sectors already accept unverified deals, none has room for %d bytes", m.cfg.UnverifiedDealSectors, size)}
+	}
+	if open := len(m.unsealedInfos) + len(m.creating); m.cfg.MaxWaitDealsSectors > 0 && open >= m.cfg.MaxWaitDealsSectors {
+		return &ErrTooManyOpenSectors{xerrors.Errorf("%d sectors already accept deals, none has room for %d bytes", open, size)}
+	}
+	return nil
+}
+
+// dealSectorSizes returns Config.SectorSizes in ascending order, or the
+// sealer sector size if not set
+func (m *Sealing) dealSectorSizes() []abi.SectorSize {
+	if len(m.cfg.SectorSizes) == 0 {
+		return []abi.SectorSize{m.sealer.SectorSize()}
+	}
+
+	out := append([]abi.SectorSize{}, m.cfg.SectorSizes...)
+	sort.Slice(out, func(i, j int) bool {
+		return out[i] < out[j]
 	})
+	return out
+}
+
+// dealSectorSize tells whether sectors accepting deals can have the size
+func (m *Sealing) dealSectorSize(ss abi.SectorSize) bool {
+	for _, s := range m.dealSectorSizes() {
+		if s == ss {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Sealing) maxSectorSize() abi.SectorSize {
+	sizes := m.dealSectorSizes()
+	return sizes[len(sizes)-1]
+}
+
+// newSectorSize returns the smallest sector size a piece fits in
+func (m *Sealing) newSectorSize(size abi.UnpaddedPieceSize) abi.SectorSize {
+	sizes := m.dealSectorSizes()
+	for _, ss := range sizes {
+		if size.Padded() <= abi.PaddedPieceSize(ss) {
+			return ss
+		}
+	}
+	return sizes[len(sizes)-1]
 }
 
-// newSector accepts a slice of pieces which will have a deal associated with
-// them (in the event of a storage deal) or no deal (in the event of sealing
-// garbage data)
-func (m *Sealing) newSector(sid abi.SectorNumber, rt abi.RegisteredSealProof, pieces []Piece) error {
-	log.Infof("Start sealing %d", sid)
-	return m.sectors.Send(uint64(sid), SectorStart{
+// newSectorOfSize creates a sector accepting deals, of the given size
+func (m *Sealing) newSectorOfSize(ctx context.Context, ss abi.SectorSize) (abi.SectorNumber, error) {
+	rt, err := m.sealProofType(ss)
+	if err != nil {
+		return 0, xerrors.Errorf("bad sector size: %w", err)
+	}
+
+	res, err := m.sc.Reserve()
+	if err != nil {
+		return 0, xerrors.Errorf("getting sector number: %w", err)
+	}
+	sid := res.Number()
+	log := m.sectorLog(sid)
+
+	if err := m.checkSectorSpace(ctx, sid, rt); err != nil {
+		if aerr := res.Abort(); aerr != nil {
+			log.Errorf("aborting sector number reservation: %+v", aerr)
+		}
+		return 0, err
+	}
+
+	if err := m.sealer.NewSector(ctx, m.minerSector(sid)); err != nil {
+		if aerr := res.Abort(); aerr != nil {
+			log.Errorf("aborting sector number reservation: %+v", aerr)
+		}
+		return 0, xerrors.Errorf("initializing sector: %w", err)
+	}
+	if err := res.Commit(); err != nil {
+		return 0, xerrors.Errorf("committing sector number: %w", err)
+	}
+
+	log.Info("Creating sector")
+	if err := m.send(sid, SectorStart{
+		ID:         sid,
+		SectorType: rt,
+		SealMode:   m.sealMode(),
+	}); err != nil {
+		return 0, xerrors.Errorf("starting the sector fsm: %w", err)
+	}
+
+	return sid, nil
+}
+
+func (m *Sealing) dealPriority() int {
+	if m.cfg.Priorities.Deals == 0 {
+		return DealSectorPriority
+	}
+	return m.cfg.Priorities.Deals
+}
+
+// newSectorCC accepts a slice of pieces with no deals, and starts sealing the
+// sector right away
+func (m *Sealing) newSectorCC(ctx context.Context, sid abi.SectorNumber, pieces []Piece) error {
+	rt, err := m.sealProofType(m.sealer.SectorSize())
+	if err != nil {
+		return xerrors.Errorf("bad sector size: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.sectorLog(sid).Info("Creating CC sector")
+	return m.send(sid, SectorStartCC{
 		ID:         sid,
-		Pieces:     pieces,
 		SectorType: rt,
+		SealMode:   m.sealMode(),
+		Pieces:     pieces,
 	})
 }
 
+// Terminate terminates a committed sector on chain
+func (m *Sealing) Terminate(ctx context.Context, sid abi.SectorNumber) error {
+	return m.sendChecked(sid, SectorTerminate{})
+}
+
+// RecoverFault declares a faulty sector recovered on chain, once its sealed
+// data is checked to still be there. The sector goes back to Proving when the
+// declaration lands, or to RecoveryFailed if the data is gone
+func (m *Sealing) RecoverFault(ctx context.Context, sid abi.SectorNumber) error {
+	return m.sendChecked(sid, SectorRecoverFault{})
+}
+
+// SetSectorPriority sets the scheduling priority of all further sealer work of
+// the sector, including the commit proof computation. The priority is kept
+// across restarts, 0 resets the sector to the default priority
+func (m *Sealing) SetSectorPriority(sid abi.SectorNumber, priority int) error {
+	if _, err := m.GetSectorInfo(sid); err != nil {
+		return err
+	}
+
+	return m.send(sid, SectorSetPriority{Priority: int64(priority)})
+}
+
+// RetryUnsealedCheck checks the unsealed copy of a sector in UnsealedCorrupt
+// again, once it was fixed, and finalizes the sector if it matches its deals
+func (m *Sealing) RetryUnsealedCheck(ctx context.Context, sid abi.SectorNumber) error {
+	return m.sendChecked(sid, SectorRetryUnsealedCheck{})
+}
+
 func (m *Sealing) Remove(ctx context.Context, sid abi.SectorNumber) error {
-	return m.sectors.Send(uint64(sid), SectorRemove{})
+	return m.sendChecked(sid, SectorRemove{})
 }
 
 func (m *Sealing) minerSector(num abi.SectorNumber) abi.SectorID {
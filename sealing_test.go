@@ -0,0 +1,780 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore/query"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	sectorstorage "github.com/filecoin-project/sector-storage"
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// setZeroDeal registers a deal for a zero piece of the given padded size
+func (h *testHarness) setZeroDeal(id abi.DealID, size abi.PaddedPieceSize) {
+	h.api.setDeal(id, market.DealProposal{
+		PieceCID:   zerocomm.ZeroPieceCommitment(size.Unpadded()),
+		PieceSize:  size,
+		StartEpoch: 10000,
+		EndEpoch:   20000,
+	})
+}
+
+// addDeal adds a zero piece of the given padded size to any sector
+func (h *testHarness) addDeal(id abi.DealID, size abi.PaddedPieceSize) (abi.SectorNumber, uint64) {
+	h.setZeroDeal(id, size)
+
+	sid, offset, err := h.m.AddPieceToAnySector(context.Background(), size.Unpadded(), bytes.NewReader(make([]byte, size.Unpadded())), DealInfo{DealID: id})
+	require.NoError(h.t, err)
+	return sid, offset
+}
+
+// waitPieces waits for the sector to record n pieces, and returns their sizes
+func (h *testHarness) waitPieces(sn abi.SectorNumber, n int) []abi.PaddedPieceSize {
+	var si SectorInfo
+	require.Eventually(h.t, func() bool {
+		si = h.sector(sn)
+		return len(si.Pieces) == n
+	}, 5*time.Second, 5*time.Millisecond, "sector %d has %d pieces, expected %d", sn, len(si.Pieces), n)
+
+	var out []abi.PaddedPieceSize
+	for _, p := range si.Pieces {
+		out = append(out, p.Piece.Size)
+	}
+	return out
+}
+
+func requireAligned(t *testing.T, offset abi.PaddedPieceSize, sizes []abi.PaddedPieceSize) {
+	for _, s := range sizes {
+		require.Zero(t, offset%s, "piece of size %d at offset %d", s, offset)
+		offset += s
+	}
+}
+
+func TestAddPieceMixedSizes(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	sealing := make(chan []abi.PieceInfo, 2)
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		sealing <- pieces
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	type placement struct {
+		sid    abi.SectorNumber
+		offset uint64
+	}
+	var placed []placement
+	for i, size := range []abi.PaddedPieceSize{256, 512, 256, 1024} {
+		sid, offset := h.addDeal(abi.DealID(i+1), size)
+		placed = append(placed, placement{sid, offset})
+	}
+
+	require.Equal(t, []placement{
+		{1, 0},
+		{1, 512}, // after 256 bytes of padding
+		{1, 1024},
+		{2, 0}, // doesn't fit in the remaining 768 bytes when aligned
+	}, placed)
+
+	require.Equal(t, []abi.PaddedPieceSize{256, 256, 512, 256}, h.waitPieces(1, 4))
+	require.Equal(t, WaitDeals, h.sector(1).State)
+	require.Equal(t, []abi.PaddedPieceSize{1024}, h.waitPieces(2, 1))
+
+	// filling sector 2 starts packing it
+	sid, offset := h.addDeal(5, 1024)
+	require.Equal(t, abi.SectorNumber(2), sid)
+	require.Equal(t, uint64(1024), offset)
+	require.Len(t, <-sealing, 2)
+
+	require.NoError(t, h.m.StartPacking(1))
+	pieces := <-sealing
+
+	var sizes []abi.PaddedPieceSize
+	var total abi.PaddedPieceSize
+	for _, p := range pieces {
+		sizes = append(sizes, p.Size)
+		total += p.Size
+	}
+	requireAligned(t, 0, sizes)
+	require.Equal(t, abi.PaddedPieceSize(2048), total)
+
+	// sector 1 doesn't accept deals anymore
+	sid, _ = h.addDeal(6, 256)
+	require.Equal(t, abi.SectorNumber(3), sid)
+}
+
+func TestAllocatePiece(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	seal := func(sid abi.SectorNumber, id abi.DealID, size abi.PaddedPieceSize) error {
+		h.setZeroDeal(id, size)
+		return h.m.SealPiece(context.Background(), size.Unpadded(), bytes.NewReader(make([]byte, size.Unpadded())), sid, DealInfo{DealID: id})
+	}
+
+	sid1, off1, err := h.m.AllocatePiece(abi.PaddedPieceSize(256).Unpadded())
+	require.NoError(t, err)
+	sid2, off2, err := h.m.AllocatePiece(abi.PaddedPieceSize(512).Unpadded())
+	require.NoError(t, err)
+
+	require.Equal(t, abi.SectorNumber(1), sid1)
+	require.Equal(t, uint64(0), off1)
+	require.Equal(t, abi.SectorNumber(1), sid2)
+	require.Equal(t, uint64(512), off2) // after 256 bytes of padding
+
+	require.Error(t, seal(sid1, 1, 1024)) // nothing of this size allocated
+
+	// the second piece waits for the first one to be written
+	sealed := make(chan error, 1)
+	go func() {
+		sealed <- seal(sid2, 2, 512)
+	}()
+	require.NoError(t, seal(sid1, 1, 256))
+	require.NoError(t, <-sealed)
+
+	require.Equal(t, []abi.PaddedPieceSize{256, 256, 512}, h.waitPieces(1, 3))
+	require.Empty(t, h.m.allocated)
+}
+
+func TestPackingFillsSector(t *testing.T) {
+	for _, tc := range []struct {
+		deal    abi.PaddedPieceSize
+		fillers []abi.UnpaddedPieceSize
+	}{
+		{1024, []abi.UnpaddedPieceSize{abi.PaddedPieceSize(1024).Unpadded()}},
+		{2048, nil}, // already full, packing doesn't write anything
+	} {
+		h := newTestHarness(t, Config{})
+
+		sealing := make(chan []abi.PieceInfo, 1)
+		h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+			sealing <- pieces
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		var lk sync.Mutex
+		var written []abi.UnpaddedPieceSize
+		h.sealer.addPiece = func(ctx context.Context, sector abi.SectorID, size abi.UnpaddedPieceSize) {
+			lk.Lock()
+			written = append(written, size)
+			lk.Unlock()
+		}
+
+		sid, _ := h.addDeal(1, tc.deal)
+		if tc.deal < abi.PaddedPieceSize(h.sealer.SectorSize()) {
+			require.Equal(t, []abi.PaddedPieceSize{tc.deal}, h.waitPieces(sid, 1))
+			require.NoError(t, h.m.StartPacking(sid))
+		} // full sectors start packing on their own
+
+		var total abi.PaddedPieceSize
+		for _, p := range <-sealing {
+			total += p.Size
+		}
+		require.Equal(t, abi.PaddedPieceSize(h.sealer.SectorSize()), total, "deal of size %d", tc.deal)
+
+		lk.Lock()
+		require.Equal(t, append([]abi.UnpaddedPieceSize{tc.deal.Unpadded()}, tc.fillers...), written, "deal of size %d", tc.deal)
+		lk.Unlock()
+
+		si := h.sector(sid)
+		require.Len(t, si.Pieces, 1+len(tc.fillers))
+		for _, p := range si.Pieces[1:] {
+			require.Nil(t, p.DealInfo)
+		}
+	}
+}
+
+func TestRequiredPadding(t *testing.T) {
+	for _, tc := range []struct {
+		stored, piece abi.PaddedPieceSize
+		pads          []abi.PaddedPieceSize
+	}{
+		{0, 512, []abi.PaddedPieceSize{}},
+		{512, 512, []abi.PaddedPieceSize{}},
+		{256, 512, []abi.PaddedPieceSize{256}},
+		{128, 1024, []abi.PaddedPieceSize{128, 256, 512}},
+		{1280, 256, []abi.PaddedPieceSize{}},
+		{1280, 2048, []abi.PaddedPieceSize{256, 512}},
+	} {
+		pads, sum := requiredPadding(tc.stored, tc.piece)
+		require.Equal(t, tc.pads, pads, "stored %d, piece %d", tc.stored, tc.piece)
+
+		var total abi.PaddedPieceSize
+		for _, p := range pads {
+			total += p
+		}
+		require.Equal(t, total, sum)
+		requireAligned(t, tc.stored, append(pads, tc.piece))
+	}
+}
+
+func TestGetSectorInfo(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	_, err := h.m.GetSectorInfo(1)
+	var nf *ErrSectorNotFound
+	require.True(t, xerrors.As(err, &nf), "%+v", err)
+
+	sid, _ := h.addDeal(1, 1024)
+	h.waitPieces(sid, 1)
+
+	si, err := h.m.GetSectorInfo(sid)
+	require.NoError(t, err)
+	require.Equal(t, WaitDeals, si.State)
+	require.Equal(t, []abi.DealID{1}, si.dealIDs())
+	require.Equal(t, zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(1024).Unpadded()), si.Pieces[0].Piece.PieceCID)
+}
+
+func TestListSectors(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	sectors, err := h.m.ListSectors()
+	require.NoError(t, err)
+	require.Empty(t, sectors)
+
+	for sn, state := range map[abi.SectorNumber]SectorState{
+		12: PreCommitWait,
+		3:  Proving,
+		7:  PreCommitWait,
+		1:  WaitSeed,
+		20: Proving,
+	} {
+		h.put(SectorInfo{SectorNumber: sn, State: state})
+	}
+
+	sectors, err = h.m.ListSectors()
+	require.NoError(t, err)
+	var sns []abi.SectorNumber
+	for _, si := range sectors {
+		sns = append(sns, si.SectorNumber)
+	}
+	require.Equal(t, []abi.SectorNumber{1, 3, 7, 12, 20}, sns)
+
+	waiting, err := h.m.ListSectorsInState(PreCommitWait)
+	require.NoError(t, err)
+	require.Len(t, waiting, 2)
+	require.Equal(t, abi.SectorNumber(7), waiting[0].SectorNumber)
+	require.Equal(t, abi.SectorNumber(12), waiting[1].SectorNumber)
+
+	none, err := h.m.ListSectorsInState(Committing)
+	require.NoError(t, err)
+	require.Empty(t, none)
+}
+
+func TestSectorPriorities(t *testing.T) {
+	priorities := func(h *testHarness) chan interface{} {
+		ch := make(chan interface{}, 16)
+		h.sealer.addPiece = func(ctx context.Context, sector abi.SectorID, size abi.UnpaddedPieceSize) {
+			ch <- ctx.Value(sectorstorage.SchedPriorityKey)
+		}
+		return ch
+	}
+
+	h := newTestHarness(t, Config{})
+	prio := priorities(h)
+	h.addDeal(1, 1024)
+	require.Equal(t, DealSectorPriority, <-prio)
+
+	h = newTestHarness(t, Config{Priorities: SectorPriorities{Deals: 5, CC: 3}})
+	prio = priorities(h)
+	h.addDeal(1, 256)
+	h.addDeal(2, 512) // with padding
+	require.Equal(t, 5, <-prio)
+	require.Equal(t, 5, <-prio)
+	require.Equal(t, 5, <-prio)
+
+	_, err := h.m.PledgeSector(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, <-prio)
+}
+
+func TestDealWeights(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	add := func(id abi.DealID, size abi.PaddedPieceSize, start, end abi.ChainEpoch, verified bool) abi.SectorNumber {
+		h.setZeroDeal(id, size)
+		sid, _, err := h.m.AddPieceToAnySector(context.Background(), size.Unpadded(), bytes.NewReader(make([]byte, size.Unpadded())), DealInfo{
+			DealID:       id,
+			DealSchedule: DealSchedule{StartEpoch: start, EndEpoch: end},
+			VerifiedDeal: verified,
+		})
+		require.NoError(t, err)
+		return sid
+	}
+
+	sid := add(1, 256, 100, 200, false)
+	require.Equal(t, sid, add(2, 256, 100, 1100, true))
+	require.Equal(t, sid, add(3, 512, 50, 150, false))
+	require.Equal(t, sid, add(4, 1024, 0, 10, true))
+	h.waitPieces(sid, 4)
+
+	si := h.sector(sid)
+	require.Equal(t, big.NewInt(256*100+512*100), si.DealWeight)
+	require.Equal(t, big.NewInt(256*1000+1024*10), si.VerifiedDealWeight)
+}
+
+func TestSetSectorPriority(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	prio := make(chan interface{}, 1)
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		prio <- ctx.Value(sectorstorage.SchedPriorityKey)
+		return storage.Proof{1}, nil
+	}
+
+	h.put(h.committingSector(1))
+	require.NoError(t, h.m.SetSectorPriority(1, 7))
+	require.Eventually(t, func() bool {
+		return h.sector(1).Priority == 7
+	}, time.Second, time.Millisecond)
+
+	// setting the priority doesn't start the commit
+	time.Sleep(20 * time.Millisecond)
+	require.Empty(t, prio)
+	require.NoError(t, h.m.Stop(context.Background()))
+
+	// the priority reaches the sealer after a restart
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+	h.m = NewWithConfig(h.api, h.api, h.m.maddr, h.ds, h.sealer, &fakeCounter{}, fakeVerifier{}, &pcp, Config{})
+	require.NoError(t, h.m.Run(context.Background()))
+
+	require.Equal(t, 7, <-prio)
+	h.waitState(1, Proving)
+
+	var notFound *ErrSectorNotFound
+	require.True(t, xerrors.As(h.m.SetSectorPriority(2, 7), &notFound))
+}
+func TestPlanPiece(t *testing.T) {
+	for _, strategy := range []PackingStrategy{FirstFit, BestFit} {
+		h := newTestHarness(t, Config{PackingStrategy: strategy})
+
+		for i, size := range []abi.PaddedPieceSize{256, 1024, 512, 1024, 256, 128, 512} {
+			sid, offset, isNew, err := h.m.PlanPiece(context.Background(), size.Unpadded(), false)
+			require.NoError(t, err)
+
+			asid, aoffset := h.addDeal(abi.DealID(i+1), size)
+			if isNew {
+				require.Zero(t, sid)
+				require.Zero(t, aoffset)
+			} else {
+				require.Equal(t, asid, sid, "strategy %d, piece %d", strategy, i)
+				require.Equal(t, aoffset, offset, "strategy %d, piece %d", strategy, i)
+			}
+		}
+
+		_, _, _, err := h.m.PlanPiece(context.Background(), 4096, false)
+		require.Error(t, err)
+	}
+}
+
+func TestAddPieceErrors(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	add := func(size abi.UnpaddedPieceSize) error {
+		_, _, err := h.m.AddPieceToAnySector(context.Background(), size, bytes.NewReader(make([]byte, size)), DealInfo{DealID: 1})
+		return err
+	}
+
+	err := add(1000)
+	require.True(t, xerrors.Is(err, ErrPieceNotPadded), "%+v", err)
+	require.True(t, xerrors.As(err, new(*ErrInvalidPieceSize)), "%+v", err)
+
+	err = add(4064)
+	require.True(t, xerrors.Is(err, ErrPieceTooLarge), "%+v", err)
+
+	noSpace := xerrors.New("no space left")
+	h.sealer.newSector = func(ctx context.Context, sector abi.SectorID) error {
+		return noSpace
+	}
+	err = add(1016)
+	require.True(t, xerrors.Is(err, ErrSectorAllocFailed), "%+v", err)
+	require.True(t, xerrors.Is(err, noSpace), "%+v", err)
+	require.False(t, xerrors.Is(err, ErrAddPieceFailed))
+	require.Contains(t, err.Error(), "getting available sector")
+	h.sealer.newSector = nil
+
+	h.sealer.addPieceErr = xerrors.New("disk on fire")
+	err = add(1016)
+	require.True(t, xerrors.Is(err, ErrAddPieceFailed), "%+v", err)
+	require.False(t, xerrors.Is(err, ErrSectorAllocFailed))
+	require.Contains(t, err.Error(), "disk on fire")
+}
+
+func TestDealStartTooSoon(t *testing.T) {
+	h := newTestHarness(t, Config{SealDurationEstimate: map[abi.RegisteredSealProof]abi.ChainEpoch{
+		abi.RegisteredSealProof_StackedDrg2KiBV1: 500,
+	}})
+	h.api.setHead(1000)
+
+	add := func(start abi.ChainEpoch) error {
+		_, _, err := h.m.AddPieceToAnySector(context.Background(), 1016, bytes.NewReader(make([]byte, 1016)), DealInfo{DealID: 1, DealSchedule: DealSchedule{StartEpoch: start}})
+		return err
+	}
+
+	err := add(1499)
+	require.True(t, xerrors.As(err, new(*ErrDealStartTooSoon)), "%+v", err)
+	require.Empty(t, h.m.unsealedInfos)
+
+	require.NoError(t, add(1500))
+	require.NoError(t, add(5000))
+
+	// other proof types aren't checked
+	h = newTestHarness(t, Config{SealDurationEstimate: map[abi.RegisteredSealProof]abi.ChainEpoch{
+		abi.RegisteredSealProof_StackedDrg32GiBV1: 500,
+	}})
+	h.api.setHead(1000)
+	require.NoError(t, add(10))
+}
+
+func TestAddPieceCancelledNewSector(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.sealer.newSector = func(ctx context.Context, sector abi.SectorID) error {
+		cancel() // storage hangs, caller gives up
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	_, _, err := h.m.AddPieceToAnySector(ctx, 1016, bytes.NewReader(make([]byte, 1016)), DealInfo{DealID: 1})
+	require.True(t, xerrors.Is(err, context.Canceled), "%+v", err)
+	require.Empty(t, h.m.unsealedInfos)
+	require.Empty(t, h.m.creating)
+
+	sectors, err := h.m.ListSectors()
+	require.NoError(t, err)
+	require.Empty(t, sectors)
+
+	// the next piece gets a fresh sector, reusing the aborted number
+	h.sealer.newSector = nil
+	sid, _ := h.addDeal(1, 1024)
+	require.Equal(t, abi.SectorNumber(1), sid)
+}
+
+func TestAddPieceConcurrentNewSector(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.setZeroDeal(1, 512)
+	h.setZeroDeal(2, 512)
+
+	var lk sync.Mutex
+	var created []abi.SectorNumber
+	creating, release := make(chan struct{}), make(chan struct{})
+	h.sealer.newSector = func(ctx context.Context, sector abi.SectorID) error {
+		lk.Lock()
+		created = append(created, sector.Number)
+		lk.Unlock()
+
+		close(creating)
+		<-release
+		return nil
+	}
+
+	type added struct {
+		sid abi.SectorNumber
+		err error
+	}
+	done := make(chan added, 2)
+	add := func(id abi.DealID) {
+		sid, _, err := h.m.AddPieceToAnySector(context.Background(), 508, bytes.NewReader(make([]byte, 508)), DealInfo{DealID: id})
+		done <- added{sid, err}
+	}
+
+	go add(1)
+	<-creating
+	// the second piece needs a new sector too, while the first one is created
+	go add(2)
+	time.Sleep(20 * time.Millisecond)
+
+	// unsealedLk isn't held while the sector is created
+	planned := make(chan error, 1)
+	go func() {
+		_, _, _, err := h.m.PlanPiece(context.Background(), 508, false)
+		planned <- err
+	}()
+	select {
+	case err := <-planned:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("PlanPiece blocked by the sector being created")
+	}
+
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		a := <-done
+		require.NoError(t, a.err)
+		require.Equal(t, abi.SectorNumber(1), a.sid)
+	}
+
+	lk.Lock()
+	defer lk.Unlock()
+	require.Equal(t, []abi.SectorNumber{1}, created)
+}
+
+func TestAddPieceAfterRestart(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.addDeal(1, 256)
+	h.addDeal(2, 512)
+	require.Equal(t, []abi.PaddedPieceSize{256, 256, 512}, h.waitPieces(1, 3))
+
+	// sealing started before the restart, doesn't take new deals
+	h.put(SectorInfo{
+		State:        Packing,
+		SectorNumber: 2,
+		Pieces:       []Piece{{Piece: abi.PieceInfo{Size: 256, PieceCID: testCommD}}},
+	})
+
+	require.NoError(t, h.m.Stop(context.Background()))
+	h.m = NewWithConfig(h.api, h.api, h.m.maddr, h.ds, h.sealer, h.m.sc, h.m.verif, h.m.pcp, h.m.cfg)
+	require.NoError(t, h.m.Run(context.Background()))
+
+	// the first piece time is restored from the sector log
+	added := time.Unix(int64(h.sector(1).Log[1].Timestamp), 0)
+	require.Equal(t, map[abi.SectorNumber]UnsealedSectorInfo{
+		1: {size: 2048, stored: 1024, pieceSizes: []abi.UnpaddedPieceSize{254, 254, 508}, firstPiece: added},
+	}, h.m.unsealedInfos)
+
+	sid, offset := h.addDeal(3, 1024)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	require.Equal(t, uint64(1024), offset)
+}
+
+func TestAddPieceToSector(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	addTo := func(sid abi.SectorNumber, id abi.DealID, size abi.PaddedPieceSize) (uint64, error) {
+		h.setZeroDeal(id, size)
+		return h.m.AddPieceToSector(context.Background(), sid, size.Unpadded(), bytes.NewReader(make([]byte, size.Unpadded())), DealInfo{DealID: id})
+	}
+
+	sid, _ := h.addDeal(1, 256)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	sid, _ = h.addDeal(2, 2048)
+	require.Equal(t, abi.SectorNumber(2), sid)
+
+	offset, err := addTo(1, 3, 512)
+	require.NoError(t, err)
+	require.Equal(t, uint64(512), offset)
+	require.Equal(t, []abi.PaddedPieceSize{256, 256, 512}, h.waitPieces(1, 3))
+
+	_, err = addTo(1, 4, 2048)
+	require.True(t, xerrors.As(err, new(*ErrSectorFull)), "%+v", err)
+
+	// sector 2 was filled up and is sealing
+	_, err = addTo(2, 5, 256)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotOpen)), "%+v", err)
+
+	_, err = addTo(3, 6, 256)
+	require.True(t, xerrors.As(err, new(*ErrSectorUnknown)), "%+v", err)
+
+	// the last piece fills the sector
+	offset, err = addTo(1, 7, 1024)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1024), offset)
+	require.NotContains(t, h.m.unsealedInfos, abi.SectorNumber(1))
+}
+
+func TestStartPackingSendFails(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	sid, _ := h.addDeal(1, 256)
+	require.Equal(t, []abi.PaddedPieceSize{256}, h.waitPieces(sid, 1))
+
+	// stopped state machines don't take events anymore
+	require.NoError(t, h.m.Stop(context.Background()))
+	require.Error(t, h.m.StartPacking(sid))
+
+	h.m.unsealedLk.Lock()
+	defer h.m.unsealedLk.Unlock()
+	require.Contains(t, h.m.unsealedInfos, sid)
+	require.Equal(t, abi.PaddedPieceSize(256), h.m.unsealedInfos[sid].stored)
+}
+
+func TestAddPieceConcurrent(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.sealer.addPiece = func(ctx context.Context, sector abi.SectorID, size abi.UnpaddedPieceSize) {
+		time.Sleep(time.Millisecond)
+	}
+
+	type placement struct {
+		sid    abi.SectorNumber
+		offset uint64
+	}
+
+	sizes := []abi.PaddedPieceSize{128, 256, 512, 1024, 2048}
+
+	var lk sync.Mutex
+	placed := map[abi.DealID]placement{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		id, size := abi.DealID(i+1), sizes[i%len(sizes)]
+		h.setZeroDeal(id, size)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sid, offset, err := h.m.AddPieceToAnySector(context.Background(), size.Unpadded(), bytes.NewReader(make([]byte, size.Unpadded())), DealInfo{DealID: id})
+			if err != nil {
+				t.Errorf("adding deal %d: %+v", id, err)
+				return
+			}
+
+			lk.Lock()
+			placed[id] = placement{sid, offset}
+			lk.Unlock()
+		}()
+	}
+	wg.Wait()
+	require.Len(t, placed, 40)
+
+	sectors := map[abi.SectorNumber]int{}
+	for _, p := range placed {
+		sectors[p.sid]++
+	}
+
+	// the pieces recorded by the sectors match the returned offsets
+	for sn, deals := range sectors {
+		var si SectorInfo
+		require.Eventually(t, func() bool {
+			si = h.sector(sn)
+			return len(si.dealIDs()) == deals
+		}, 5*time.Second, 5*time.Millisecond)
+
+		var offset abi.PaddedPieceSize
+		for _, p := range si.Pieces {
+			require.Zero(t, offset%p.Piece.Size)
+			if p.DealInfo != nil {
+				require.Equal(t, placement{sn, uint64(offset)}, placed[p.DealInfo.DealID])
+			}
+			offset += p.Piece.Size
+		}
+		require.LessOrEqual(t, uint64(offset), uint64(h.sealer.SectorSize()))
+	}
+
+	h.m.unsealedLk.Lock()
+	defer h.m.unsealedLk.Unlock()
+	for sn, ui := range h.m.unsealedInfos {
+		require.Nil(t, ui.lastWrite, "sector %d", sn)
+
+		require.Eventually(t, func() bool {
+			si := h.sector(sn)
+			restored, err := si.unsealedInfo(time.Now())
+			return err == nil && restored.stored == ui.stored && reflect.DeepEqual(restored.pieceSizes, ui.pieceSizes)
+		}, 5*time.Second, 5*time.Millisecond, "sector %d", sn)
+	}
+}
+
+func TestAddPieceFailedWrite(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	sealing := make(chan []abi.PieceInfo, 1)
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		sealing <- pieces
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	h.addDeal(1, 256)
+
+	h.sealer.addPieceErr = xerrors.New("disk on fire")
+	h.setZeroDeal(2, 512)
+	_, _, err := h.m.AddPieceToAnySector(context.Background(), 508, bytes.NewReader(make([]byte, 508)), DealInfo{DealID: 2})
+	require.Error(t, err)
+	h.sealer.addPieceErr = nil
+
+	// sealed with what was written before
+	pieces := <-sealing
+	require.Equal(t, abi.PaddedPieceSize(256), pieces[0].Size)
+	si := h.sector(1)
+	require.Equal(t, []abi.DealID{1}, si.dealIDs())
+
+	sid, offset := h.addDeal(3, 512)
+	require.Equal(t, abi.SectorNumber(2), sid)
+	require.Zero(t, offset)
+}
+
+func TestDatastorePrefix(t *testing.T) {
+	a := newTestHarness(t, Config{DatastorePrefix: "/a"})
+	b := newTestHarnessOn(t, a.ds, 1000, Config{DatastorePrefix: "/b"})
+	c := newTestHarnessOn(t, a.ds, 1001, Config{DatastorePerMiner: true})
+
+	sizes := map[*testHarness]abi.PaddedPieceSize{a: 1024, b: 512, c: 256}
+	for h, size := range sizes {
+		sid, _ := h.addDeal(1, size)
+		require.Equal(t, abi.SectorNumber(1), sid)
+		h.waitPieces(sid, 1)
+	}
+
+	for h, size := range sizes {
+		sectors, err := h.m.ListSectors()
+		require.NoError(t, err)
+		require.Len(t, sectors, 1)
+		require.Equal(t, size, sectors[0].Pieces[0].Piece.Size)
+	}
+
+	// nothing under the default namespace
+	res, err := a.ds.Query(query.Query{Prefix: SectorStorePrefix})
+	require.NoError(t, err)
+	entries, err := res.Rest()
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// restarted instances pick their own sectors back up
+	require.NoError(t, b.m.Stop(context.Background()))
+	b = newTestHarnessOn(t, a.ds, 1000, Config{DatastorePrefix: "/b"})
+	require.NoError(t, b.m.Run(context.Background()))
+	sectors, err := b.m.ListSectors()
+	require.NoError(t, err)
+	require.Len(t, sectors, 1)
+	require.Equal(t, abi.PaddedPieceSize(512), sectors[0].Pieces[0].Piece.Size)
+}
+
+func TestSectorSizes(t *testing.T) {
+	h := newTestHarness(t, Config{SectorSizes: []abi.SectorSize{8 << 20, 2048}})
+
+	small, _ := h.addDeal(1, 1024)
+	large, _ := h.addDeal(2, 4096)
+	require.NotEqual(t, small, large)
+	h.waitPieces(small, 1)
+	h.waitPieces(large, 1)
+	require.Equal(t, abi.RegisteredSealProof_StackedDrg2KiBV1, h.sector(small).SectorType)
+	require.Equal(t, abi.RegisteredSealProof_StackedDrg8MiBV1, h.sector(large).SectorType)
+
+	// pieces go to open sectors they fit in
+	sid, offset := h.addDeal(3, 512)
+	require.Equal(t, small, sid)
+	require.Equal(t, uint64(1024), offset)
+	sid, offset = h.addDeal(4, 2048)
+	require.Equal(t, large, sid)
+	require.Equal(t, uint64(4096), offset)
+
+	h.setZeroDeal(5, 2048)
+	_, err := h.m.AddPieceToSector(context.Background(), small, 2032, bytes.NewReader(make([]byte, 2032)), DealInfo{DealID: 5})
+	require.True(t, xerrors.As(err, new(*ErrSectorFull)), "%+v", err)
+
+	_, _, _, err = h.m.PlanPiece(context.Background(), abi.PaddedPieceSize(16<<20).Unpadded(), false)
+	require.True(t, xerrors.Is(err, ErrPieceTooLarge), "%+v", err)
+
+	// fillers fill the sector up to its own size
+	require.NoError(t, h.m.StartPacking(large))
+	h.waitState(large, WaitSeed)
+
+	var total abi.PaddedPieceSize
+	for _, p := range h.sector(large).Pieces {
+		total += p.Piece.Size
+	}
+	require.Equal(t, abi.PaddedPieceSize(8<<20), total)
+}
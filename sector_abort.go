@@ -0,0 +1,202 @@
+package sealing
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	statemachine "github.com/filecoin-project/go-statemachine"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// ErrSectorCommitted is returned when aborting a sector which is already
+// committed on chain
+type ErrSectorCommitted struct{ error }
+
+// states sealing can be aborted in. Sectors with a precommit on chain can be
+// aborted, losing the precommit deposit, committed ones can't
+var abortStates = map[SectorState]struct{}{
+	WaitDeals:            {},
+	Packing:              {},
+	PreCommit1:           {},
+	PreCommit2:           {},
+	WaitDisk:             {},
+	PreCommitting:        {},
+	PledgeInsufficient:   {},
+	PreCommitWait:        {},
+	CommitHold:           {},
+	WaitSeed:             {},
+	Committing:           {},
+	CommitWait:           {},
+	MessageStuck:         {},
+	MessageFailed:        {},
+	PackingFailed:        {},
+	SealPreCommit1Failed: {},
+	SealPreCommit2Failed: {},
+	PreCommitFailed:      {},
+	ComputeProofFailed:   {},
+	BadProof:             {},
+	CommitFailed:         {},
+	AbortFailed:          {},
+}
+
+// sectorJobs tracks the sealer calls in flight for each sector, so Abort can
+// cancel them
+type sectorJobs struct {
+	lk      sync.Mutex
+	next    uint64
+	cancels map[abi.SectorNumber]map[uint64]context.CancelFunc
+	aborted map[abi.SectorNumber]struct{} // calls started after Abort are cancelled right away
+}
+
+// start returns the context for a sealer call on the sector, done must be
+// called once the call returns
+func (j *sectorJobs) start(ctx context.Context, sn abi.SectorNumber) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	if _, ok := j.aborted[sn]; ok {
+		cancel()
+		return ctx, cancel
+	}
+
+	if j.cancels == nil {
+		j.cancels = map[abi.SectorNumber]map[uint64]context.CancelFunc{}
+	}
+	if j.cancels[sn] == nil {
+		j.cancels[sn] = map[uint64]context.CancelFunc{}
+	}
+	id := j.next
+	j.next++
+	j.cancels[sn][id] = cancel
+
+	return ctx, func() {
+		cancel()
+
+		j.lk.Lock()
+		defer j.lk.Unlock()
+		delete(j.cancels[sn], id)
+		if len(j.cancels[sn]) == 0 {
+			delete(j.cancels, sn)
+		}
+	}
+}
+
+// abort cancels the calls in flight for the sector, and the ones started
+// until clear is called
+func (j *sectorJobs) abort(sn abi.SectorNumber) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	if j.aborted == nil {
+		j.aborted = map[abi.SectorNumber]struct{}{}
+	}
+	j.aborted[sn] = struct{}{}
+
+	for _, cancel := range j.cancels[sn] {
+		cancel()
+	}
+}
+
+func (j *sectorJobs) clear(sn abi.SectorNumber) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	delete(j.aborted, sn)
+}
+
+// jobCtx returns the context for sealer work on the sector, cancelled when the
+// sector is aborted. done must be called once the work returns
+func (m *Sealing) jobCtx(ctx context.Context, sector SectorInfo) (jctx context.Context, done func()) {
+	jctx, done = m.jobs.start(ctx, sector.SectorNumber)
+	return sector.sealingCtx(jctx), done
+}
+
+// Abort stops sealing the sector. Sealer work in flight for it is cancelled,
+// and the partially sealed data is removed once the sector is in the Aborting
+// state. Sectors committed on chain are rejected with ErrSectorCommitted, use
+// Terminate for those
+func (m *Sealing) Abort(ctx context.Context, sid abi.SectorNumber) error {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := committedStates[si.State]; ok {
+		return &ErrSectorCommitted{xerrors.Errorf("sector %d in state %s is committed", sid, si.State)}
+	}
+	if _, ok := abortStates[si.State]; !ok {
+		return &ErrIllegalTransition{xerrors.Errorf("sector %d can't be aborted in state %s", sid, si.State)}
+	}
+
+	tok, _, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+	cs, err := m.sectorsChainState(ctx, []abi.SectorNumber{sid}, tok)
+	if err != nil {
+		return err
+	}
+	onChain := cs.Sectors[sid]
+	if onChain.Sector != nil {
+		return &ErrSectorCommitted{xerrors.Errorf("sector %d in state %s is committed on chain", sid, si.State)}
+	}
+	if onChain.PreCommit != nil {
+		log.Warnf("aborting precommitted sector %d, its precommit deposit will be lost", sid)
+	}
+
+	if si.State == WaitDeals {
+		m.unsealedLk.Lock()
+		m.closeSector(sid)
+		m.unsealedLk.Unlock()
+	}
+
+	// the event is queued behind the state handler, which only returns once
+	// the sealer work it waits for is cancelled
+	if err := m.send(sid, SectorAbort{}); err != nil {
+		return err
+	}
+	m.jobs.abort(sid)
+
+	return nil
+}
+
+// planAborting ignores events sent by sealer work or chain callbacks which were
+// still pending when the sector was aborted. Events sent from outside of state handlers aren't
+// valid in the Aborting state
+func planAborting(events []statemachine.Event, state *SectorInfo) error {
+	for _, event := range events {
+		switch e := event.User.(type) {
+		case globalMutator:
+			if e.applyGlobal(state) {
+				return nil
+			}
+		case SectorAborted:
+			state.State = Aborted
+		case SectorAbortFailed:
+			log.Warnf("sector %d got error event %T: %+v", state.SectorNumber, e, e)
+			state.State = AbortFailed
+		default:
+			if _, guarded := guardedTransitions[reflect.TypeOf(e)]; guarded {
+				return xerrors.Errorf("planner for state %s received unexpected event %T (%+v)", state.State, e, event)
+			}
+			log.Warnf("sector %d: ignoring %T sent before it was aborted", state.SectorNumber, e)
+		}
+	}
+
+	return nil
+}
+
+func (m *Sealing) handleAborting(ctx statemachine.Context, sector SectorInfo) error {
+	m.forgetCommitDeadline(sector.SectorNumber)
+
+	if err := m.sealer.Remove(ctx.Context(), m.minerSector(sector.SectorNumber)); err != nil {
+		return ctx.Send(SectorAbortFailed{xerrors.Errorf("removing sector data: %w", err)})
+	}
+
+	return ctx.Send(SectorAborted{})
+}
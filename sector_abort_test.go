@@ -0,0 +1,101 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+func TestAbortCancelsSealing(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.setHead(2000)
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		close(started)
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return nil, ctx.Err()
+	}
+
+	var lk sync.Mutex
+	var removed []abi.SectorNumber
+	h.sealer.remove = func(sector abi.SectorID) error {
+		lk.Lock()
+		defer lk.Unlock()
+		removed = append(removed, sector.Number)
+		return nil
+	}
+
+	h.start(SectorInfo{
+		State:        PreCommit1,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+	})
+	<-started
+
+	require.NoError(t, h.m.Abort(context.Background(), 1))
+	require.Equal(t, context.Canceled, <-cancelled)
+
+	h.waitState(1, Aborted)
+	lk.Lock()
+	require.Equal(t, []abi.SectorNumber{1}, removed)
+	lk.Unlock()
+}
+
+func TestAbortRetry(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	var lk sync.Mutex
+	fail := true
+	h.sealer.remove = func(sector abi.SectorID) error {
+		lk.Lock()
+		defer lk.Unlock()
+		if fail {
+			return xerrors.New("storage offline")
+		}
+		return nil
+	}
+
+	h.put(SectorInfo{State: SealPreCommit2Failed, SectorNumber: 1})
+	require.NoError(t, h.m.Abort(context.Background(), 1))
+	h.waitState(1, AbortFailed)
+
+	lk.Lock()
+	fail = false
+	lk.Unlock()
+	require.NoError(t, h.m.Abort(context.Background(), 1))
+	h.waitState(1, Aborted)
+}
+
+func TestAbortCommittedRejected(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.put(SectorInfo{State: Proving, SectorNumber: 1})
+	h.put(SectorInfo{State: CommitWait, SectorNumber: 2})
+	h.put(SectorInfo{State: Removed, SectorNumber: 3})
+
+	h.api.lk.Lock()
+	h.api.sectors[2] = &miner.SectorOnChainInfo{}
+	h.api.lk.Unlock()
+
+	err := h.m.Abort(context.Background(), 1)
+	require.True(t, xerrors.As(err, new(*ErrSectorCommitted)), "%+v", err)
+
+	// the commit landed before the sector saw it
+	err = h.m.Abort(context.Background(), 2)
+	require.True(t, xerrors.As(err, new(*ErrSectorCommitted)), "%+v", err)
+
+	err = h.m.Abort(context.Background(), 3)
+	require.True(t, xerrors.As(err, new(*ErrIllegalTransition)), "%+v", err)
+
+	require.Equal(t, Proving, h.sector(1).State)
+	require.Equal(t, CommitWait, h.sector(2).State)
+}
@@ -0,0 +1,258 @@
+package sealing
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// SectorNumberReservation is a sector number handed out by Reserve. It has to
+// be committed once the sector is created, or aborted if creating it failed
+type SectorNumberReservation interface {
+	Number() abi.SectorNumber
+
+	Commit() error
+	// Abort makes the number available to the next Reserve call
+	Abort() error
+}
+
+// ReservingSectorIDCounter is a SectorIDCounter which can give back numbers
+// of sectors which were never created. Counters only implementing Next are
+// wrapped with a default implementation, see reservingCounter
+type ReservingSectorIDCounter interface {
+	SectorIDCounter
+
+	Reserve() (SectorNumberReservation, error)
+}
+
+// reservingCounter keeps aborted numbers in memory and hands them out before
+// asking the underlying counter for new ones. Aborted numbers which weren't
+// reused before a restart are skipped, like all failed sectors were before
+type reservingCounter struct {
+	sc SectorIDCounter
+
+	lk   sync.Mutex
+	free []abi.SectorNumber // sorted
+}
+
+func newReservingCounter(sc SectorIDCounter) ReservingSectorIDCounter {
+	if rsc, ok := sc.(ReservingSectorIDCounter); ok {
+		return rsc
+	}
+
+	return &reservingCounter{sc: sc}
+}
+
+func (c *reservingCounter) Next() (abi.SectorNumber, error) {
+	res, err := c.Reserve()
+	if err != nil {
+		return 0, err
+	}
+	return res.Number(), res.Commit()
+}
+
+func (c *reservingCounter) Reserve() (SectorNumberReservation, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if len(c.free) > 0 {
+		sn := c.free[0]
+		c.free = c.free[1:]
+		return &numberReservation{c: c, sn: sn}, nil
+	}
+
+	sn, err := c.sc.Next()
+	if err != nil {
+		return nil, err
+	}
+	return &numberReservation{c: c, sn: sn}, nil
+}
+
+func (c *reservingCounter) release(sn abi.SectorNumber) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	i := sort.Search(len(c.free), func(i int) bool {
+		return c.free[i] >= sn
+	})
+	c.free = append(c.free, 0)
+	copy(c.free[i+1:], c.free[i:])
+	c.free[i] = sn
+}
+
+type numberReservation struct {
+	c  *reservingCounter
+	sn abi.SectorNumber
+
+	lk   sync.Mutex
+	done bool
+}
+
+func (r *numberReservation) Number() abi.SectorNumber {
+	return r.sn
+}
+
+func (r *numberReservation) finish() error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if r.done {
+		return xerrors.Errorf("sector number %d reservation already committed or aborted", r.sn)
+	}
+	r.done = true
+	return nil
+}
+
+func (r *numberReservation) Commit() error {
+	return r.finish()
+}
+
+func (r *numberReservation) Abort() error {
+	if err := r.finish(); err != nil {
+		return err
+	}
+
+	r.c.release(r.sn)
+	return nil
+}
+
+// SectorCounterKey is where DatastoreSectorIDCounter keeps the next sector
+// number, next to SectorStorePrefix
+const SectorCounterKey = "/sector-counter"
+
+// ReconcilingSectorIDCounter is a SectorIDCounter which can skip sector
+// numbers already in use. Run calls Reconcile with the highest sector number
+// in the sector records, and ImportSector with the imported one, so that
+// numbers of restored or imported sectors aren't handed out again
+type ReconcilingSectorIDCounter interface {
+	SectorIDCounter
+
+	// Reconcile makes sure numbers up to and including used aren't handed
+	// out anymore
+	Reconcile(used abi.SectorNumber) error
+}
+
+// PeekingSectorIDCounter is a SectorIDCounter which can tell the number it
+// hands out next without using it up. ExportState exports that number
+type PeekingSectorIDCounter interface {
+	SectorIDCounter
+
+	Peek() (abi.SectorNumber, error)
+}
+
+// DatastoreSectorIDCounter keeps the next sector number in a datastore. Keep
+// it in the datastore of the sector records, see NewDatastoreSectorIDCounter,
+// so that both are backed up and restored together. Numbers start at 0
+type DatastoreSectorIDCounter struct {
+	ds  datastore.Datastore
+	key datastore.Key
+
+	lk sync.Mutex
+}
+
+// NewDatastoreSectorIDCounter creates a counter stored under SectorCounterKey,
+// in the namespace the sector records of a Sealing created with the same
+// datastore, miner and Config are kept in
+func NewDatastoreSectorIDCounter(ds datastore.Datastore, maddr address.Address, cfg Config) *DatastoreSectorIDCounter {
+	return &DatastoreSectorIDCounter{
+		ds:  ds,
+		key: datastoreRoot(cfg, maddr).Child(datastore.NewKey(SectorCounterKey)),
+	}
+}
+
+// Next hands out the next sector number
+func (c *DatastoreSectorIDCounter) Next() (abi.SectorNumber, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	next, err := c.get()
+	if err != nil {
+		return 0, err
+	}
+	if err := c.put(next + 1); err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// Peek returns the number the next call to Next hands out
+func (c *DatastoreSectorIDCounter) Peek() (abi.SectorNumber, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	return c.get()
+}
+
+func (c *DatastoreSectorIDCounter) Reconcile(used abi.SectorNumber) error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	next, err := c.get()
+	if err != nil {
+		return err
+	}
+	if next > used {
+		return nil
+	}
+
+	log.Warnf("sector number %d is already used, advancing the sector counter from %d", used, next)
+	return c.put(used + 1)
+}
+
+func (c *DatastoreSectorIDCounter) get() (abi.SectorNumber, error) {
+	b, err := c.ds.Get(c.key)
+	if err == datastore.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("reading sector counter: %w", err)
+	}
+
+	next, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, xerrors.Errorf("decoding sector counter %x", b)
+	}
+	return abi.SectorNumber(next), nil
+}
+
+func (c *DatastoreSectorIDCounter) put(next abi.SectorNumber) error {
+	b := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(b, uint64(next))
+	if err := c.ds.Put(c.key, b[:n]); err != nil {
+		return xerrors.Errorf("writing sector counter: %w", err)
+	}
+	return nil
+}
+
+var _ ReconcilingSectorIDCounter = &DatastoreSectorIDCounter{}
+var _ PeekingSectorIDCounter = &DatastoreSectorIDCounter{}
+
+// reconcileSectorCounter makes the counter skip the numbers of all sectors
+// in the sector records
+func (m *Sealing) reconcileSectorCounter() error {
+	if m.reconciler == nil {
+		return nil
+	}
+
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return xerrors.Errorf("listing sectors: %w", err)
+	}
+	if len(sectors) == 0 {
+		return nil
+	}
+
+	var highest abi.SectorNumber
+	for _, si := range sectors {
+		if si.SectorNumber > highest {
+			highest = si.SectorNumber
+		}
+	}
+	return m.reconciler.Reconcile(highest)
+}
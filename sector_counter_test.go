@@ -0,0 +1,132 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestReservingCounter(t *testing.T) {
+	sc := newReservingCounter(&fakeCounter{})
+
+	r1, err := sc.Reserve()
+	require.NoError(t, err)
+	r2, err := sc.Reserve()
+	require.NoError(t, err)
+	r3, err := sc.Reserve()
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(3), r3.Number())
+
+	require.NoError(t, r1.Commit())
+	require.NoError(t, r3.Abort())
+	require.NoError(t, r2.Abort())
+
+	require.Error(t, r1.Abort())
+	require.Error(t, r2.Commit())
+
+	// aborted numbers come back lowest first, before new ones
+	for _, expect := range []abi.SectorNumber{2, 3, 4} {
+		sn, err := sc.Next()
+		require.NoError(t, err)
+		require.Equal(t, expect, sn)
+	}
+
+	// already reserving counters aren't wrapped again
+	require.Equal(t, sc, newReservingCounter(sc))
+}
+
+func TestNewSectorFailureReusesNumber(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.sealer.newSector = func(ctx context.Context, sector abi.SectorID) error {
+		return xerrors.New("no space left")
+	}
+
+	_, _, err := h.m.AddPieceToAnySector(context.Background(), 1016, bytes.NewReader(make([]byte, 1016)), DealInfo{DealID: 1})
+	require.Error(t, err)
+
+	h.sealer.newSector = nil
+	sid, _ := h.addDeal(1, 1024)
+	require.Equal(t, abi.SectorNumber(1), sid)
+}
+
+func TestPledgeNewSectorFailureReusesNumber(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.sealer.newSector = func(ctx context.Context, sector abi.SectorID) error {
+		return xerrors.New("no space left")
+	}
+
+	_, err := h.m.PledgeSector(context.Background())
+	require.Error(t, err)
+
+	h.sealer.newSector = nil
+	sid, _ := h.addDeal(1, 1024)
+	require.Equal(t, abi.SectorNumber(1), sid)
+}
+
+func TestDatastoreSectorIDCounter(t *testing.T) {
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	ds := datastore.NewMapDatastore()
+	sc := NewDatastoreSectorIDCounter(ds, maddr, Config{})
+
+	for _, expect := range []abi.SectorNumber{0, 1, 2} {
+		peek, err := sc.Peek()
+		require.NoError(t, err)
+		require.Equal(t, expect, peek)
+
+		sn, err := sc.Next()
+		require.NoError(t, err)
+		require.Equal(t, expect, sn)
+	}
+
+	// lower numbers don't move it back
+	require.NoError(t, sc.Reconcile(1))
+	require.NoError(t, sc.Reconcile(7))
+
+	// the number is persisted
+	sn, err := NewDatastoreSectorIDCounter(ds, maddr, Config{}).Next()
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(8), sn)
+
+	// counters of other namespaces are separate
+	sn, err = NewDatastoreSectorIDCounter(ds, maddr, Config{DatastorePrefix: "/other"}).Next()
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(0), sn)
+}
+
+func TestSectorCounterReconciledOnStart(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	sc := NewDatastoreSectorIDCounter(h.ds, h.m.maddr, Config{})
+	for i := 0; i < 3; i++ {
+		_, err := sc.Next()
+		require.NoError(t, err)
+	}
+
+	// restored sector records with higher numbers than the counter
+	h.put(SectorInfo{SectorNumber: 1, State: Proving})
+	h.put(SectorInfo{SectorNumber: 12, State: Proving})
+
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+	h.m = NewWithConfig(h.api, h.api, h.m.maddr, h.ds, h.sealer, sc, fakeVerifier{}, &pcp, Config{})
+	require.NoError(t, h.m.Run(context.Background()))
+
+	next, err := sc.Peek()
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(13), next)
+
+	// imported sectors are skipped as well
+	h.api.setHead(2000)
+	require.NoError(t, h.m.ImportSector(context.Background(), importInfo(20)))
+	next, err = sc.Peek()
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(21), next)
+}
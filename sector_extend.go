@@ -0,0 +1,91 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+// ErrSectorNotCommitted is returned when extending a sector which isn't
+// committed, or not anymore
+type ErrSectorNotCommitted struct{ error }
+
+// ErrBadExpiration is returned by ExtendSectorExpiration for sectors which
+// already expired, and for expirations the miner actor would reject
+type ErrBadExpiration struct{ error }
+
+// ExtendSectorExpiration moves the expiration of a committed sector to
+// newExpiration, e.g. to keep proving a sector whose deals all ended as
+// committed capacity. It waits for the message to land, and records the new
+// expiration in the PreCommitInfo of the sector. newExpiration has to be past
+// the current expiration, and within MaxSectorLifetime from the chain head
+func (m *Sealing) ExtendSectorExpiration(ctx context.Context, sid abi.SectorNumber, newExpiration abi.ChainEpoch) error {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return err
+	}
+	if _, ok := committedStates[si.State]; !ok {
+		return &ErrSectorNotCommitted{xerrors.Errorf("sector %d is %s, only committed sectors can be extended", sid, si.State)}
+	}
+
+	tok, height, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	onChain, err := m.api.StateSectorGetInfo(ctx, m.maddr, sid, tok)
+	if err != nil {
+		return xerrors.Errorf("getting on chain sector info: %w", err)
+	}
+	if onChain == nil {
+		return &ErrSectorNotCommitted{xerrors.Errorf("sector %d isn't on chain", sid)}
+	}
+
+	expiration := onChain.Info.Expiration
+	switch {
+	case expiration <= height:
+		return &ErrBadExpiration{xerrors.Errorf("sector %d expired at %d, head %d", sid, expiration, height)}
+	case newExpiration <= expiration:
+		return &ErrBadExpiration{xerrors.Errorf("new expiration %d of sector %d isn't past its expiration %d", newExpiration, sid, expiration)}
+	case newExpiration > height+MaxSectorLifetime:
+		return &ErrBadExpiration{xerrors.Errorf("new expiration %d of sector %d is more than %d epochs past head %d", newExpiration, sid, MaxSectorLifetime, height)}
+	}
+
+	waddr, err := m.api.StateMinerWorkerAddress(ctx, m.maddr, tok)
+	if err != nil {
+		return xerrors.Errorf("getting worker address: %w", err)
+	}
+
+	enc := new(bytes.Buffer)
+	params := &miner.ExtendSectorExpirationParams{
+		SectorNumber:  sid,
+		NewExpiration: newExpiration,
+	}
+	if err := params.MarshalCBOR(enc); err != nil {
+		return xerrors.Errorf("could not serialize extend expiration parameters: %w", err)
+	}
+
+	m.sectorLog(sid).Infof("extending expiration of sector %d from %d to %d", sid, expiration, newExpiration)
+	gasPrice, gasLimit := m.messageGas(ctx, waddr, m.maddr, builtin.MethodsMiner.ExtendSectorExpiration, big.NewInt(0), enc.Bytes())
+	mcid, err := m.api.SendMsg(ctx, waddr, m.maddr, builtin.MethodsMiner.ExtendSectorExpiration, big.NewInt(0), gasPrice, gasLimit, enc.Bytes())
+	if err != nil {
+		return xerrors.Errorf("pushing message to mpool: %w", err)
+	}
+
+	mw, err := m.api.StateWaitMsg(ctx, mcid)
+	if err != nil {
+		return xerrors.Errorf("waiting for extend expiration message %s: %w", mcid, err)
+	}
+	if mw.Receipt.ExitCode != exitcode.Ok {
+		return xerrors.Errorf("extending expiration of sector %d failed (exit %d)", sid, mw.Receipt.ExitCode)
+	}
+
+	return m.send(sid, SectorExpirationExtended{Expiration: newExpiration})
+}
@@ -0,0 +1,72 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// extendableSector puts a proving sector expiring at 1000, with the chain at
+// head
+func extendableSector(h *testHarness, head abi.ChainEpoch) {
+	pci := miner.SectorPreCommitInfo{SectorNumber: 1, Expiration: 1000}
+
+	h.api.setHead(head)
+	h.api.lk.Lock()
+	h.api.sectors[1] = &miner.SectorOnChainInfo{Info: pci}
+	h.api.lk.Unlock()
+
+	h.put(SectorInfo{State: Proving, SectorNumber: 1, PreCommitInfo: &pci})
+}
+
+func TestExtendSectorExpiration(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	extendableSector(h, 100)
+
+	require.NoError(t, h.m.ExtendSectorExpiration(context.Background(), 1, 5000))
+
+	msgs := h.api.sentMsgs()
+	require.Len(t, msgs, 1)
+	require.Equal(t, builtin.MethodsMiner.ExtendSectorExpiration, msgs[0].method)
+	var params miner.ExtendSectorExpirationParams
+	require.NoError(t, params.UnmarshalCBOR(bytes.NewReader(msgs[0].params)))
+	require.Equal(t, abi.SectorNumber(1), params.SectorNumber)
+	require.Equal(t, abi.ChainEpoch(5000), params.NewExpiration)
+
+	require.Eventually(t, func() bool {
+		return h.sector(1).PreCommitInfo.Expiration == 5000
+	}, 5*time.Second, 5*time.Millisecond)
+	require.Equal(t, Proving, h.sector(1).State)
+}
+
+func TestExtendSectorExpirationInvalid(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	extendableSector(h, 100)
+	ctx := context.Background()
+
+	for _, exp := range []abi.ChainEpoch{900, 1000, 100 + MaxSectorLifetime + 1} {
+		err := h.m.ExtendSectorExpiration(ctx, 1, exp)
+		require.True(t, xerrors.As(err, new(*ErrBadExpiration)), "%d: %+v", exp, err)
+	}
+
+	// expired already
+	h.api.setHead(1000)
+	err := h.m.ExtendSectorExpiration(ctx, 1, 5000)
+	require.True(t, xerrors.As(err, new(*ErrBadExpiration)), "%+v", err)
+
+	// not committed yet
+	h.put(SectorInfo{State: WaitSeed, SectorNumber: 2})
+	err = h.m.ExtendSectorExpiration(ctx, 2, 5000)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotCommitted)), "%+v", err)
+
+	require.Empty(t, h.api.sentMsgs())
+	require.Equal(t, abi.ChainEpoch(1000), h.sector(1).PreCommitInfo.Expiration)
+}
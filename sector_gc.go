@@ -0,0 +1,112 @@
+package sealing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+const defaultGCAge = 24 * time.Hour
+
+// sector states GarbageCollect removes sectors from, if Config.GCStates isn't
+// set. Sectors in these states don't move on without user action
+var defaultGCStates = []SectorState{
+	FailedUnrecoverable,
+	PackingFailed,
+	DealsExpired,
+	DataCommitmentMismatch,
+	ExpiredPreCommit,
+	RemoveFailed,
+	Removed,
+	AbortFailed,
+	Aborted,
+}
+
+// GarbageCollect removes sectors which are in one of Config.GCStates for at
+// least Config.GCAge. Their files are removed by the sealer, and their state is
+// deleted from the datastore. Sectors with a precommit or commitment on chain
+// are kept. Returns the removed sectors, also when removing one of them failed
+func (m *Sealing) GarbageCollect(ctx context.Context) ([]abi.SectorNumber, error) {
+	states := m.cfg.GCStates
+	if states == nil {
+		states = defaultGCStates
+	}
+	eligible := map[SectorState]bool{}
+	for _, st := range states {
+		eligible[st] = true
+	}
+
+	age := m.cfg.GCAge
+	if age == 0 {
+		age = defaultGCAge
+	}
+	cutoff := uint64(m.clock.Now().Add(-age).Unix())
+
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return nil, xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	var candidates []abi.SectorNumber
+	infos := map[abi.SectorNumber]SectorInfo{}
+	for _, si := range sectors {
+		if !eligible[si.State] {
+			continue
+		}
+		if len(si.Log) > 0 && si.Log[len(si.Log)-1].Timestamp > cutoff {
+			continue
+		}
+
+		candidates = append(candidates, si.SectorNumber)
+		infos[si.SectorNumber] = si
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	tok, _, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	cs, err := m.sectorsChainState(ctx, candidates, tok)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []abi.SectorNumber
+	for _, sn := range candidates {
+		if onChain := cs.Sectors[sn]; onChain.PreCommit != nil || onChain.Sector != nil {
+			log.Warnf("not garbage collecting sector %d (%s), it is precommitted or committed on chain", sn, infos[sn].State)
+			continue
+		}
+
+		si := infos[sn]
+		if si.State != Removed && si.State != Aborted {
+			if err := m.sealer.Remove(ctx, m.minerSector(sn)); err != nil {
+				return removed, xerrors.Errorf("removing files of sector %d: %w", sn, err)
+			}
+		}
+
+		if err := m.ds.Delete(datastore.NewKey(fmt.Sprint(uint64(sn)))); err != nil {
+			return removed, xerrors.Errorf("deleting state of sector %d: %w", sn, err)
+		}
+		if err := m.dropJournal(sn); err != nil {
+			log.Warnf("dropping event journal of garbage collected sector %d: %+v", sn, err)
+		}
+
+		m.counts.removed(si.State)
+		si.State = Removed
+		m.pieces.update(&si)
+
+		log.Infow("garbage collected sector", "sector", sn, "state", infos[sn].State)
+		removed = append(removed, sn)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,109 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// putAged stores a sector whose last state change was `age` ago
+func (h *testHarness) putAged(clk *fakeClock, sn abi.SectorNumber, state SectorState, age time.Duration) {
+	h.put(SectorInfo{
+		State:        state,
+		SectorNumber: sn,
+		Log:          []Log{{Timestamp: uint64(clk.Now().Add(-age).Unix())}},
+	})
+}
+
+// recordRemoves makes the sealer record the sectors whose files are removed
+func recordRemoves(h *testHarness) *[]abi.SectorNumber {
+	var removed []abi.SectorNumber
+	h.sealer.remove = func(sector abi.SectorID) error {
+		removed = append(removed, sector.Number)
+		return nil
+	}
+	return &removed
+}
+
+func TestGarbageCollect(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk})
+	files := recordRemoves(h)
+
+	h.putAged(clk, 1, PackingFailed, 48*time.Hour)
+	h.putAged(clk, 2, DealsExpired, time.Hour) // too recent
+	h.putAged(clk, 3, Proving, 48*time.Hour)
+	h.putAged(clk, 4, PackingFailed, 48*time.Hour)
+	h.putAged(clk, 5, Removed, 48*time.Hour)
+	h.put(SectorInfo{State: FailedUnrecoverable, SectorNumber: 6})
+
+	h.api.lk.Lock()
+	h.api.precommits[4] = &miner.SectorPreCommitOnChainInfo{PreCommitEpoch: 10}
+	h.api.lk.Unlock()
+
+	removed, err := h.m.GarbageCollect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []abi.SectorNumber{1, 5, 6}, removed)
+	require.Equal(t, []abi.SectorNumber{1, 6}, *files) // files of Removed sectors are gone already
+
+	sectors, err := h.m.ListSectors()
+	require.NoError(t, err)
+	var left []abi.SectorNumber
+	for _, si := range sectors {
+		left = append(left, si.SectorNumber)
+	}
+	require.Equal(t, []abi.SectorNumber{2, 3, 4}, left)
+
+	_, err = h.m.GetSectorInfo(1)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)))
+
+	// sector 2 is old enough a day later
+	clk.advance(24 * time.Hour)
+	removed, err = h.m.GarbageCollect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []abi.SectorNumber{2}, removed)
+}
+
+func TestGarbageCollectConfig(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk, GCAge: time.Minute, GCStates: []SectorState{DealsExpired}})
+	files := recordRemoves(h)
+
+	h.putAged(clk, 1, PackingFailed, 48*time.Hour)
+	h.putAged(clk, 2, DealsExpired, time.Hour)
+
+	removed, err := h.m.GarbageCollect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []abi.SectorNumber{2}, removed)
+	require.Equal(t, []abi.SectorNumber{2}, *files)
+	require.Equal(t, PackingFailed, h.sector(1).State)
+}
+
+func TestGarbageCollectRemoveFailed(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk})
+	h.sealer.remove = func(sector abi.SectorID) error {
+		if sector.Number == 2 {
+			return xerrors.New("storage offline")
+		}
+		return nil
+	}
+
+	h.putAged(clk, 1, PackingFailed, 48*time.Hour)
+	h.putAged(clk, 2, PackingFailed, 48*time.Hour)
+	h.putAged(clk, 3, PackingFailed, 48*time.Hour)
+
+	removed, err := h.m.GarbageCollect(context.Background())
+	require.Error(t, err)
+	require.Equal(t, []abi.SectorNumber{1}, removed)
+
+	// kept for the next run
+	require.Equal(t, PackingFailed, h.sector(2).State)
+	require.Equal(t, PackingFailed, h.sector(3).State)
+}
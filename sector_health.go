@@ -0,0 +1,168 @@
+package sealing
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// SealingAPIFaults can be implemented by the SealingAPI passed to New, to report
+// the faulty sectors of the miner. Without it, CheckSector doesn't compare
+// fault states
+type SealingAPIFaults interface {
+	StateMinerFaults(ctx context.Context, maddr address.Address, tok TipSetToken) (*abi.BitField, error)
+}
+
+// DiscrepancyKind identifies a mismatch between the local state of a sector and
+// its on-chain state
+type DiscrepancyKind string
+
+const (
+	// the sector is committed locally, but not on chain
+	DiscrepancyNotOnChain DiscrepancyKind = "NotOnChain"
+	// the sector is waiting to be committed, but isn't precommitted on chain
+	DiscrepancyPreCommitMissing DiscrepancyKind = "PreCommitMissing"
+	// the sector is precommitted or committed on chain, but wasn't precommitted
+	// locally
+	DiscrepancyUnexpectedOnChain DiscrepancyKind = "UnexpectedOnChain"
+	// the on-chain sealed CID differs from the local CommR
+	DiscrepancySealedCID DiscrepancyKind = "SealedCIDMismatch"
+	// the sector is faulty on chain, but proving locally
+	DiscrepancyFaultNotTracked DiscrepancyKind = "FaultNotTracked"
+	// the fault of the sector was declared locally, but the sector isn't
+	// faulty on chain
+	DiscrepancyFaultNotOnChain DiscrepancyKind = "FaultNotOnChain"
+)
+
+// SectorDiscrepancy is a mismatch found by CheckSector
+type SectorDiscrepancy struct {
+	Kind   DiscrepancyKind
+	Detail string
+}
+
+// SectorHealth compares the local state of a sector to its on-chain state
+type SectorHealth struct {
+	SectorNumber abi.SectorNumber
+	State        SectorState
+
+	PreCommitted bool // precommit on chain
+	Committed    bool // sector info on chain
+	Faulty       bool // faulty on chain, only checked if the API implements SealingAPIFaults
+
+	Discrepancies []SectorDiscrepancy
+}
+
+// Healthy is true if no discrepancies were found
+func (h SectorHealth) Healthy() bool {
+	return len(h.Discrepancies) == 0
+}
+
+// states in which the precommit can't have landed yet
+var notPreCommittedStates = map[SectorState]struct{}{
+	WaitDeals:            {},
+	Packing:              {},
+	PreCommit1:           {},
+	PreCommit2:           {},
+	WaitDisk:             {},
+	PledgeInsufficient:   {},
+	PackingFailed:        {},
+	SealPreCommit1Failed: {},
+	SealPreCommit2Failed: {},
+}
+
+// states in which the precommit landed, and the commit wasn't sent yet
+var preCommittedStates = map[SectorState]struct{}{
+	CommitHold: {},
+	WaitSeed:   {},
+	Committing: {},
+}
+
+// states of sectors declared faulty on chain
+var faultDeclaredStates = map[SectorState]struct{}{
+	FaultReported: {},
+	FaultedFinal:  {},
+}
+
+func isCommittedState(st SectorState) bool {
+	if st == FinalizeSector || st == FinalizeFailed || st == UnsealedCorrupt {
+		return true
+	}
+	_, ok := committedStates[st]
+	return ok
+}
+
+// CheckSector compares the local state of the sector with its precommit and
+// sector info on chain, and reports the discrepancies found. Nothing is changed
+func (m *Sealing) CheckSector(ctx context.Context, sid abi.SectorNumber) (SectorHealth, error) {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return SectorHealth{}, err
+	}
+
+	tok, _, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return SectorHealth{}, xerrors.Errorf("getting chain head: %w", err)
+	}
+	cs, err := m.sectorsChainState(ctx, []abi.SectorNumber{sid}, tok)
+	if err != nil {
+		return SectorHealth{}, err
+	}
+	onChain := cs.Sectors[sid]
+
+	out := SectorHealth{
+		SectorNumber: sid,
+		State:        si.State,
+		PreCommitted: onChain.PreCommit != nil,
+		Committed:    onChain.Sector != nil,
+	}
+	report := func(kind DiscrepancyKind, format string, args ...interface{}) {
+		out.Discrepancies = append(out.Discrepancies, SectorDiscrepancy{
+			Kind:   kind,
+			Detail: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if _, ok := notPreCommittedStates[si.State]; ok && (out.PreCommitted || out.Committed) {
+		report(DiscrepancyUnexpectedOnChain, "sector in state %s is on chain (precommitted: %t, committed: %t)", si.State, out.PreCommitted, out.Committed)
+	}
+	if _, ok := preCommittedStates[si.State]; ok && !out.PreCommitted && !out.Committed {
+		report(DiscrepancyPreCommitMissing, "sector in state %s has no precommit on chain", si.State)
+	}
+	if isCommittedState(si.State) && !out.Committed {
+		report(DiscrepancyNotOnChain, "sector in state %s has no sector info on chain", si.State)
+	}
+
+	if si.CommR != nil {
+		if onChain.PreCommit != nil && onChain.PreCommit.Info.SealedCID != *si.CommR {
+			report(DiscrepancySealedCID, "precommitted sealed CID %s differs from CommR %s", onChain.PreCommit.Info.SealedCID, *si.CommR)
+		}
+		if onChain.Sector != nil && onChain.Sector.Info.SealedCID != *si.CommR {
+			report(DiscrepancySealedCID, "committed sealed CID %s differs from CommR %s", onChain.Sector.Info.SealedCID, *si.CommR)
+		}
+	}
+
+	if m.faults != nil && out.Committed {
+		faults, err := m.faults.StateMinerFaults(ctx, m.maddr, tok)
+		if err != nil {
+			return SectorHealth{}, xerrors.Errorf("getting miner faults: %w", err)
+		}
+		out.Faulty, err = faults.IsSet(uint64(sid))
+		if err != nil {
+			return SectorHealth{}, xerrors.Errorf("checking miner faults: %w", err)
+		}
+
+		_, declared := faultDeclaredStates[si.State]
+		switch {
+		case out.Faulty && si.State == Proving:
+			report(DiscrepancyFaultNotTracked, "sector is faulty on chain, but in state %s", si.State)
+		case !out.Faulty && declared:
+			report(DiscrepancyFaultNotOnChain, "sector in state %s isn't faulty on chain", si.State)
+		}
+	}
+
+	return out, nil
+}
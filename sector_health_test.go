@@ -0,0 +1,122 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// faultsAPI reports the given sectors as faulty
+type faultsAPI struct {
+	*fakeAPI
+
+	faulty []abi.SectorNumber
+}
+
+func (a *faultsAPI) StateMinerFaults(ctx context.Context, maddr address.Address, tok TipSetToken) (*abi.BitField, error) {
+	bf := abi.NewBitField()
+	for _, sn := range a.faulty {
+		bf.Set(uint64(sn))
+	}
+	return bf, nil
+}
+
+// healthSector stores a sector in the given state with CommR set, and returns
+// its health
+func healthSector(t *testing.T, h *testHarness, st SectorState) SectorHealth {
+	commR := testCommR
+	h.put(SectorInfo{
+		State:        st,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		CommR:        &commR,
+	})
+
+	sh, err := h.m.CheckSector(context.TODO(), 1)
+	require.NoError(t, err)
+	return sh
+}
+
+func onChainSector(h *testHarness, sn abi.SectorNumber) {
+	h.api.lk.Lock()
+	defer h.api.lk.Unlock()
+	h.api.sectors[sn] = &miner.SectorOnChainInfo{Info: miner.SectorPreCommitInfo{SectorNumber: sn, SealedCID: testCommR}}
+}
+
+func discrepancyKinds(sh SectorHealth) []DiscrepancyKind {
+	var out []DiscrepancyKind
+	for _, d := range sh.Discrepancies {
+		out = append(out, d.Kind)
+	}
+	return out
+}
+
+func TestCheckSectorHealthy(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	onChainSector(h, 1)
+
+	sh := healthSector(t, h, Proving)
+	require.True(t, sh.Healthy(), "%+v", sh.Discrepancies)
+	require.True(t, sh.Committed)
+}
+
+func TestCheckSectorNotOnChain(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	sh := healthSector(t, h, Proving)
+	require.False(t, sh.Committed)
+	require.Equal(t, []DiscrepancyKind{DiscrepancyNotOnChain}, discrepancyKinds(sh))
+}
+
+func TestCheckSectorPreCommitMissing(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	sh := healthSector(t, h, WaitSeed)
+	require.Equal(t, []DiscrepancyKind{DiscrepancyPreCommitMissing}, discrepancyKinds(sh))
+}
+
+func TestCheckSectorUnexpectedOnChain(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	onChainSector(h, 1)
+
+	sh := healthSector(t, h, PreCommit1)
+	require.Equal(t, []DiscrepancyKind{DiscrepancyUnexpectedOnChain}, discrepancyKinds(sh))
+}
+
+func TestCheckSectorSealedCIDMismatch(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.lk.Lock()
+	h.api.precommits[1] = &miner.SectorPreCommitOnChainInfo{
+		Info: miner.SectorPreCommitInfo{SectorNumber: 1, SealedCID: testCommD},
+	}
+	h.api.lk.Unlock()
+
+	sh := healthSector(t, h, WaitSeed)
+	require.True(t, sh.PreCommitted)
+	require.Equal(t, []DiscrepancyKind{DiscrepancySealedCID}, discrepancyKinds(sh))
+}
+
+func TestCheckSectorFaults(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+	api := &faultsAPI{fakeAPI: h.api, faulty: []abi.SectorNumber{1}}
+	h.m = NewWithConfig(api, h.api, h.m.maddr, h.ds, h.sealer, &fakeCounter{}, fakeVerifier{}, &pcp, Config{})
+	onChainSector(h, 1)
+
+	sh := healthSector(t, h, Proving)
+	require.True(t, sh.Faulty)
+	require.Equal(t, []DiscrepancyKind{DiscrepancyFaultNotTracked}, discrepancyKinds(sh))
+
+	sh = healthSector(t, h, Faulty)
+	require.True(t, sh.Healthy(), "%+v", sh.Discrepancies)
+
+	api.faulty = nil
+	sh = healthSector(t, h, FaultedFinal)
+	require.False(t, sh.Faulty)
+	require.Equal(t, []DiscrepancyKind{DiscrepancyFaultNotOnChain}, discrepancyKinds(sh))
+}
@@ -0,0 +1,55 @@
+package sealing
+
+import (
+	"sync"
+	"time"
+)
+
+// sectorLimiter is a token bucket pacing sector creation. It holds up to
+// `burst` tokens, refilled one per interval
+type sectorLimiter struct {
+	interval time.Duration
+	burst    int
+	clock    Clock
+
+	lk sync.Mutex
+	// time at which the bucket is full again; tokens are taken by moving it
+	// forward by one interval
+	full time.Time
+}
+
+func newSectorLimiter(perMinute int, burst int, clock Clock) *sectorLimiter {
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &sectorLimiter{
+		interval: time.Minute / time.Duration(perMinute),
+		burst:    burst,
+		clock:    clock,
+	}
+}
+
+// take takes a token if one is available, otherwise returns how long it
+// takes for the next one to be
+func (l *sectorLimiter) take() time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	now := l.clock.Now()
+	full := l.full
+	if full.Before(now) {
+		full = now
+	}
+
+	if wait := full.Sub(now) - time.Duration(l.burst-1)*l.interval; wait > 0 {
+		return wait
+	}
+
+	l.full = full.Add(l.interval)
+	return 0
+}
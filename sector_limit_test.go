@@ -0,0 +1,83 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestSectorLimiter(t *testing.T) {
+	clk := newFakeClock()
+	l := newSectorLimiter(2, 3, clk)
+
+	for i := 0; i < 3; i++ {
+		require.Zero(t, l.take(), "take %d", i)
+	}
+	require.Equal(t, 30*time.Second, l.take())
+
+	clk.advance(10 * time.Second)
+	require.Equal(t, 20*time.Second, l.take())
+
+	// one token per interval after the burst
+	clk.advance(20 * time.Second)
+	require.Zero(t, l.take())
+	require.Equal(t, 30*time.Second, l.take())
+
+	// refills up to the burst size
+	clk.advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		require.Zero(t, l.take(), "take %d", i)
+	}
+	require.NotZero(t, l.take())
+}
+
+func TestSectorsPerMinute(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk, SectorsPerMinute: 2, SectorBurst: 2})
+
+	sid, _ := h.addDeal(1, 2048)
+	require.Equal(t, abi.SectorNumber(1), sid)
+	sid, _ = h.addDeal(2, 2048)
+	require.Equal(t, abi.SectorNumber(2), sid)
+
+	add := func(deal abi.DealID) <-chan abi.SectorNumber {
+		h.setZeroDeal(deal, 2048)
+		done := make(chan abi.SectorNumber, 1)
+		go func() {
+			sid, _, err := h.m.AddPieceToAnySector(context.Background(), 2032, bytes.NewReader(make([]byte, 2032)), DealInfo{DealID: deal})
+			if err != nil {
+				t.Errorf("adding deal %d: %+v", deal, err)
+			}
+			done <- sid
+		}()
+		return done
+	}
+
+	for deal := abi.DealID(3); deal <= 4; deal++ {
+		done := add(deal)
+		clk.waitTimers(t, 1)
+
+		clk.advance(29 * time.Second)
+		select {
+		case <-done:
+			t.Fatalf("deal %d sector created over the rate limit", deal)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		clk.advance(time.Second)
+		require.Equal(t, abi.SectorNumber(deal), <-done)
+	}
+
+	// waiting gives up with the context
+	h.setZeroDeal(5, 2048)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := h.m.AddPieceToAnySector(ctx, 2032, bytes.NewReader(make([]byte, 2032)), DealInfo{DealID: 5})
+	require.True(t, xerrors.Is(err, context.DeadlineExceeded), "%+v", err)
+}
@@ -0,0 +1,21 @@
+package sealing
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// sectorLog returns a logger tagging all lines with the sector number
+func (m *Sealing) sectorLog(sid abi.SectorNumber) *zap.SugaredLogger {
+	logger := m.logger
+	if logger == nil {
+		logger = &log.SugaredLogger
+	}
+	return logger.With("sector", sid)
+}
+
+// stateLog is sectorLog also carrying the state the sector is in
+func (m *Sealing) stateLog(sector SectorInfo) *zap.SugaredLogger {
+	return m.sectorLog(sector.SectorNumber).With("state", sector.State)
+}
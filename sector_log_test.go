@@ -0,0 +1,28 @@
+package sealing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+)
+
+func TestStateLogFields(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	core, logs := observer.New(zapcore.InfoLevel)
+	h.m.logger = zap.New(core).Sugar()
+
+	sealed := blockPreCommit1(h)
+	si := packingSector(h)
+	h.api.setDeal(2, market.DealProposal{PieceCID: testCommD, PieceSize: 1024, StartEpoch: 100, EndEpoch: 1000})
+	h.start(si)
+	<-sealed
+
+	packing := logs.FilterMessage("performing filling up rest of the sector...").All()
+	require.Len(t, packing, 1)
+	require.Equal(t, map[string]interface{}{"sector": "1", "state": Packing}, packing[0].ContextMap())
+}
@@ -0,0 +1,55 @@
+package sealing
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// heldSectors are paused sectors whose state handler was held back, it's run
+// when they are resumed
+type heldSectors struct {
+	lk      sync.Mutex
+	sectors map[abi.SectorNumber]struct{}
+}
+
+func (h *heldSectors) add(sid abi.SectorNumber) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	if h.sectors == nil {
+		h.sectors = map[abi.SectorNumber]struct{}{}
+	}
+	h.sectors[sid] = struct{}{}
+}
+
+// take reports whether the sector had a handler held back, and forgets it
+func (h *heldSectors) take(sid abi.SectorNumber) bool {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	_, ok := h.sectors[sid]
+	delete(h.sectors, sid)
+	return ok
+}
+
+// Pause holds the sector in its current state. Sealer work and chain waits
+// already in flight aren't interrupted, and the sector still moves on when
+// they finish, but the handler of the state it gets to doesn't run until the
+// sector is resumed. The pause is kept across restarts
+func (m *Sealing) Pause(sid abi.SectorNumber) error {
+	if _, err := m.GetSectorInfo(sid); err != nil {
+		return err
+	}
+
+	return m.send(sid, SectorPause{})
+}
+
+// Resume lets a paused sector continue from the state it's in
+func (m *Sealing) Resume(sid abi.SectorNumber) error {
+	if _, err := m.GetSectorInfo(sid); err != nil {
+		return err
+	}
+
+	return m.send(sid, SectorResume{})
+}
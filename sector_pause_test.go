@@ -0,0 +1,74 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+func TestPauseResume(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.put(h.committingSector(1))
+
+	require.NoError(t, h.m.Pause(1))
+	require.Eventually(t, func() bool {
+		return h.sector(1).Paused
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// restarted paused, the commit isn't sent
+	require.NoError(t, h.m.sectors.Send(uint64(1), SectorRestart{}))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, Committing, h.sector(1).State)
+	require.Empty(t, h.api.sentMsgs())
+
+	require.NoError(t, h.m.Resume(1))
+	si := h.waitState(1, Proving)
+	require.False(t, si.Paused)
+	require.Len(t, h.api.sentMsgs(), 1)
+}
+
+func TestPauseInFlight(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		close(started)
+		<-release
+		return storage.PreCommit1Out{}, nil
+	}
+
+	h.start(SectorInfo{
+		State:        PreCommit1,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces:       []Piece{{Piece: abi.PieceInfo{Size: 2048, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(2048).Unpadded())}}},
+	})
+	<-started
+	require.NoError(t, h.m.Pause(1))
+
+	// PreCommit1 finishes, PreCommit2 doesn't start
+	close(release)
+	si := h.waitState(1, PreCommit2)
+	require.True(t, si.Paused)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, PreCommit2, h.sector(1).State)
+
+	require.NoError(t, h.m.Resume(1))
+	h.waitState(1, Proving)
+}
+
+func TestPauseNotFound(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	err := h.m.Pause(1)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)), "%+v", err)
+	err = h.m.Resume(1)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)), "%+v", err)
+}
@@ -6,32 +6,57 @@ const (
 	UndefinedSectorState SectorState = ""
 
 	// happy path
-	Empty          SectorState = "Empty"
-	Packing        SectorState = "Packing"       // sector not in sealStore, and not on chain
-	PreCommit1     SectorState = "PreCommit1"    // do PreCommit1
-	PreCommit2     SectorState = "PreCommit2"    // do PreCommit1
-	PreCommitting  SectorState = "PreCommitting" // on chain pre-commit
-	PreCommitWait  SectorState = "PreCommitWait" // waiting for precommit to land on chain
-	WaitSeed       SectorState = "WaitSeed"      // waiting for seed
-	Committing     SectorState = "Committing"
-	CommitWait     SectorState = "CommitWait" // waiting for message to land on chain
-	FinalizeSector SectorState = "FinalizeSector"
-	Proving        SectorState = "Proving"
+	Empty              SectorState = "Empty"
+	WaitDeals          SectorState = "WaitDeals"          // waiting for more pieces (deals) to be added to the sector
+	Packing            SectorState = "Packing"            // sector not in sealStore, and not on chain
+	PreCommit1         SectorState = "PreCommit1"         // do PreCommit1
+	PreCommit2         SectorState = "PreCommit2"         // do PreCommit1
+	WaitDisk           SectorState = "WaitDisk"           // waiting for disk space to start the next phase
+	PreCommitting      SectorState = "PreCommitting"      // on chain pre-commit
+	PledgeInsufficient SectorState = "PledgeInsufficient" // waiting for enough miner balance for the initial pledge
+	PreCommitWait      SectorState = "PreCommitWait"      // waiting for precommit to land on chain
+	CommitHold         SectorState = "CommitHold"         // precommit landed, waiting for CommitSector or the commit deadline
+	WaitSeed           SectorState = "WaitSeed"           // waiting for seed
+	Committing         SectorState = "Committing"
+	CommitWait         SectorState = "CommitWait"    // waiting for message to land on chain
+	MessageStuck       SectorState = "MessageStuck"  // precommit or commit message didn't land within MessageWaitTimeout, resending
+	MessageFailed      SectorState = "MessageFailed" // precommit or commit message was executed with a non-zero exit code
+	FinalizeSector     SectorState = "FinalizeSector"
+	Proving            SectorState = "Proving"
 	// error modes
 	FailedUnrecoverable  SectorState = "FailedUnrecoverable"
 	SealPreCommit1Failed SectorState = "SealPreCommit1Failed"
 	SealPreCommit2Failed SectorState = "SealPreCommit2Failed"
 	PreCommitFailed      SectorState = "PreCommitFailed"
 	ComputeProofFailed   SectorState = "ComputeProofFailed"
+	BadProof             SectorState = "BadProof" // commit proof failed local verification, it wasn't submitted
 	CommitFailed         SectorState = "CommitFailed"
 	PackingFailed        SectorState = "PackingFailed"
 	FinalizeFailed       SectorState = "FinalizeFailed"
+	DealsExpired         SectorState = "DealsExpired" // deals in the sector expired before it could be committed, or outlive MaxSectorLifetime
+
+	DataCommitmentMismatch SectorState = "DataCommitmentMismatch" // data commitment computed on chain from the deals differs from the sealed one
+	ExpiredPreCommit       SectorState = "ExpiredPreCommit"       // precommit wasn't proven within MaxSealDuration, its deposit is lost
+	UnsealedCorrupt        SectorState = "UnsealedCorrupt"        // unsealed copy of the committed sector doesn't match its deals, see CheckUnsealedPieces
 
 	Faulty        SectorState = "Faulty"        // sector is corrupted or gone for some reason
 	FaultReported SectorState = "FaultReported" // sector has been declared as a fault on chain
 	FaultedFinal  SectorState = "FaultedFinal"  // fault declared on chain
 
+	RecoveringFault SectorState = "RecoveringFault" // checking sealed data, and declaring the fault recovered on chain
+	RecoveryWait    SectorState = "RecoveryWait"    // waiting for the recovery declaration to land on chain
+	RecoveryFailed  SectorState = "RecoveryFailed"  // sealed data is gone, or the recovery declaration failed
+
+	Terminating     SectorState = "Terminating"     // submitting the on-chain sector termination
+	TerminateWait   SectorState = "TerminateWait"   // waiting for the termination message to land on chain
+	TerminateFailed SectorState = "TerminateFailed" // termination message couldn't be sent, or failed on chain
+	Terminated      SectorState = "Terminated"      // sector terminated on chain
+
 	Removing     SectorState = "Removing"
 	RemoveFailed SectorState = "RemoveFailed"
 	Removed      SectorState = "Removed"
+
+	Aborting    SectorState = "Aborting"    // sealing was cancelled with Abort, removing partial sector data
+	AbortFailed SectorState = "AbortFailed" // removing the data of the aborted sector failed, call Abort to retry
+	Aborted     SectorState = "Aborted"
 )
@@ -0,0 +1,40 @@
+package sealing
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+const seedEpochRecheck = 30 * time.Second
+
+// commitSeedEpoch returns the epoch the interactive seed of the commit proof
+// is drawn at. A proof with a seed drawn at any other epoch doesn't verify
+func (m *Sealing) commitSeedEpoch(pci *miner.SectorPreCommitOnChainInfo) abi.ChainEpoch {
+	return pci.PreCommitEpoch + miner.PreCommitChallengeDelay
+}
+
+// waitSeedEpoch waits for the chain head to reach the seed epoch, and
+// returns the head the seed can be drawn from
+func (m *Sealing) waitSeedEpoch(ctx context.Context, seedEpoch abi.ChainEpoch) (TipSetToken, abi.ChainEpoch, error) {
+	for {
+		tok, height, err := m.api.ChainHead(ctx)
+		if err != nil {
+			return nil, 0, xerrors.Errorf("getting chain head: %w", err)
+		}
+		if height >= seedEpoch {
+			return tok, height, nil
+		}
+
+		log.Infof("waiting for the chain to reach seed epoch %d, head at %d", seedEpoch, height)
+		select {
+		case <-m.clock.After(seedEpochRecheck):
+		case <-ctx.Done():
+			return nil, 0, xerrors.Errorf("waiting for seed epoch %d: %w", seedEpoch, ctx.Err())
+		}
+	}
+}
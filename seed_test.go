@@ -0,0 +1,67 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+)
+
+// earlyEvents calls height handlers right away, at the current head
+type earlyEvents struct {
+	api *fakeAPI
+}
+
+func (e earlyEvents) ChainAt(hnd HeightHandler, rev RevertHandler, confidence int, h abi.ChainEpoch) error {
+	e.api.lk.Lock()
+	head := e.api.head
+	e.api.lk.Unlock()
+
+	return hnd(context.TODO(), TipSetToken{1, 2, 3}, head)
+}
+
+func TestCommitSeedEpoch(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	pci := &miner.SectorPreCommitOnChainInfo{PreCommitEpoch: 1000}
+	require.Equal(t, 1000+miner.PreCommitChallengeDelay, h.m.commitSeedEpoch(pci))
+}
+
+func TestWaitSeedEpoch(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk})
+	h.m.events = earlyEvents{api: h.api}
+
+	randCalls := func() int {
+		h.api.lk.Lock()
+		defer h.api.lk.Unlock()
+		return h.api.randCalls
+	}
+
+	si := h.committingSector(1)
+	si.State = WaitSeed
+	si.SeedValue, si.SeedEpoch = nil, 0
+	h.api.setHead(10)
+	h.start(si)
+
+	seedEpoch := 10 + miner.PreCommitChallengeDelay
+
+	// the chain is still short of the seed epoch
+	clk.waitTimers(t, 1)
+	h.api.setHead(seedEpoch - 1)
+	clk.advance(seedEpochRecheck)
+	clk.waitTimers(t, 1)
+	require.Zero(t, randCalls())
+	require.Equal(t, WaitSeed, h.sector(1).State)
+
+	h.api.setHead(seedEpoch)
+	clk.advance(seedEpochRecheck)
+
+	si = h.waitState(1, Proving)
+	require.Equal(t, seedEpoch, si.SeedEpoch)
+	require.Equal(t, abi.InteractiveSealRandomness(testRand), si.SeedValue)
+	require.NotZero(t, randCalls())
+}
@@ -0,0 +1,84 @@
+package sealing
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+)
+
+const (
+	defaultRetryBaseDelay = 1 * time.Second
+	defaultRetryMaxDelay  = 1 * time.Minute
+)
+
+// ErrMessageInvalid should be returned (or wrapped) by SendMsg implementations
+// when the node rejects the message itself. Sending it again won't help, so
+// such errors aren't retried
+type ErrMessageInvalid struct{ error }
+
+// RetryPolicy configures retries of failed message sends. Delays between
+// attempts double from BaseDelay up to MaxDelay
+type RetryPolicy struct {
+	// MaxAttempts is the total number of send attempts; 0 or 1 disables
+	// retries
+	MaxAttempts int
+
+	BaseDelay time.Duration // defaultRetryBaseDelay if not set
+	MaxDelay  time.Duration // defaultRetryMaxDelay if not set
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	base, max := p.BaseDelay, p.MaxDelay
+	if base == 0 {
+		base = defaultRetryBaseDelay
+	}
+	if max == 0 {
+		max = defaultRetryMaxDelay
+	}
+
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func retryableSendError(err error) bool {
+	return !xerrors.As(err, new(*ErrMessageInvalid)) &&
+		!xerrors.Is(err, context.Canceled) &&
+		!xerrors.Is(err, context.DeadlineExceeded)
+}
+
+// sendMsg sends a message, retrying transient failures with exponential
+// backoff according to cfg.SendRetry. The sector stays in its current state
+// while retries are pending
+func (m *Sealing) sendMsg(ctx context.Context, from, to address.Address, method abi.MethodNum, value, gasPrice big.Int, gasLimit int64, params []byte) (cid.Cid, error) {
+	for attempt := 1; ; attempt++ {
+		mcid, err := m.api.SendMsg(ctx, from, to, method, value, gasPrice, gasLimit, params)
+		if err == nil {
+			return mcid, nil
+		}
+
+		if attempt >= m.cfg.SendRetry.MaxAttempts || !retryableSendError(err) {
+			return cid.Undef, err
+		}
+
+		delay := m.cfg.SendRetry.delay(attempt)
+		log.Warnf("sending message (method %d) failed, retrying in %s (attempt %d of %d): %+v", method, delay, attempt, m.cfg.SendRetry.MaxAttempts, err)
+
+		select {
+		case <-m.clock.After(delay):
+		case <-ctx.Done():
+			return cid.Undef, xerrors.Errorf("retrying send: %w", ctx.Err())
+		}
+	}
+}
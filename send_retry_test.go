@@ -0,0 +1,69 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	require.Equal(t, time.Second, p.delay(1))
+	require.Equal(t, 2*time.Second, p.delay(2))
+	require.Equal(t, 4*time.Second, p.delay(3))
+	require.Equal(t, 5*time.Second, p.delay(4))
+	require.Equal(t, 5*time.Second, p.delay(100))
+
+	require.Equal(t, defaultRetryBaseDelay, RetryPolicy{}.delay(1))
+}
+
+func TestSendRetry(t *testing.T) {
+	h := newTestHarness(t, Config{SendRetry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}})
+	h.api.sendErrs = []error{
+		xerrors.New("connection refused"),
+		xerrors.New("mpool full"),
+	}
+
+	h.start(h.committingSector(1))
+	h.waitState(1, Proving)
+
+	h.api.lk.Lock()
+	require.Equal(t, 3, h.api.sendCalls)
+	h.api.lk.Unlock()
+
+	msgs := h.api.sentMsgs()
+	require.Len(t, msgs, 1)
+	require.Equal(t, builtin.MethodsMiner.ProveCommitSector, msgs[0].method)
+}
+
+func TestSendRetryGivesUp(t *testing.T) {
+	h := newTestHarness(t, Config{SendRetry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}})
+	h.api.sendErrs = []error{
+		xerrors.New("connection refused"),
+		xerrors.New("connection refused"),
+	}
+
+	h.start(h.committingSector(1))
+	si := h.waitState(1, CommitFailed)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "connection refused")
+}
+
+func TestSendRetryPermanentError(t *testing.T) {
+	h := newTestHarness(t, Config{SendRetry: RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond}})
+	h.api.sendErrs = []error{
+		xerrors.Errorf("pushing: %w", &ErrMessageInvalid{xerrors.New("not enough funds")}),
+	}
+
+	_, err := h.m.sendMsg(context.Background(), h.m.maddr, h.m.maddr, builtin.MethodsMiner.ProveCommitSector, big.Zero(), big.Zero(), 1, nil)
+	require.True(t, xerrors.As(err, new(*ErrMessageInvalid)), "%+v", err)
+
+	h.api.lk.Lock()
+	require.Equal(t, 1, h.api.sendCalls)
+	h.api.lk.Unlock()
+}
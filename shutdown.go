@@ -0,0 +1,22 @@
+package sealing
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// ErrShuttingDown is returned for AddPiece calls made after Stop was called
+type ErrShuttingDown struct{ error }
+
+// startAddPiece tracks an AddPiece call until the returned func is called, so
+// that Stop doesn't stop the state machines while its piece is still written
+func (m *Sealing) startAddPiece() (func(), error) {
+	m.shutdownLk.Lock()
+	defer m.shutdownLk.Unlock()
+
+	if m.shuttingDown {
+		return nil, &ErrShuttingDown{xerrors.New("sealing is shutting down, not accepting pieces")}
+	}
+
+	m.addPieces.Add(1)
+	return m.addPieces.Done, nil
+}
@@ -0,0 +1,91 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// blockAddPiece holds sealer writes until the returned channel is closed
+func blockAddPiece(h *testHarness) (started chan struct{}, release chan struct{}) {
+	started, release = make(chan struct{}, 1), make(chan struct{})
+	h.sealer.addPiece = func(ctx context.Context, sector abi.SectorID, size abi.UnpaddedPieceSize) {
+		started <- struct{}{}
+		<-release
+	}
+	return started, release
+}
+
+func (m *Sealing) isShuttingDown() bool {
+	m.shutdownLk.Lock()
+	defer m.shutdownLk.Unlock()
+	return m.shuttingDown
+}
+
+func TestStopDrainsAddPiece(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	started, release := blockAddPiece(h)
+	size := abi.PaddedPieceSize(512).Unpadded()
+
+	added := make(chan error, 1)
+	go func() {
+		h.setZeroDeal(1, 512)
+		_, _, err := h.m.AddPieceToAnySector(context.Background(), size, bytes.NewReader(make([]byte, size)), DealInfo{DealID: 1})
+		added <- err
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- h.m.Stop(context.Background())
+	}()
+	require.Eventually(t, h.m.isShuttingDown, time.Second, time.Millisecond)
+
+	// new pieces are rejected while the write in flight is waited for
+	h.setZeroDeal(2, 512)
+	_, _, err := h.m.AddPieceToAnySector(context.Background(), size, bytes.NewReader(make([]byte, size)), DealInfo{DealID: 2})
+	require.True(t, xerrors.As(err, new(*ErrShuttingDown)), "%+v", err)
+	_, err = h.m.AddPieceToSector(context.Background(), 1, size, bytes.NewReader(make([]byte, size)), DealInfo{DealID: 2})
+	require.True(t, xerrors.As(err, new(*ErrShuttingDown)), "%+v", err)
+
+	select {
+	case <-stopped:
+		t.Fatal("stopped before the piece was written")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-added)
+	require.NoError(t, <-stopped)
+
+	// the piece was fully accounted for, and nothing else was reserved
+	h.m.unsealedLk.Lock()
+	defer h.m.unsealedLk.Unlock()
+	require.Len(t, h.m.unsealedInfos, 1)
+	require.Equal(t, abi.PaddedPieceSize(512), h.m.unsealedInfos[1].stored)
+	require.Nil(t, h.m.unsealedInfos[1].lastWrite)
+}
+
+func TestStopDeadline(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	started, release := blockAddPiece(h)
+	size := abi.PaddedPieceSize(512).Unpadded()
+	defer close(release)
+
+	go func() {
+		h.setZeroDeal(1, 512)
+		_, _, _ = h.m.AddPieceToAnySector(context.Background(), size, bytes.NewReader(make([]byte, size)), DealInfo{DealID: 1})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := h.m.Stop(ctx)
+	require.True(t, xerrors.Is(err, context.DeadlineExceeded), "%+v", err)
+}
@@ -0,0 +1,164 @@
+package sealing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// stateExportVersion is bumped on incompatible changes of the export format
+const stateExportVersion = 1
+
+// ErrStoreNotEmpty is returned by ImportState when the datastore already has
+// sector records, and the import isn't forced
+type ErrStoreNotEmpty struct{ error }
+
+// ExportState writes all sector records, for ImportState on another node.
+// The export starts with the format version and the next sector number,
+// followed by the count of records and the CBOR encoded SectorInfo records.
+// The next sector number is the one the SectorIDCounter hands out next if it
+// implements PeekingSectorIDCounter, so that numbers of sectors which were
+// removed, or never created, aren't reused; otherwise it's one past the
+// highest exported sector. Sectors should be stopped while exporting,
+// records changing during the export may or may not be included
+func (m *Sealing) ExportState(w io.Writer) error {
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	var next abi.SectorNumber
+	for _, si := range sectors {
+		if si.SectorNumber >= next {
+			next = si.SectorNumber + 1
+		}
+	}
+
+	if m.peeker != nil {
+		counted, err := m.peeker.Peek()
+		if err != nil {
+			return xerrors.Errorf("getting the next sector number: %w", err)
+		}
+		if counted > next {
+			next = counted
+		}
+	}
+
+	for _, v := range []uint64{stateExportVersion, uint64(next), uint64(len(sectors))} {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, v)); err != nil {
+			return err
+		}
+	}
+
+	for i := range sectors {
+		if err := sectors[i].MarshalCBOR(w); err != nil {
+			return xerrors.Errorf("writing sector %d: %w", sectors[i].SectorNumber, err)
+		}
+	}
+
+	return nil
+}
+
+// ImportState loads sector records written by ExportState into ds, which
+// must be the datastore Sealing is constructed with afterwards; with
+// Config.DatastorePrefix or DatastorePerMiner set it has to be wrapped in
+// that namespace. A datastore which already has sector records is only
+// overwritten, dropping all of them, when force is set.
+//
+// The returned number is the next sector number of the exporting node, the
+// SectorIDCounter of the new node must not hand out lower numbers
+func ImportState(ds datastore.Batching, r io.Reader, force bool) (abi.SectorNumber, error) {
+	sds := namespace.Wrap(ds, datastore.NewKey(SectorStorePrefix))
+
+	existing, err := recordKeys(sds)
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) > 0 && !force {
+		return 0, &ErrStoreNotEmpty{xerrors.Errorf("datastore already has %d sector records", len(existing))}
+	}
+
+	br := cbg.GetPeeker(r)
+	var header [3]uint64
+	for i := range header {
+		maj, v, err := cbg.CborReadHeader(br)
+		if err != nil {
+			return 0, xerrors.Errorf("reading export header: %w", err)
+		}
+		if maj != cbg.MajUnsignedInt {
+			return 0, xerrors.Errorf("bad export header: expected an unsigned int, got major type %d", maj)
+		}
+		header[i] = v
+	}
+	if header[0] != stateExportVersion {
+		return 0, xerrors.Errorf("unsupported export version %d", header[0])
+	}
+	next, count := abi.SectorNumber(header[1]), header[2]
+
+	// records are decoded before anything is written, so that a broken
+	// export doesn't leave a partial import behind
+	records := map[datastore.Key][]byte{}
+	for i := uint64(0); i < count; i++ {
+		var si SectorInfo
+		if err := si.UnmarshalCBOR(br); err != nil {
+			return 0, xerrors.Errorf("reading sector record %d of %d: %w", i+1, count, err)
+		}
+
+		buf := new(bytes.Buffer)
+		if err := si.MarshalCBOR(buf); err != nil {
+			return 0, xerrors.Errorf("encoding sector %d: %w", si.SectorNumber, err)
+		}
+
+		key := datastore.NewKey(fmt.Sprint(uint64(si.SectorNumber)))
+		if _, ok := records[key]; ok {
+			return 0, xerrors.Errorf("sector %d exported twice", si.SectorNumber)
+		}
+		records[key] = buf.Bytes()
+	}
+
+	b, err := sds.Batch()
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range existing {
+		if err := b.Delete(key); err != nil {
+			return 0, xerrors.Errorf("deleting record %s: %w", key, err)
+		}
+	}
+	for key, rec := range records {
+		if err := b.Put(key, rec); err != nil {
+			return 0, xerrors.Errorf("writing record %s: %w", key, err)
+		}
+	}
+	if err := b.Commit(); err != nil {
+		return 0, xerrors.Errorf("committing import: %w", err)
+	}
+
+	return next, nil
+}
+
+// recordKeys lists the keys of all records in the datastore
+func recordKeys(ds datastore.Datastore) ([]datastore.Key, error) {
+	res, err := ds.Query(query.Query{KeysOnly: true})
+	if err != nil {
+		return nil, xerrors.Errorf("querying sector records: %w", err)
+	}
+	defer res.Close() // nolint:errcheck
+
+	var out []datastore.Key
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating sector records: %w", r.Error)
+		}
+		out = append(out, datastore.NewKey(r.Key))
+	}
+	return out, nil
+}
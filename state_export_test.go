@@ -0,0 +1,105 @@
+package sealing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestExportImportState(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.put(SectorInfo{SectorNumber: 1, State: WaitDeals, SectorType: abi.RegisteredSealProof_StackedDrg2KiBV1})
+	h.put(h.committingSector(3))
+	h.put(SectorInfo{
+		SectorNumber: 7,
+		State:        Proving,
+		Pieces:       []Piece{{Piece: abi.PieceInfo{Size: 2048, PieceCID: testCommD}, DealInfo: &DealInfo{DealID: 5}}},
+		Log:          []Log{{Timestamp: 10, Message: "proving", Kind: "event;sealing.SectorFinalized"}},
+	})
+
+	exported, err := h.m.ListSectors()
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, h.m.ExportState(buf))
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	next, err := ImportState(ds, bytes.NewReader(buf.Bytes()), false)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(8), next)
+
+	h2 := newTestHarnessOn(t, ds, 1000, Config{})
+	imported, err := h2.m.ListSectors()
+	require.NoError(t, err)
+	require.Equal(t, exported, imported)
+
+	// the store isn't empty anymore
+	_, err = ImportState(ds, bytes.NewReader(buf.Bytes()), false)
+	require.True(t, xerrors.As(err, new(*ErrStoreNotEmpty)), "%+v", err)
+}
+
+func TestExportStateSectorCounter(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	// sectors 3 to 5 were removed
+	sc := NewDatastoreSectorIDCounter(h.ds, h.m.maddr, Config{})
+	for i := 0; i < 6; i++ {
+		_, err := sc.Next()
+		require.NoError(t, err)
+	}
+	h.put(SectorInfo{SectorNumber: 2, State: Proving})
+
+	pcp := NewDealPreCommitPolicy(h.api, 10000, 0, 0)
+	h.m = NewWithConfig(h.api, h.api, h.m.maddr, h.ds, h.sealer, sc, fakeVerifier{}, &pcp, Config{})
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, h.m.ExportState(buf))
+
+	next, err := ImportState(dssync.MutexWrap(datastore.NewMapDatastore()), bytes.NewReader(buf.Bytes()), false)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(6), next)
+}
+
+func TestImportStateForce(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.put(SectorInfo{SectorNumber: 2, State: Proving})
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, h.m.ExportState(buf))
+
+	h2 := newTestHarness(t, Config{})
+	h2.put(SectorInfo{SectorNumber: 9, State: Proving})
+
+	_, err := ImportState(h2.ds, bytes.NewReader(buf.Bytes()), true)
+	require.NoError(t, err)
+
+	sectors, err := h2.m.ListSectors()
+	require.NoError(t, err)
+	require.Len(t, sectors, 1)
+	require.Equal(t, abi.SectorNumber(2), sectors[0].SectorNumber)
+}
+
+func TestImportStateTruncated(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.put(SectorInfo{SectorNumber: 1, State: Proving})
+	h.put(SectorInfo{SectorNumber: 2, State: Proving})
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, h.m.ExportState(buf))
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	_, err := ImportState(ds, bytes.NewReader(buf.Bytes()[:buf.Len()-3]), false)
+	require.Error(t, err)
+
+	// nothing was written
+	keys, err := recordKeys(ds)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
@@ -11,14 +11,16 @@ import (
 
 const minRetryTime = 1 * time.Minute
 
-func failedCooldown(ctx statemachine.Context, sector SectorInfo) error {
+func (m *Sealing) failedCooldown(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
 	// TODO: Exponential backoff when we see consecutive failures
 
 	retryStart := time.Unix(int64(sector.Log[len(sector.Log)-1].Timestamp), 0).Add(minRetryTime)
-	if len(sector.Log) > 0 && !time.Now().After(retryStart) {
-		log.Infof("%s(%d), waiting %s before retrying", sector.State, sector.SectorNumber, time.Until(retryStart))
+	if now := m.clock.Now(); len(sector.Log) > 0 && !now.After(retryStart) {
+		log.Infof("%s(%d), waiting %s before retrying", sector.State, sector.SectorNumber, retryStart.Sub(now))
 		select {
-		case <-time.After(time.Until(retryStart)):
+		case <-m.clock.After(retryStart.Sub(now)):
 		case <-ctx.Context().Done():
 			return ctx.Context().Err()
 		}
@@ -28,7 +30,9 @@ func failedCooldown(ctx statemachine.Context, sector SectorInfo) error {
 }
 
 func (m *Sealing) checkPreCommitted(ctx statemachine.Context, sector SectorInfo) (*miner.SectorPreCommitOnChainInfo, bool) {
-	tok, _, err := m.api.ChainHead(ctx.Context())
+	log := m.stateLog(sector)
+
+	tok, _, err := m.tickAPI(sector).ChainHead(ctx.Context())
 	if err != nil {
 		log.Errorf("handleSealPrecommit1Failed(%d): temp error: %+v", sector.SectorNumber, err)
 		return nil, true
@@ -44,7 +48,7 @@ func (m *Sealing) checkPreCommitted(ctx statemachine.Context, sector SectorInfo)
 }
 
 func (m *Sealing) handleSealPrecommit1Failed(ctx statemachine.Context, sector SectorInfo) error {
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -52,7 +56,7 @@ func (m *Sealing) handleSealPrecommit1Failed(ctx statemachine.Context, sector Se
 }
 
 func (m *Sealing) handleSealPrecommit2Failed(ctx statemachine.Context, sector SectorInfo) error {
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -64,19 +68,21 @@ func (m *Sealing) handleSealPrecommit2Failed(ctx statemachine.Context, sector Se
 }
 
 func (m *Sealing) handlePreCommitFailed(ctx statemachine.Context, sector SectorInfo) error {
-	tok, height, err := m.api.ChainHead(ctx.Context())
+	log := m.stateLog(sector)
+
+	tok, height, err := m.tickAPI(sector).ChainHead(ctx.Context())
 	if err != nil {
 		log.Errorf("handlePreCommitFailed: api error, not proceeding: %+v", err)
 		return nil
 	}
 
-	if err := checkPrecommit(ctx.Context(), m.Address(), sector, tok, height, m.api); err != nil {
+	if err := checkPrecommit(ctx.Context(), m.Address(), sector, tok, height, m.tickAPI(sector)); err != nil {
 		switch err.(type) {
 		case *ErrApi:
 			log.Errorf("handlePreCommitFailed: api error, not proceeding: %+v", err)
 			return nil
-		case *ErrBadCommD: // TODO: Should this just back to packing? (not really needed since handlePreCommit1 will do that too)
-			return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("bad CommD error: %w", err)})
+		case *ErrBadCommD:
+			return ctx.Send(SectorDataCommitmentMismatch{xerrors.Errorf("bad CommD error: %w", err)})
 		case *ErrExpiredTicket:
 			return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("ticket expired error: %w", err)})
 		case *ErrBadTicket:
@@ -91,7 +97,7 @@ func (m *Sealing) handlePreCommitFailed(ctx statemachine.Context, sector SectorI
 	if pci, is := m.checkPreCommitted(ctx, sector); is && pci != nil {
 		if sector.PreCommitMessage != nil {
 			log.Warn("sector %d is precommitted on chain, but we don't have precommit message", sector.SectorNumber)
-			return ctx.Send(SectorPreCommitLanded{TipSet: tok})
+			return ctx.Send(m.preCommitLanded(tok))
 		}
 
 		if pci.Info.SealedCID != *sector.CommR {
@@ -102,7 +108,7 @@ func (m *Sealing) handlePreCommitFailed(ctx statemachine.Context, sector SectorI
 		// TODO: we could compare more things, but I don't think we really need to
 		//  CommR tells us that CommD (and CommPs), and the ticket are all matching
 
-		if err := failedCooldown(ctx, sector); err != nil {
+		if err := m.failedCooldown(ctx, sector); err != nil {
 			return err
 		}
 
@@ -113,7 +119,7 @@ func (m *Sealing) handlePreCommitFailed(ctx statemachine.Context, sector SectorI
 		log.Warn("retrying precommit even though the message failed to apply")
 	}
 
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -123,7 +129,7 @@ func (m *Sealing) handlePreCommitFailed(ctx statemachine.Context, sector SectorI
 func (m *Sealing) handleComputeProofFailed(ctx statemachine.Context, sector SectorInfo) error {
 	// TODO: Check sector files
 
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -134,20 +140,36 @@ func (m *Sealing) handleComputeProofFailed(ctx statemachine.Context, sector Sect
 	return ctx.Send(SectorRetryComputeProof{})
 }
 
+// handleBadProof recomputes a commit proof which failed verification once,
+// and reseals the sector if the new one doesn't verify either
+func (m *Sealing) handleBadProof(ctx statemachine.Context, sector SectorInfo) error {
+	if err := m.failedCooldown(ctx, sector); err != nil {
+		return err
+	}
+
+	if sector.InvalidProofs > 0 {
+		return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("consecutive invalid proofs")})
+	}
+
+	return ctx.Send(SectorRetryInvalidProof{})
+}
+
 func (m *Sealing) handleCommitFailed(ctx statemachine.Context, sector SectorInfo) error {
-	tok, height, err := m.api.ChainHead(ctx.Context())
+	log := m.stateLog(sector)
+
+	tok, height, err := m.tickAPI(sector).ChainHead(ctx.Context())
 	if err != nil {
 		log.Errorf("handleCommitting: api error, not proceeding: %+v", err)
 		return nil
 	}
 
-	if err := checkPrecommit(ctx.Context(), m.maddr, sector, tok, height, m.api); err != nil {
+	if err := checkPrecommit(ctx.Context(), m.maddr, sector, tok, height, m.tickAPI(sector)); err != nil {
 		switch err.(type) {
 		case *ErrApi:
 			log.Errorf("handleCommitFailed: api error, not proceeding: %+v", err)
 			return nil
 		case *ErrBadCommD:
-			return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("bad CommD error: %w", err)})
+			return ctx.Send(SectorDataCommitmentMismatch{xerrors.Errorf("bad CommD error: %w", err)})
 		case *ErrExpiredTicket:
 			return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("ticket expired error: %w", err)})
 		case *ErrBadTicket:
@@ -168,7 +190,7 @@ func (m *Sealing) handleCommitFailed(ctx statemachine.Context, sector SectorInfo
 			log.Errorf("seed changed, will retry: %+v", err)
 			return ctx.Send(SectorRetryWaitSeed{})
 		case *ErrInvalidProof:
-			if err := failedCooldown(ctx, sector); err != nil {
+			if err := m.failedCooldown(ctx, sector); err != nil {
 				return err
 			}
 
@@ -187,7 +209,7 @@ func (m *Sealing) handleCommitFailed(ctx statemachine.Context, sector SectorInfo
 
 	// TODO: Check sector files
 
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -197,7 +219,7 @@ func (m *Sealing) handleCommitFailed(ctx statemachine.Context, sector SectorInfo
 func (m *Sealing) handleFinalizeFailed(ctx statemachine.Context, sector SectorInfo) error {
 	// TODO: Check sector files
 
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -1,9 +1,16 @@
 package sealing
 
 import (
+	"bytes"
+	"context"
+
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-statemachine"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 )
 
 func (m *Sealing) handleFaulty(ctx statemachine.Context, sector SectorInfo) error {
@@ -14,6 +21,8 @@ func (m *Sealing) handleFaulty(ctx statemachine.Context, sector SectorInfo) erro
 }
 
 func (m *Sealing) handleFaultReported(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
 	if sector.FaultReportMsg == nil {
 		return xerrors.Errorf("entered fault reported state without a FaultReportMsg cid")
 	}
@@ -31,10 +40,166 @@ func (m *Sealing) handleFaultReported(ctx statemachine.Context, sector SectorInf
 	return ctx.Send(SectorFaultedFinal{})
 }
 
+func (m *Sealing) handleRecoveringFault(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
+	tok, _, err := m.tickAPI(sector).ChainHead(ctx.Context())
+	if err != nil {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("getting chain head: %w", err)})
+	}
+
+	// the miner actor only keeps proven sectors, terminated or expired ones
+	// can't be recovered
+	onChain, err := m.api.StateSectorGetInfo(ctx.Context(), m.maddr, sector.SectorNumber, tok)
+	if err != nil {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("getting on-chain sector info: %w", err)})
+	}
+	if onChain == nil {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("sector %d is not active on chain", sector.SectorNumber)})
+	}
+
+	bad, err := m.sealer.CheckProvable(ctx.Context(), sector.SectorType, []abi.SectorID{m.minerSector(sector.SectorNumber)})
+	if err != nil {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("checking sealed data: %w", err)})
+	}
+	if len(bad) > 0 {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("sealed data of sector %d is missing or corrupted", sector.SectorNumber)})
+	}
+
+	dl, err := m.sectorDeadline(ctx.Context(), sector.SectorNumber, tok)
+	if err != nil {
+		return ctx.Send(SectorRecoveryFailed{err})
+	}
+
+	waddr, err := m.api.StateMinerWorkerAddress(ctx.Context(), m.maddr, tok)
+	if err != nil {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("getting worker address: %w", err)})
+	}
+
+	sectors := abi.NewBitField()
+	sectors.Set(uint64(sector.SectorNumber))
+
+	params := &miner.DeclareFaultsRecoveredParams{
+		Recoveries: []miner.RecoveryDeclaration{{Deadline: dl, Sectors: sectors}},
+	}
+	enc := new(bytes.Buffer)
+	if err := params.MarshalCBOR(enc); err != nil {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("could not serialize recovery declaration parameters: %w", err)})
+	}
+
+	log.Info("declaring fault recovered for sector: ", sector.SectorNumber)
+	gasPrice, gasLimit := m.messageGas(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.DeclareFaultsRecovered, big.NewInt(0), enc.Bytes())
+	mcid, err := m.api.SendMsg(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.DeclareFaultsRecovered, big.NewInt(0), gasPrice, gasLimit, enc.Bytes())
+	if err != nil {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("pushing message to mpool: %w", err)})
+	}
+
+	return ctx.Send(SectorRecovering{Message: mcid})
+}
+
+// sectorDeadline returns the proving deadline the sector is assigned to
+func (m *Sealing) sectorDeadline(ctx context.Context, sn abi.SectorNumber, tok TipSetToken) (uint64, error) {
+	dls, err := m.api.StateMinerDeadlines(ctx, m.maddr, tok)
+	if err != nil {
+		return 0, xerrors.Errorf("getting miner deadlines: %w", err)
+	}
+
+	for i, due := range dls.Due {
+		if due == nil {
+			continue
+		}
+
+		has, err := due.IsSet(uint64(sn))
+		if err != nil {
+			return 0, xerrors.Errorf("checking sectors in deadline %d: %w", i, err)
+		}
+		if has {
+			return uint64(i), nil
+		}
+	}
+
+	return 0, xerrors.Errorf("sector %d is not assigned to a proving deadline", sn)
+}
+
+func (m *Sealing) handleRecoveryWait(ctx statemachine.Context, sector SectorInfo) error {
+	if sector.RecoveryMessage == nil {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("entered recovery wait state without a RecoveryMessage cid")})
+	}
+
+	mw, err := m.api.StateWaitMsg(ctx.Context(), *sector.RecoveryMessage)
+	if err != nil {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("failed to wait for recovery declaration: %w", err)})
+	}
+
+	if mw.Receipt.ExitCode != 0 {
+		return ctx.Send(SectorRecoveryFailed{xerrors.Errorf("declaring fault recovered failed (exit %d)", mw.Receipt.ExitCode)})
+	}
+
+	return ctx.Send(SectorRecovered{})
+}
+
 func (m *Sealing) handleRemoving(ctx statemachine.Context, sector SectorInfo) error {
-	if err := m.sealer.Remove(ctx.Context(), m.minerSector(sector.SectorNumber)); err != nil {
+	m.forgetCommitDeadline(sector.SectorNumber)
+
+	done, err := m.beginIntent(sector.SectorNumber, IntentRemove)
+	if err != nil {
+		return ctx.Send(SectorRemoveFailed{err})
+	}
+	err = m.sealer.Remove(ctx.Context(), m.minerSector(sector.SectorNumber))
+	done()
+	if err != nil {
 		return ctx.Send(SectorRemoveFailed{err})
 	}
 
 	return ctx.Send(SectorRemoved{})
 }
+
+func (m *Sealing) handleTerminating(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
+	tok, _, err := m.tickAPI(sector).ChainHead(ctx.Context())
+	if err != nil {
+		log.Errorf("handleTerminating: api error, not proceeding: %+v", err)
+		return nil
+	}
+
+	waddr, err := m.api.StateMinerWorkerAddress(ctx.Context(), m.maddr, tok)
+	if err != nil {
+		log.Errorf("handleTerminating: api error, not proceeding: %+v", err)
+		return nil
+	}
+
+	sectors := abi.NewBitField()
+	sectors.Set(uint64(sector.SectorNumber))
+
+	enc := new(bytes.Buffer)
+	if err := (&miner.TerminateSectorsParams{Sectors: sectors}).MarshalCBOR(enc); err != nil {
+		return ctx.Send(SectorTerminateFailed{xerrors.Errorf("could not serialize terminate sectors parameters: %w", err)})
+	}
+
+	log.Info("submitting termination for sector: ", sector.SectorNumber)
+	gasPrice, gasLimit := m.messageGas(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.TerminateSectors, big.NewInt(0), enc.Bytes())
+	mcid, err := m.api.SendMsg(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.TerminateSectors, big.NewInt(0), gasPrice, gasLimit, enc.Bytes())
+	if err != nil {
+		return ctx.Send(SectorTerminateFailed{xerrors.Errorf("pushing message to mpool: %w", err)})
+	}
+
+	return ctx.Send(SectorTerminating{Message: mcid})
+}
+
+func (m *Sealing) handleTerminateWait(ctx statemachine.Context, sector SectorInfo) error {
+	if sector.TerminateMessage == nil {
+		return ctx.Send(SectorTerminateFailed{xerrors.Errorf("entered terminate wait state without a TerminateMessage cid")})
+	}
+
+	mw, err := m.api.StateWaitMsg(ctx.Context(), *sector.TerminateMessage)
+	if err != nil {
+		return ctx.Send(SectorTerminateFailed{xerrors.Errorf("failed to wait for termination: %w", err)})
+	}
+
+	if mw.Receipt.ExitCode != 0 {
+		return ctx.Send(SectorTerminateFailed{xerrors.Errorf("terminating sector failed (exit %d)", mw.Receipt.ExitCode)})
+	}
+
+	return ctx.Send(SectorTerminated{})
+}
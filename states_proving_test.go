@@ -0,0 +1,140 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+)
+
+func TestTerminate(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.start(SectorInfo{State: Proving, SectorNumber: 3})
+
+	require.NoError(t, h.m.Terminate(context.Background(), 3))
+	si := h.waitState(3, Terminated)
+	require.NotNil(t, si.TerminateMessage)
+
+	msgs := h.api.sentMsgs()
+	require.Len(t, msgs, 1)
+	require.Equal(t, builtin.MethodsMiner.TerminateSectors, msgs[0].method)
+
+	var params miner.TerminateSectorsParams
+	require.NoError(t, params.UnmarshalCBOR(bytes.NewReader(msgs[0].params)))
+	sectors, err := params.Sectors.All(10)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, sectors)
+
+	// local data can still be removed
+	require.NoError(t, h.m.Remove(context.Background(), 3))
+	h.waitState(3, Removed)
+}
+
+func TestTerminateFailed(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.waitMsg = func(cid.Cid) (MsgLookup, error) {
+		return MsgLookup{Receipt: MessageReceipt{ExitCode: exitcode.ErrIllegalState}}, nil
+	}
+	h.start(SectorInfo{State: Proving, SectorNumber: 1})
+
+	require.NoError(t, h.m.Terminate(context.Background(), 1))
+	si := h.waitState(1, TerminateFailed)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "terminating sector failed")
+
+	h.api.lk.Lock()
+	h.api.waitMsg = nil
+	h.api.lk.Unlock()
+
+	require.NoError(t, h.m.Terminate(context.Background(), 1))
+	h.waitState(1, Terminated)
+	require.Len(t, h.api.sentMsgs(), 2)
+}
+
+func TestTerminateNotCommitted(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.put(SectorInfo{State: WaitSeed, SectorNumber: 1})
+
+	require.Error(t, h.m.Terminate(context.Background(), 1))
+	require.Equal(t, WaitSeed, h.sector(1).State)
+
+	err := h.m.Terminate(context.Background(), 2)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)), "%+v", err)
+	require.Empty(t, h.api.sentMsgs())
+}
+
+func faultySector(h *testHarness, sn abi.SectorNumber) SectorInfo {
+	h.api.lk.Lock()
+	h.api.sectors[sn] = &miner.SectorOnChainInfo{}
+	h.api.deadlines[sn] = 7
+	h.api.lk.Unlock()
+
+	return SectorInfo{State: FaultedFinal, SectorNumber: sn, SectorType: abi.RegisteredSealProof_StackedDrg2KiBV1}
+}
+
+func TestRecoverFault(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.start(faultySector(h, 3))
+
+	require.NoError(t, h.m.RecoverFault(context.Background(), 3))
+	si := h.waitState(3, Proving)
+	require.NotNil(t, si.RecoveryMessage)
+
+	msgs := h.api.sentMsgs()
+	require.Len(t, msgs, 1)
+	require.Equal(t, builtin.MethodsMiner.DeclareFaultsRecovered, msgs[0].method)
+
+	var params miner.DeclareFaultsRecoveredParams
+	require.NoError(t, params.UnmarshalCBOR(bytes.NewReader(msgs[0].params)))
+	require.Len(t, params.Recoveries, 1)
+	require.Equal(t, uint64(7), params.Recoveries[0].Deadline)
+	sectors, err := params.Recoveries[0].Sectors.All(10)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, sectors)
+
+	// not faulty anymore
+	require.Error(t, h.m.RecoverFault(context.Background(), 3))
+}
+
+func TestRecoverFaultDataMissing(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.sealer.checkProvable = badSectors(1)
+	h.start(faultySector(h, 1))
+
+	require.NoError(t, h.m.RecoverFault(context.Background(), 1))
+	si := h.waitState(1, RecoveryFailed)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "sealed data of sector 1 is missing")
+	require.Empty(t, h.api.sentMsgs())
+
+	// the sector can still be terminated
+	require.NoError(t, h.m.Terminate(context.Background(), 1))
+	h.waitState(1, Terminated)
+}
+
+func TestRecoverFaultNotOnChain(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	si := faultySector(h, 1)
+	h.api.lk.Lock()
+	delete(h.api.sectors, 1)
+	h.api.lk.Unlock()
+	h.start(si)
+
+	require.NoError(t, h.m.RecoverFault(context.Background(), 1))
+	si = h.waitState(1, RecoveryFailed)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "not active on chain")
+	require.Empty(t, h.api.sentMsgs())
+
+	h.api.lk.Lock()
+	h.api.sectors[1] = &miner.SectorOnChainInfo{}
+	h.api.lk.Unlock()
+
+	require.NoError(t, h.m.RecoverFault(context.Background(), 1))
+	h.waitState(1, Proving)
+}
@@ -3,6 +3,7 @@ package sealing
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"golang.org/x/xerrors"
 
@@ -17,15 +18,50 @@ import (
 
 var DealSectorPriority = 1024
 
+// how often sectors held back by the CommitDeadlinePolicy check again
+const commitDeadlineRecheck = 1 * time.Minute
+
+// how often CommitWait checks the chain head while waiting for
+// CommitWaitConfidence
+const commitConfidenceRecheck = 30 * time.Second
+
 func (m *Sealing) handlePacking(ctx statemachine.Context, sector SectorInfo) error {
-	log.Infow("performing filling up rest of the sector...", "sector", sector.SectorNumber)
+	log := m.stateLog(sector)
+
+	unpublished, err := checkDealsPublished(ctx.Context(), sector, m.tickAPI(sector))
+	switch err.(type) {
+	case nil:
+	case *ErrApi:
+		log.Errorf("handlePacking: api error, not proceeding: %+v", err)
+		return nil
+	case *ErrExpiredDeals:
+		return m.handleExpiredDeals(ctx, sector, err)
+	default:
+		return xerrors.Errorf("checking deals are published: %w", err)
+	}
+
+	if len(unpublished) > 0 {
+		// pieces can't be dropped selectively, see handleExpiredDeals
+		if len(unpublished) == len(sector.dealIDs()) {
+			log.Warnf("none of the deals in sector %d were published, repacking it with filler data: %v", sector.SectorNumber, unpublished)
+			return ctx.Send(SectorDropUnpublishedDeals{})
+		}
+
+		return ctx.Send(SectorPackingFailed{xerrors.Errorf("deals %v in sector %d were never published", unpublished, sector.SectorNumber)})
+	}
+
+	log.Info("performing filling up rest of the sector...")
 
 	var allocated abi.UnpaddedPieceSize
 	for _, piece := range sector.Pieces {
 		allocated += piece.Piece.Size.Unpadded()
 	}
 
-	ubytes := abi.PaddedPieceSize(m.sealer.SectorSize()).Unpadded()
+	ssize, err := sector.SectorType.SectorSize()
+	if err != nil {
+		return xerrors.Errorf("getting sector size: %w", err)
+	}
+	ubytes := abi.PaddedPieceSize(ssize).Unpadded()
 
 	if allocated > ubytes {
 		return xerrors.Errorf("too much data in sector: %d > %d", allocated, ubytes)
@@ -49,15 +85,17 @@ func (m *Sealing) handlePacking(ctx statemachine.Context, sector SectorInfo) err
 }
 
 func (m *Sealing) getTicket(ctx statemachine.Context, sector SectorInfo) (abi.SealRandomness, abi.ChainEpoch, error) {
-	tok, epoch, err := m.api.ChainHead(ctx.Context())
+	log := m.stateLog(sector)
+
+	tok, epoch, err := m.tickAPI(sector).ChainHead(ctx.Context())
 	if err != nil {
 		log.Errorf("handlePreCommit1: api error, not proceeding: %+v", err)
 		return nil, 0, nil
 	}
 
 	ticketEpoch := epoch - SealRandomnessLookback
-	buf := new(bytes.Buffer)
-	if err := m.maddr.MarshalCBOR(buf); err != nil {
+	entropy, err := m.entropy.TicketEntropy(m.maddr, sector.SectorNumber)
+	if err != nil {
 		return nil, 0, err
 	}
 
@@ -66,11 +104,37 @@ func (m *Sealing) getTicket(ctx statemachine.Context, sector SectorInfo) (abi.Se
 		return nil, 0, xerrors.Errorf("getting precommit info: %w", err)
 	}
 
+	if len(sector.ForcedTicket) > 0 {
+		log.Warnf("using forced ticket drawn at epoch %d", sector.ForcedTicketEpoch)
+		return sector.ForcedTicket, sector.ForcedTicketEpoch, nil
+	}
+
+	if m.cfg.ExternalTickets {
+		if m.cfg.TicketProvider == nil {
+			return nil, 0, xerrors.New("ExternalTickets is set, but no TicketProvider is configured")
+		}
+
+		ticket, providedEpoch, err := m.cfg.TicketProvider.Ticket(ctx.Context(), sector.SectorNumber, epoch)
+		if err != nil {
+			return nil, 0, xerrors.Errorf("getting ticket from provider: %w", err)
+		}
+
+		if pci != nil && pci.Info.SealRandEpoch != providedEpoch {
+			return nil, 0, xerrors.Errorf("provided ticket epoch %d doesn't match the on-chain precommit ticket epoch %d", providedEpoch, pci.Info.SealRandEpoch)
+		}
+
+		if err := checkTicketEpoch(sector.SectorType, providedEpoch, epoch); err != nil {
+			return nil, 0, xerrors.Errorf("provided ticket: %w", err)
+		}
+
+		return ticket, providedEpoch, nil
+	}
+
 	if pci != nil {
 		ticketEpoch = pci.Info.SealRandEpoch
 	}
 
-	rand, err := m.api.ChainGetRandomness(ctx.Context(), tok, crypto.DomainSeparationTag_SealRandomness, ticketEpoch, buf.Bytes())
+	rand, err := m.api.ChainGetRandomness(ctx.Context(), tok, crypto.DomainSeparationTag_SealRandomness, ticketEpoch, entropy)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -79,27 +143,46 @@ func (m *Sealing) getTicket(ctx statemachine.Context, sector SectorInfo) (abi.Se
 }
 
 func (m *Sealing) handlePreCommit1(ctx statemachine.Context, sector SectorInfo) error {
-	if err := checkPieces(ctx.Context(), sector, m.api); err != nil { // Sanity check state
+	log := m.stateLog(sector)
+
+	if err := checkPieces(ctx.Context(), sector, m.tickAPI(sector)); err != nil { // Sanity check state
 		switch err.(type) {
 		case *ErrApi:
 			log.Errorf("handlePreCommit1: api error, not proceeding: %+v", err)
 			return nil
 		case *ErrInvalidDeals:
 			return ctx.Send(SectorPackingFailed{xerrors.Errorf("invalid dealIDs in sector: %w", err)})
-		case *ErrExpiredDeals: // Probably not much we can do here, maybe re-pack the sector?
-			return ctx.Send(SectorPackingFailed{xerrors.Errorf("expired dealIDs in sector: %w", err)})
+		case *ErrExpiredDeals:
+			return m.handleExpiredDeals(ctx, sector, err)
 		default:
 			return xerrors.Errorf("checkPieces sanity check error: %w", err)
 		}
 	}
 
-	log.Infow("performing sector replication...", "sector", sector.SectorNumber)
+	if ok, err := m.enoughDisk(ctx.Context(), sector, PreCommit1); err != nil {
+		log.Warnf("handlePreCommit1: checking disk space, waiting for disk: %+v", err)
+		return ctx.Send(SectorWaitDisk{Phase: PreCommit1})
+	} else if !ok {
+		return ctx.Send(SectorWaitDisk{Phase: PreCommit1})
+	}
+
+	// the ticket is drawn once the sector got a slot, so it doesn't age while
+	// the sector is queued
+	if err := m.p1Limit.acquireOrdered(ctx.Context(), sector.limitOrder()); err != nil {
+		return err
+	}
+
+	log.Info("performing sector replication...")
 	ticketValue, ticketEpoch, err := m.getTicket(ctx, sector)
 	if err != nil {
+		m.p1Limit.release()
 		return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("getting ticket failed: %w", err)})
 	}
 
-	pc1o, err := m.sealer.SealPreCommit1(sector.sealingCtx(ctx.Context()), m.minerSector(sector.SectorNumber), ticketValue, sector.pieceInfos())
+	sctx, done := m.jobCtx(ctx.Context(), sector)
+	pc1o, err := m.sealer.SealPreCommit1(sctx, m.minerSector(sector.SectorNumber), ticketValue, sector.pieceInfos())
+	done()
+	m.p1Limit.release()
 	if err != nil {
 		return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("seal pre commit(1) failed: %w", err)})
 	}
@@ -108,11 +191,53 @@ func (m *Sealing) handlePreCommit1(ctx statemachine.Context, sector SectorInfo)
 		PreCommit1Out: pc1o,
 		TicketValue:   ticketValue,
 		TicketEpoch:   ticketEpoch,
+		Worker:        m.sectorWorker(ctx.Context(), sector.SectorNumber),
 	})
 }
 
+// handleExpiredDeals flags sectors with deals which started before the sector
+// could be committed, as those deals can't be activated anymore.
+//
+// With Config.DropExpiredDeals set, a sector which didn't start sealing yet, and
+// has no live deals left, is repacked with filler data instead. Pieces can't be
+// dropped selectively, so sectors which still have live deals are always
+// flagged.
+func (m *Sealing) handleExpiredDeals(ctx statemachine.Context, sector SectorInfo, err error) error {
+	log := m.stateLog(sector)
+
+	if m.cfg.DropExpiredDeals && (sector.State == Packing || sector.State == PreCommit1) {
+		live, lerr := liveDeals(ctx.Context(), sector, m.tickAPI(sector))
+		if lerr != nil {
+			log.Errorf("handleExpiredDeals: api error, not proceeding: %+v", lerr)
+			return nil
+		}
+
+		if len(live) == 0 {
+			log.Warnf("all deals in sector %d expired before sealing, repacking it with filler data: %+v", sector.SectorNumber, err)
+			return ctx.Send(SectorDropExpiredDeals{})
+		}
+	}
+
+	return ctx.Send(SectorDealsExpired{xerrors.Errorf("deal expired before sealing: %w", err)})
+}
+
 func (m *Sealing) handlePreCommit2(ctx statemachine.Context, sector SectorInfo) error {
-	cids, err := m.sealer.SealPreCommit2(sector.sealingCtx(ctx.Context()), m.minerSector(sector.SectorNumber), sector.PreCommit1Out)
+	log := m.stateLog(sector)
+
+	if ok, err := m.enoughDisk(ctx.Context(), sector, PreCommit2); err != nil {
+		log.Warnf("handlePreCommit2: checking disk space, waiting for disk: %+v", err)
+		return ctx.Send(SectorWaitDisk{Phase: PreCommit2})
+	} else if !ok {
+		return ctx.Send(SectorWaitDisk{Phase: PreCommit2})
+	}
+
+	if err := m.p2Limit.acquireOrdered(ctx.Context(), sector.limitOrder()); err != nil {
+		return err
+	}
+	sctx, done := m.jobCtx(ctx.Context(), sector)
+	cids, err := m.sealer.SealPreCommit2(sctx, m.minerSector(sector.SectorNumber), sector.PreCommit1Out)
+	done()
+	m.p2Limit.release()
 	if err != nil {
 		return ctx.Send(SectorSealPreCommit2Failed{xerrors.Errorf("seal pre commit(2) failed: %w", err)})
 	}
@@ -120,11 +245,14 @@ func (m *Sealing) handlePreCommit2(ctx statemachine.Context, sector SectorInfo)
 	return ctx.Send(SectorPreCommit2{
 		Unsealed: cids.Unsealed,
 		Sealed:   cids.Sealed,
+		Worker:   m.sectorWorker(ctx.Context(), sector.SectorNumber),
 	})
 }
 
 func (m *Sealing) handlePreCommitting(ctx statemachine.Context, sector SectorInfo) error {
-	tok, height, err := m.api.ChainHead(ctx.Context())
+	log := m.stateLog(sector)
+
+	tok, height, err := m.tickAPI(sector).ChainHead(ctx.Context())
 	if err != nil {
 		log.Errorf("handlePreCommitting: api error, not proceeding: %+v", err)
 		return nil
@@ -136,27 +264,68 @@ func (m *Sealing) handlePreCommitting(ctx statemachine.Context, sector SectorInf
 		return nil
 	}
 
-	if err := checkPrecommit(ctx.Context(), m.Address(), sector, tok, height, m.api); err != nil {
+	if err := checkPrecommit(ctx.Context(), m.Address(), sector, tok, height, m.tickAPI(sector)); err != nil {
 		switch err := err.(type) {
 		case *ErrApi:
 			log.Errorf("handlePreCommitting: api error, not proceeding: %+v", err)
 			return nil
-		case *ErrBadCommD: // TODO: Should this just back to packing? (not really needed since handlePreCommit1 will do that too)
-			return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("bad CommD error: %w", err)})
+		case *ErrBadCommD:
+			return ctx.Send(SectorDataCommitmentMismatch{xerrors.Errorf("bad CommD error: %w", err)})
 		case *ErrExpiredTicket:
-			return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("ticket expired: %w", err)})
+			return ctx.Send(SectorTicketExpired{xerrors.Errorf("ticket expired: %w", err)})
 		case *ErrBadTicket:
 			return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("bad ticket: %w", err)})
 		case *ErrPrecommitOnChain:
-			return ctx.Send(SectorPreCommitLanded{TipSet: tok}) // we re-did precommit
+			return ctx.Send(m.preCommitLanded(tok)) // we re-did precommit
 		default:
 			return xerrors.Errorf("checkPrecommit sanity check error: %w", err)
 		}
 	}
 
+	if err := m.checkTicketAge(sector, height); err != nil {
+		log.Warnf("redrawing the ticket of sector %d: %+v", sector.SectorNumber, err)
+		return ctx.Send(SectorTicketExpired{err})
+	}
+
+	// sealing can take a while, make sure the deals didn't start in the meantime
+	if err := checkPieces(ctx.Context(), sector, m.tickAPI(sector)); err != nil {
+		switch err.(type) {
+		case *ErrApi:
+			log.Errorf("handlePreCommitting: api error, not proceeding: %+v", err)
+			return nil
+		case *ErrExpiredDeals:
+			return m.handleExpiredDeals(ctx, sector, err)
+		default:
+			return xerrors.Errorf("checkPieces sanity check error: %w", err)
+		}
+	}
+
+	if ok, err := m.enoughPledge(ctx.Context(), sector, tok); err != nil {
+		log.Errorf("handlePreCommitting: api error, not proceeding: %+v", err)
+		return nil
+	} else if !ok {
+		return ctx.Send(SectorPledgeInsufficient{})
+	}
+
 	expiration, err := m.pcp.Expiration(ctx.Context(), sector.Pieces...)
 	if err != nil {
-		return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("handlePreCommitting: failed to compute pre-commit expiry: %w", err)})
+		switch err.(type) {
+		case *ErrApi:
+			log.Errorf("handlePreCommitting: api error, not proceeding: %+v", err)
+			return nil
+		case *ErrExpiredDeals:
+			return m.handleExpiredDeals(ctx, sector, err)
+		case *ErrDealsTooLong:
+			// sealing again won't shorten the deals
+			return ctx.Send(SectorDealsExpired{xerrors.Errorf("deals can't be sealed: %w", err)})
+		default:
+			return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("handlePreCommitting: failed to compute pre-commit expiry: %w", err)})
+		}
+	}
+
+	if min := m.minExpiration(expiration, height); min != expiration {
+		log.Warnf("extending expiration of sector %d from epoch %d to %d, MinSectorExpiration is %d", sector.SectorNumber, expiration, min, m.cfg.MinSectorExpiration)
+		expiration = min
 	}
 
 	params := &miner.SectorPreCommitInfo{
@@ -175,37 +344,121 @@ func (m *Sealing) handlePreCommitting(ctx statemachine.Context, sector SectorInf
 	}
 
 	log.Info("submitting precommit for sector: ", sector.SectorNumber)
-	mcid, err := m.api.SendMsg(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.PreCommitSector, big.NewInt(0), big.NewInt(1), 1000000, enc.Bytes())
+	gasPrice, gasLimit := m.messageGas(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.PreCommitSector, big.NewInt(0), enc.Bytes())
+	mcid, err := m.sendMsg(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.PreCommitSector, big.NewInt(0), gasPrice, gasLimit, enc.Bytes())
 	if err != nil {
 		return ctx.Send(SectorChainPreCommitFailed{xerrors.Errorf("pushing message to mpool: %w", err)})
 	}
 
-	return ctx.Send(SectorPreCommitted{Message: mcid})
+	return ctx.Send(SectorPreCommitted{Message: mcid, PreCommitInfo: *params})
 }
 
 func (m *Sealing) handlePreCommitWait(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
 	if sector.PreCommitMessage == nil {
 		return ctx.Send(SectorChainPreCommitFailed{xerrors.Errorf("precommit message was nil")})
 	}
 
 	// would be ideal to just use the events.Called handler, but it wouldnt be able to handle individual message timeouts
 	log.Info("Sector precommitted: ", sector.SectorNumber)
-	mw, err := m.api.StateWaitMsg(ctx.Context(), *sector.PreCommitMessage)
+	mw, landed, err := m.waitMsg(ctx.Context(), *sector.PreCommitMessage)
 	if err != nil {
 		return ctx.Send(SectorChainPreCommitFailed{err})
 	}
+	if !landed {
+		log.Warnf("precommit message %s of sector %d didn't land within %s", sector.PreCommitMessage, sector.SectorNumber, m.cfg.MessageWaitTimeout)
+		return ctx.Send(SectorMessageStuck{Wait: PreCommitWait})
+	}
 
 	if mw.Receipt.ExitCode != 0 {
-		log.Error("sector precommit failed: ", mw.Receipt.ExitCode)
-		err := xerrors.Errorf("sector precommit failed: %d", mw.Receipt.ExitCode)
-		return ctx.Send(SectorChainPreCommitFailed{err})
+		log.Errorf("precommit message %s of sector %d failed: %d", sector.PreCommitMessage, sector.SectorNumber, mw.Receipt.ExitCode)
+		return ctx.Send(SectorMessageFailed{Wait: PreCommitWait, ExitCode: mw.Receipt.ExitCode, GasUsed: mw.Receipt.GasUsed})
 	}
 	log.Info("precommit message landed on chain: ", sector.SectorNumber)
 
-	return ctx.Send(SectorPreCommitLanded{TipSet: mw.TipSetTok})
+	return ctx.Send(m.preCommitLanded(mw.TipSetTok))
+}
+
+func (m *Sealing) preCommitLanded(tok TipSetToken) interface{} {
+	if m.cfg.HoldCommits {
+		return SectorHoldCommit{TipSet: tok}
+	}
+	return SectorPreCommitLanded{TipSet: tok}
+}
+
+// handleCommitHold keeps the sector waiting for CommitSector, but triggers the
+// commit itself when the prove-commit deadline gets close
+func (m *Sealing) handleCommitHold(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
+	pci, err := m.api.StateSectorPreCommitInfo(ctx.Context(), m.maddr, sector.SectorNumber, sector.PreCommitTipSet)
+	if err != nil {
+		return xerrors.Errorf("getting precommit info: %w", err)
+	}
+	if pci == nil {
+		return ctx.Send(SectorChainPreCommitFailed{error: xerrors.Errorf("precommit info not found on chain")})
+	}
+
+	deadline := pci.PreCommitEpoch + miner.MaxSealDuration[sector.SectorType]
+	triggerHeight := m.commitTriggerHeight(sector.SectorType, pci)
+	alertHeight := triggerHeight - m.commitHoldMargin()
+
+	log.Infof("sector %d is waiting for CommitSector, will commit anyway at epoch %d", sector.SectorNumber, triggerHeight)
+
+	err = m.events.ChainAt(func(ectx context.Context, tok TipSetToken, curH abi.ChainEpoch) error {
+		si, err := m.GetSectorInfo(sector.SectorNumber)
+		if err != nil {
+			return xerrors.Errorf("getting sector info: %w", err)
+		}
+		if si.State != CommitHold {
+			return nil
+		}
+
+		log.Warnf("sector %d is still held, and will be committed automatically at epoch %d (prove-commit deadline %d)", sector.SectorNumber, triggerHeight, deadline)
+		m.addCommitAlert(CommitDeadlineAlert{
+			SectorNumber: sector.SectorNumber,
+			TriggerAt:    triggerHeight,
+			Deadline:     deadline,
+		})
+		return nil
+	}, func(ctx context.Context, ts TipSetToken) error {
+		return nil
+	}, InteractivePoRepConfidence, alertHeight)
+	if err != nil {
+		log.Warn("handleCommitHold ChainAt errored: ", err)
+	}
+
+	err = m.events.ChainAt(func(ectx context.Context, tok TipSetToken, curH abi.ChainEpoch) error {
+		si, err := m.GetSectorInfo(sector.SectorNumber)
+		if err != nil {
+			return xerrors.Errorf("getting sector info: %w", err)
+		}
+		if si.State != CommitHold {
+			return nil // already triggered
+		}
+
+		log.Warnf("sector %d is close to its prove-commit deadline, committing", sector.SectorNumber)
+		return ctx.Send(SectorCommitTrigger{})
+	}, func(ctx context.Context, ts TipSetToken) error {
+		return nil
+	}, InteractivePoRepConfidence, triggerHeight)
+	if err != nil {
+		log.Warn("handleCommitHold ChainAt errored: ", err)
+	}
+
+	return nil
+}
+
+// preCommitExpired checks if the precommit can't be proven at height anymore.
+// The miner actor burns the deposit of such precommits
+func preCommitExpired(spt abi.RegisteredSealProof, pci *miner.SectorPreCommitOnChainInfo, height abi.ChainEpoch) bool {
+	return pci != nil && height > pci.PreCommitEpoch+miner.MaxSealDuration[spt]
 }
 
 func (m *Sealing) handleWaitSeed(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
 	pci, err := m.api.StateSectorPreCommitInfo(ctx.Context(), m.maddr, sector.SectorNumber, sector.PreCommitTipSet)
 	if err != nil {
 		return xerrors.Errorf("getting precommit info: %w", err)
@@ -214,14 +467,33 @@ func (m *Sealing) handleWaitSeed(ctx statemachine.Context, sector SectorInfo) er
 		return ctx.Send(SectorChainPreCommitFailed{error: xerrors.Errorf("precommit info not found on chain")})
 	}
 
-	randHeight := pci.PreCommitEpoch + miner.PreCommitChallengeDelay
+	// after downtime, the precommit may not be provable anymore
+	_, height, err := m.tickAPI(sector).ChainHead(ctx.Context())
+	if err != nil {
+		log.Errorf("handleWaitSeed: api error, not proceeding: %+v", err)
+		return nil
+	}
+	if preCommitExpired(sector.SectorType, pci, height) {
+		return ctx.Send(SectorPreCommitExpired{Deposit: pci.PreCommitDeposit})
+	}
+
+	randHeight := m.commitSeedEpoch(pci)
 
 	err = m.events.ChainAt(func(ectx context.Context, tok TipSetToken, curH abi.ChainEpoch) error {
-		buf := new(bytes.Buffer)
-		if err := m.maddr.MarshalCBOR(buf); err != nil {
+		// randomness of an epoch the chain didn't reach yet isn't the seed
+		if curH < randHeight {
+			log.Warnf("height handler called at epoch %d, before seed epoch %d", curH, randHeight)
+			var err error
+			if tok, curH, err = m.waitSeedEpoch(ectx, randHeight); err != nil {
+				return err
+			}
+		}
+
+		entropy, err := m.entropy.SeedEntropy(m.maddr, sector.SectorNumber)
+		if err != nil {
 			return err
 		}
-		rand, err := m.api.ChainGetRandomness(ectx, tok, crypto.DomainSeparationTag_InteractiveSealChallengeSeed, randHeight, buf.Bytes())
+		rand, err := m.api.ChainGetRandomness(ectx, tok, crypto.DomainSeparationTag_InteractiveSealChallengeSeed, randHeight, entropy)
 		if err != nil {
 			err = xerrors.Errorf("failed to get randomness for computing seal proof (ch %d; rh %d; tsk %x): %w", curH, randHeight, tok, err)
 
@@ -245,36 +517,83 @@ func (m *Sealing) handleWaitSeed(ctx statemachine.Context, sector SectorInfo) er
 }
 
 func (m *Sealing) handleCommitting(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
+	// a reorg can drop the precommit after it landed, don't prove against it
+	tok, height, err := m.tickAPI(sector).ChainHead(ctx.Context())
+	if err != nil {
+		log.Errorf("handleCommitting: api error, not proceeding: %+v", err)
+		return nil
+	}
+
+	cs, err := m.sectorsChainState(ctx.Context(), []abi.SectorNumber{sector.SectorNumber}, tok)
+	if err != nil {
+		log.Errorf("handleCommitting: api error, not proceeding: %+v", err)
+		return nil
+	}
+	// proven sectors don't have precommit info anymore
+	onChain := cs.Sectors[sector.SectorNumber]
+	if onChain.PreCommit == nil && onChain.Sector == nil {
+		if height-(sector.TicketEpoch+SealRandomnessLookback) > SealRandomnessLookbackLimit(sector.SectorType) {
+			return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("precommit not found on chain, and the ticket expired: seal height: %d, head: %d", sector.TicketEpoch+SealRandomnessLookback, height)})
+		}
+		return ctx.Send(SectorChainPreCommitFailed{xerrors.Errorf("precommit not found on chain, it was reverted")})
+	}
+	if preCommitExpired(sector.SectorType, onChain.PreCommit, height) {
+		return ctx.Send(SectorPreCommitExpired{Deposit: onChain.PreCommit.PreCommitDeposit})
+	}
+
 	log.Info("scheduling seal proof computation...")
 
 	log.Infof("KOMIT %d %x(%d); %x(%d); %v; r:%x; d:%x", sector.SectorNumber, sector.TicketValue, sector.TicketEpoch, sector.SeedValue, sector.SeedEpoch, sector.pieceInfos(), sector.CommR, sector.CommD)
 
-	cids := storage.SectorCids{
-		Unsealed: *sector.CommD,
-		Sealed:   *sector.CommR,
-	}
-	c2in, err := m.sealer.SealCommit1(sector.sealingCtx(ctx.Context()), m.minerSector(sector.SectorNumber), sector.TicketValue, sector.SeedValue, sector.pieceInfos(), cids)
-	if err != nil {
-		return ctx.Send(SectorComputeProofFailed{xerrors.Errorf("computing seal proof failed(1): %w", err)})
+	// resubmits of a commit whose seed and precommit didn't change reuse the
+	// proof sent before
+	proof, cached := sector.cachedProof()
+	if cached {
+		log.Infof("reusing the commit proof computed for seed epoch %d", sector.SeedEpoch)
+	} else {
+		cids := storage.SectorCids{
+			Unsealed: *sector.CommD,
+			Sealed:   *sector.CommR,
+		}
+		c2in, err := m.sealCommit1(ctx.Context(), sector, cids)
+		if err != nil {
+			return ctx.Send(SectorComputeProofFailed{xerrors.Errorf("computing seal proof failed(1): %w", err)})
+		}
+
+		if err := m.c2Limit.acquireOrdered(ctx.Context(), sector.limitOrder()); err != nil {
+			return err
+		}
+		sctx, done := m.jobCtx(ctx.Context(), sector)
+		proof, err = m.sealer.SealCommit2(sctx, m.minerSector(sector.SectorNumber), c2in)
+		done()
+		m.c2Limit.release()
+		if err != nil {
+			return ctx.Send(SectorComputeProofFailed{xerrors.Errorf("computing seal proof failed(2): %w", err)})
+		}
 	}
+	worker := m.sectorWorker(ctx.Context(), sector.SectorNumber)
 
-	proof, err := m.sealer.SealCommit2(sector.sealingCtx(ctx.Context()), m.minerSector(sector.SectorNumber), c2in)
-	if err != nil {
-		return ctx.Send(SectorComputeProofFailed{xerrors.Errorf("computing seal proof failed(2): %w", err)})
+	if err := m.waitCommitDeadline(ctx, sector); err != nil {
+		return err
 	}
 
-	tok, _, err := m.api.ChainHead(ctx.Context())
+	// the pinned head is stale after computing the proof and waiting for the
+	// commit deadline
+	tok, _, err = m.api.ChainHead(ctx.Context())
 	if err != nil {
 		log.Errorf("handleCommitting: api error, not proceeding: %+v", err)
 		return nil
 	}
 
 	if err := m.checkCommit(ctx.Context(), sector, proof, tok); err != nil {
+		if _, bad := err.(*ErrInvalidProof); bad {
+			return ctx.Send(SectorBadProof{xerrors.Errorf("not submitting commit proof: %w", err)})
+		}
 		return ctx.Send(SectorCommitFailed{xerrors.Errorf("commit check error: %w", err)})
 	}
 
-	// TODO: Consider splitting states and persist proof for faster recovery
-
 	params := &miner.ProveCommitSectorParams{
 		SectorNumber: sector.SectorNumber,
 		Proof:        proof,
@@ -291,36 +610,120 @@ func (m *Sealing) handleCommitting(ctx statemachine.Context, sector SectorInfo)
 		return nil
 	}
 
-	collateral, err := m.api.StateMinerInitialPledgeCollateral(ctx.Context(), m.maddr, sector.SectorNumber, tok)
-	if err != nil {
-		return xerrors.Errorf("getting initial pledge collateral: %w", err)
+	collateral, err := m.commitCollateral(ctx.Context(), sector.SectorNumber, tok)
+	if _, short := err.(*ErrInsufficientCollateral); short {
+		return ctx.Send(SectorCommitFailed{err})
+	} else if err != nil {
+		return err
 	}
 
 	// TODO: check seed / ticket are up to date
-	mcid, err := m.api.SendMsg(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.ProveCommitSector, collateral, big.NewInt(1), 1000000, enc.Bytes())
+	gasPrice, gasLimit := m.messageGas(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.ProveCommitSector, collateral, enc.Bytes())
+	mcid, err := m.sendMsg(ctx.Context(), waddr, m.maddr, builtin.MethodsMiner.ProveCommitSector, collateral, gasPrice, gasLimit, enc.Bytes())
 	if err != nil {
 		return ctx.Send(SectorCommitFailed{xerrors.Errorf("pushing message to mpool: %w", err)})
 	}
 
 	return ctx.Send(SectorCommitted{
-		Proof:   proof,
-		Message: mcid,
+		Proof:     proof,
+		SeedEpoch: sector.SeedEpoch,
+		Message:   mcid,
+		Worker:    worker,
 	})
 }
 
+func (m *Sealing) commitHoldMargin() abi.ChainEpoch {
+	if m.cfg.CommitHoldMargin == 0 {
+		return defaultCommitHoldMargin
+	}
+	return m.cfg.CommitHoldMargin
+}
+
+// commitTriggerHeight is the height after which a sector is committed without
+// waiting for CommitSector or the CommitDeadlinePolicy
+func (m *Sealing) commitTriggerHeight(spt abi.RegisteredSealProof, pci *miner.SectorPreCommitOnChainInfo) abi.ChainEpoch {
+	return pci.PreCommitEpoch + miner.MaxSealDuration[spt] - m.commitHoldMargin()
+}
+
+// forgetCommitDeadline tells the CommitDeadlinePolicy the sector stopped
+// asking to commit, if it implements CommitDeadlineForgetter
+func (m *Sealing) forgetCommitDeadline(sn abi.SectorNumber) {
+	if f, ok := m.cfg.CommitDeadlinePolicy.(CommitDeadlineForgetter); ok {
+		f.Forget(sn)
+	}
+}
+
+// waitCommitDeadline holds the sector until the configured
+// CommitDeadlinePolicy allows it to submit its commit, or until the sector
+// gets close to its prove-commit deadline
+func (m *Sealing) waitCommitDeadline(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
+	if m.cfg.CommitDeadlinePolicy == nil {
+		return nil
+	}
+
+	pci, err := m.api.StateSectorPreCommitInfo(ctx.Context(), m.maddr, sector.SectorNumber, sector.PreCommitTipSet)
+	if err != nil {
+		return xerrors.Errorf("getting precommit info: %w", err)
+	}
+
+	for {
+		tok, height, err := m.api.ChainHead(ctx.Context())
+		if err != nil {
+			log.Errorf("waitCommitDeadline(%d): api error: %+v", sector.SectorNumber, err)
+		} else if pci != nil && height >= m.commitTriggerHeight(sector.SectorType, pci) {
+			log.Warnf("waitCommitDeadline(%d): sector is close to its prove-commit deadline, committing now", sector.SectorNumber)
+			m.forgetCommitDeadline(sector.SectorNumber)
+			return nil
+		} else {
+			ok, err := m.cfg.CommitDeadlinePolicy.ShouldCommit(ctx.Context(), sector.SectorNumber, tok, height)
+			if err != nil {
+				log.Errorf("waitCommitDeadline(%d): commit deadline policy error, committing now: %+v", sector.SectorNumber, err)
+				m.forgetCommitDeadline(sector.SectorNumber)
+				return nil
+			}
+			if ok {
+				return nil
+			}
+		}
+
+		select {
+		case <-m.clock.After(commitDeadlineRecheck):
+		case <-ctx.Context().Done():
+			return ctx.Context().Err()
+		}
+	}
+}
+
 func (m *Sealing) handleCommitWait(ctx statemachine.Context, sector SectorInfo) error {
+	log := m.stateLog(sector)
+
 	if sector.CommitMessage == nil {
 		log.Errorf("sector %d entered commit wait state without a message cid", sector.SectorNumber)
 		return ctx.Send(SectorCommitFailed{xerrors.Errorf("entered commit wait with no commit cid")})
 	}
 
-	mw, err := m.api.StateWaitMsg(ctx.Context(), *sector.CommitMessage)
+	start := m.clock.Now()
+	mw, landed, err := m.waitMsg(ctx.Context(), *sector.CommitMessage)
 	if err != nil {
 		return ctx.Send(SectorCommitFailed{xerrors.Errorf("failed to wait for porep inclusion: %w", err)})
 	}
+	if !landed {
+		log.Warnf("commit message %s of sector %d didn't land within %s", sector.CommitMessage, sector.SectorNumber, m.cfg.MessageWaitTimeout)
+		return ctx.Send(SectorMessageStuck{Wait: CommitWait})
+	}
+	m.metrics.CommitWaited(sector.SectorNumber, m.clock.Now().Sub(start))
 
 	if mw.Receipt.ExitCode != 0 {
-		return ctx.Send(SectorCommitFailed{xerrors.Errorf("submitting sector proof failed (exit=%d, msg=%s) (t:%x; s:%x(%d); p:%x)", mw.Receipt.ExitCode, sector.CommitMessage, sector.TicketValue, sector.SeedValue, sector.SeedEpoch, sector.Proof)})
+		// a resent message fails if the stuck one landed in the meantime
+		if si, err := m.api.StateSectorGetInfo(ctx.Context(), m.maddr, sector.SectorNumber, mw.TipSetTok); err == nil && si != nil {
+			log.Warnf("commit message %s of sector %d failed, but the sector was already proven", sector.CommitMessage, sector.SectorNumber)
+			return ctx.Send(m.sectorProven(ctx.Context(), sector, mw.TipSetTok))
+		}
+
+		log.Errorf("commit message %s of sector %d failed: %d", sector.CommitMessage, sector.SectorNumber, mw.Receipt.ExitCode)
+		return ctx.Send(SectorMessageFailed{Wait: CommitWait, ExitCode: mw.Receipt.ExitCode, GasUsed: mw.Receipt.GasUsed})
 	}
 
 	_, err = m.api.StateSectorGetInfo(ctx.Context(), m.maddr, sector.SectorNumber, mw.TipSetTok)
@@ -328,15 +731,89 @@ func (m *Sealing) handleCommitWait(ctx statemachine.Context, sector SectorInfo)
 		return ctx.Send(SectorCommitFailed{xerrors.Errorf("proof validation failed, sector not found in sector set after cron: %w", err)})
 	}
 
-	return ctx.Send(SectorProving{})
+	if m.cfg.CommitWaitConfidence > 0 {
+		reverted, err := m.waitCommitConfidence(ctx.Context(), sector.SectorNumber, mw.Height+m.cfg.CommitWaitConfidence)
+		if err != nil {
+			return err
+		}
+		if reverted {
+			log.Warnf("commit message %s of sector %d was reverted, resubmitting", sector.CommitMessage, sector.SectorNumber)
+			return ctx.Send(SectorCommitReverted{})
+		}
+	}
+
+	return ctx.Send(m.sectorProven(ctx.Context(), sector, mw.TipSetTok))
+}
+
+// waitCommitConfidence waits for the chain to reach the given height. reverted
+// is true if the sector disappears from the sector set before that
+func (m *Sealing) waitCommitConfidence(ctx context.Context, sn abi.SectorNumber, height abi.ChainEpoch) (reverted bool, err error) {
+	log := m.sectorLog(sn)
+
+	for {
+		tok, head, err := m.api.ChainHead(ctx)
+		if err != nil {
+			log.Errorf("waiting for commit confidence of sector %d: %+v", sn, err)
+		} else {
+			si, err := m.api.StateSectorGetInfo(ctx, m.maddr, sn, tok)
+			switch {
+			case err != nil:
+				log.Errorf("waiting for commit confidence of sector %d: %+v", sn, err)
+			case si == nil:
+				return true, nil
+			case head >= height:
+				return false, nil
+			}
+		}
+
+		select {
+		case <-m.clock.After(commitConfidenceRecheck):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
 }
 
 func (m *Sealing) handleFinalizeSector(ctx statemachine.Context, sector SectorInfo) error {
 	// TODO: Maybe wait for some finality
 
-	if err := m.sealer.FinalizeSector(sector.sealingCtx(ctx.Context()), m.minerSector(sector.SectorNumber), nil); err != nil {
+	if m.cfg.OnSectorCommitted != nil && !sector.CommitNotified {
+		tok, _, err := m.tickAPI(sector).ChainHead(ctx.Context())
+		if err != nil {
+			return ctx.Send(SectorFinalizeFailed{xerrors.Errorf("notifying about commit: getting chain head: %w", err)})
+		}
+		onChain, err := m.api.StateSectorGetInfo(ctx.Context(), m.maddr, sector.SectorNumber, tok)
+		if err != nil {
+			return ctx.Send(SectorFinalizeFailed{xerrors.Errorf("notifying about commit: getting on chain sector info: %w", err)})
+		}
+		if onChain == nil {
+			m.stateLog(sector).Warnf("sector %d isn't on chain anymore, commit was reverted", sector.SectorNumber)
+			return ctx.Send(SectorCommitReverted{})
+		}
+
+		m.cfg.OnSectorCommitted(sector.SectorNumber, *onChain)
+		if err := ctx.Send(SectorCommitNotified{}); err != nil {
+			return err
+		}
+	}
+
+	if m.cfg.CheckUnsealedPieces {
+		// the unsealed copy is only dropped when it's known to be good
+		err := m.checkUnsealedPieces(ctx.Context(), sector)
+		switch err.(type) {
+		case nil:
+		case *ErrCorruptPiece:
+			return ctx.Send(SectorUnsealedCorrupt{xerrors.Errorf("checking unsealed deal data: %w", err)})
+		default:
+			return ctx.Send(SectorFinalizeFailed{xerrors.Errorf("checking unsealed deal data: %w", err)})
+		}
+	}
+
+	if err := m.finalizeSector(sector.sealingCtx(ctx.Context()), sector); err != nil {
 		return ctx.Send(SectorFinalizeFailed{xerrors.Errorf("finalize sector: %w", err)})
 	}
 
-	return ctx.Send(SectorFinalized{})
+	m.recordTimings(sector)
+
+	return ctx.Send(SectorFinalized{Worker: m.sectorWorker(ctx.Context(), sector.SectorNumber)})
 }
@@ -0,0 +1,684 @@
+package sealing
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/storiface"
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// dealSector returns a sector with a single deal which has started at epoch 5,
+// while the chain is at epoch 10
+func dealSector(h *testHarness, state SectorState) SectorInfo {
+	h.api.setHead(10)
+	h.api.setDeal(1, market.DealProposal{
+		PieceCID:   testCommD,
+		PieceSize:  1024,
+		StartEpoch: 5,
+		EndEpoch:   1000,
+	})
+
+	commD, commR := testCommD, testCommR
+	return SectorInfo{
+		State:        state,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces: []Piece{
+			{
+				Piece:    abi.PieceInfo{Size: 1024, PieceCID: testCommD},
+				DealInfo: &DealInfo{DealID: 1, DealSchedule: DealSchedule{StartEpoch: 5, EndEpoch: 1000}},
+			},
+			{
+				Piece: abi.PieceInfo{Size: 1024, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(1024).Unpadded())},
+			},
+		},
+		CommD: &commD,
+		CommR: &commR,
+	}
+}
+
+func TestExpiredDealsBeforeSealing(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.start(dealSector(h, PreCommit1))
+
+	si := h.waitState(1, DealsExpired)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "deal expired before sealing")
+	require.Len(t, si.Pieces, 2)
+}
+
+func TestExpiredDealsDropped(t *testing.T) {
+	h := newTestHarness(t, Config{DropExpiredDeals: true})
+
+	sealed := make(chan []abi.PieceInfo, 1)
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		sealed <- pieces
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	h.start(dealSector(h, PreCommit1))
+
+	pieces := <-sealed
+	require.NotEmpty(t, pieces)
+	for _, p := range pieces {
+		require.Equal(t, zerocomm.ZeroPieceCommitment(p.Size.Unpadded()), p.PieceCID)
+	}
+
+	si := h.sector(1)
+	require.Equal(t, PreCommit1, si.State)
+	require.Empty(t, si.dealIDs())
+}
+
+func TestExpiredDealsAfterSealing(t *testing.T) {
+	// too late to repack
+	h := newTestHarness(t, Config{DropExpiredDeals: true})
+	h.start(dealSector(h, PreCommitting))
+
+	si := h.waitState(1, DealsExpired)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "deal expired before sealing")
+	require.Empty(t, h.api.sentMsgs())
+}
+
+func TestDealsTooLongNotResealed(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	si := dealSector(h, PreCommitting)
+	h.api.setDeal(1, market.DealProposal{
+		PieceCID:   testCommD,
+		PieceSize:  1024,
+		StartEpoch: 5000,
+		EndEpoch:   11 + MaxSectorLifetime,
+	})
+	si.Pieces[0].DealInfo.DealSchedule.StartEpoch = 5000
+	si.TicketValue = abi.SealRandomness(testRand)
+	si.TicketEpoch = 5
+	h.start(si)
+
+	si = h.waitState(1, DealsExpired)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "maximum sector lifetime")
+	require.False(t, hasEvent(si, SectorSealPreCommit1Failed{}))
+	require.Empty(t, h.api.sentMsgs())
+}
+
+func finalizingSector(h *testHarness) SectorInfo {
+	si := dealSector(h, FinalizeSector) // deal start doesn't matter after commit
+	si.TicketValue = abi.SealRandomness(testRand)
+	return si
+}
+
+// zeroCommP returns testCommD for all-zero pieces, and testCommR otherwise
+func zeroCommP(spt abi.RegisteredSealProof, piece io.Reader, size abi.UnpaddedPieceSize) (cid.Cid, error) {
+	b, err := ioutil.ReadAll(piece)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if len(b) != int(size) {
+		return cid.Undef, xerrors.Errorf("read %d bytes, expected %d", len(b), size)
+	}
+
+	for _, c := range b {
+		if c != 0 {
+			return testCommR, nil
+		}
+	}
+	return testCommD, nil
+}
+
+func TestCheckUnsealedPieces(t *testing.T) {
+	h := newTestHarness(t, Config{CheckUnsealedPieces: true})
+	h.m.commP = zeroCommP
+
+	finalized := make(chan struct{}, 1)
+	h.sealer.finalize = func(sector abi.SectorID) error {
+		finalized <- struct{}{}
+		return nil
+	}
+
+	h.start(finalizingSector(h))
+	h.waitState(1, Proving)
+	require.Len(t, finalized, 1)
+}
+
+func TestCheckUnsealedPiecesCorrupted(t *testing.T) {
+	h := newTestHarness(t, Config{CheckUnsealedPieces: true})
+	h.m.commP = zeroCommP
+
+	h.sealer.readPiece = func(w io.Writer, sector abi.SectorID, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) error {
+		require.Equal(t, storiface.UnpaddedByteIndex(0), offset)
+
+		b := make([]byte, size)
+		b[100] = 1 // bit flip in the unsealed copy
+		_, err := w.Write(b)
+		return err
+	}
+	h.sealer.finalize = func(sector abi.SectorID) error {
+		t.Error("unsealed copy of a corrupted sector was dropped")
+		return nil
+	}
+
+	h.start(finalizingSector(h))
+
+	si := h.waitState(1, UnsealedCorrupt)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "doesn't match its PieceCID")
+	require.False(t, hasEvent(si, SectorFinalizeFailed{}))
+
+	// checked again once the unsealed copy was fixed
+	h.sealer.readPiece = nil
+	h.sealer.finalize = nil
+	require.NoError(t, h.m.RetryUnsealedCheck(context.Background(), 1))
+	h.waitState(1, Proving)
+}
+
+func heldSector(h *testHarness) SectorInfo {
+	si := h.committingSector(1)
+	si.State = PreCommitWait
+	si.PreCommitMessage = &testCommD
+	si.SeedValue, si.SeedEpoch = nil, 0
+	return si
+}
+
+func TestCommitHold(t *testing.T) {
+	h := newTestHarness(t, Config{HoldCommits: true, CommitHoldMargin: 100})
+	h.start(heldSector(h))
+
+	h.waitState(1, CommitHold)
+	h.api.waitHandlers(t, 2) // deadline alert and trigger
+
+	h.api.setHead(1000)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, CommitHold, h.sector(1).State)
+
+	require.NoError(t, h.m.CommitSector(1))
+	si := h.waitState(1, Proving)
+	require.Equal(t, abi.ChainEpoch(10+miner.PreCommitChallengeDelay), si.SeedEpoch)
+
+	require.Error(t, h.m.CommitSector(1))
+
+	// the deadline trigger doesn't affect committed sectors
+	h.api.setHead(miner.MaxSealDuration[si.SectorType])
+	require.Equal(t, Proving, h.sector(1).State)
+}
+
+func TestCommitHoldDeadline(t *testing.T) {
+	h := newTestHarness(t, Config{HoldCommits: true, CommitHoldMargin: 100})
+	h.start(heldSector(h))
+
+	h.waitState(1, CommitHold)
+	h.api.waitHandlers(t, 2)
+
+	deadline := 10 + miner.MaxSealDuration[abi.RegisteredSealProof_StackedDrg2KiBV1]
+
+	h.api.setHead(deadline - 101)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, CommitHold, h.sector(1).State)
+
+	h.api.setHead(deadline - 100)
+	h.waitState(1, Proving)
+}
+
+type neverCommit struct{}
+
+func (neverCommit) ShouldCommit(ctx context.Context, sn abi.SectorNumber, tok TipSetToken, height abi.ChainEpoch) (bool, error) {
+	return false, nil
+}
+
+func TestCommitHoldDeadlineOverridesPolicy(t *testing.T) {
+	h := newTestHarness(t, Config{HoldCommits: true, CommitHoldMargin: 100, CommitDeadlinePolicy: neverCommit{}})
+	h.start(heldSector(h))
+
+	h.waitState(1, CommitHold)
+	h.api.waitHandlers(t, 2)
+	require.Empty(t, h.m.Health().CommitDeadlines)
+
+	deadline := 10 + miner.MaxSealDuration[abi.RegisteredSealProof_StackedDrg2KiBV1]
+
+	h.api.setHead(deadline - 200)
+	require.Equal(t, []CommitDeadlineAlert{
+		{SectorNumber: 1, TriggerAt: deadline - 100, Deadline: deadline},
+	}, h.m.Health().CommitDeadlines)
+
+	h.api.setHead(deadline - 100)
+	h.waitState(1, Proving)
+	require.Empty(t, h.m.Health().CommitDeadlines)
+}
+
+// forgetfulPolicy never allows commits, and records the sectors it's told to
+// forget
+type forgetfulPolicy struct {
+	neverCommit
+
+	lk     sync.Mutex
+	forgot []abi.SectorNumber
+}
+
+func (p *forgetfulPolicy) Forget(sector abi.SectorNumber) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.forgot = append(p.forgot, sector)
+}
+
+func TestCommitDeadlineBypassForgets(t *testing.T) {
+	p := &forgetfulPolicy{}
+	h := newTestHarness(t, Config{CommitHoldMargin: 100, CommitDeadlinePolicy: p})
+
+	deadline := 10 + miner.MaxSealDuration[abi.RegisteredSealProof_StackedDrg2KiBV1]
+	si := h.committingSector(1)
+	h.api.setHead(deadline - 100)
+	h.start(si)
+	h.waitState(1, Proving)
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	require.Equal(t, []abi.SectorNumber{1}, p.forgot)
+}
+
+func TestCommitWaitConfidence(t *testing.T) {
+	clk := newFakeClock()
+	sm := NewStateMetrics()
+	h := newTestHarness(t, Config{Clock: clk, CommitWaitConfidence: 5, Metrics: sm})
+	h.api.waitMsg = func(cid.Cid) (MsgLookup, error) {
+		return MsgLookup{Height: 10}, nil
+	}
+	h.start(h.committingSector(1))
+
+	clk.waitTimers(t, 1)
+	require.Equal(t, CommitWait, h.sector(1).State)
+
+	h.api.setHead(14)
+	clk.advance(commitConfidenceRecheck)
+	clk.waitTimers(t, 1)
+	require.Equal(t, CommitWait, h.sector(1).State)
+
+	h.api.setHead(15)
+	clk.advance(commitConfidenceRecheck)
+	h.waitState(1, Proving)
+	require.Len(t, h.api.sentMsgs(), 1)
+	require.Equal(t, uint64(1), sm.Snapshot().CommitWait.Count)
+	require.Equal(t, 2*commitConfidenceRecheck, sm.Snapshot().TimeInState[CommitWait].Sum)
+}
+
+func TestCommitWaitConfidenceReorg(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk, CommitWaitConfidence: 5})
+	h.api.waitMsg = func(cid.Cid) (MsgLookup, error) {
+		return MsgLookup{Height: 10}, nil
+	}
+	h.start(h.committingSector(1))
+	clk.waitTimers(t, 1)
+
+	// the commit is reverted before it's deep enough
+	h.api.lk.Lock()
+	delete(h.api.sectors, 1)
+	h.api.lk.Unlock()
+	clk.advance(commitConfidenceRecheck)
+
+	// and resubmitted
+	h.waitSent(2)
+	clk.waitTimers(t, 1)
+	require.Equal(t, CommitWait, h.sector(1).State)
+
+	h.api.setHead(15)
+	clk.advance(commitConfidenceRecheck)
+	si := h.waitState(1, Proving)
+	require.True(t, hasEvent(si, SectorCommitReverted{}))
+
+	for _, msg := range h.api.sentMsgs() {
+		require.Equal(t, builtin.MethodsMiner.ProveCommitSector, msg.method)
+	}
+}
+
+func TestCommitRevertedReusesProof(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk, CommitWaitConfidence: 5})
+	h.api.waitMsg = func(cid.Cid) (MsgLookup, error) {
+		return MsgLookup{Height: 10}, nil
+	}
+
+	var lk sync.Mutex
+	var computed int
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		lk.Lock()
+		defer lk.Unlock()
+		computed++
+		return storage.Proof{2}, nil
+	}
+	h.start(h.committingSector(1))
+	clk.waitTimers(t, 1)
+
+	h.api.lk.Lock()
+	delete(h.api.sectors, 1)
+	h.api.lk.Unlock()
+	clk.advance(commitConfidenceRecheck)
+
+	// the seed didn't change, the same proof is resubmitted
+	h.waitSent(2)
+	clk.waitTimers(t, 1)
+	h.api.setHead(15)
+	clk.advance(commitConfidenceRecheck)
+	si := h.waitState(1, Proving)
+
+	lk.Lock()
+	require.Equal(t, 1, computed)
+	lk.Unlock()
+	require.Equal(t, []byte{2}, si.Proof)
+
+	msgs := h.api.sentMsgs()
+	require.Equal(t, msgs[0].params, msgs[1].params)
+}
+
+func TestCommitSeedChangeDropsProof(t *testing.T) {
+	si := SectorInfo{SeedEpoch: 10, SeedValue: abi.InteractiveSealRandomness{1}, Proof: []byte{1}, ProofSeedEpoch: 10}
+	commR := testCommR
+	si.CommR, si.ProofCommR = &commR, &commR
+
+	_, cached := si.cachedProof()
+	require.True(t, cached)
+
+	SectorSeedReady{SeedValue: abi.InteractiveSealRandomness{1}, SeedEpoch: 10}.apply(&si)
+	_, cached = si.cachedProof()
+	require.True(t, cached)
+
+	SectorSeedReady{SeedValue: abi.InteractiveSealRandomness{2}, SeedEpoch: 10}.apply(&si)
+	_, cached = si.cachedProof()
+	require.False(t, cached)
+}
+
+// testVerifier counts VerifySeal calls, and fails them unless valid is set
+type testVerifier struct {
+	fakeVerifier
+
+	lk    sync.Mutex
+	valid bool
+	calls int
+}
+
+func (v *testVerifier) VerifySeal(abi.SealVerifyInfo) (bool, error) {
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	v.calls++
+	return v.valid, nil
+}
+
+func (v *testVerifier) verified() int {
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	return v.calls
+}
+
+func TestCommitProofVerified(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	v := &testVerifier{valid: true}
+	h.m.verif = v
+	h.start(h.committingSector(1))
+
+	h.waitState(1, Proving)
+	require.Equal(t, 1, v.verified())
+	require.Len(t, h.api.sentMsgs(), 1)
+}
+
+func TestCommitBadProof(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk})
+	v := &testVerifier{}
+	h.m.verif = v
+	h.start(h.committingSector(1))
+
+	si := h.waitState(1, BadProof)
+	require.True(t, hasEvent(si, SectorBadProof{}))
+	require.Equal(t, 1, v.verified())
+	require.Empty(t, h.api.sentMsgs())
+
+	// the proof is recomputed once
+	clk.waitTimers(t, 1)
+	clk.advance(minRetryTime)
+	clk.waitTimers(t, 1)
+	require.Equal(t, 2, v.verified())
+	require.Equal(t, BadProof, h.sector(1).State)
+	require.Empty(t, h.api.sentMsgs())
+
+	clk.advance(minRetryTime)
+	h.waitState(1, SealPreCommit1Failed)
+	require.Empty(t, h.api.sentMsgs())
+}
+
+func TestCommitSkipVerification(t *testing.T) {
+	h := newTestHarness(t, Config{SkipCommitVerification: true})
+	v := &testVerifier{}
+	h.m.verif = v
+	h.start(h.committingSector(1))
+
+	h.waitState(1, Proving)
+	require.Zero(t, v.verified())
+	require.Len(t, h.api.sentMsgs(), 1)
+}
+
+func TestCommitPreCommitReverted(t *testing.T) {
+	clk := newFakeClock()
+	h := newTestHarness(t, Config{Clock: clk})
+	h.api.setHead(10)
+
+	si := h.committingSector(1)
+	si.Pieces = []Piece{{Piece: abi.PieceInfo{Size: 2048, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(2048).Unpadded())}}}
+	si.TicketEpoch = 5
+	h.api.lk.Lock()
+	delete(h.api.precommits, 1) // dropped by a reorg
+	delete(h.api.sectors, 1)
+	h.api.lk.Unlock()
+	h.start(si)
+
+	h.waitState(1, PreCommitFailed)
+	clk.waitTimers(t, 1)
+	clk.advance(minRetryTime)
+
+	// precommit resubmitted instead of proving
+	h.waitState(1, WaitSeed)
+	msgs := h.api.sentMsgs()
+	require.Len(t, msgs, 1)
+	require.Equal(t, builtin.MethodsMiner.PreCommitSector, msgs[0].method)
+}
+
+func TestCommitPreCommitExpired(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	si := h.committingSector(1)
+	si.TicketEpoch = 5
+	h.api.lk.Lock()
+	delete(h.api.precommits, 1)
+	delete(h.api.sectors, 1)
+	h.api.lk.Unlock()
+	h.api.setHead(5 + SealRandomnessLookback + SealRandomnessLookbackLimit(si.SectorType) + 1)
+	h.start(si)
+
+	si = h.waitState(1, SealPreCommit1Failed)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "ticket expired")
+	require.Empty(t, h.api.sentMsgs())
+}
+
+// packingSector returns a sector with two deals which start at epoch 100,
+// while the chain is at epoch 10. Both deals name testCommD as their publish
+// message, only the first one is on chain
+func packingSector(h *testHarness) SectorInfo {
+	h.api.setHead(10)
+	h.api.setDeal(1, market.DealProposal{
+		PieceCID:   testCommD,
+		PieceSize:  1024,
+		StartEpoch: 100,
+		EndEpoch:   1000,
+	})
+
+	publish := testCommD
+	return SectorInfo{
+		State:        Packing,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces: []Piece{
+			{
+				Piece:    abi.PieceInfo{Size: 1024, PieceCID: testCommD},
+				DealInfo: &DealInfo{PublishCid: &publish, DealID: 1, DealSchedule: DealSchedule{StartEpoch: 100, EndEpoch: 1000}},
+			},
+			{
+				Piece:    abi.PieceInfo{Size: 1024, PieceCID: testCommD},
+				DealInfo: &DealInfo{PublishCid: &publish, DealID: 2, DealSchedule: DealSchedule{StartEpoch: 100, EndEpoch: 1000}},
+			},
+		},
+	}
+}
+
+// blockPreCommit1 makes PreCommit1 report the sealed pieces, and wait for ctx
+func blockPreCommit1(h *testHarness) chan []abi.PieceInfo {
+	sealed := make(chan []abi.PieceInfo, 1)
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		sealed <- pieces
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return sealed
+}
+
+func TestPackingDealsPublished(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	sealed := blockPreCommit1(h)
+
+	si := packingSector(h)
+	h.api.setDeal(2, market.DealProposal{PieceCID: testCommD, PieceSize: 1024, StartEpoch: 100, EndEpoch: 1000})
+	h.start(si)
+
+	require.Len(t, <-sealed, 2)
+	si = h.sector(1)
+	require.Equal(t, []abi.DealID{1, 2}, si.dealIDs())
+}
+
+func TestPackingDealsPending(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	sealed := blockPreCommit1(h)
+
+	landed := make(chan struct{})
+	h.api.waitMsg = func(c cid.Cid) (MsgLookup, error) {
+		<-landed
+		return MsgLookup{Height: 11}, nil
+	}
+
+	si := packingSector(h)
+	h.start(si)
+
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, Packing, h.sector(1).State)
+
+	// the second deal is on chain once the publish message lands
+	h.api.setDeal(2, market.DealProposal{PieceCID: testCommD, PieceSize: 1024, StartEpoch: 100, EndEpoch: 1000})
+	close(landed)
+
+	require.Len(t, <-sealed, 2)
+	si = h.sector(1)
+	require.Equal(t, []abi.DealID{1, 2}, si.dealIDs())
+}
+
+func TestPackingDealsNeverPublished(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	sealed := blockPreCommit1(h)
+
+	h.api.waitMsg = func(c cid.Cid) (MsgLookup, error) {
+		return MsgLookup{Receipt: MessageReceipt{ExitCode: exitcode.ErrIllegalArgument}}, nil
+	}
+	h.start(packingSector(h))
+
+	// repacked with filler data
+	pieces := <-sealed
+	require.NotEmpty(t, pieces)
+	for _, p := range pieces {
+		require.Equal(t, zerocomm.ZeroPieceCommitment(p.Size.Unpadded()), p.PieceCID)
+	}
+	si := h.sector(1)
+	require.Empty(t, si.dealIDs())
+	require.True(t, hasEvent(si, SectorDropUnpublishedDeals{}))
+}
+
+func TestPackingDealsPartlyPublished(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.start(packingSector(h)) // deal 2 never lands on chain
+
+	si := h.waitState(1, PackingFailed)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "deals [2] in sector 1 were never published")
+	require.Len(t, si.Pieces, 2)
+}
+
+func TestPreCommitDataCommitmentMismatch(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	si := precommittingSector(h)
+	commD := testCommR // the chain computes testCommD from the deals
+	si.CommD = &commD
+	h.start(si)
+
+	si = h.waitState(1, DataCommitmentMismatch)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "on chain CommD differs from sector")
+	require.Empty(t, h.api.sentMsgs())
+
+	require.NoError(t, h.m.Remove(context.Background(), 1))
+	h.waitState(1, Removed)
+}
+
+// expiringSector returns a sector whose precommit landed at epoch 10 with a
+// deposit of 42, and lets the chain reach the first epoch it can't be proven at
+func expiringSector(h *testHarness, state SectorState) SectorInfo {
+	si := h.committingSector(1)
+	si.State = state
+
+	h.api.lk.Lock()
+	h.api.precommits[1].PreCommitDeposit = big.NewInt(42)
+	delete(h.api.sectors, 1)
+	h.api.lk.Unlock()
+	h.api.setHead(10 + miner.MaxSealDuration[si.SectorType] + 1)
+
+	return si
+}
+
+func TestPreCommitExpiredCommitting(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		t.Error("computed a proof for an expired precommit")
+		return nil, nil
+	}
+	h.start(expiringSector(h, Committing))
+
+	si := h.waitState(1, ExpiredPreCommit)
+	require.Equal(t, big.NewInt(42), si.PreCommitDeposit)
+	require.Empty(t, h.api.sentMsgs())
+}
+
+func TestPreCommitExpiredWaitSeed(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	si := expiringSector(h, WaitSeed)
+	si.SeedValue, si.SeedEpoch = nil, 0
+	h.start(si)
+
+	si = h.waitState(1, ExpiredPreCommit)
+	require.Equal(t, big.NewInt(42), si.PreCommitDeposit)
+}
+
+func TestPreCommitLastProvableEpoch(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	si := expiringSector(h, Committing)
+	h.api.setHead(10 + miner.MaxSealDuration[si.SectorType])
+	h.api.lk.Lock()
+	h.api.sectors[1] = &miner.SectorOnChainInfo{}
+	h.api.lk.Unlock()
+	h.start(si)
+	h.waitState(1, Proving)
+}
@@ -0,0 +1,123 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// SectorFileLocator tells whether the files of a sector can be found by the
+// storage subsystem
+type SectorFileLocator interface {
+	// MissingFiles returns the subset of the requested file types which can't
+	// be found for the sector
+	MissingFiles(ctx context.Context, sector abi.SectorID, spt abi.RegisteredSealProof, files stores.SectorFileType) (stores.SectorFileType, error)
+}
+
+// StorageCheckReport is the result of checking that in-flight sectors can
+// still locate their files, e.g. after sector storage was moved around
+type StorageCheckReport struct {
+	// Checked is the number of sectors which were checked
+	Checked int
+	Missing []MissingSectorFiles
+}
+
+// MissingSectorFiles describes a sector with files which couldn't be found
+type MissingSectorFiles struct {
+	SectorNumber abi.SectorNumber
+	State        SectorState
+	Files        []string
+}
+
+func (r StorageCheckReport) missing() map[abi.SectorNumber]struct{} {
+	out := map[abi.SectorNumber]struct{}{}
+	for _, ms := range r.Missing {
+		out[ms.SectorNumber] = struct{}{}
+	}
+	return out
+}
+
+// CheckSectorStorage verifies that every in-flight sector can locate the files
+// the next steps of sealing need.
+//
+// Files are located with Config.SectorLocator. Without one, the sealer's
+// CheckProvable is used, which only covers sealed and cache files, so sectors
+// which only have unsealed data aren't checked.
+func (m *Sealing) CheckSectorStorage(ctx context.Context) (StorageCheckReport, error) {
+	var out StorageCheckReport
+
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return StorageCheckReport{}, xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	for _, sector := range sectors {
+		files := requiredSectorFiles(sector)
+		if files == stores.FTNone {
+			continue
+		}
+
+		var missing stores.SectorFileType
+		if m.cfg.SectorLocator != nil {
+			missing, err = m.cfg.SectorLocator.MissingFiles(ctx, m.minerSector(sector.SectorNumber), sector.SectorType, files)
+			if err != nil {
+				return StorageCheckReport{}, xerrors.Errorf("locating files of sector %d: %w", sector.SectorNumber, err)
+			}
+		} else {
+			files &= stores.FTSealed | stores.FTCache
+			if files == stores.FTNone {
+				continue
+			}
+
+			bad, err := m.sealer.CheckProvable(ctx, sector.SectorType, []abi.SectorID{m.minerSector(sector.SectorNumber)})
+			if err != nil {
+				return StorageCheckReport{}, xerrors.Errorf("checking files of sector %d: %w", sector.SectorNumber, err)
+			}
+			if len(bad) > 0 {
+				missing = files
+			}
+		}
+
+		out.Checked++
+		if missing == stores.FTNone {
+			continue
+		}
+
+		ms := MissingSectorFiles{
+			SectorNumber: sector.SectorNumber,
+			State:        sector.State,
+		}
+		for _, ft := range stores.PathTypes {
+			if missing.Has(ft) {
+				ms.Files = append(ms.Files, ft.String())
+			}
+		}
+		out.Missing = append(out.Missing, ms)
+	}
+
+	return out, nil
+}
+
+// requiredSectorFiles returns the files a sector needs to continue sealing
+// from its current state
+func requiredSectorFiles(sector SectorInfo) stores.SectorFileType {
+	switch sector.State {
+	case Empty, WaitDeals, Packing, PackingFailed, PreCommit1, SealPreCommit1Failed:
+		if len(sector.Pieces) == 0 {
+			return stores.FTNone
+		}
+		return stores.FTUnsealed
+	case WaitDisk:
+		sector.State = sector.DiskWaitPhase
+		return requiredSectorFiles(sector)
+	case PreCommit2, SealPreCommit2Failed, PreCommitting, PreCommitWait, PreCommitFailed,
+		CommitHold, WaitSeed, Committing, ComputeProofFailed, BadProof, CommitWait, CommitFailed,
+		FinalizeSector, FinalizeFailed, UnsealedCorrupt:
+		return stores.FTSealed | stores.FTCache
+	default:
+		return stores.FTNone
+	}
+}
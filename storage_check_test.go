@@ -0,0 +1,108 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+type fakeLocator struct {
+	missing map[abi.SectorNumber]stores.SectorFileType
+}
+
+func (f *fakeLocator) MissingFiles(ctx context.Context, sector abi.SectorID, spt abi.RegisteredSealProof, files stores.SectorFileType) (stores.SectorFileType, error) {
+	return f.missing[sector.Number] & files, nil
+}
+
+func badSectors(bad ...abi.SectorNumber) func([]abi.SectorID) ([]abi.SectorID, error) {
+	return func(sectors []abi.SectorID) ([]abi.SectorID, error) {
+		var out []abi.SectorID
+		for _, s := range sectors {
+			for _, b := range bad {
+				if s.Number == b {
+					out = append(out, s)
+				}
+			}
+		}
+		return out, nil
+	}
+}
+
+func TestCheckSectorStorage(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.sealer.checkProvable = badSectors(2)
+
+	withPiece := []Piece{{Piece: abi.PieceInfo{Size: 2048, PieceCID: testCommD}}}
+
+	h.put(SectorInfo{State: WaitSeed, SectorNumber: 1})
+	h.put(SectorInfo{State: Committing, SectorNumber: 2})
+	h.put(SectorInfo{State: Packing, SectorNumber: 3, Pieces: withPiece}) // unsealed only, not covered by CheckProvable
+	h.put(SectorInfo{State: Proving, SectorNumber: 4})
+
+	report, err := h.m.CheckSectorStorage(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, StorageCheckReport{
+		Checked: 2,
+		Missing: []MissingSectorFiles{
+			{SectorNumber: 2, State: Committing, Files: []string{"sealed", "cache"}},
+		},
+	}, report)
+}
+
+func TestCheckSectorStorageLocator(t *testing.T) {
+	h := newTestHarness(t, Config{
+		SectorLocator: &fakeLocator{missing: map[abi.SectorNumber]stores.SectorFileType{
+			2: stores.FTCache,
+			3: stores.FTUnsealed | stores.FTSealed,
+		}},
+	})
+
+	withPiece := []Piece{{Piece: abi.PieceInfo{Size: 2048, PieceCID: testCommD}}}
+
+	h.put(SectorInfo{State: PreCommit1, SectorNumber: 1, Pieces: withPiece})
+	h.put(SectorInfo{State: PreCommit2, SectorNumber: 2})
+	h.put(SectorInfo{State: PreCommit1, SectorNumber: 3, Pieces: withPiece})
+	h.put(SectorInfo{State: Packing, SectorNumber: 4})
+
+	report, err := h.m.CheckSectorStorage(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, StorageCheckReport{
+		Checked: 3,
+		Missing: []MissingSectorFiles{
+			{SectorNumber: 2, State: PreCommit2, Files: []string{"cache"}},
+			{SectorNumber: 3, State: PreCommit1, Files: []string{"unsealed"}},
+		},
+	}, report)
+}
+
+func TestRunSkipsSectorsWithMissingFiles(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.sealer.checkProvable = badSectors(2)
+
+	var lk sync.Mutex
+	var proved []abi.SectorNumber
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		lk.Lock()
+		defer lk.Unlock()
+		proved = append(proved, sector.Number)
+		return storage.Proof{1}, nil
+	}
+
+	h.put(h.committingSector(1))
+	h.put(h.committingSector(2))
+
+	require.NoError(t, h.m.Run(context.Background()))
+
+	h.waitState(1, Proving)
+	require.Equal(t, Committing, h.sector(2).State)
+
+	lk.Lock()
+	defer lk.Unlock()
+	require.Equal(t, []abi.SectorNumber{1}, proved)
+}
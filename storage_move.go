@@ -0,0 +1,52 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// StorageMover can be implemented by SectorManagers which can relocate the
+// sealed and cache files of a sector to another storage path
+type StorageMover interface {
+	MoveStorage(ctx context.Context, sector abi.SectorID) error
+}
+
+// ErrSectorBusy is returned when moving the storage of a sector whose data is
+// still being written
+type ErrSectorBusy struct{ error }
+
+// MoveStorage relocates the sealed and cache files of a committed sector to
+// the storage path the sealer picks for it. Sectors which are still being
+// sealed are rejected, their data is still being written. Unlike finalization
+// this doesn't drop any of the sector files
+func (m *Sealing) MoveStorage(ctx context.Context, sid abi.SectorNumber) error {
+	mover, ok := m.sealer.(StorageMover)
+	if !ok {
+		return xerrors.Errorf("sealer doesn't support moving sector storage")
+	}
+
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := committedStates[si.State]; !ok {
+		return &ErrSectorBusy{xerrors.Errorf("sector %d in state %s is still being sealed", sid, si.State)}
+	}
+
+	done, err := m.beginIntent(sid, IntentMoveStorage)
+	if err != nil {
+		return err
+	}
+	err = mover.MoveStorage(ctx, m.minerSector(sid))
+	done()
+	if err != nil {
+		return xerrors.Errorf("moving storage of sector %d: %w", sid, err)
+	}
+
+	// the worker holding the sector data may have changed with the move
+	return m.send(sid, SectorStorageMoved{Worker: m.sectorWorker(ctx, sid)})
+}
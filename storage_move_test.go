@@ -0,0 +1,80 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+type movingSealer struct {
+	*fakeSealer
+
+	lk     sync.Mutex
+	moved  []abi.SectorID
+	worker string
+}
+
+func (s *movingSealer) MoveStorage(ctx context.Context, sector abi.SectorID) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.moved = append(s.moved, sector)
+	s.worker = "storage-worker"
+	return nil
+}
+
+func (s *movingSealer) SectorWorker(ctx context.Context, sector abi.SectorID) (string, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.worker, nil
+}
+
+func (s *movingSealer) movedSectors() []abi.SectorID {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return append([]abi.SectorID(nil), s.moved...)
+}
+
+func TestMoveStorage(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	ms := &movingSealer{fakeSealer: h.sealer}
+	h.m.sealer = ms
+
+	h.put(SectorInfo{SectorNumber: 1, State: Proving, Worker: "seal-worker"})
+	require.NoError(t, h.m.MoveStorage(context.Background(), 1))
+	require.Equal(t, []abi.SectorID{h.m.minerSector(1)}, ms.movedSectors())
+
+	require.Eventually(t, func() bool {
+		return h.sector(1).Worker == "storage-worker"
+	}, time.Second, time.Millisecond)
+	require.Equal(t, Proving, h.sector(1).State)
+
+	var notFound *ErrSectorNotFound
+	require.True(t, xerrors.As(h.m.MoveStorage(context.Background(), 2), &notFound))
+}
+
+func TestMoveStorageSealingSector(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	ms := &movingSealer{fakeSealer: h.sealer}
+	h.m.sealer = ms
+
+	for i, st := range []SectorState{WaitDeals, Packing, PreCommit1, Committing} {
+		sn := abi.SectorNumber(i + 1)
+		h.put(SectorInfo{SectorNumber: sn, State: st})
+
+		var busy *ErrSectorBusy
+		require.True(t, xerrors.As(h.m.MoveStorage(context.Background(), sn), &busy), "state %s", st)
+	}
+	require.Empty(t, ms.movedSectors())
+}
+
+func TestMoveStorageUnsupported(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.put(SectorInfo{SectorNumber: 1, State: Proving})
+	require.Error(t, h.m.MoveStorage(context.Background(), 1))
+}
@@ -0,0 +1,65 @@
+package sealing
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// subscriberBuffer is the number of state changes buffered for each
+// subscriber. Changes which don't fit are dropped
+const subscriberBuffer = 64
+
+// SectorStateChange is a sector state transition, as sent to subscribers
+type SectorStateChange struct {
+	SectorNumber abi.SectorNumber
+	From, To     SectorState
+}
+
+type subscribers struct {
+	lk   sync.Mutex
+	next int
+	subs map[int]chan SectorStateChange
+}
+
+// Subscribe returns a channel receiving all sector state changes, and a
+// function cancelling the subscription, which closes the channel. Sealing
+// doesn't wait for slow subscribers; changes are dropped when their buffer
+// is full
+func (m *Sealing) Subscribe() (<-chan SectorStateChange, func()) {
+	m.subs.lk.Lock()
+	defer m.subs.lk.Unlock()
+
+	if m.subs.subs == nil {
+		m.subs.subs = map[int]chan SectorStateChange{}
+	}
+
+	id := m.subs.next
+	m.subs.next++
+
+	ch := make(chan SectorStateChange, subscriberBuffer)
+	m.subs.subs[id] = ch
+
+	return ch, func() {
+		m.subs.lk.Lock()
+		defer m.subs.lk.Unlock()
+
+		if _, ok := m.subs.subs[id]; ok {
+			delete(m.subs.subs, id)
+			close(ch)
+		}
+	}
+}
+
+func (m *Sealing) publishStateChange(change SectorStateChange) {
+	m.subs.lk.Lock()
+	defer m.subs.lk.Unlock()
+
+	for id, ch := range m.subs.subs {
+		select {
+		case ch <- change:
+		default:
+			log.Warnf("state change subscriber %d is full, dropping sector %d change to %s", id, change.SectorNumber, change.To)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package sealing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestSubscribe(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	ch, unsubscribe := h.m.Subscribe()
+	other, unsubscribeOther := h.m.Subscribe()
+	unsubscribeOther()
+	unsubscribeOther() // no-op
+	_, open := <-other
+	require.False(t, open)
+
+	h.start(h.committingSector(1))
+	h.waitState(1, Proving)
+
+	var changes []SectorStateChange
+	for len(changes) < 3 {
+		select {
+		case c := <-ch:
+			changes = append(changes, c)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got %+v", changes)
+		}
+	}
+	require.Equal(t, []SectorStateChange{
+		{SectorNumber: 1, From: Committing, To: CommitWait},
+		{SectorNumber: 1, From: CommitWait, To: FinalizeSector},
+		{SectorNumber: 1, From: FinalizeSector, To: Proving},
+	}, changes)
+
+	unsubscribe()
+	_, open = <-ch
+	require.False(t, open)
+	require.Empty(t, h.m.subs.subs)
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	ch, unsubscribe := h.m.Subscribe() // never read from
+
+	const sectors = 30 // 3 transitions each, more than fits the buffer
+	for sn := abi.SectorNumber(1); sn <= sectors; sn++ {
+		h.start(h.committingSector(sn))
+	}
+	for sn := abi.SectorNumber(1); sn <= sectors; sn++ {
+		h.waitState(sn, Proving)
+	}
+
+	unsubscribe()
+	var n int
+	for range ch {
+		n++
+	}
+	require.Equal(t, subscriberBuffer, n)
+}
@@ -0,0 +1,85 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// TicketProvider supplies seal tickets instead of drawing them from chain
+// randomness. Used with Config.ExternalTickets
+type TicketProvider interface {
+	// Ticket returns the ticket for the sector, and the epoch it was drawn
+	// for. height is the current chain height
+	Ticket(ctx context.Context, sector abi.SectorNumber, height abi.ChainEpoch) (abi.SealRandomness, abi.ChainEpoch, error)
+}
+
+// checkTicketEpoch checks that a ticket drawn at the given epoch can still be
+// used to precommit a sector at the given height
+func checkTicketEpoch(spt abi.RegisteredSealProof, ticketEpoch, height abi.ChainEpoch) error {
+	if ticketEpoch >= height {
+		return xerrors.Errorf("ticket epoch %d is not before the current epoch %d", ticketEpoch, height)
+	}
+
+	if height-(ticketEpoch+SealRandomnessLookback) > SealRandomnessLookbackLimit(spt) {
+		return &ErrExpiredTicket{xerrors.Errorf("ticket expired: seal height: %d, head: %d", ticketEpoch+SealRandomnessLookback, height)}
+	}
+
+	return nil
+}
+
+// checkTicketAge checks that the ticket of the sector is recent enough to send
+// its precommit at the given height, see Config.MaxPreCommitRandomnessLookback
+func (m *Sealing) checkTicketAge(sector SectorInfo, height abi.ChainEpoch) error {
+	limit := m.cfg.MaxPreCommitRandomnessLookback
+	if limit <= 0 {
+		limit = SealRandomnessLookbackLimit(sector.SectorType)
+	}
+
+	if height-(sector.TicketEpoch+SealRandomnessLookback) > limit {
+		return &ErrExpiredTicket{xerrors.Errorf("ticket too old: seal height: %d, head: %d, lookback limit: %d", sector.TicketEpoch+SealRandomnessLookback, height, limit)}
+	}
+	return nil
+}
+
+// ErrTicketDrawn is returned by ForceTicket for sectors which already started
+// sealing with a ticket
+type ErrTicketDrawn struct{ error }
+
+// states in which sectors didn't draw their ticket yet
+var ticketForceStates = map[SectorState]bool{
+	Empty:     true,
+	WaitDeals: true,
+	Packing:   true,
+}
+
+func checkForceTicket(si SectorInfo) error {
+	if !ticketForceStates[si.State] || len(si.TicketValue) > 0 {
+		return &ErrTicketDrawn{xerrors.Errorf("sector %d in state %s is past drawing its ticket", si.SectorNumber, si.State)}
+	}
+	return nil
+}
+
+// ForceTicket makes PreCommit1 of the sector use the given ticket instead of
+// drawing one, to replay sealing of a sector deterministically. Only sectors
+// which didn't start PreCommit1 yet can be given a ticket. The ticket isn't
+// checked against chain randomness at its epoch - a ticket which doesn't
+// match it produces a sector with invalid proofs, which can't be
+// precommitted
+func (m *Sealing) ForceTicket(sid abi.SectorNumber, ticket abi.SealRandomness, epoch abi.ChainEpoch) error {
+	if len(ticket) == 0 {
+		return xerrors.New("empty ticket")
+	}
+
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return err
+	}
+	if err := checkForceTicket(si); err != nil {
+		return err
+	}
+
+	return m.send(sid, SectorForceTicket{Ticket: ticket, Epoch: epoch})
+}
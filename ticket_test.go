@@ -0,0 +1,167 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+type fixedTicket struct {
+	ticket abi.SealRandomness
+	epoch  abi.ChainEpoch
+}
+
+func (f fixedTicket) Ticket(ctx context.Context, sector abi.SectorNumber, height abi.ChainEpoch) (abi.SealRandomness, abi.ChainEpoch, error) {
+	return f.ticket, f.epoch, nil
+}
+
+func TestExternalTicket(t *testing.T) {
+	ticket := abi.SealRandomness{9, 9, 9}
+	h := newTestHarness(t, Config{
+		ExternalTickets: true,
+		TicketProvider:  fixedTicket{ticket: ticket, epoch: 1500},
+	})
+	h.api.setHead(2000)
+
+	sealedWith := make(chan abi.SealRandomness, 1)
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		sealedWith <- ticket
+		return storage.PreCommit1Out{1}, nil
+	}
+
+	h.start(SectorInfo{
+		State:        Packing,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+	})
+
+	h.waitState(1, WaitSeed)
+	require.Equal(t, ticket, <-sealedWith)
+
+	h.api.setHead(2000 + miner.PreCommitChallengeDelay)
+	si := h.waitState(1, Proving)
+
+	require.Equal(t, ticket, si.TicketValue)
+	require.Equal(t, abi.ChainEpoch(1500), si.TicketEpoch)
+
+	h.api.lk.Lock()
+	defer h.api.lk.Unlock()
+	require.Equal(t, abi.ChainEpoch(1500), h.api.precommits[1].Info.SealRandEpoch)
+	require.NotNil(t, h.api.sectors[1])
+}
+
+func TestExternalTicketBadEpoch(t *testing.T) {
+	h := newTestHarness(t, Config{
+		ExternalTickets: true,
+		TicketProvider:  fixedTicket{ticket: abi.SealRandomness{9}, epoch: 2000},
+	})
+	h.api.setHead(2000)
+
+	h.start(SectorInfo{
+		State:        PreCommit1,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+	})
+
+	si := h.waitState(1, SealPreCommit1Failed)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "ticket epoch 2000 is not before the current epoch 2000")
+}
+
+func TestExternalTicketNoProvider(t *testing.T) {
+	h := newTestHarness(t, Config{ExternalTickets: true})
+	h.api.setHead(2000)
+
+	h.start(SectorInfo{
+		State:        PreCommit1,
+		SectorNumber: 1,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+	})
+
+	si := h.waitState(1, SealPreCommit1Failed)
+	require.Contains(t, si.Log[len(si.Log)-1].Trace, "no TicketProvider is configured")
+}
+
+func TestCheckTicketEpoch(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1
+	limit := SealRandomnessLookbackLimit(spt)
+
+	require.NoError(t, checkTicketEpoch(spt, 1000, 1000+SealRandomnessLookback))
+	require.NoError(t, checkTicketEpoch(spt, 1000, 1001))
+	require.Error(t, checkTicketEpoch(spt, 1000, 1000))
+
+	require.NoError(t, checkTicketEpoch(spt, 1000, 1000+SealRandomnessLookback+limit))
+	err := checkTicketEpoch(spt, 1000, 1000+SealRandomnessLookback+limit+1)
+	require.IsType(t, &ErrExpiredTicket{}, err)
+}
+
+func TestForceTicket(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.api.setHead(2000)
+
+	sealedWith := make(chan abi.SealRandomness, 1)
+	h.sealer.preCommit1 = func(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage.PreCommit1Out, error) {
+		sealedWith <- ticket
+		return storage.PreCommit1Out{1}, nil
+	}
+
+	sid, _ := h.addDeal(1, 1024)
+	h.waitPieces(sid, 1)
+
+	ticket := abi.SealRandomness{7, 7, 7}
+	require.NoError(t, h.m.ForceTicket(sid, ticket, 1500))
+	require.Eventually(t, func() bool {
+		return len(h.sector(sid).ForcedTicket) > 0
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, h.m.StartPacking(sid))
+	require.Equal(t, ticket, <-sealedWith)
+
+	si := h.waitState(sid, WaitSeed)
+	require.Equal(t, ticket, si.TicketValue)
+	require.Equal(t, abi.ChainEpoch(1500), si.TicketEpoch)
+
+	// too late once the ticket was drawn
+	err := h.m.ForceTicket(sid, abi.SealRandomness{8}, 1600)
+	require.True(t, xerrors.As(err, new(*ErrTicketDrawn)), "%+v", err)
+
+	require.Error(t, h.m.ForceTicket(sid, nil, 1600))
+	require.True(t, xerrors.As(h.m.ForceTicket(2, ticket, 1500), new(*ErrSectorNotFound)))
+}
+
+func TestPreCommitTicketRedrawn(t *testing.T) {
+	h := newTestHarness(t, Config{MaxPreCommitRandomnessLookback: 10})
+
+	si := precommittingSector(h)
+	head := si.TicketEpoch + SealRandomnessLookback + 11
+	h.api.setHead(head)
+	h.start(si)
+
+	si = h.waitState(1, Proving)
+	require.True(t, hasEvent(si, SectorTicketExpired{}))
+	require.Equal(t, head-SealRandomnessLookback, si.TicketEpoch)
+
+	h.api.lk.Lock()
+	defer h.api.lk.Unlock()
+	require.Equal(t, head-SealRandomnessLookback, h.api.precommits[1].Info.SealRandEpoch)
+}
+
+func TestPreCommitTicketExpired(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	si := precommittingSector(h)
+	head := si.TicketEpoch + SealRandomnessLookback + SealRandomnessLookbackLimit(si.SectorType) + 1
+	h.api.setHead(head)
+	h.start(si)
+
+	si = h.waitState(1, Proving)
+	require.True(t, hasEvent(si, SectorTicketExpired{}))
+	require.False(t, hasEvent(si, SectorSealPreCommit1Failed{}))
+	require.Equal(t, head-SealRandomnessLookback, si.TicketEpoch)
+}
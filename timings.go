@@ -0,0 +1,234 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+const SectorTimingsPrefix = "/sector-timings"
+
+const defaultTimingRetention = 1000
+
+const defaultMetricsRefresh = 10 * time.Minute
+
+// SectorTimings records how long a finished sector spent in each phase
+type SectorTimings struct {
+	SectorNumber abi.SectorNumber
+	Finished     uint64 // unix time
+
+	Phases []PhaseTiming
+}
+
+type PhaseTiming struct {
+	Phase   SectorState
+	Seconds uint64
+}
+
+// SealingStats holds the average phase durations of recently finished
+// sectors, and the current state of the sealing pipeline
+type SealingStats struct {
+	// number of sectors the averages are computed over
+	Sectors int
+	Updated time.Time
+
+	Phases map[SectorState]time.Duration
+
+	// States is the number of sectors in each state
+	States map[SectorState]int64
+	// AwaitingMessages is the number of sectors waiting for a message to land
+	AwaitingMessages int64
+	// Failed is the number of sectors in a failed state
+	Failed int64
+
+	// OpenSectors is the number of sectors accepting deals, with
+	// OpenFreeSpace left in them
+	OpenSectors   int
+	OpenFreeSpace abi.PaddedPieceSize
+}
+
+// events ending a phase; the time between two of them is accounted to the
+// phase the latter one ends
+var phaseEndEvents = map[string]SectorState{
+	logKind(SectorPacked{}):          Packing,
+	logKind(SectorDiskAvailable{}):   WaitDisk,
+	logKind(SectorPreCommit1{}):      PreCommit1,
+	logKind(SectorPreCommit2{}):      PreCommit2,
+	logKind(SectorPledgeAvailable{}): PledgeInsufficient,
+	logKind(SectorPreCommitted{}):    PreCommitting,
+	logKind(SectorPreCommitLanded{}): PreCommitWait,
+	logKind(SectorHoldCommit{}):      PreCommitWait,
+	logKind(SectorCommitTrigger{}):   CommitHold,
+	logKind(SectorSeedReady{}):       WaitSeed,
+	logKind(SectorCommitted{}):       Committing,
+	logKind(SectorProving{}):         CommitWait,
+}
+
+func logKind(evt interface{}) string {
+	return fmt.Sprintf("event;%T", evt)
+}
+
+// sectorTimings computes phase timings from the sector log. The sector is
+// expected to be finalizing at `now`
+func sectorTimings(sector SectorInfo, now uint64) SectorTimings {
+	out := SectorTimings{
+		SectorNumber: sector.SectorNumber,
+		Finished:     now,
+	}
+	if len(sector.Log) == 0 {
+		return out
+	}
+
+	idx := map[SectorState]int{}
+	add := func(phase SectorState, from, to uint64) {
+		if to < from {
+			return
+		}
+		i, ok := idx[phase]
+		if !ok {
+			i = len(out.Phases)
+			idx[phase] = i
+			out.Phases = append(out.Phases, PhaseTiming{Phase: phase})
+		}
+		out.Phases[i].Seconds += to - from
+	}
+
+	start := sector.Log[0].Timestamp
+	for _, l := range sector.Log[1:] {
+		phase, ok := phaseEndEvents[l.Kind]
+		if !ok {
+			continue
+		}
+		add(phase, start, l.Timestamp)
+		start = l.Timestamp
+	}
+	add(FinalizeSector, start, now)
+
+	return out
+}
+
+func (m *Sealing) recordTimings(sector SectorInfo) {
+	log := m.stateLog(sector)
+
+	st := sectorTimings(sector, uint64(m.clock.Now().Unix()))
+	b, err := cborutil.Dump(&st)
+	if err != nil {
+		log.Errorf("encoding timings of sector %d: %+v", sector.SectorNumber, err)
+		return
+	}
+
+	if err := m.timings.Put(datastore.NewKey(fmt.Sprint(uint64(sector.SectorNumber))), b); err != nil {
+		log.Errorf("storing timings of sector %d: %+v", sector.SectorNumber, err)
+	}
+}
+
+// Stats returns phase averages as of the last metrics refresh, and the current
+// sector counts. Counts are kept in memory, so this is cheap to call
+func (m *Sealing) Stats() SealingStats {
+	m.statsLk.Lock()
+	out := m.stats
+	m.statsLk.Unlock()
+
+	m.counts.fill(&out)
+	out.OpenSectors, out.OpenFreeSpace = m.openSectorSpace()
+
+	return out
+}
+
+// refreshStats prunes timing records beyond the retention limit, and
+// recomputes the averages from the remaining ones
+func (m *Sealing) refreshStats() error {
+	res, err := m.timings.Query(query.Query{})
+	if err != nil {
+		return xerrors.Errorf("querying timing records: %w", err)
+	}
+	defer res.Close() // nolint:errcheck
+
+	var records []SectorTimings
+	for r := range res.Next() {
+		if r.Error != nil {
+			return xerrors.Errorf("iterating timing records: %w", r.Error)
+		}
+
+		var st SectorTimings
+		if err := cborutil.ReadCborRPC(bytes.NewReader(r.Value), &st); err != nil {
+			return xerrors.Errorf("decoding timing record %s: %w", r.Key, err)
+		}
+		records = append(records, st)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Finished != records[j].Finished {
+			return records[i].Finished > records[j].Finished
+		}
+		return records[i].SectorNumber > records[j].SectorNumber
+	})
+
+	keep := m.cfg.TimingRetention
+	if keep == 0 {
+		keep = defaultTimingRetention
+	}
+	if len(records) > keep {
+		for _, st := range records[keep:] {
+			if err := m.timings.Delete(datastore.NewKey(fmt.Sprint(uint64(st.SectorNumber)))); err != nil {
+				return xerrors.Errorf("pruning timings of sector %d: %w", st.SectorNumber, err)
+			}
+		}
+		records = records[:keep]
+	}
+
+	total := map[SectorState]uint64{}
+	for _, st := range records {
+		for _, p := range st.Phases {
+			total[p.Phase] += p.Seconds
+		}
+	}
+
+	stats := SealingStats{
+		Sectors: len(records),
+		Updated: m.clock.Now(),
+		Phases:  map[SectorState]time.Duration{},
+	}
+	for phase, sec := range total {
+		stats.Phases[phase] = time.Duration(sec) * time.Second / time.Duration(len(records))
+	}
+
+	m.statsLk.Lock()
+	m.stats = stats
+	m.statsLk.Unlock()
+
+	return nil
+}
+
+// runMaintenance periodically refreshes sealing stats and records deadlines
+// of proving sectors until ctx is cancelled
+func (m *Sealing) runMaintenance(ctx context.Context) {
+	interval := m.cfg.MetricsRefreshInterval
+	if interval == 0 {
+		interval = defaultMetricsRefresh
+	}
+
+	for {
+		if err := m.refreshStats(); err != nil {
+			log.Errorf("refreshing sealing stats: %+v", err)
+		}
+		if err := m.assignPendingDeadlines(ctx); err != nil {
+			log.Errorf("assigning sector deadlines: %+v", err)
+		}
+
+		select {
+		case <-m.clock.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package sealing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestSectorTimings(t *testing.T) {
+	si := SectorInfo{
+		SectorNumber: 1,
+		Log: []Log{
+			{Timestamp: 100, Kind: logKind(SectorStart{})},
+			{Timestamp: 110, Kind: logKind(SectorPacked{})},
+			{Timestamp: 150, Kind: logKind(SectorPreCommit1{})},
+			{Timestamp: 155, Kind: logKind(SectorSealPreCommit1Failed{})},
+			{Timestamp: 170, Kind: logKind(SectorRetrySealPreCommit1{})},
+			{Timestamp: 200, Kind: logKind(SectorPreCommit1{})},
+			{Timestamp: 230, Kind: logKind(SectorPreCommit2{})},
+		},
+	}
+
+	require.Equal(t, SectorTimings{
+		SectorNumber: 1,
+		Finished:     240,
+		Phases: []PhaseTiming{
+			{Phase: Packing, Seconds: 10},
+			{Phase: PreCommit1, Seconds: 90},
+			{Phase: PreCommit2, Seconds: 30},
+			{Phase: FinalizeSector, Seconds: 10},
+		},
+	}, sectorTimings(si, 240))
+}
+
+func TestTimingRetention(t *testing.T) {
+	h := newTestHarness(t, Config{TimingRetention: 3})
+
+	for sn := abi.SectorNumber(1); sn <= 5; sn++ {
+		b, err := cborutil.Dump(&SectorTimings{
+			SectorNumber: sn,
+			Finished:     uint64(1000 - sn), // older sectors finished later
+			Phases:       []PhaseTiming{{Phase: PreCommit1, Seconds: uint64(sn) * 10}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, h.m.timings.Put(datastore.NewKey(fmt.Sprint(uint64(sn))), b))
+	}
+
+	require.NoError(t, h.m.refreshStats())
+
+	for sn := 1; sn <= 5; sn++ {
+		has, err := h.m.timings.Has(datastore.NewKey(fmt.Sprint(sn)))
+		require.NoError(t, err)
+		require.Equal(t, sn <= 3, has, "sector %d", sn)
+	}
+
+	stats := h.m.Stats()
+	require.Equal(t, 3, stats.Sectors)
+	require.Equal(t, map[SectorState]time.Duration{PreCommit1: 20 * time.Second}, stats.Phases)
+}
+
+func TestRecordTimings(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.m.recordTimings(SectorInfo{SectorNumber: 1, Log: []Log{{Timestamp: 100, Kind: logKind(SectorStart{})}}})
+	require.NoError(t, h.m.refreshStats())
+	require.Equal(t, 1, h.m.Stats().Sectors)
+}
@@ -0,0 +1,99 @@
+package sealing
+
+import (
+	"reflect"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// ErrIllegalTransition is returned for events which aren't valid in the
+// state the sector is in
+type ErrIllegalTransition struct{ error }
+
+// guardedTransitions lists, for events sent from outside of state handlers,
+// the states accepting them and the state each one leads to. Such events can
+// race with the sector moving on, so they are checked before being sent, and
+// again when planning, see checkTransition
+var guardedTransitions = map[reflect.Type]map[SectorState]SectorState{
+	reflect.TypeOf(SectorStart{}): {
+		UndefinedSectorState: WaitDeals,
+	},
+	reflect.TypeOf(SectorStartCC{}): {
+		UndefinedSectorState: Packing,
+	},
+	reflect.TypeOf(SectorImport{}): {
+		UndefinedSectorState: PreCommitting, // WaitSeed if already precommitted
+	},
+	reflect.TypeOf(SectorAddPiece{}): {
+		WaitDeals: WaitDeals,
+	},
+	reflect.TypeOf(SectorPieceWriting{}): {
+		WaitDeals: WaitDeals,
+	},
+	reflect.TypeOf(SectorPiecesReconciled{}): {
+		WaitDeals: WaitDeals,
+	},
+	reflect.TypeOf(SectorStartPacking{}): {
+		WaitDeals: Packing,
+	},
+	reflect.TypeOf(SectorCommitTrigger{}): {
+		CommitHold: WaitSeed,
+	},
+	reflect.TypeOf(SectorRemove{}): {
+		DealsExpired:           Removing,
+		DataCommitmentMismatch: Removing,
+		ExpiredPreCommit:       Removing,
+		UnsealedCorrupt:        Removing,
+		Proving:                Removing,
+		Terminated:             Removing,
+	},
+	reflect.TypeOf(SectorRetryUnsealedCheck{}): {
+		UnsealedCorrupt: FinalizeSector,
+	},
+	reflect.TypeOf(SectorTerminate{}): {
+		Proving:         Terminating,
+		Faulty:          Terminating,
+		FaultReported:   Terminating,
+		FaultedFinal:    Terminating,
+		RecoveryFailed:  Terminating,
+		TerminateFailed: Terminating,
+	},
+	reflect.TypeOf(SectorRecoverFault{}): {
+		Faulty:         RecoveringFault,
+		FaultedFinal:   RecoveringFault,
+		RecoveryFailed: RecoveringFault,
+	},
+}
+
+// checkTransition returns the state the event leads to from the given one.
+// ok is false for events which aren't guarded, their validity is up to the
+// planners
+func checkTransition(state SectorState, evt interface{}) (next SectorState, ok bool, err error) {
+	to, guarded := guardedTransitions[reflect.TypeOf(evt)]
+	if !guarded {
+		return UndefinedSectorState, false, nil
+	}
+
+	next, legal := to[state]
+	if !legal {
+		return UndefinedSectorState, true, &ErrIllegalTransition{xerrors.Errorf("event %T not allowed in state %q", evt, state)}
+	}
+	return next, true, nil
+}
+
+// sendChecked sends an event to the sector if it's valid in the current
+// state of the sector
+func (m *Sealing) sendChecked(sid abi.SectorNumber, evt interface{}) error {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := checkTransition(si.State, evt); err != nil {
+		return xerrors.Errorf("sector %d: %w", sid, err)
+	}
+
+	return m.send(sid, evt)
+}
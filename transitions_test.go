@@ -0,0 +1,58 @@
+package sealing
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	statemachine "github.com/filecoin-project/go-statemachine"
+)
+
+func TestGuardedTransitionsMatchPlanners(t *testing.T) {
+	for typ, to := range guardedTransitions {
+		for from, planner := range fsmPlanners {
+			state := &SectorInfo{State: from}
+			err := planner([]statemachine.Event{{User: reflect.Zero(typ).Interface()}}, state)
+
+			if next, ok := to[from]; ok {
+				require.NoError(t, err, "%s in %s", typ, from)
+				require.Equal(t, next, state.State, "%s in %s", typ, from)
+			} else {
+				require.Error(t, err, "%s in %s", typ, from)
+			}
+		}
+	}
+}
+
+func TestIllegalTransitionRejected(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.put(SectorInfo{State: WaitSeed, SectorNumber: 1})
+	h.put(SectorInfo{State: Packing, SectorNumber: 2})
+
+	err := h.m.Remove(context.Background(), 1)
+	require.True(t, xerrors.As(err, new(*ErrIllegalTransition)), "%+v", err)
+	require.Error(t, h.m.CommitSector(1))
+
+	err = h.m.StartPacking(2)
+	require.True(t, xerrors.As(err, new(*ErrIllegalTransition)), "%+v", err)
+
+	require.Equal(t, WaitSeed, h.sector(1).State)
+	require.Equal(t, Packing, h.sector(2).State)
+}
+
+func TestIllegalEventDropped(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	h.put(SectorInfo{State: Proving, SectorNumber: 1})
+
+	// sent behind the checks of the public methods
+	require.NoError(t, h.m.sectors.Send(uint64(1), SectorStartPacking{}))
+	require.NoError(t, h.m.sectors.Send(uint64(1), SectorAddPiece{}))
+
+	// the state machine keeps handling legal events
+	require.NoError(t, h.m.Remove(context.Background(), 1))
+	si := h.waitState(1, Removed)
+	require.Empty(t, si.Pieces)
+}
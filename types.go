@@ -3,11 +3,16 @@ package sealing
 import (
 	"bytes"
 	"context"
+	"io"
+	"time"
 
 	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
 
 	sectorstorage "github.com/filecoin-project/sector-storage"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	"github.com/filecoin-project/specs-storage/storage"
 )
@@ -26,10 +31,20 @@ type Piece struct {
 
 // DealInfo is a tuple of deal identity and its schedule
 type DealInfo struct {
+	PublishCid   *cid.Cid // the message publishing the deal, waited for before packing. Optional
 	DealID       abi.DealID
 	DealSchedule DealSchedule
+	VerifiedDeal bool // the deal is for verified client data
+
+	// Reopen reads the deal data again, for Config.RequeueFailedDeals. It
+	// isn't persisted, so only deals added since the last restart can be
+	// requeued. Optional
+	Reopen DealReopener `json:"-"`
 }
 
+// DealReopener returns a new reader of the deal data, from the start
+type DealReopener func(ctx context.Context) (io.ReadCloser, error)
+
 // DealSchedule communicates the time interval of a storage deal. The deal must
 // appear in a sealed (proven) sector no later than StartEpoch, otherwise it
 // is invalid.
@@ -53,15 +68,30 @@ type SectorInfo struct {
 	SectorNumber abi.SectorNumber
 
 	SectorType abi.RegisteredSealProof
+	SealMode   SealMode // fixed when the sector is created
 
 	// Packing
 	Pieces []Piece
 
+	// space-time of the deal pieces, DealWeight of the deals with unverified
+	// data and VerifiedDealWeight of the verified ones, see dealWeights
+	DealWeight         abi.DealWeight
+	VerifiedDealWeight abi.DealWeight
+
+	// deal piece being written, recorded before it's written when the sealer
+	// can report written pieces, see reconcilePieces
+	WritingDeal *DealInfo
+	WritingSize abi.UnpaddedPieceSize
+
 	// PreCommit1
 	TicketValue   abi.SealRandomness
 	TicketEpoch   abi.ChainEpoch
 	PreCommit1Out storage.PreCommit1Out
 
+	// set by ForceTicket, used instead of drawing a ticket
+	ForcedTicket      abi.SealRandomness
+	ForcedTicketEpoch abi.ChainEpoch
+
 	// PreCommit2
 	CommD *cid.Cid
 	CommR *cid.Cid
@@ -69,9 +99,13 @@ type SectorInfo struct {
 
 	PreCommitMessage *cid.Cid
 	PreCommitTipSet  TipSetToken
+	PreCommitInfo    *miner.SectorPreCommitInfo // params of the precommit message
 
 	PreCommit2Fails uint64
 
+	// ExpiredPreCommit
+	PreCommitDeposit abi.TokenAmount // deposit of a precommit which expired before it was proven
+
 	// WaitSeed
 	SeedValue abi.InteractiveSealRandomness
 	SeedEpoch abi.ChainEpoch
@@ -80,13 +114,61 @@ type SectorInfo struct {
 	CommitMessage *cid.Cid
 	InvalidProofs uint64 // failed proof computations (doesn't validate with proof inputs; can't compute)
 
+	// seed epoch and sealed CID the commit Proof was computed for, it's
+	// reused on resubmits while they don't change
+	ProofSeedEpoch abi.ChainEpoch
+	ProofCommR     *cid.Cid
+
+	// CommitWait, deals which weren't activated when the sector was proven.
+	// They were dropped from Pieces, see InactiveDeals
+	InactiveDeals []DealInfo
+
+	// MessageStuck
+	StuckWait SectorState // wait state whose message didn't land in time
+
+	// MessageFailed, the last precommit or commit message executed with a
+	// non-zero exit code
+	FailedWait      SectorState // wait state of the failed message
+	MessageExitCode exitcode.ExitCode
+	MessageGasUsed  int64
+
+	// WaitDisk
+	DiskWaitPhase SectorState // phase to start once there is enough disk space
+
+	// Proving, proving deadline and partition the miner actor assigned the
+	// sector to, set once it shows up in the miner deadlines
+	DeadlineAssigned bool
+	Deadline         uint64
+	Partition        uint64
+
+	// Config.OnSectorCommitted was called for the sector
+	CommitNotified bool
+
 	// Faults
-	FaultReportMsg *cid.Cid
+	FaultReportMsg  *cid.Cid
+	RecoveryMessage *cid.Cid
+
+	// Termination
+	TerminateMessage *cid.Cid
+
+	// Worker which ran the last sealing phase, if the sealer reports it
+	Worker string
+
+	// Priority of the sealer work of the sector, set with SetSectorPriority.
+	// The default priority is used if not set
+	Priority int64
+
+	// Paused sectors don't run state handlers until resumed, see Pause
+	Paused bool
 
 	// Debug
 	LastErr string
 
 	Log []Log
+
+	// chain head pinned for the running state handler, set by Plan, see
+	// chainTick. Not persisted
+	tick *chainTick
 }
 
 func (t *SectorInfo) pieceInfos() []abi.PieceInfo {
@@ -108,6 +190,32 @@ func (t *SectorInfo) dealIDs() []abi.DealID {
 	return out
 }
 
+// weight returns the space-time of a deal with a piece of the given size
+func (d *DealInfo) weight(size abi.PaddedPieceSize) abi.DealWeight {
+	duration := d.DealSchedule.EndEpoch - d.DealSchedule.StartEpoch
+	if duration <= 0 {
+		return big.Zero()
+	}
+	return big.Mul(big.NewInt(int64(size)), big.NewInt(int64(duration)))
+}
+
+// updateDealWeights recomputes the deal weights from the sector pieces, it's
+// called by events which change them
+func (t *SectorInfo) updateDealWeights() {
+	t.DealWeight, t.VerifiedDealWeight = big.Zero(), big.Zero()
+	for _, p := range t.Pieces {
+		if p.DealInfo == nil {
+			continue
+		}
+		w := p.DealInfo.weight(p.Piece.Size)
+		if p.DealInfo.VerifiedDeal {
+			t.VerifiedDealWeight = big.Add(t.VerifiedDealWeight, w)
+		} else {
+			t.DealWeight = big.Add(t.DealWeight, w)
+		}
+	}
+}
+
 func (t *SectorInfo) existingPieceSizes() []abi.UnpaddedPieceSize {
 	out := make([]abi.UnpaddedPieceSize, len(t.Pieces))
 	for i, p := range t.Pieces {
@@ -116,6 +224,50 @@ func (t *SectorInfo) existingPieceSizes() []abi.UnpaddedPieceSize {
 	return out
 }
 
+// unsealedInfo returns space used by pieces of a sector accepting deals. now
+// is used as the time of the first piece if the log doesn't have it
+func (t *SectorInfo) unsealedInfo(now time.Time) (UnsealedSectorInfo, error) {
+	ss, err := t.SectorType.SectorSize()
+	if err != nil {
+		return UnsealedSectorInfo{}, xerrors.Errorf("getting sector size: %w", err)
+	}
+
+	ui := UnsealedSectorInfo{size: abi.PaddedPieceSize(ss)}
+	for _, p := range t.Pieces {
+		ui.stored += p.Piece.Size
+		ui.pieceSizes = append(ui.pieceSizes, p.Piece.Size.Unpadded())
+		if p.DealInfo != nil && p.DealInfo.VerifiedDeal {
+			ui.verified = true
+		}
+	}
+	if t.WritingDeal != nil && t.WritingDeal.VerifiedDeal {
+		ui.verified = true
+	}
+
+	if len(t.Pieces) > 0 {
+		ui.firstPiece = now
+		for _, l := range t.Log {
+			if l.Kind == logKind(SectorAddPiece{}) {
+				ui.firstPiece = time.Unix(int64(l.Timestamp), 0)
+				break
+			}
+		}
+	}
+	return ui, nil
+}
+
+// cachedProof returns the commit proof computed earlier, if it was computed
+// for the current seed and precommit
+func (t *SectorInfo) cachedProof() ([]byte, bool) {
+	if len(t.Proof) == 0 || t.CommR == nil || t.ProofCommR == nil {
+		return nil, false
+	}
+	if t.ProofSeedEpoch != t.SeedEpoch || *t.ProofCommR != *t.CommR {
+		return nil, false
+	}
+	return t.Proof, true
+}
+
 func (t *SectorInfo) hasDeals() bool {
 	for _, piece := range t.Pieces {
 		if piece.DealInfo != nil {
@@ -130,13 +282,41 @@ func (t *SectorInfo) sealingCtx(ctx context.Context) context.Context {
 	// TODO: can also take start epoch into account to give priority to sectors
 	//  we need sealed sooner
 
-	if t.hasDeals() {
-		return sectorstorage.WithPriority(ctx, DealSectorPriority)
+	if p := t.schedPriority(); p != 0 {
+		return sectorstorage.WithPriority(ctx, p)
 	}
 
 	return ctx
 }
 
+// schedPriority is the priority of the sealer work of the sector, 0 for the
+// sealer default
+func (t *SectorInfo) schedPriority() int {
+	if t.Priority != 0 {
+		return int(t.Priority)
+	}
+
+	if t.hasDeals() {
+		return DealSectorPriority
+	}
+
+	return 0
+}
+
+// limitOrder ranks the sector among sectors waiting for a limited phase
+func (t *SectorInfo) limitOrder() limitOrder {
+	order := limitOrder{priority: t.schedPriority()}
+	for _, p := range t.Pieces {
+		if p.DealInfo == nil {
+			continue
+		}
+		if start := p.DealInfo.DealSchedule.StartEpoch; start > 0 && (order.dealStart == 0 || start < order.dealStart) {
+			order.dealStart = start
+		}
+	}
+	return order
+}
+
 type SectorIDCounter interface {
 	Next() (abi.SectorNumber, error)
 }
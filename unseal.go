@@ -0,0 +1,56 @@
+package sealing
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/storiface"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// ErrNoUnsealKey is returned when reading from a sealed sector whose ticket
+// or unsealed CID isn't known, so it can't be unsealed
+type ErrNoUnsealKey struct{ error }
+
+// UnsealPiece writes size unpadded bytes of sector data, starting at the
+// padded offset returned by AddPieceToAnySector or GetPieceInfo, to w. Sealed
+// sectors are unsealed by the sealer if it doesn't have an unsealed copy,
+// open sectors are read from their unsealed data directly
+func (m *Sealing) UnsealPiece(ctx context.Context, sid abi.SectorNumber, offset uint64, size abi.UnpaddedPieceSize, w io.Writer) error {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return err
+	}
+
+	if err := size.Validate(); err != nil {
+		return xerrors.Errorf("invalid piece size: %w", err)
+	}
+	if offset%uint64(abi.PaddedPieceSize(128)) != 0 {
+		return xerrors.Errorf("offset %d isn't aligned to 128 bytes", offset)
+	}
+	ssize, err := si.SectorType.SectorSize()
+	if err != nil {
+		return xerrors.Errorf("getting sector size: %w", err)
+	}
+	if ss := uint64(ssize); offset+uint64(size.Padded()) > ss {
+		return xerrors.Errorf("range %d+%d is out of bounds of sector %d (%d bytes)", offset, size.Padded(), sid, ss)
+	}
+
+	ticket, unsealed := abi.SealRandomness(nil), cid.Undef
+	if si.CommR != nil { // sealed
+		if len(si.TicketValue) == 0 || si.CommD == nil {
+			return &ErrNoUnsealKey{xerrors.Errorf("sector %d is sealed, but its ticket or CommD isn't known", sid)}
+		}
+		ticket, unsealed = si.TicketValue, *si.CommD
+	}
+
+	upOffset := storiface.UnpaddedByteIndex(abi.PaddedPieceSize(offset).Unpadded())
+	if err := m.sealer.ReadPiece(ctx, w, m.minerSector(sid), upOffset, size, ticket, unsealed); err != nil {
+		return xerrors.Errorf("reading piece from sector %d: %w", sid, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/storiface"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+type unsealRequest struct {
+	sector   abi.SectorID
+	offset   storiface.UnpaddedByteIndex
+	size     abi.UnpaddedPieceSize
+	ticket   abi.SealRandomness
+	unsealed cid.Cid
+}
+
+// recordReads makes the sealer record ReadPiece requests, and return the
+// given data for them
+func (h *testHarness) recordReads(data []byte) chan unsealRequest {
+	reqs := make(chan unsealRequest, 1)
+
+	var ticket abi.SealRandomness
+	var unsealed cid.Cid
+	h.sealer.readKey = func(t abi.SealRandomness, u cid.Cid) {
+		ticket, unsealed = t, u
+	}
+	h.sealer.readPiece = func(w io.Writer, sector abi.SectorID, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) error {
+		reqs <- unsealRequest{sector, offset, size, ticket, unsealed}
+		_, err := w.Write(data[:size])
+		return err
+	}
+	return reqs
+}
+
+func TestUnsealPiece(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	si := finalizingSector(h)
+	si.State = Proving
+	h.put(si)
+
+	data := bytes.Repeat([]byte{7}, 1016)
+	reqs := h.recordReads(data)
+
+	// the second piece of the sector
+	var buf bytes.Buffer
+	require.NoError(t, h.m.UnsealPiece(context.Background(), 1, 1024, 1016, &buf))
+	require.Equal(t, data, buf.Bytes())
+
+	require.Equal(t, unsealRequest{
+		sector:   h.m.minerSector(1),
+		offset:   1016,
+		size:     1016,
+		ticket:   abi.SealRandomness(testRand),
+		unsealed: testCommD,
+	}, <-reqs)
+}
+
+func TestUnsealPieceOpenSector(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	reqs := h.recordReads(make([]byte, 1016))
+
+	sid, offset := h.addDeal(1, 1024)
+	h.waitPieces(sid, 1)
+
+	var buf bytes.Buffer
+	require.NoError(t, h.m.UnsealPiece(context.Background(), sid, offset, 1016, &buf))
+	require.Len(t, buf.Bytes(), 1016)
+
+	// read from the unsealed data, there is nothing to unseal with yet
+	req := <-reqs
+	require.Nil(t, req.ticket)
+	require.Equal(t, cid.Undef, req.unsealed)
+}
+
+func TestUnsealPieceErrors(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	si := finalizingSector(h)
+	si.State = Proving
+	si.TicketValue = nil
+	h.put(si)
+
+	err := h.m.UnsealPiece(context.Background(), 1, 0, 1016, ioutil.Discard)
+	require.True(t, xerrors.As(err, new(*ErrNoUnsealKey)), "%+v", err)
+
+	err = h.m.UnsealPiece(context.Background(), 2, 0, 1016, ioutil.Discard)
+	require.True(t, xerrors.As(err, new(*ErrSectorNotFound)), "%+v", err)
+
+	require.Error(t, h.m.UnsealPiece(context.Background(), 1, 1024, 2032, ioutil.Discard)) // out of bounds
+	require.Error(t, h.m.UnsealPiece(context.Background(), 1, 100, 1016, ioutil.Discard))  // unaligned
+}
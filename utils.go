@@ -1,11 +1,54 @@
 package sealing
 
 import (
+	"fmt"
 	"math/bits"
+	"sort"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
 )
 
+// ErrInvalidPieceSize is returned when a piece size isn't a valid unpadded
+// piece size, that is 127 * 2^n bytes
+type ErrInvalidPieceSize struct {
+	Size abi.UnpaddedPieceSize
+
+	// Nearest valid piece sizes around Size. Lower is 0 when Size is smaller
+	// than the minimum piece size
+	Lower abi.UnpaddedPieceSize
+	Upper abi.UnpaddedPieceSize
+}
+
+func (e *ErrInvalidPieceSize) Error() string {
+	if e.Lower == 0 {
+		return fmt.Sprintf("invalid piece size %d: minimum piece size is %d", e.Size, e.Upper)
+	}
+	return fmt.Sprintf("invalid piece size %d: must be 127 * 2^n bytes, nearest valid sizes are %d and %d", e.Size, e.Lower, e.Upper)
+}
+
+func (e *ErrInvalidPieceSize) Unwrap() error {
+	return ErrPieceNotPadded
+}
+
+// checkPieceSize returns *ErrInvalidPieceSize if the size is not a valid
+// unpadded piece size
+func checkPieceSize(size abi.UnpaddedPieceSize) error {
+	if size.Validate() == nil {
+		return nil
+	}
+
+	minSize := abi.PaddedPieceSize(128).Unpadded()
+	if size < minSize {
+		return &ErrInvalidPieceSize{Size: size, Upper: minSize}
+	}
+
+	lower := minSize << (bits.Len64(uint64(size/minSize)) - 1)
+	return &ErrInvalidPieceSize{Size: size, Lower: lower, Upper: lower << 1}
+}
+
 func fillersFromRem(in abi.UnpaddedPieceSize) ([]abi.UnpaddedPieceSize, error) {
 	// Convert to in-sector bytes for easier math:
 	//
@@ -42,16 +85,68 @@ func fillersFromRem(in abi.UnpaddedPieceSize) ([]abi.UnpaddedPieceSize, error) {
 	return out, nil
 }
 
+// requiredPadding returns the padding pieces which have to be written to a
+// sector holding `stored` bytes, so that a piece of size `piece` can be added
+// after them. Pieces have to be aligned to their own size in the sector, and
+// so do the padding pieces, which is why they come smallest first
+func requiredPadding(stored abi.PaddedPieceSize, piece abi.PaddedPieceSize) ([]abi.PaddedPieceSize, abi.PaddedPieceSize) {
+	toFill := uint64(-stored % piece)
+
+	out := make([]abi.PaddedPieceSize, bits.OnesCount64(toFill))
+	for i := range out {
+		psize := uint64(1) << bits.TrailingZeros64(toFill)
+		toFill ^= psize
+
+		out[i] = abi.PaddedPieceSize(psize)
+	}
+
+	var sum abi.PaddedPieceSize
+	for _, p := range out {
+		sum += p
+	}
+
+	return out, sum
+}
+
+// ListSectors returns all tracked sectors, ordered by sector number
 func (m *Sealing) ListSectors() ([]SectorInfo, error) {
 	var sectors []SectorInfo
 	if err := m.sectors.List(&sectors); err != nil {
 		return nil, err
 	}
+
+	sort.Slice(sectors, func(i, j int) bool {
+		return sectors[i].SectorNumber < sectors[j].SectorNumber
+	})
 	return sectors, nil
 }
 
+// ListSectorsInState returns tracked sectors in the given state, ordered by
+// sector number
+func (m *Sealing) ListSectorsInState(state SectorState) ([]SectorInfo, error) {
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return nil, err
+	}
+
+	out := sectors[:0]
+	for _, sector := range sectors {
+		if sector.State == state {
+			out = append(out, sector)
+		}
+	}
+	return out, nil
+}
+
+// ErrSectorNotFound is returned when asking for a sector which isn't tracked
+type ErrSectorNotFound struct{ error }
+
+// GetSectorInfo returns the persisted state of the sector
 func (m *Sealing) GetSectorInfo(sid abi.SectorNumber) (SectorInfo, error) {
 	var out SectorInfo
 	err := m.sectors.Get(uint64(sid)).Get(&out)
+	if xerrors.Is(err, datastore.ErrNotFound) {
+		return SectorInfo{}, &ErrSectorNotFound{xerrors.Errorf("sector %d not found", sid)}
+	}
 	return out, err
 }
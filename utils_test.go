@@ -3,6 +3,8 @@ package sealing
 import (
 	"testing"
 
+	"golang.org/x/xerrors"
+
 	"github.com/filecoin-project/specs-actors/actors/abi"
 
 	"github.com/stretchr/testify/assert"
@@ -43,3 +45,30 @@ func TestFillersFromRem(t *testing.T) {
 		testFill(t, ub, []abi.UnpaddedPieceSize{ub1, ub4})
 	}
 }
+
+func TestCheckPieceSize(t *testing.T) {
+	for _, s := range []abi.UnpaddedPieceSize{127, 254, 508, 1016, 127 << 20, abi.PaddedPieceSize(32 << 30).Unpadded()} {
+		assert.NoError(t, checkPieceSize(s), "size %d", s)
+	}
+
+	for _, tc := range []struct {
+		size         abi.UnpaddedPieceSize
+		lower, upper abi.UnpaddedPieceSize
+	}{
+		{0, 0, 127},
+		{100, 0, 127},
+		{128, 127, 254},
+		{253, 127, 254},
+		{255, 254, 508},
+		{1000, 508, 1016},
+		{1024, 1016, 2032},
+	} {
+		err := checkPieceSize(tc.size)
+		var perr *ErrInvalidPieceSize
+		if assert.True(t, xerrors.As(err, &perr), "size %d", tc.size) {
+			assert.Equal(t, tc.size, perr.Size)
+			assert.Equal(t, tc.lower, perr.Lower)
+			assert.Equal(t, tc.upper, perr.Upper)
+		}
+	}
+}
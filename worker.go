@@ -0,0 +1,48 @@
+package sealing
+
+import (
+	"context"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// WorkerReporter can be implemented by SectorManagers which know which worker
+// sealing tasks are assigned to
+type WorkerReporter interface {
+	// SectorWorker returns the worker running, or which last ran a task for the
+	// sector. An empty string means the worker isn't known
+	SectorWorker(ctx context.Context, sector abi.SectorID) (string, error)
+}
+
+// sectorWorker asks the sealer which worker the sector is assigned to. Sealers
+// which don't implement WorkerReporter are reported as an unknown worker
+func (m *Sealing) sectorWorker(ctx context.Context, sector abi.SectorNumber) string {
+	wr, ok := m.sealer.(WorkerReporter)
+	if !ok {
+		return ""
+	}
+
+	w, err := wr.SectorWorker(ctx, m.minerSector(sector))
+	if err != nil {
+		log.Warnf("getting worker of sector %d: %+v", sector, err)
+		return ""
+	}
+
+	return w
+}
+
+// SectorWorker returns the worker the sector is currently assigned to. When
+// the sealer can't tell, the worker which ran the last sealing phase of the
+// sector is returned
+func (m *Sealing) SectorWorker(ctx context.Context, sid abi.SectorNumber) (string, error) {
+	if w := m.sectorWorker(ctx, sid); w != "" {
+		return w, nil
+	}
+
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return "", err
+	}
+
+	return si.Worker, nil
+}
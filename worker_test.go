@@ -0,0 +1,83 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+type reportingSealer struct {
+	*fakeSealer
+
+	lk     sync.Mutex
+	worker string
+}
+
+func (s *reportingSealer) SectorWorker(ctx context.Context, sector abi.SectorID) (string, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.worker, nil
+}
+
+func (s *reportingSealer) assign(w string) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.worker = w
+}
+
+func TestSectorWorker(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	rs := &reportingSealer{fakeSealer: h.sealer}
+	h.m.sealer = rs
+
+	h.sealer.commit2 = func(ctx context.Context, sector abi.SectorID) (storage.Proof, error) {
+		rs.assign("gpu-worker")
+		return storage.Proof{1}, nil
+	}
+	h.sealer.finalize = func(sector abi.SectorID) error {
+		rs.assign("finalize-worker")
+		return nil
+	}
+
+	h.start(h.committingSector(1))
+	si := h.waitState(1, Proving)
+	require.Equal(t, "finalize-worker", si.Worker)
+
+	var committed bool
+	for _, l := range si.Log {
+		if l.Kind == "event;sealing.SectorCommitted" {
+			require.Contains(t, l.Message, `"Worker":"gpu-worker"`)
+			committed = true
+		}
+	}
+	require.True(t, committed)
+
+	rs.assign("other-worker")
+	w, err := h.m.SectorWorker(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, "other-worker", w)
+
+	// falls back to the last recorded worker
+	rs.assign("")
+	w, err = h.m.SectorWorker(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, "finalize-worker", w)
+}
+
+func TestSectorWorkerNotReported(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	h.start(h.committingSector(1))
+	si := h.waitState(1, Proving)
+	require.Equal(t, "", si.Worker)
+
+	w, err := h.m.SectorWorker(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, "", w)
+}